@@ -15,17 +15,22 @@ import (
 	"watcher/internal/ingestion"
 	"watcher/internal/metrics"
 	"watcher/internal/persistence"
+	"watcher/internal/supervisor"
 	"watcher/pkg/chain/base"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/sync/errgroup"
 )
 
 func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "configs/config.yaml", "Path to configuration file")
+	var opts runOptions
+	flag.StringVar(&opts.recordDir, "record", "", "Record the ingestion stream to this directory")
+	flag.Int64Var(&opts.recordMaxMB, "record-max-mb", 100, "Rotate recording files at this size in MiB")
+	flag.StringVar(&opts.replayPath, "replay", "", "Replay a recording file or directory instead of connecting to the chain")
+	flag.BoolVar(&opts.replayRealtime, "replay-realtime", false, "Replay at the original speed instead of as fast as possible")
 	flag.Parse()
 
 	// Load .env file
@@ -59,16 +64,46 @@ func main() {
 	}()
 
 	// Initialize components
-	if err := run(ctx, cfg); err != nil && err != context.Canceled {
+	runFn := run
+	if opts.replayPath != "" {
+		runFn = runReplay
+	}
+	if err := runFn(ctx, cfg, opts); err != nil && err != context.Canceled {
 		log.Fatal().Err(err).Msg("Application error")
 	}
 
 	log.Info().Msg("Watcher shutdown complete")
 }
 
-func run(ctx context.Context, cfg *config.Config) error {
+// runOptions holds command line options for recording and replay.
+type runOptions struct {
+	recordDir   string
+	recordMaxMB int64
+
+	replayPath     string
+	replayRealtime bool
+}
+
+// Opportunity and reserve history writer settings
+const (
+	opportunityBufferSize    = 1000
+	opportunityBatchSize     = 100
+	opportunityFlushInterval = time.Second
+
+	reserveHistoryBufferSize    = 10000
+	reserveHistoryBatchSize     = 500
+	reserveHistoryFlushInterval = time.Second
+)
+
+func run(ctx context.Context, cfg *config.Config, opts runOptions) error {
 	// Initialize metrics
 	m := metrics.New()
+
+	// Long-running components are restarted individually when they fail;
+	// /health reports each component's state.
+	sup := supervisor.New(supervisor.DefaultPolicy(), m)
+	m.SetHealthCheck(sup.HealthReport)
+
 	if cfg.Metrics.Enabled {
 		if err := m.StartServer(cfg.Metrics.Port, cfg.Metrics.Path); err != nil {
 			return err
@@ -94,19 +129,72 @@ func run(ctx context.Context, cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	rpcClient.SetRateLimit(cfg.Chain.RPCRateLimit, cfg.Chain.RPCBurst)
+	rpcClient.SetMulticallHook(m.SetMulticallLimit)
 	log.Info().Msg("RPC client connected")
 
 	// Initialize graph manager
 	graphManager := graph.NewManager(m)
 	defer graphManager.Close()
 
+	// Every reserve update applied to the graph is stored for charts and
+	// reconstructing past graph states
+	var reserveHistory *persistence.ReserveHistoryWriter
+	if cfg.Persistence.ReserveHistory.Enabled {
+		reserveHistory = persistence.NewReserveHistoryWriter(store, reserveHistoryBufferSize, reserveHistoryBatchSize, reserveHistoryFlushInterval)
+		graphManager.SetUpdatesAppliedHook(func(updates []graph.ReserveUpdate) {
+			for _, u := range updates {
+				reserveHistory.Write(persistence.ReserveRecord{
+					Pool:      u.PoolAddress,
+					Block:     u.BlockNumber,
+					LogIndex:  u.LogIndex,
+					Reserve0:  u.Reserve0.String(),
+					Reserve1:  u.Reserve1.String(),
+					BlockTime: u.BlockTime,
+				})
+			}
+		})
+		graphManager.SetUpdatesRevertedHook(func(updates []graph.ReserveUpdate) {
+			for _, u := range updates {
+				reserveHistory.Write(persistence.ReserveRecord{
+					Pool:     u.PoolAddress,
+					Block:    u.BlockNumber,
+					LogIndex: u.LogIndex,
+					Removed:  true,
+				})
+			}
+		})
+	}
+
 	// Initialize ingestion service
 	ingestionSvc := ingestion.NewService(
-		cfg.Chain.WSURL,
+		cfg.Chain.WebSocketURLs(),
 		cfg.Contracts.AerodromeFactory,
 		graphManager,
 		m,
 	)
+	ingestionSvc.SetMode(ingestion.Mode(cfg.Ingestion.Mode))
+	if cfg.Ingestion.Mode == string(ingestion.ModeHTTP) || cfg.Ingestion.HTTPFallback {
+		ingestionSvc.SetPoller(ingestion.NewPoller(rpcClient), cfg.Ingestion.PollInterval)
+	}
+
+	// Record from before bootstrap so the recording starts with the initial pools
+	if opts.recordDir != "" {
+		recorder, err := ingestion.NewRecorder(opts.recordDir, opts.recordMaxMB<<20)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		graphManager.SetPoolsAddedHook(recorder.RecordPools)
+		graphManager.SetPoolsRemovedHook(recorder.RecordRemovedPools)
+		ingestionSvc.SetRecorder(recorder)
+	}
+
+	// Saved graph state is only loaded if it is also kept up to date
+	var warmRestartMaxGap uint64
+	if cfg.Persistence.GraphState.Enabled {
+		warmRestartMaxGap = cfg.Persistence.GraphState.MaxGapBlocks
+	}
 
 	// Initialize curator
 	curatorSvc := curator.NewCurator(
@@ -115,7 +203,23 @@ func run(ctx context.Context, cfg *config.Config) error {
 			TopPoolsCount:        cfg.Curator.TopPoolsCount,
 			ReevaluationInterval: cfg.Curator.ReevaluationInterval,
 			BootstrapBatchSize:   cfg.Curator.BootstrapBatchSize,
+			BootstrapWorkers:     cfg.Curator.BootstrapWorkers,
 			StartTokens:          cfg.Detector.StartTokens, // Ensure pools with start tokens are always included
+			MaxPathLength:        cfg.Detector.MaxPathLength,
+			Scoring: curator.ScoringWeights{
+				TVL:        cfg.Curator.Scoring.TVLWeight,
+				Syncs:      cfg.Curator.Scoring.SyncWeight,
+				Volatility: cfg.Curator.Scoring.VolatilityWeight,
+				Cycles:     cfg.Curator.Scoring.CycleWeight,
+			},
+			Lists: curator.CurationLists{
+				AllowPools:  cfg.Curator.AllowPools,
+				DenyPools:   cfg.Curator.DenyPools,
+				AllowTokens: cfg.Curator.AllowTokens,
+				DenyTokens:  cfg.Curator.DenyTokens,
+			},
+			WarmRestartMaxGap: warmRestartMaxGap,
+			RecordReserves:    cfg.Persistence.ReserveHistory.Enabled,
 		},
 		rpcClient,
 		store,
@@ -131,6 +235,7 @@ func run(ctx context.Context, cfg *config.Config) error {
 			MaxPathLength:   cfg.Detector.MaxPathLength,
 			NumWorkers:      cfg.Detector.NumWorkers,
 			StartTokens:     cfg.Detector.StartTokens,
+			InventoryTokens: cfg.Detector.InventoryTokens,
 		},
 		graphManager.SnapshotCh(),
 		m,
@@ -154,12 +259,12 @@ func run(ctx context.Context, cfg *config.Config) error {
 
 	// Set up reconciliation to fill the gap between bootstrap and WebSocket streaming.
 	// This ensures the graph is up-to-date with any events that occurred during bootstrap.
-	bootstrapStartBlock := curatorSvc.BootstrapStartBlock()
-	if bootstrapStartBlock > 0 {
+	bootstrapBlock := curatorSvc.BootstrapBlock()
+	if bootstrapBlock > 0 {
 		reconciler := ingestion.NewReconciler(rpcClient, graphManager)
-		ingestionSvc.SetReconciler(reconciler, bootstrapStartBlock)
+		ingestionSvc.SetReconciler(reconciler, bootstrapBlock)
 		log.Info().
-			Uint64("bootstrap_start_block", bootstrapStartBlock).
+			Uint64("bootstrap_block", bootstrapBlock).
 			Msg("Reconciliation configured - will run after WebSocket subscription")
 	}
 
@@ -178,34 +283,105 @@ func run(ctx context.Context, cfg *config.Config) error {
 		log.Info().Msg("No arbitrage opportunities found in initial scan")
 	}
 
-	// Start all services
-	g, gCtx := errgroup.WithContext(ctx)
-
-	// Start ingestion service
-	g.Go(func() error {
+	// Start all services under the supervisor
+	sup.Add("ingestion", func(ctx context.Context) error {
 		log.Info().Msg("Starting ingestion service...")
-		return ingestionSvc.Run(gCtx)
+		return ingestionSvc.Run(ctx)
 	})
 
-	// Start detector
-	g.Go(func() error {
+	sup.Add("detector", func(ctx context.Context) error {
 		log.Info().Msg("Starting detector...")
-		return detectorSvc.Run(gCtx)
+		return detectorSvc.Run(ctx)
 	})
 
-	// Start curator (background re-evaluation)
-	g.Go(func() error {
+	// Curator (background re-evaluation)
+	sup.Add("curator", func(ctx context.Context) error {
 		log.Info().Msg("Starting curator...")
-		return curatorSvc.Run(gCtx)
+		return curatorSvc.Run(ctx)
+	})
+
+	// Opportunities are stored in the background so detection never waits on SQLite
+	var history *persistence.OpportunityWriter
+	if cfg.Persistence.RecordOpportunities {
+		history = persistence.NewOpportunityWriter(store, opportunityBufferSize, opportunityBatchSize, opportunityFlushInterval)
+		sup.Add("opportunity-writer", history.Run)
+	}
+
+	sup.Add("opportunity-logger", func(ctx context.Context) error {
+		return logOpportunities(ctx, detectorSvc.Opportunities(), m, ingestionSvc.Activity(), history)
+	})
+
+	if reserveHistory != nil {
+		sup.Add("reserve-history", reserveHistory.Run)
+		sup.Add("reserve-history-pruner", func(ctx context.Context) error {
+			return pruneReserveHistory(ctx, store, cfg.Persistence.ReserveHistory)
+		})
+	}
+
+	// Untracked pools only get activity from the factory-wide scan
+	if cfg.Curator.ActivityScanInterval > 0 {
+		scanner := curator.NewActivityScanner(rpcClient, store, ingestionSvc, cfg.Curator.ActivityScanInterval)
+		sup.Add("activity-scanner", scanner.Run)
+	}
+
+	// The graph is saved periodically and on shutdown for warm restarts
+	if cfg.Persistence.GraphState.Enabled {
+		saver := curator.NewStateSaver(store, graphManager, ingestionSvc, cfg.Persistence.GraphState.SaveInterval)
+		sup.Add("graph-state", saver.Run)
+	}
+
+	// Runs until shutdown or an unrecoverable configuration error
+	if err := sup.Run(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+
+	return nil
+}
+
+// runReplay replays a recording through ingestion and detection, without
+// connecting to the chain, and returns once every record has been processed.
+func runReplay(ctx context.Context, cfg *config.Config, opts runOptions) error {
+	m := metrics.New()
+	sup := supervisor.New(supervisor.DefaultPolicy(), m)
+
+	graphManager := graph.NewManager(m)
+	ingestionSvc := ingestion.NewService(nil, cfg.Contracts.AerodromeFactory, graphManager, m)
+
+	detectorSvc := detector.NewDetector(
+		detector.Config{
+			MinProfitFactor: cfg.Detector.MinProfitFactor,
+			MaxPathLength:   cfg.Detector.MaxPathLength,
+			NumWorkers:      cfg.Detector.NumWorkers,
+			StartTokens:     cfg.Detector.StartTokens,
+			InventoryTokens: cfg.Detector.InventoryTokens,
+		},
+		graphManager.SnapshotCh(),
+		m,
+	)
+
+	log.Info().
+		Str("path", opts.replayPath).
+		Bool("realtime", opts.replayRealtime).
+		Msg("Starting replay")
+
+	// Closing the graph manager lets the detector and logger drain and finish
+	sup.Add("replay", func(ctx context.Context) error {
+		defer graphManager.Close()
+		if err := ingestionSvc.Replay(ctx, opts.replayPath, opts.replayRealtime); err != nil {
+			return supervisor.Permanent(err)
+		}
+		return nil
+	})
+
+	sup.Add("detector", func(ctx context.Context) error {
+		return detectorSvc.Run(ctx)
 	})
 
-	// Start opportunity logger
-	g.Go(func() error {
-		return logOpportunities(gCtx, detectorSvc.Opportunities(), m)
+	sup.Add("opportunity-logger", func(ctx context.Context) error {
+		return logOpportunities(ctx, detectorSvc.Opportunities(), m, ingestionSvc.Activity(), nil)
 	})
 
-	// Wait for all goroutines
-	if err := g.Wait(); err != nil && err != context.Canceled {
+	if err := sup.Run(ctx); err != nil && err != context.Canceled {
 		return err
 	}
 
@@ -231,7 +407,10 @@ func setupLogging(cfg config.LoggingConfig) {
 	}
 }
 
-func logOpportunities(ctx context.Context, ch <-chan *detector.Opportunity, m *metrics.Metrics) error {
+// logOpportunities logs detected opportunities and counts each one against its
+// pools' activity, which weights pool curation. Each opportunity is also queued
+// on history, if set.
+func logOpportunities(ctx context.Context, ch <-chan *detector.Opportunity, m *metrics.Metrics, activity *ingestion.ActivityTracker, history *persistence.OpportunityWriter) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -257,6 +436,8 @@ func logOpportunities(ctx context.Context, ch <-chan *detector.Opportunity, m *m
 				Float64("profit_factor", opp.ProfitFactor).
 				Str("max_input", opp.MaxInputWei.String()).
 				Str("estimated_profit", opp.EstimatedProfitWei.String()).
+				Str("capital_mode", opp.CapitalMode.String()).
+				Str("capital_required", opp.CapitalRequiredWei.String()).
 				Uint64("block", opp.DetectedAtBlock).
 				Dur("detection_latency", opp.DetectionLatency).
 				Msg("ARBITRAGE OPPORTUNITY DETECTED")
@@ -265,6 +446,63 @@ func logOpportunities(ctx context.Context, ch <-chan *detector.Opportunity, m *m
 			if m != nil {
 				m.RecordPipelineLatency(opp.DetectionLatency)
 			}
+			if activity != nil {
+				activity.RecordCycle(opp.Pools, time.Now())
+			}
+			if history != nil {
+				history.Write(opportunityRecord(opp, time.Now()))
+			}
+		}
+	}
+}
+
+// pruneReserveHistory applies the reserve history retention and downsampling
+// every prune interval until ctx is done.
+func pruneReserveHistory(ctx context.Context, store *persistence.Store, cfg config.ReserveHistoryConfig) error {
+	ticker := time.NewTicker(cfg.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			now := time.Now()
+			deleted, err := store.PruneReserveHistory(ctx, now.Add(-cfg.Retention), now.Add(-cfg.DownsampleAfter), cfg.DownsampleBlocks)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to prune reserve history")
+				continue
+			}
+			log.Debug().Int64("deleted", deleted).Msg("Pruned reserve history")
+		}
+	}
+}
+
+// opportunityRecord converts a detected opportunity for the opportunity history.
+func opportunityRecord(opp *detector.Opportunity, detectedAt time.Time) persistence.OpportunityRecord {
+	record := persistence.OpportunityRecord{
+		Block:        opp.DetectedAtBlock,
+		DetectedAt:   detectedAt,
+		InputWei:     opp.MaxInputWei.String(),
+		ProfitWei:    opp.EstimatedProfitWei.String(),
+		ProfitFactor: opp.ProfitFactor,
+		Latency:      opp.DetectionLatency,
+		CapitalMode:  opp.CapitalMode.String(),
+		Hops:         make([]persistence.OpportunityHop, len(opp.Pools)),
+	}
+	for i, pool := range opp.Pools {
+		hop := persistence.OpportunityHop{
+			Pool:      pool,
+			TokenIn:   opp.Path[i].Address,
+			TokenOut:  opp.Path[i+1].Address,
+			AmountIn:  "0",
+			AmountOut: "0",
+		}
+		if i+1 < len(opp.HopAmountsWei) {
+			hop.AmountIn = opp.HopAmountsWei[i].String()
+			hop.AmountOut = opp.HopAmountsWei[i+1].String()
 		}
+		record.Hops[i] = hop
 	}
+	return record
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"watcher/internal/config"
+	"watcher/internal/mocknode"
+	"watcher/internal/persistence"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	e2eFactory = common.HexToAddress("0x420DD381b31aEf6683db6B902084cB0FFECe40Da")
+	e2eWETH    = common.HexToAddress("0x4200000000000000000000000000000000000006")
+	e2eUSDC    = common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+	e2eDAI     = common.HexToAddress("0x50c5725949A6F0c72E6C4a641F24049A917DB0Cb")
+
+	e2ePoolWETHUSDC = common.HexToAddress("0x0000000000000000000000000000000000000a01")
+	e2ePoolUSDCDAI  = common.HexToAddress("0x0000000000000000000000000000000000000a02")
+	e2ePoolDAIWETH  = common.HexToAddress("0x0000000000000000000000000000000000000a03")
+)
+
+// units returns amount * 10^decimals.
+func units(amount int64, decimals int) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+}
+
+// newE2ENode starts a node with a balanced WETH/USDC/DAI triangle.
+func newE2ENode(t *testing.T) *mocknode.Node {
+	node := mocknode.New(e2eFactory)
+	t.Cleanup(node.Close)
+
+	node.AddToken(mocknode.Token{Address: e2eWETH, Symbol: "WETH", Decimals: 18})
+	node.AddToken(mocknode.Token{Address: e2eUSDC, Symbol: "USDC", Decimals: 6})
+	node.AddToken(mocknode.Token{Address: e2eDAI, Symbol: "DAI", Decimals: 18})
+
+	node.AddPool(mocknode.Pool{
+		Address: e2ePoolWETHUSDC, Token0: e2eWETH, Token1: e2eUSDC,
+		Reserve0: units(1000, 18), Reserve1: units(3_000_000, 6),
+	})
+	node.AddPool(mocknode.Pool{
+		Address: e2ePoolUSDCDAI, Token0: e2eUSDC, Token1: e2eDAI,
+		Reserve0: units(3_000_000, 6), Reserve1: units(3_000_000, 18),
+	})
+	node.AddPool(mocknode.Pool{
+		Address: e2ePoolDAIWETH, Token0: e2eDAI, Token1: e2eWETH,
+		Reserve0: units(3_000_000, 18), Reserve1: units(1000, 18),
+	})
+	// Stable pools are skipped by bootstrap
+	node.AddPool(mocknode.Pool{
+		Address: common.HexToAddress("0x0000000000000000000000000000000000000a04"), Token0: e2eUSDC, Token1: e2eDAI,
+		Reserve0: units(1_000_000, 6), Reserve1: units(1_000_000, 18), Stable: true,
+	})
+
+	return node
+}
+
+func e2eConfig(t *testing.T, node *mocknode.Node) *config.Config {
+	return &config.Config{
+		Chain: config.ChainConfig{
+			RPCURL:       node.URL(),
+			WSURL:        node.WSURL(),
+			ChainID:      mocknode.DefaultChainID,
+			RPCRateLimit: 100,
+			RPCBurst:     100,
+		},
+		Contracts: config.ContractsConfig{AerodromeFactory: e2eFactory.Hex()},
+		Ingestion: config.IngestionConfig{Mode: "websocket", PollInterval: time.Second},
+		Curator: config.CuratorConfig{
+			TopPoolsCount:        10,
+			ReevaluationInterval: time.Hour,
+			BootstrapBatchSize:   100,
+			BootstrapWorkers:     2,
+		},
+		Detector: config.DetectorConfig{
+			MinProfitFactor: 1.001,
+			MaxPathLength:   4,
+			NumWorkers:      2,
+			StartTokens:     []string{e2eWETH.Hex()},
+		},
+		Persistence: config.PersistenceConfig{
+			SQLitePath:          filepath.Join(t.TempDir(), "watcher.db"),
+			RecordOpportunities: true,
+			ReserveHistory: config.ReserveHistoryConfig{
+				Enabled:          true,
+				Retention:        time.Hour,
+				DownsampleAfter:  time.Hour,
+				DownsampleBlocks: 1,
+				PruneInterval:    time.Hour,
+			},
+			GraphState: config.GraphStateConfig{
+				Enabled:      true,
+				SaveInterval: time.Hour,
+				MaxGapBlocks: 100,
+			},
+		},
+		Logging: config.LoggingConfig{Level: "info", Format: "json"},
+	}
+}
+
+// gatheredValue sums a counter or gauge from the default registry across label sets
+// that include every given label.
+func gatheredValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gathering metrics: %v", err)
+	}
+
+	var total float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for key, value := range labels {
+				found := false
+				for _, pair := range metric.GetLabel() {
+					if pair.GetName() == key && pair.GetValue() == value {
+						found = true
+					}
+				}
+				if !found {
+					continue metrics
+				}
+			}
+			if c := metric.GetCounter(); c != nil {
+				total += c.GetValue()
+			} else if g := metric.GetGauge(); g != nil {
+				total += g.GetValue()
+			}
+		}
+	}
+	return total
+}
+
+// waitFor polls cond until it holds or the timeout expires.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRunEndToEnd runs the full pipeline against a mock node: bootstrap over
+// Multicall3, WebSocket subscription, backfill, a streamed Sync that opens an
+// arbitrage, and detection of it.
+func TestRunEndToEnd(t *testing.T) {
+	node := newE2ENode(t)
+	cfg := e2eConfig(t, node)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, runOptions{}) }()
+
+	// Bootstrap is done once ingestion has subscribed
+	if err := node.WaitForSubscriptions(ctx, mocknode.SubscriptionLogs, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.WaitForSubscriptions(ctx, mocknode.SubscriptionNewHeads, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := gatheredValue(t, "arb_pools_tracked", nil); got != 3 {
+		t.Errorf("Expected 3 volatile pools tracked, got %v", got)
+	}
+	// Bootstrap read the state at the head block, so reconciliation finds
+	// nothing to backfill once it has checked the head
+	waitFor(t, 5*time.Second, "reconciliation", func() bool { return node.Calls("eth_blockNumber") > 0 })
+	if calls := node.Calls("eth_getLogs"); calls != 0 {
+		t.Errorf("Expected no backfill after bootstrap at the head block, got %d eth_getLogs calls", calls)
+	}
+
+	if got := gatheredValue(t, "arb_profitable_opportunities_total", nil); got != 0 {
+		t.Errorf("Expected no opportunities in the balanced triangle, got %v", got)
+	}
+
+	// WETH gets 10% cheaper in the DAI pool: WETH -> USDC -> DAI -> WETH is profitable
+	if err := node.SetReserves(e2ePoolDAIWETH, units(3_000_000, 18), units(1100, 18)); err != nil {
+		t.Fatal(err)
+	}
+	node.MineBlock()
+
+	waitFor(t, 10*time.Second, "the streamed Sync to be applied", func() bool {
+		return gatheredValue(t, "arb_events_received_total", map[string]string{"type": "sync"}) >= 1
+	})
+	waitFor(t, 10*time.Second, "the opportunity to be detected", func() bool {
+		return gatheredValue(t, "arb_profitable_opportunities_total", nil) >= 1
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run did not return after cancellation")
+	}
+
+	// The opportunity was stored before shutdown
+	store, err := persistence.NewStore(cfg.Persistence.SQLitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	history, err := store.QueryOpportunities(context.Background(), persistence.OpportunityFilter{Pool: e2ePoolDAIWETH.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) == 0 {
+		t.Fatal("Expected the opportunity in the opportunity history")
+	}
+	if opp := history[0]; len(opp.Hops) != 3 || opp.StartToken() != strings.ToLower(e2eWETH.Hex()) {
+		t.Errorf("Expected a 3-hop cycle from WETH, got %+v", opp)
+	}
+
+	// So were the bootstrap reserves and those the streamed Sync set
+	reserves, err := store.GetReservesAtBlock(context.Background(), []string{e2ePoolDAIWETH.Hex(), e2ePoolWETHUSDC.Hex()}, history[0].Block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reserves[strings.ToLower(e2ePoolDAIWETH.Hex())]; got.Reserve1 != units(1100, 18).String() {
+		t.Errorf("Expected the DAI/WETH reserves from the Sync in the reserve history, got %+v", got)
+	}
+	if got := reserves[strings.ToLower(e2ePoolWETHUSDC.Hex())]; got.Reserve1 != units(3_000_000, 6).String() {
+		t.Errorf("Expected the WETH/USDC reserves from bootstrap in the reserve history, got %+v", got)
+	}
+
+	// The graph was saved on shutdown, with the streamed Sync applied
+	state, err := store.LoadGraphState(context.Background())
+	if err != nil || state == nil {
+		t.Fatalf("Expected saved graph state, got %+v (%v)", state, err)
+	}
+	if len(state.Pools) != 3 || len(state.Tokens) != 3 {
+		t.Errorf("Expected 3 pools and 3 tokens in the saved graph, got %d and %d", len(state.Pools), len(state.Tokens))
+	}
+	for _, p := range state.Pools {
+		if p.Address == strings.ToLower(e2ePoolDAIWETH.Hex()) &&
+			(p.Reserve1 != units(1100, 18).String() || p.Block != node.Head()) {
+			t.Errorf("Expected the DAI/WETH reserves from the Sync at block %d in the saved graph, got %+v", node.Head(), p)
+		}
+	}
+	if state.Block == 0 || state.Block > node.Head() {
+		t.Errorf("Expected the saved block to be at most the head %d, got %d", node.Head(), state.Block)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,6 +14,7 @@ import (
 type Config struct {
 	Chain       ChainConfig       `yaml:"chain"`
 	Contracts   ContractsConfig   `yaml:"contracts"`
+	Ingestion   IngestionConfig   `yaml:"ingestion"`
 	Curator     CuratorConfig     `yaml:"curator"`
 	Detector    DetectorConfig    `yaml:"detector"`
 	Persistence PersistenceConfig `yaml:"persistence"`
@@ -25,6 +27,32 @@ type ChainConfig struct {
 	RPCURL  string `yaml:"rpc_url"`
 	WSURL   string `yaml:"ws_url"`
 	ChainID int64  `yaml:"chain_id"`
+
+	// WSURLs lists additional WebSocket providers. Events are taken from
+	// whichever provider delivers them first.
+	WSURLs []string `yaml:"ws_urls"`
+
+	// RPC request budget shared by every component: requests per second on
+	// average, with bursts of up to RPCBurst requests.
+	RPCRateLimit float64 `yaml:"rpc_rate_limit"`
+	RPCBurst     int     `yaml:"rpc_burst"`
+}
+
+// WebSocketURLs returns every configured WebSocket endpoint, primary first, without duplicates.
+func (c ChainConfig) WebSocketURLs() []string {
+	urls := make([]string, 0, len(c.WSURLs)+1)
+	seen := make(map[string]bool, len(c.WSURLs)+1)
+
+	for _, u := range append([]string{c.WSURL}, c.WSURLs...) {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+
+	return urls
 }
 
 // ContractsConfig holds smart contract addresses.
@@ -32,11 +60,46 @@ type ContractsConfig struct {
 	AerodromeFactory string `yaml:"aerodrome_factory"`
 }
 
+// IngestionConfig holds event ingestion settings.
+type IngestionConfig struct {
+	// Mode is "websocket" (eth_subscribe) or "http" (eth_getLogs polling).
+	Mode         string        `yaml:"mode"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+
+	// HTTPFallback switches to polling once every WebSocket provider has failed.
+	HTTPFallback bool `yaml:"http_fallback"`
+}
+
 // CuratorConfig holds pool curation settings.
 type CuratorConfig struct {
 	TopPoolsCount        int           `yaml:"top_pools_count"`
 	ReevaluationInterval time.Duration `yaml:"reevaluation_interval"`
 	BootstrapBatchSize   int           `yaml:"bootstrap_batch_size"`
+	BootstrapWorkers     int           `yaml:"bootstrap_workers"`
+
+	// ActivityScanInterval is how often Swap and Sync logs of untracked
+	// factory pools are fetched for scoring; 0 disables the scan.
+	ActivityScanInterval time.Duration `yaml:"activity_scan_interval"`
+
+	// Scoring weights the ranking used to select pools.
+	Scoring ScoringConfig `yaml:"scoring"`
+
+	// Allow and deny lists of pool and token addresses. Allowed pools, and
+	// pools of allowed tokens, are always tracked; denied ones never are.
+	// Denials win over allowances.
+	AllowPools  []string `yaml:"allow_pools"`
+	DenyPools   []string `yaml:"deny_pools"`
+	AllowTokens []string `yaml:"allow_tokens"`
+	DenyTokens  []string `yaml:"deny_tokens"`
+}
+
+// ScoringConfig weights the components of a pool's curation score. Each
+// component is normalized to [0, 1] across the ranked pools.
+type ScoringConfig struct {
+	TVLWeight        float64 `yaml:"tvl_weight"`
+	SyncWeight       float64 `yaml:"sync_weight"`       // Sync events in the activity window
+	VolatilityWeight float64 `yaml:"volatility_weight"` // Realized volatility of the pool price
+	CycleWeight      float64 `yaml:"cycle_weight"`      // Appearances in detected cycles
 }
 
 // DetectorConfig holds arbitrage detection settings.
@@ -45,11 +108,39 @@ type DetectorConfig struct {
 	MaxPathLength   int      `yaml:"max_path_length"`
 	NumWorkers      int      `yaml:"num_workers"`
 	StartTokens     []string `yaml:"start_tokens"`
+
+	// InventoryTokens lists the start tokens held in inventory. Opportunities
+	// starting from other tokens must be fundable by a flash swap.
+	// Leave unset to treat every start token as held.
+	InventoryTokens []string `yaml:"inventory_tokens"`
 }
 
 // PersistenceConfig holds database settings.
 type PersistenceConfig struct {
 	SQLitePath string `yaml:"sqlite_path"`
+
+	// RecordOpportunities stores every detected opportunity in SQLite
+	RecordOpportunities bool `yaml:"record_opportunities"`
+
+	ReserveHistory ReserveHistoryConfig `yaml:"reserve_history"`
+
+	GraphState GraphStateConfig `yaml:"graph_state"`
+}
+
+// ReserveHistoryConfig holds settings for the per-pool reserve time series.
+type ReserveHistoryConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Retention        time.Duration `yaml:"retention"`         // Updates older than this are deleted
+	DownsampleAfter  time.Duration `yaml:"downsample_after"`  // Updates older than this are downsampled
+	DownsampleBlocks uint64        `yaml:"downsample_blocks"` // Keep the last update per pool per this many blocks
+	PruneInterval    time.Duration `yaml:"prune_interval"`
+}
+
+// GraphStateConfig holds settings for saving the graph for warm restarts.
+type GraphStateConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	SaveInterval time.Duration `yaml:"save_interval"`  // Also saved on graceful shutdown
+	MaxGapBlocks uint64        `yaml:"max_gap_blocks"` // Older saved state is replaced by a full bootstrap
 }
 
 // MetricsConfig holds Prometheus metrics settings.
@@ -100,15 +191,30 @@ func Load(path string) (*Config, error) {
 // setDefaults sets default values for all configuration options.
 func (c *Config) setDefaults() {
 	c.Chain = ChainConfig{
-		ChainID: 8453, // Base mainnet
+		ChainID:      8453, // Base mainnet
+		RPCRateLimit: 20,
+		RPCBurst:     20,
 	}
 	c.Contracts = ContractsConfig{
 		AerodromeFactory: "0x420DD381b31aEf6683db6B902084cB0FFECe40Da",
 	}
+	c.Ingestion = IngestionConfig{
+		Mode:         "websocket",
+		PollInterval: 2 * time.Second, // Base block time
+		HTTPFallback: true,
+	}
 	c.Curator = CuratorConfig{
 		TopPoolsCount:        500,
 		ReevaluationInterval: time.Hour,
 		BootstrapBatchSize:   100,
+		BootstrapWorkers:     4,
+		ActivityScanInterval: time.Minute,
+		Scoring: ScoringConfig{
+			TVLWeight:        0.5,
+			SyncWeight:       0.2,
+			VolatilityWeight: 0.1,
+			CycleWeight:      0.2,
+		},
 	}
 	c.Detector = DetectorConfig{
 		MinProfitFactor: 1.001,
@@ -121,7 +227,20 @@ func (c *Config) setDefaults() {
 		},
 	}
 	c.Persistence = PersistenceConfig{
-		SQLitePath: "./data/watcher.db",
+		SQLitePath:          "./data/watcher.db",
+		RecordOpportunities: true,
+		ReserveHistory: ReserveHistoryConfig{
+			Enabled:          true,
+			Retention:        7 * 24 * time.Hour,
+			DownsampleAfter:  24 * time.Hour,
+			DownsampleBlocks: 150, // 5 minutes of Base blocks
+			PruneInterval:    time.Hour,
+		},
+		GraphState: GraphStateConfig{
+			Enabled:      true,
+			SaveInterval: time.Minute,
+			MaxGapBlocks: 1800, // 1 hour of Base blocks
+		},
 	}
 	c.Metrics = MetricsConfig{
 		Enabled: true,
@@ -143,6 +262,14 @@ func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("BASE_WS_URL"); v != "" {
 		c.Chain.WSURL = v
 	}
+	if v := os.Getenv("BASE_WS_URLS"); v != "" {
+		c.Chain.WSURLs = strings.Split(v, ",")
+	}
+
+	// Ingestion config
+	if v := os.Getenv("INGESTION_MODE"); v != "" {
+		c.Ingestion.Mode = strings.ToLower(v)
+	}
 
 	// Curator config
 	if v := os.Getenv("CURATOR_TOP_POOLS_COUNT"); v != "" {
@@ -190,8 +317,20 @@ func (c *Config) validate() error {
 	if c.Chain.RPCURL == "" {
 		return fmt.Errorf("chain.rpc_url is required (set BASE_RPC_URL env var)")
 	}
-	if c.Chain.WSURL == "" {
-		return fmt.Errorf("chain.ws_url is required (set BASE_WS_URL env var)")
+	if c.Chain.RPCRateLimit <= 0 {
+		return fmt.Errorf("chain.rpc_rate_limit must be positive")
+	}
+	if c.Chain.RPCBurst < 1 {
+		return fmt.Errorf("chain.rpc_burst must be at least 1")
+	}
+	if c.Ingestion.Mode != "websocket" && c.Ingestion.Mode != "http" {
+		return fmt.Errorf("ingestion.mode must be \"websocket\" or \"http\"")
+	}
+	if c.Ingestion.Mode == "websocket" && len(c.Chain.WebSocketURLs()) == 0 {
+		return fmt.Errorf("chain.ws_url is required (set BASE_WS_URL or BASE_WS_URLS env var)")
+	}
+	if c.Ingestion.PollInterval <= 0 {
+		return fmt.Errorf("ingestion.poll_interval must be positive")
 	}
 	if c.Contracts.AerodromeFactory == "" {
 		return fmt.Errorf("contracts.aerodrome_factory is required")
@@ -199,6 +338,37 @@ func (c *Config) validate() error {
 	if c.Curator.TopPoolsCount <= 0 {
 		return fmt.Errorf("curator.top_pools_count must be positive")
 	}
+	if c.Curator.BootstrapBatchSize <= 0 {
+		return fmt.Errorf("curator.bootstrap_batch_size must be positive")
+	}
+	if c.Curator.BootstrapWorkers <= 0 {
+		return fmt.Errorf("curator.bootstrap_workers must be positive")
+	}
+	if c.Curator.ActivityScanInterval < 0 {
+		return fmt.Errorf("curator.activity_scan_interval must not be negative")
+	}
+	scoring := c.Curator.Scoring
+	if scoring.TVLWeight < 0 || scoring.SyncWeight < 0 || scoring.VolatilityWeight < 0 || scoring.CycleWeight < 0 {
+		return fmt.Errorf("curator.scoring weights must not be negative")
+	}
+	if scoring.TVLWeight+scoring.SyncWeight+scoring.VolatilityWeight+scoring.CycleWeight == 0 {
+		return fmt.Errorf("curator.scoring needs at least one positive weight")
+	}
+	for _, list := range []struct {
+		name      string
+		addresses []string
+	}{
+		{"allow_pools", c.Curator.AllowPools},
+		{"deny_pools", c.Curator.DenyPools},
+		{"allow_tokens", c.Curator.AllowTokens},
+		{"deny_tokens", c.Curator.DenyTokens},
+	} {
+		for _, address := range list.addresses {
+			if !common.IsHexAddress(address) {
+				return fmt.Errorf("curator.%s has an invalid address %q", list.name, address)
+			}
+		}
+	}
 	if c.Detector.MinProfitFactor <= 1.0 {
 		return fmt.Errorf("detector.min_profit_factor must be greater than 1.0")
 	}
@@ -211,6 +381,28 @@ func (c *Config) validate() error {
 	if len(c.Detector.StartTokens) == 0 {
 		return fmt.Errorf("detector.start_tokens must have at least one token")
 	}
+	if history := c.Persistence.ReserveHistory; history.Enabled {
+		if history.Retention <= 0 {
+			return fmt.Errorf("persistence.reserve_history.retention must be positive")
+		}
+		if history.DownsampleAfter <= 0 || history.DownsampleAfter > history.Retention {
+			return fmt.Errorf("persistence.reserve_history.downsample_after must be positive and at most the retention")
+		}
+		if history.DownsampleBlocks == 0 {
+			return fmt.Errorf("persistence.reserve_history.downsample_blocks must be positive")
+		}
+		if history.PruneInterval <= 0 {
+			return fmt.Errorf("persistence.reserve_history.prune_interval must be positive")
+		}
+	}
+	if state := c.Persistence.GraphState; state.Enabled {
+		if state.SaveInterval <= 0 {
+			return fmt.Errorf("persistence.graph_state.save_interval must be positive")
+		}
+		if state.MaxGapBlocks == 0 {
+			return fmt.Errorf("persistence.graph_state.max_gap_blocks must be positive")
+		}
+	}
 	if c.Metrics.Port <= 0 || c.Metrics.Port > 65535 {
 		return fmt.Errorf("metrics.port must be a valid port number")
 	}
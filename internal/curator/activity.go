@@ -0,0 +1,176 @@
+package curator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"watcher/internal/ingestion"
+	"watcher/internal/persistence"
+	"watcher/pkg/chain/base"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// activityScanStep is the block range of one activity log query. The
+	// query has no address filter, so it matches every pair's Sync and Swap
+	// on the chain; a range the node still rejects is split further.
+	activityScanStep = 50
+
+	// activityScanMaxBlocks bounds how far behind the head a scan starts;
+	// older blocks are skipped
+	activityScanMaxBlocks = 1000
+
+	// knownPoolsRefresh is how often the set of factory pools is reloaded
+	knownPoolsRefresh = 10 * time.Minute
+)
+
+// ActivityScanner counts the activity of factory pools that aren't tracked.
+// Ingestion only subscribes to tracked pools, so it fetches Swap and Sync
+// logs of every address and keeps those from pools the factory scan found.
+// Without it, a busy pool outside the tracked set could never rank into it.
+type ActivityScanner struct {
+	poller    *ingestion.Poller
+	decoder   *ingestion.Decoder
+	store     *persistence.Store
+	ingestion *ingestion.Service
+	interval  time.Duration
+
+	known         map[string]struct{}
+	knownLoadedAt time.Time
+
+	// next is the first block not scanned yet, 0 before the first scan
+	next uint64
+}
+
+// NewActivityScanner creates a scanner that fetches new blocks every interval.
+func NewActivityScanner(client *base.Client, store *persistence.Store, ingestionSvc *ingestion.Service, interval time.Duration) *ActivityScanner {
+	return &ActivityScanner{
+		poller:    ingestion.NewPoller(client),
+		decoder:   ingestion.NewDecoder(),
+		store:     store,
+		ingestion: ingestionSvc,
+		interval:  interval,
+	}
+}
+
+// Run scans new blocks every interval until ctx is cancelled.
+func (a *ActivityScanner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := a.Scan(ctx); err != nil {
+				log.Warn().Err(err).Msg("Activity scan failed")
+			}
+		}
+	}
+}
+
+// Scan records the activity of untracked factory pools in the blocks since
+// the last scan, activityScanStep blocks at a time. The first scan only sets
+// the starting block. A scan more than activityScanMaxBlocks behind the head
+// skips the older blocks.
+func (a *ActivityScanner) Scan(ctx context.Context) error {
+	head, err := a.poller.Head(ctx)
+	if err != nil {
+		return err
+	}
+	if a.next == 0 {
+		a.next = head + 1
+		return nil
+	}
+	if head < a.next {
+		return nil
+	}
+	if head-a.next >= activityScanMaxBlocks {
+		skipTo := head - activityScanMaxBlocks + 1
+		log.Warn().
+			Uint64("from_block", a.next).
+			Uint64("to_block", skipTo-1).
+			Msg("Activity scan fell behind, skipping blocks")
+		a.next = skipTo
+	}
+
+	if err := a.loadKnownPools(ctx); err != nil {
+		return err
+	}
+
+	for a.next <= head {
+		from, to := a.next, min(head, a.next+activityScanStep-1)
+		if err := a.scanRange(ctx, from, to); err != nil {
+			return err
+		}
+		a.next = to + 1
+	}
+	return nil
+}
+
+// scanRange records the activity of untracked factory pools in [from, to].
+func (a *ActivityScanner) scanRange(ctx context.Context, from, to uint64) error {
+	logs, err := a.poller.PollSplit(ctx, nil, []common.Hash{ingestion.SwapEventTopic, ingestion.SyncEventTopic}, from, to)
+	if err != nil {
+		return err
+	}
+
+	activity := a.ingestion.Activity()
+	now := time.Now()
+	recorded := 0
+	for _, entry := range logs {
+		address := strings.ToLower(entry.Address)
+		if _, ok := a.known[address]; !ok || a.ingestion.IsTracked(address) || len(entry.Topics) == 0 {
+			continue
+		}
+
+		switch common.HexToHash(entry.Topics[0]) {
+		case ingestion.SwapEventTopic:
+			event, err := a.decoder.DecodeSwapEvent(entry)
+			if err != nil {
+				continue
+			}
+			activity.RecordSwap(event, now)
+		case ingestion.SyncEventTopic:
+			event, err := a.decoder.DecodeSyncEvent(entry)
+			if err != nil {
+				continue
+			}
+			activity.RecordSync(event, now)
+		default:
+			continue
+		}
+		recorded++
+	}
+
+	log.Debug().
+		Uint64("from_block", from).
+		Uint64("to_block", to).
+		Int("events", recorded).
+		Msg("Scanned untracked pool activity")
+	return nil
+}
+
+// loadKnownPools reloads the factory pools found by the scan, if stale.
+func (a *ActivityScanner) loadKnownPools(ctx context.Context) error {
+	if a.known != nil && time.Since(a.knownLoadedAt) < knownPoolsRefresh {
+		return nil
+	}
+
+	pools, err := a.store.GetAllPools(ctx)
+	if err != nil {
+		return fmt.Errorf("loading factory pools: %w", err)
+	}
+	known := make(map[string]struct{}, len(pools))
+	for _, p := range pools {
+		known[strings.ToLower(p.Address)] = struct{}{}
+	}
+	a.known = known
+	a.knownLoadedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,134 @@
+package curator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"watcher/internal/ingestion"
+	"watcher/internal/mocknode"
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+)
+
+func TestActivityScannerCountsUntrackedFactoryPools(t *testing.T) {
+	ctx := context.Background()
+	node, b, store := newScanBootstrap(t)
+	if _, _, err := b.FetchTopPools(ctx, 10); err != nil {
+		t.Fatalf("FetchTopPools: %v", err)
+	}
+
+	// Created after the factory scan, so not a known pool yet
+	node.AddPool(mocknode.Pool{
+		Address: scanPool2, Token0: scanToken, Token1: aerodrome.USDCAddress,
+		Reserve0: units(1_000, 18), Reserve1: units(1_000, 6),
+	})
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	ingestionSvc := ingestion.NewService(nil, scanFactory.Hex(), nil, nil)
+	ingestionSvc.SetTrackedPools([]string{lower(scanPool0)})
+	scanner := NewActivityScanner(client, store, ingestionSvc, 0)
+	if err := scanner.Scan(ctx); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	if err := node.SetReserves(scanPool0, units(99, 18), units(301_000, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SetReserves(scanPool1, units(9_000, 18), units(11, 18)); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SetReserves(scanPool2, units(900, 18), units(1_100, 6)); err != nil {
+		t.Fatal(err)
+	}
+	node.MineBlock()
+
+	if err := scanner.Scan(ctx); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	activity := ingestionSvc.Activity()
+	if a, ok := activity.Activity(lower(scanPool1), time.Now()); !ok || a.Syncs != 1 {
+		t.Errorf("Expected the untracked factory pool's Sync to be counted, got %+v", a)
+	}
+	if _, ok := activity.Activity(lower(scanPool0), time.Now()); ok {
+		t.Error("Expected the tracked pool to be left to ingestion")
+	}
+	if _, ok := activity.Activity(lower(scanPool2), time.Now()); ok {
+		t.Error("Expected a pool the factory scan hasn't found to be skipped")
+	}
+}
+
+func TestActivityScannerSplitsRejectedRanges(t *testing.T) {
+	ctx := context.Background()
+	node, b, store := newScanBootstrap(t)
+	if _, _, err := b.FetchTopPools(ctx, 10); err != nil {
+		t.Fatalf("FetchTopPools: %v", err)
+	}
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	ingestionSvc := ingestion.NewService(nil, scanFactory.Hex(), nil, nil)
+	scanner := NewActivityScanner(client, store, ingestionSvc, 0)
+	if err := scanner.Scan(ctx); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	// One Sync per block, but the node returns at most one log per query
+	node.SetMaxLogResults(1)
+	for i := range 3 {
+		if err := node.SetReserves(scanPool1, units(int64(9_000+i), 18), units(11, 18)); err != nil {
+			t.Fatal(err)
+		}
+		node.MineBlock()
+	}
+
+	if err := scanner.Scan(ctx); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if a, ok := ingestionSvc.Activity().Activity(lower(scanPool1), time.Now()); !ok || a.Syncs != 3 {
+		t.Errorf("Expected all 3 Syncs counted, got %+v", a)
+	}
+	if scanner.next != node.Head()+1 {
+		t.Errorf("Expected the scan to reach the head, next block %d", scanner.next)
+	}
+}
+
+func TestActivityScannerSkipsBlocksWhenFarBehind(t *testing.T) {
+	ctx := context.Background()
+	node, _, store := newScanBootstrap(t)
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	scanner := NewActivityScanner(client, store, ingestion.NewService(nil, scanFactory.Hex(), nil, nil), 0)
+	if err := scanner.Scan(ctx); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	for range activityScanMaxBlocks + 10 {
+		node.MineBlock()
+	}
+
+	calls := node.Calls("eth_getLogs")
+	if err := scanner.Scan(ctx); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if scanner.next != node.Head()+1 {
+		t.Errorf("Expected the scan to reach the head, next block %d", scanner.next)
+	}
+	if got, want := node.Calls("eth_getLogs")-calls, activityScanMaxBlocks/activityScanStep; got != want {
+		t.Errorf("Expected %d queries for the last %d blocks, got %d", want, activityScanMaxBlocks, got)
+	}
+}
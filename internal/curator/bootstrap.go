@@ -4,24 +4,39 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"watcher/internal/graph"
+	"watcher/internal/metrics"
 	"watcher/internal/persistence"
 	"watcher/pkg/chain/base"
 	"watcher/pkg/dex/aerodrome"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	poolBatchSize  = 100
-	tokenBatchSize = 50
-	poolInfoCalls  = 4 // stable, reserves, token0, token1
-	poolsPerBatch  = 25
+	defaultBatchSize = 100 // Calls per multicall
+	poolInfoCalls    = 4   // stable, reserves, token0, token1
+	poolsPerBatch    = 25
+	poolMetaCalls    = 3 // stable, token0, token1
+	tokenInfoCalls   = 2 // symbol, decimals
+
+	// volatilePoolFee is the Aerodrome V2 volatile pool fee (0.3%)
+	volatilePoolFee = 0.003
+
+	// factoryScanChunk is how many factory indices are scanned between checkpoints.
+	factoryScanChunk = 1000
+
+	// factoryScanKey is the system state key holding the number of factory
+	// indices whose pools have been scanned and persisted.
+	factoryScanKey = "factory_scan_index"
 )
 
 // PoolInfo holds pool information during bootstrap.
@@ -32,7 +47,21 @@ type PoolInfo struct {
 	Reserve0 *big.Int
 	Reserve1 *big.Int
 	IsStable bool
-	Fee      float64
+	Fee      float64 // The pool's swap fee
+	TVL      float64 // USD, 0 if neither token could be priced
+	Score    float64 // Curation score, set when pools are ranked by a Scorer
+
+	// TransferFee is the fee-on-transfer of the pool's tokens, set by screening
+	TransferFee float64
+}
+
+// EffectiveFee returns the fee a trade through the pool pays: the pool's swap
+// fee composed with its tokens' transfer fees.
+func (p PoolInfo) EffectiveFee() float64 {
+	if p.TransferFee == 0 {
+		return p.Fee
+	}
+	return 1 - (1-p.Fee)*(1-p.TransferFee)
 }
 
 // TokenInfo holds token information during bootstrap.
@@ -46,8 +75,19 @@ type TokenInfo struct {
 type Bootstrap struct {
 	client         *base.Client
 	factoryAddress common.Address
-	batchSize      int
+	batchSize      int // Calls per multicall, capped by the client's multicall limit
+	workers        int // Batches fetched concurrently
+	metrics        *metrics.Metrics
 	startTokens    map[string]struct{} // Lowercase start tokens for quick lookup
+	pricer         *Pricer
+	store          *persistence.Store
+	screener       *Screener
+	scorer         *Scorer
+	policy         *Policy
+	maxPathLength  int // Longest cycle considered in selection, 0 to skip topology analysis
+
+	// Block every read is made at
+	block rpc.BlockNumberOrHash
 
 	// Token cache
 	tokenCache   map[string]*TokenInfo
@@ -66,12 +106,71 @@ func NewBootstrap(client *base.Client, factoryAddress string, batchSize int, sta
 		client:         client,
 		factoryAddress: common.HexToAddress(factoryAddress),
 		batchSize:      batchSize,
+		workers:        1,
 		startTokens:    startTokenSet,
+		pricer:         NewPricer(),
+		block:          base.Latest,
 		tokenCache:     make(map[string]*TokenInfo),
 	}
 }
 
-// FetchTopPools fetches the top N pools by TVL.
+// SetPricer shares a pricer with the bootstrap, so prices from its pool scans
+// are available to other components.
+func (b *Bootstrap) SetPricer(pricer *Pricer) {
+	b.pricer = pricer
+}
+
+// SetStore enables incremental factory scanning. Pool metadata and the scan
+// checkpoint are persisted, so later scans only visit new factory indices and
+// an interrupted scan resumes where it stopped. Cached tokens are reused.
+func (b *Bootstrap) SetStore(store *persistence.Store) {
+	b.store = store
+}
+
+// SetScreener screens the tokens of selected pools. Pools with blocked tokens
+// are dropped and pools with fee-on-transfer tokens are fee-adjusted.
+func (b *Bootstrap) SetScreener(screener *Screener) {
+	b.screener = screener
+}
+
+// SetScorer ranks pools by activity-weighted score instead of TVL alone.
+func (b *Bootstrap) SetScorer(scorer *Scorer) {
+	b.scorer = scorer
+}
+
+// SetPolicy applies curation allow and deny lists. Denied pools are never
+// selected and allowed pools always are.
+func (b *Bootstrap) SetPolicy(policy *Policy) {
+	b.policy = policy
+}
+
+// SetMaxPathLength makes selection analyse the topology of the candidate pools.
+// Pools that can't be on a cycle of at most maxPathLength pools through a start
+// token are dropped, and bridging pools that close such cycles are added.
+func (b *Bootstrap) SetMaxPathLength(maxPathLength int) {
+	b.maxPathLength = maxPathLength
+}
+
+// SetWorkers sets how many batches are fetched concurrently. The client's
+// rate limit is shared by all of them.
+func (b *Bootstrap) SetWorkers(workers int) {
+	b.workers = max(workers, 1)
+}
+
+// SetMetrics reports the progress of each bootstrap stage.
+func (b *Bootstrap) SetMetrics(m *metrics.Metrics) {
+	b.metrics = m
+}
+
+// SetBlock pins every read to block, so the fetched pools are a consistent
+// snapshot of one block. base.Latest reads each batch at the latest block.
+// A batch failing because the node pruned the block's state fails the fetch,
+// see isStatePruned.
+func (b *Bootstrap) SetBlock(block rpc.BlockNumberOrHash) {
+	b.block = block
+}
+
+// FetchTopPools fetches the top N pools by TVL, or by score if a scorer is set.
 func (b *Bootstrap) FetchTopPools(ctx context.Context, topN int) ([]PoolInfo, map[string]*TokenInfo, error) {
 	startTime := time.Now()
 
@@ -82,19 +181,27 @@ func (b *Bootstrap) FetchTopPools(ctx context.Context, topN int) ([]PoolInfo, ma
 	}
 	log.Info().Int("total", totalPools).Msg("Total pools in factory")
 
-	// Fetch all pool addresses
-	poolAddresses, err := b.fetchPoolAddresses(ctx, totalPools)
+	// Scan factory indices not scanned yet
+	known, err := b.scanFactory(ctx, totalPools)
 	if err != nil {
-		return nil, nil, fmt.Errorf("fetching pool addresses: %w", err)
+		return nil, nil, fmt.Errorf("scanning factory: %w", err)
 	}
-	log.Info().Int("fetched", len(poolAddresses)).Dur("elapsed", time.Since(startTime)).Msg("Fetched pool addresses")
+	log.Info().Int("volatile", len(known)).Dur("elapsed", time.Since(startTime)).Msg("Scanned factory pools")
 
-	// Fetch pool details in batches
-	pools, err := b.fetchPoolDetails(ctx, poolAddresses)
+	// Refresh reserves of every known pool
+	pools, err := b.fetchReserves(ctx, known)
 	if err != nil {
-		return nil, nil, fmt.Errorf("fetching pool details: %w", err)
+		return nil, nil, fmt.Errorf("fetching reserves: %w", err)
+	}
+	log.Info().Int("valid", len(pools)).Dur("elapsed", time.Since(startTime)).Msg("Fetched pool reserves")
+
+	if b.policy != nil {
+		pools = b.policy.Filter(pools)
+	}
+
+	if b.store != nil {
+		b.seedTokenCache(ctx)
 	}
-	log.Info().Int("valid", len(pools)).Dur("elapsed", time.Since(startTime)).Msg("Fetched pool details")
 
 	// Fetch token info for all unique tokens
 	tokens, err := b.fetchTokenInfo(ctx, pools)
@@ -103,7 +210,21 @@ func (b *Bootstrap) FetchTopPools(ctx context.Context, topN int) ([]PoolInfo, ma
 	}
 	log.Info().Int("tokens", len(tokens)).Dur("elapsed", time.Since(startTime)).Msg("Fetched token info")
 
-	// Sort by TVL and take top N, ensuring start token pools are included
+	// Value every pool in USD from prices derived across all of them
+	b.pricer.Update(pools, tokens)
+	b.pricer.AssignTVL(pools, tokens)
+	if b.scorer != nil {
+		b.scorer.Score(pools)
+	}
+
+	// Exclude or fee-adjust pools with unsafe tokens before selection, so
+	// excluded pools don't take the place of safe ones
+	if b.screener != nil {
+		b.screener.Screen(ctx, pools)
+		pools = b.screener.Apply(pools)
+	}
+
+	// Rank and take top N, ensuring start token pools are included
 	sortedPools := b.selectPoolsWithStartTokens(pools, tokens, topN)
 	log.Info().
 		Int("selected", len(sortedPools)).
@@ -128,7 +249,7 @@ func (b *Bootstrap) getPoolCount(ctx context.Context) (int, error) {
 		CallData: callData,
 	}}
 
-	results, err := b.client.BatchCallContract(ctx, calls)
+	results, err := b.client.BatchCallContractAt(ctx, calls, b.block)
 	if err != nil {
 		return 0, fmt.Errorf("calling contract: %w", err)
 	}
@@ -145,60 +266,361 @@ func (b *Bootstrap) getPoolCount(ctx context.Context) (int, error) {
 	return int(length.Int64()), nil
 }
 
-// fetchPoolAddresses fetches all pool addresses from the factory.
-func (b *Bootstrap) fetchPoolAddresses(ctx context.Context, total int) ([]string, error) {
-	addresses := make([]string, 0, total)
+// scanFactory returns the static metadata of every volatile pool in the factory.
+// With a store, factory indices below the persisted checkpoint are skipped, and
+// metadata and the checkpoint are persisted after every chunk. Without one,
+// every index is scanned.
+func (b *Bootstrap) scanFactory(ctx context.Context, total int) ([]PoolInfo, error) {
+	from := 0
+	if b.store != nil {
+		checkpoint, err := b.scanCheckpoint(ctx)
+		if err != nil {
+			return nil, err
+		}
+		from = checkpoint
+	}
+
+	if from < total {
+		log.Info().Int("from", from).Int("to", total).Int("workers", b.workers).Msg("Scanning factory pools")
+	}
+
+	// Each worker fetches a batch's addresses, then their metadata, while
+	// others are at either stage. Finished batches are committed in index
+	// order, so the checkpoint never passes a batch still in flight.
+	var (
+		mu        sync.Mutex
+		finished  = make(map[int][]PoolInfo) // Metadata by batch start, not yet committed
+		committed = from                     // Indices below are committed
+		persisted = from                     // Indices below are persisted
+		pending   []PoolInfo                 // Committed, not yet persisted
+		scanned   []PoolInfo
+	)
+	size := b.batchItems(1)
+	progress := newStageProgress("scan", total-from, b.metrics)
+
+	// commit moves finished batches in order into pending, and persists them
+	// with the checkpoint every factoryScanChunk indices. Called with mu held.
+	commit := func(ctx context.Context) error {
+		for {
+			metadata, ok := finished[committed]
+			if !ok {
+				break
+			}
+			delete(finished, committed)
+			pending = append(pending, metadata...)
+			committed = min(committed+size, total)
+		}
+		if committed-persisted < factoryScanChunk && committed < total {
+			return nil
+		}
+
+		if b.store == nil {
+			for _, pool := range pending {
+				if !pool.IsStable {
+					scanned = append(scanned, pool)
+				}
+			}
+		} else {
+			if err := b.store.InsertPoolMetadata(ctx, ConvertToPersistencePools(pending)); err != nil {
+				return fmt.Errorf("persisting pool metadata: %w", err)
+			}
+			if err := b.store.SetSystemState(ctx, factoryScanKey, strconv.Itoa(committed)); err != nil {
+				return fmt.Errorf("persisting scan checkpoint: %w", err)
+			}
+			log.Debug().Int("scanned", committed).Int("total", total).Msg("Factory scan checkpoint")
+		}
+		pending = nil
+		persisted = committed
+		return nil
+	}
+
+	err := forEachBatch(ctx, total-from, size, b.workers, func(ctx context.Context, start, end int) error {
+		addresses, err := b.fetchPoolAddresses(ctx, from+start, from+end)
+		if err != nil {
+			return fmt.Errorf("fetching pool addresses: %w", err)
+		}
+		metadata, err := b.fetchPoolMetadata(ctx, addresses)
+		if err != nil {
+			return fmt.Errorf("fetching pool metadata: %w", err)
+		}
+		progress.add(end - start)
+
+		mu.Lock()
+		defer mu.Unlock()
+		finished[from+start] = metadata
+		return commit(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if b.store == nil {
+		return scanned, nil
+	}
+
+	records, err := b.store.GetAllPools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading pool metadata: %w", err)
+	}
+	pools := make([]PoolInfo, len(records))
+	for i, r := range records {
+		pools[i] = PoolInfo{
+			Address:  r.Address,
+			Token0:   r.Token0,
+			Token1:   r.Token1,
+			IsStable: r.IsStable,
+			Fee:      r.Fee,
+		}
+	}
+	return pools, nil
+}
+
+// scanCheckpoint returns the number of factory indices already scanned.
+func (b *Bootstrap) scanCheckpoint(ctx context.Context) (int, error) {
+	value, err := b.store.GetSystemState(ctx, factoryScanKey)
+	if err != nil {
+		return 0, fmt.Errorf("loading scan checkpoint: %w", err)
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	checkpoint, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn().Str("value", value).Msg("Invalid factory scan checkpoint, rescanning from index 0")
+		return 0, nil
+	}
+	return checkpoint, nil
+}
+
+// seedTokenCache loads persisted tokens into the token cache.
+func (b *Bootstrap) seedTokenCache(ctx context.Context) {
+	records, err := b.store.GetAllTokens(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load cached tokens")
+		return
+	}
+
+	b.tokenCacheMu.Lock()
+	defer b.tokenCacheMu.Unlock()
+
+	for _, t := range records {
+		if _, ok := b.tokenCache[t.Address]; !ok {
+			b.tokenCache[t.Address] = &TokenInfo{Address: t.Address, Symbol: t.Symbol, Decimals: t.Decimals}
+		}
+	}
+}
+
+// fetchPoolAddresses fetches the pool addresses at factory indices [from, to)
+// in one multicall. Fails if any index can't be read, so a scan checkpoint
+// never skips a pool.
+func (b *Bootstrap) fetchPoolAddresses(ctx context.Context, from, to int) ([]string, error) {
+	calls := make([]base.ContractCall, to-from)
+	for i := from; i < to; i++ {
+		callData, err := aerodrome.V2FactoryABI.Pack("allPools", big.NewInt(int64(i)))
+		if err != nil {
+			return nil, fmt.Errorf("packing call for index %d: %w", i, err)
+		}
+		calls[i-from] = base.ContractCall{
+			Target:   b.factoryAddress,
+			CallData: callData,
+		}
+	}
+
+	results, err := b.client.BatchCallContractAt(ctx, calls, b.block)
+	if err != nil {
+		return nil, fmt.Errorf("batch call failed at offset %d: %w", from, err)
+	}
+
+	addresses := make([]string, 0, to-from)
+	for j, result := range results {
+		if !result.Success {
+			return nil, fmt.Errorf("allPools(%d) failed", from+j)
+		}
+
+		var addr common.Address
+		if err := aerodrome.V2FactoryABI.UnpackIntoInterface(&addr, "allPools", result.Data); err != nil {
+			return nil, fmt.Errorf("unpacking allPools(%d): %w", from+j, err)
+		}
+
+		addresses = append(addresses, strings.ToLower(addr.Hex()))
+	}
+
+	return addresses, nil
+}
+
+// fetchPoolMetadata fetches the immutable fields of pools: tokens and curve type.
+// Fails if any pool's metadata can't be read, so a scan checkpoint never
+// skips a pool.
+func (b *Bootstrap) fetchPoolMetadata(ctx context.Context, addresses []string) ([]PoolInfo, error) {
+	stableData, _ := aerodrome.V2PoolABI.Pack("stable")
+	token0Data, _ := aerodrome.V2PoolABI.Pack("token0")
+	token1Data, _ := aerodrome.V2PoolABI.Pack("token1")
 
-	for i := 0; i < total; i += poolBatchSize {
+	perBatch := b.batchItems(poolMetaCalls)
+	pools := make([]PoolInfo, 0, len(addresses))
+	for i := 0; i < len(addresses); i += perBatch {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
 		}
 
-		end := i + poolBatchSize
-		if end > total {
-			end = total
+		end := i + perBatch
+		if end > len(addresses) {
+			end = len(addresses)
 		}
+		batch := addresses[i:end]
 
-		// Build batch of calls
-		calls := make([]base.ContractCall, end-i)
-		for j := i; j < end; j++ {
-			callData, err := aerodrome.V2FactoryABI.Pack("allPools", big.NewInt(int64(j)))
-			if err != nil {
-				return nil, fmt.Errorf("packing call for index %d: %w", j, err)
-			}
-			calls[j-i] = base.ContractCall{
-				Target:   b.factoryAddress,
-				CallData: callData,
-			}
+		calls := make([]base.ContractCall, 0, len(batch)*poolMetaCalls)
+		for _, addr := range batch {
+			target := common.HexToAddress(addr)
+			calls = append(calls,
+				base.ContractCall{Target: target, CallData: stableData},
+				base.ContractCall{Target: target, CallData: token0Data},
+				base.ContractCall{Target: target, CallData: token1Data},
+			)
 		}
 
-		results, err := b.client.BatchCallContract(ctx, calls)
+		results, err := b.client.BatchCallContractAt(ctx, calls, b.block)
 		if err != nil {
 			return nil, fmt.Errorf("batch call failed at offset %d: %w", i, err)
 		}
 
+		if len(results) < len(calls) {
+			return nil, fmt.Errorf("batch at offset %d returned %d of %d results", i, len(results), len(calls))
+		}
+
+		for j, addr := range batch {
+			baseIdx := j * poolMetaCalls
+
+			var isStable bool
+			var token0, token1 common.Address
+			if !results[baseIdx].Success || !results[baseIdx+1].Success || !results[baseIdx+2].Success ||
+				aerodrome.V2PoolABI.UnpackIntoInterface(&isStable, "stable", results[baseIdx].Data) != nil ||
+				aerodrome.V2PoolABI.UnpackIntoInterface(&token0, "token0", results[baseIdx+1].Data) != nil ||
+				aerodrome.V2PoolABI.UnpackIntoInterface(&token1, "token1", results[baseIdx+2].Data) != nil {
+				return nil, fmt.Errorf("reading metadata of pool %s", addr)
+			}
+
+			pools = append(pools, PoolInfo{
+				Address:  addr,
+				Token0:   strings.ToLower(token0.Hex()),
+				Token1:   strings.ToLower(token1.Hex()),
+				Reserve0: big.NewInt(0),
+				Reserve1: big.NewInt(0),
+				IsStable: isStable,
+				Fee:      volatilePoolFee,
+			})
+		}
+	}
+
+	return pools, nil
+}
+
+// fetchReserves fetches current reserves for pools with known metadata,
+// fetching batches concurrently. Pools without enough liquidity are dropped.
+func (b *Bootstrap) fetchReserves(ctx context.Context, known []PoolInfo) ([]PoolInfo, error) {
+	reservesData, _ := aerodrome.V2PoolABI.Pack("getReserves")
+
+	size := b.batchItems(1)
+	batches := make([][]PoolInfo, (len(known)+size-1)/size)
+	progress := newStageProgress("reserves", len(known), b.metrics)
+
+	err := forEachBatch(ctx, len(known), size, b.workers, func(ctx context.Context, start, end int) error {
+		defer progress.add(end - start)
+		batch := known[start:end]
+
+		calls := make([]base.ContractCall, len(batch))
+		for j, pool := range batch {
+			calls[j] = base.ContractCall{Target: common.HexToAddress(pool.Address), CallData: reservesData}
+		}
+
+		results, err := b.client.BatchCallContractAt(ctx, calls, b.block)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if isStatePruned(err) {
+				return fmt.Errorf("reserves batch at offset %d: %w", start, err)
+			}
+			log.Warn().Err(err).Int("offset", start).Msg("Reserves batch failed, continuing")
+			return nil
+		}
+
+		var pools []PoolInfo
 		for j, result := range results {
-			if !result.Success {
+			if j >= len(batch) || !result.Success {
 				continue
 			}
 
-			var addr common.Address
-			if err := aerodrome.V2FactoryABI.UnpackIntoInterface(&addr, "allPools", result.Data); err != nil {
-				log.Warn().Int("index", i+j).Err(err).Msg("Failed to unpack pool address")
+			reserves := struct {
+				Reserve0           *big.Int
+				Reserve1           *big.Int
+				BlockTimestampLast *big.Int
+			}{}
+			if err := aerodrome.V2PoolABI.UnpackIntoInterface(&reserves, "getReserves", result.Data); err != nil {
+				continue
+			}
+			if !hasLiquidity(reserves.Reserve0, reserves.Reserve1) {
 				continue
 			}
 
-			addresses = append(addresses, strings.ToLower(addr.Hex()))
+			pool := batch[j]
+			pool.Reserve0 = reserves.Reserve0
+			pool.Reserve1 = reserves.Reserve1
+			pools = append(pools, pool)
 		}
+		batches[start/size] = pools
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]PoolInfo, 0, len(known))
+	for _, batch := range batches {
+		pools = append(pools, batch...)
+	}
+	return pools, nil
+}
 
-		if (i+poolBatchSize)%1000 == 0 || end == total {
-			log.Debug().Int("progress", end).Int("total", total).Msg("Fetching pool addresses")
+// batchItems returns how many items of callsPerItem calls fit in one
+// multicall: the batch size, capped by the client's multicall limit.
+func (b *Bootstrap) batchItems(callsPerItem int) int {
+	calls := b.batchSize
+	if calls <= 0 {
+		calls = defaultBatchSize
+	}
+	if b.client != nil {
+		if limit := b.client.MulticallLimit(); limit > 0 && limit < calls {
+			calls = limit
 		}
 	}
+	return max(calls/callsPerItem, 1)
+}
 
-	return addresses, nil
+// hasLiquidity reports whether a pool's reserves are worth tracking.
+func hasLiquidity(reserve0, reserve1 *big.Int) bool {
+	// Skip zero reserves
+	if reserve0.Sign() == 0 || reserve1.Sign() == 0 {
+		return false
+	}
+
+	// Skip very low liquidity (both reserves < 1e15 wei, roughly $1-10)
+	minReserve := big.NewInt(1e15)
+	return reserve0.Cmp(minReserve) >= 0 || reserve1.Cmp(minReserve) >= 0
+}
+
+// isStatePruned reports whether err is a node failing a read because it no
+// longer has the state of the block it was pinned to. Non-archive nodes only
+// keep the state of about the last 128 blocks.
+func isStatePruned(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "missing trie node") || strings.Contains(msg, "historical state")
 }
 
 // fetchPoolDetails fetches details for all pools.
@@ -254,7 +676,7 @@ func (b *Bootstrap) fetchPoolBatch(ctx context.Context, addresses []string) ([]P
 		)
 	}
 
-	results, err := b.client.BatchCallContract(ctx, calls)
+	results, err := b.client.BatchCallContractAt(ctx, calls, b.block)
 	if err != nil {
 		return nil, err
 	}
@@ -297,14 +719,7 @@ func (b *Bootstrap) fetchPoolBatch(ctx context.Context, addresses []string) ([]P
 			continue
 		}
 
-		// Skip zero reserves
-		if reserves.Reserve0.Sign() == 0 || reserves.Reserve1.Sign() == 0 {
-			continue
-		}
-
-		// Skip very low liquidity (both reserves < 1e15 wei, roughly $1-10)
-		minReserve := big.NewInt(1e15)
-		if reserves.Reserve0.Cmp(minReserve) < 0 && reserves.Reserve1.Cmp(minReserve) < 0 {
+		if !hasLiquidity(reserves.Reserve0, reserves.Reserve1) {
 			continue
 		}
 
@@ -324,7 +739,7 @@ func (b *Bootstrap) fetchPoolBatch(ctx context.Context, addresses []string) ([]P
 			Reserve0: reserves.Reserve0,
 			Reserve1: reserves.Reserve1,
 			IsStable: isStable,
-			Fee:      0.003, // Aerodrome V2 volatile fee is 0.3%
+			Fee:      volatilePoolFee,
 		})
 	}
 
@@ -352,23 +767,23 @@ func (b *Bootstrap) fetchTokenInfo(ctx context.Context, pools []PoolInfo) (map[s
 
 	log.Debug().Int("unique", len(uniqueTokens)).Int("to_fetch", len(tokensToFetch)).Msg("Fetching token info")
 
-	// Fetch in batches
-	for i := 0; i < len(tokensToFetch); i += tokenBatchSize {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		end := i + tokenBatchSize
-		if end > len(tokensToFetch) {
-			end = len(tokensToFetch)
-		}
-
-		batch := tokensToFetch[i:end]
-		if err := b.fetchTokenBatch(ctx, batch); err != nil {
+	// Fetch in concurrent batches
+	progress := newStageProgress("tokens", len(tokensToFetch), b.metrics)
+	err := forEachBatch(ctx, len(tokensToFetch), b.batchItems(tokenInfoCalls), b.workers, func(ctx context.Context, start, end int) error {
+		defer progress.add(end - start)
+		if err := b.fetchTokenBatch(ctx, tokensToFetch[start:end]); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if isStatePruned(err) {
+				return fmt.Errorf("token batch at offset %d: %w", start, err)
+			}
 			log.Warn().Err(err).Msg("Token batch failed, continuing with defaults")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Return all tokens (including cached)
@@ -394,8 +809,7 @@ func (b *Bootstrap) fetchTokenInfo(ctx context.Context, pools []PoolInfo) (map[s
 
 // fetchTokenBatch fetches metadata for a batch of tokens.
 func (b *Bootstrap) fetchTokenBatch(ctx context.Context, addresses []string) error {
-	// 2 calls per token (symbol, decimals)
-	calls := make([]base.ContractCall, 0, len(addresses)*2)
+	calls := make([]base.ContractCall, 0, len(addresses)*tokenInfoCalls)
 
 	symbolData, _ := aerodrome.ERC20ABI.Pack("symbol")
 	decimalsData, _ := aerodrome.ERC20ABI.Pack("decimals")
@@ -408,7 +822,7 @@ func (b *Bootstrap) fetchTokenBatch(ctx context.Context, addresses []string) err
 		)
 	}
 
-	results, err := b.client.BatchCallContract(ctx, calls)
+	results, err := b.client.BatchCallContractAt(ctx, calls, b.block)
 	if err != nil {
 		return err
 	}
@@ -450,21 +864,37 @@ func (b *Bootstrap) fetchTokenBatch(ctx context.Context, addresses []string) err
 	return nil
 }
 
-// selectPoolsWithStartTokens selects top N pools by TVL, ensuring all pools
-// containing start tokens are included regardless of TVL ranking.
+// selectPoolsWithStartTokens selects the top N ranked pools, ensuring all pools
+// containing start tokens are included regardless of ranking. With a maximum
+// path length set, only pools that can be on a bounded cycle through a start
+// token within the selection are selected.
 func (b *Bootstrap) selectPoolsWithStartTokens(pools []PoolInfo, tokens map[string]*TokenInfo, topN int) []PoolInfo {
+	// Allowed pools are always selected, ahead of ranking and topology analysis
+	target := topN
+	var allowed []PoolInfo
+	if b.policy != nil {
+		allowed, pools = b.policy.splitAllowed(pools)
+		b.rankPools(allowed)
+		topN = max(topN-len(allowed), 0)
+	}
+
+	topology := b.maxPathLength > 0 && len(b.startTokens) > 0
+	if topology {
+		candidates := len(pools)
+		pools = b.reachablePools(pools)
+		log.Info().
+			Int("candidates", candidates).
+			Int("unreachable", candidates-len(pools)).
+			Int("max_path_length", b.maxPathLength).
+			Msg("Pool selection: dropped pools on no cycle through a start token")
+	}
+
 	// Separate pools containing start tokens from others
 	var startTokenPools []PoolInfo
 	var otherPools []PoolInfo
 
 	for _, pool := range pools {
-		token0Lower := strings.ToLower(pool.Token0)
-		token1Lower := strings.ToLower(pool.Token1)
-
-		_, hasToken0 := b.startTokens[token0Lower]
-		_, hasToken1 := b.startTokens[token1Lower]
-
-		if hasToken0 || hasToken1 {
+		if b.isStartTokenPool(pool) {
 			startTokenPools = append(startTokenPools, pool)
 		} else {
 			otherPools = append(otherPools, pool)
@@ -477,11 +907,11 @@ func (b *Bootstrap) selectPoolsWithStartTokens(pools []PoolInfo, tokens map[stri
 		Int("start_tokens_configured", len(b.startTokens)).
 		Msg("Pool selection: separated start token pools")
 
-	// Sort start token pools by TVL
-	startTokenPools = sortPoolsByTVL(startTokenPools, tokens)
+	// Rank start token pools
+	b.rankPools(startTokenPools)
 
-	// Sort other pools by TVL
-	otherPools = sortPoolsByTVL(otherPools, tokens)
+	// Rank other pools
+	b.rankPools(otherPools)
 
 	// Build result: all start token pools first, then fill remaining with other pools
 	result := make([]PoolInfo, 0, topN)
@@ -498,16 +928,44 @@ func (b *Bootstrap) selectPoolsWithStartTokens(pools []PoolInfo, tokens map[stri
 		result = append(result, otherPools[:remaining]...)
 	}
 
+	// Replace pools stranded by the cut with bridging pools or later candidates
+	if topology {
+		ranked := append(append([]PoolInfo{}, startTokenPools...), otherPools...)
+		result = b.closeCycles(ranked, len(result), max(topN, len(startTokenPools)))
+	}
+
+	startIncluded := 0
+	for _, pool := range result {
+		if b.isStartTokenPool(pool) {
+			startIncluded++
+		}
+	}
+	result = append(allowed, result...)
+
 	log.Info().
-		Int("start_token_pools_included", len(startTokenPools)).
-		Int("other_pools_included", len(result)-len(startTokenPools)).
+		Int("allowed_pools_included", len(allowed)).
+		Int("start_token_pools_included", startIncluded).
+		Int("other_pools_included", len(result)-len(allowed)-startIncluded).
 		Int("total_selected", len(result)).
-		Int("target", topN).
+		Int("target", target).
 		Msg("Pool selection complete")
 
 	return result
 }
 
+// isMandatoryPool reports whether a pool is selected regardless of rank: it
+// contains a start token or is allowed by the curation policy.
+func (b *Bootstrap) isMandatoryPool(pool PoolInfo) bool {
+	return b.isStartTokenPool(pool) || (b.policy != nil && b.policy.Allowed(pool))
+}
+
+// isStartTokenPool reports whether a pool contains a start token.
+func (b *Bootstrap) isStartTokenPool(pool PoolInfo) bool {
+	_, hasToken0 := b.startTokens[strings.ToLower(pool.Token0)]
+	_, hasToken1 := b.startTokens[strings.ToLower(pool.Token1)]
+	return hasToken0 || hasToken1
+}
+
 // validateStartTokenPools logs which start tokens have pools and warns if any are missing.
 func (b *Bootstrap) validateStartTokenPools(pools []PoolInfo, tokens map[string]*TokenInfo) {
 	// Count pools per start token
@@ -550,53 +1008,20 @@ func (b *Bootstrap) validateStartTokenPools(pools []PoolInfo, tokens map[string]
 	}
 }
 
-// sortPoolsByTVL sorts pools by approximate TVL descending.
-func sortPoolsByTVL(pools []PoolInfo, tokens map[string]*TokenInfo) []PoolInfo {
-	if len(pools) == 0 {
-		return pools
-	}
-
-	// Calculate approximate TVL for sorting
-	type poolWithTVL struct {
-		pool PoolInfo
-		tvl  float64
-	}
-
-	poolsWithTVL := make([]poolWithTVL, len(pools))
-	for i, pool := range pools {
-		// Simple TVL estimate: sum of reserves normalized by decimals
-		token0Info := tokens[pool.Token0]
-		token1Info := tokens[pool.Token1]
-
-		var tvl float64
-		if token0Info != nil {
-			r0, _ := new(big.Float).SetInt(pool.Reserve0).Float64()
-			tvl += r0 / float64(int64(1)<<(token0Info.Decimals*3))
-		}
-		if token1Info != nil {
-			r1, _ := new(big.Float).SetInt(pool.Reserve1).Float64()
-			tvl += r1 / float64(int64(1)<<(token1Info.Decimals*3))
-		}
-
-		poolsWithTVL[i] = poolWithTVL{pool: pool, tvl: tvl}
-	}
-
-	// Sort by TVL descending (simple bubble sort for clarity, can optimize if needed)
-	for i := 0; i < len(poolsWithTVL); i++ {
-		for j := i + 1; j < len(poolsWithTVL); j++ {
-			if poolsWithTVL[j].tvl > poolsWithTVL[i].tvl {
-				poolsWithTVL[i], poolsWithTVL[j] = poolsWithTVL[j], poolsWithTVL[i]
-			}
-		}
-	}
-
-	// Extract sorted pools
-	result := make([]PoolInfo, len(poolsWithTVL))
-	for i, p := range poolsWithTVL {
-		result[i] = p.pool
+// rankPools sorts pools by score if a scorer is set, otherwise by TVL.
+func (b *Bootstrap) rankPools(pools []PoolInfo) {
+	if b.scorer != nil {
+		sortPoolsByScore(pools)
+		return
 	}
+	sortPoolsByTVL(pools)
+}
 
-	return result
+// sortPoolsByTVL sorts pools by USD TVL descending, in place.
+func sortPoolsByTVL(pools []PoolInfo) {
+	sort.SliceStable(pools, func(i, j int) bool {
+		return pools[i].TVL > pools[j].TVL
+	})
 }
 
 // ConvertToGraphPools converts PoolInfo to graph.PoolState.
@@ -609,7 +1034,8 @@ func ConvertToGraphPools(pools []PoolInfo) []graph.PoolState {
 			Token1:   p.Token1,
 			Reserve0: p.Reserve0,
 			Reserve1: p.Reserve1,
-			Fee:      p.Fee,
+			Fee:      p.EffectiveFee(),
+			IsStable: p.IsStable,
 		}
 	}
 	return result
@@ -640,6 +1066,23 @@ func ConvertToPersistencePools(pools []PoolInfo) []persistence.PoolRecord {
 			Reserve1: p.Reserve1.String(),
 			Fee:      p.Fee,
 			IsStable: p.IsStable,
+			TVL:      p.TVL,
+		}
+	}
+	return result
+}
+
+// ConvertToReserveRecords converts PoolInfo to reserve history records of
+// the reserves at the end of block.
+func ConvertToReserveRecords(pools []PoolInfo, block uint64) []persistence.ReserveRecord {
+	result := make([]persistence.ReserveRecord, len(pools))
+	for i, p := range pools {
+		result[i] = persistence.ReserveRecord{
+			Pool:     p.Address,
+			Block:    block,
+			LogIndex: persistence.BlockEndLogIndex,
+			Reserve0: p.Reserve0.String(),
+			Reserve1: p.Reserve1.String(),
 		}
 	}
 	return result
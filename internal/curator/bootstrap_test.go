@@ -0,0 +1,276 @@
+package curator
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"watcher/internal/mocknode"
+	"watcher/internal/persistence"
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	scanFactory = common.HexToAddress("0x00000000000000000000000000000000000000f0")
+	scanToken   = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	scanPool0   = common.HexToAddress("0x0000000000000000000000000000000000000a01")
+	scanPool1   = common.HexToAddress("0x0000000000000000000000000000000000000a02")
+	scanPool2   = common.HexToAddress("0x0000000000000000000000000000000000000a03")
+)
+
+func newScanBootstrap(t *testing.T) (*mocknode.Node, *Bootstrap, *persistence.Store) {
+	node := mocknode.New(scanFactory)
+	t.Cleanup(node.Close)
+
+	node.AddToken(mocknode.Token{Address: aerodrome.WETHAddress, Symbol: "WETH", Decimals: 18})
+	node.AddToken(mocknode.Token{Address: aerodrome.USDCAddress, Symbol: "USDC", Decimals: 6})
+	node.AddToken(mocknode.Token{Address: scanToken, Symbol: "TKN", Decimals: 18})
+	node.AddPool(mocknode.Pool{
+		Address: scanPool0, Token0: aerodrome.WETHAddress, Token1: aerodrome.USDCAddress,
+		Reserve0: units(100, 18), Reserve1: units(300_000, 6),
+	})
+	node.AddPool(mocknode.Pool{
+		Address: scanPool1, Token0: scanToken, Token1: aerodrome.WETHAddress,
+		Reserve0: units(10_000, 18), Reserve1: units(10, 18),
+	})
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "watcher.db"))
+	if err != nil {
+		t.Fatalf("Opening store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	b := NewBootstrap(client, scanFactory.Hex(), 100, nil)
+	b.SetStore(store)
+	return node, b, store
+}
+
+func poolAddressSet(pools []PoolInfo) map[string]PoolInfo {
+	set := make(map[string]PoolInfo, len(pools))
+	for _, p := range pools {
+		set[p.Address] = p
+	}
+	return set
+}
+
+func lower(addr common.Address) string {
+	return strings.ToLower(addr.Hex())
+}
+
+func TestFetchTopPoolsScansOnlyNewIndices(t *testing.T) {
+	node, b, store := newScanBootstrap(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pools, _, err := b.FetchTopPools(ctx, 10)
+	if err != nil {
+		t.Fatalf("First scan: %v", err)
+	}
+	if len(pools) != 2 {
+		t.Fatalf("Expected 2 pools, got %d", len(pools))
+	}
+	if checkpoint, _ := store.GetSystemState(ctx, factoryScanKey); checkpoint != "2" {
+		t.Errorf("Expected checkpoint 2, got %q", checkpoint)
+	}
+
+	// Change an already scanned pool's tokens: the stored metadata must be kept
+	node.AddPool(mocknode.Pool{
+		Address: scanPool1, Token0: aerodrome.WETHAddress, Token1: scanToken,
+		Reserve0: units(10, 18), Reserve1: units(10_000, 18),
+	})
+	node.AddPool(mocknode.Pool{
+		Address: scanPool2, Token0: scanToken, Token1: aerodrome.USDCAddress,
+		Reserve0: units(10_000, 18), Reserve1: units(15_000, 6),
+	})
+
+	pools, _, err = b.FetchTopPools(ctx, 10)
+	if err != nil {
+		t.Fatalf("Second scan: %v", err)
+	}
+	if checkpoint, _ := store.GetSystemState(ctx, factoryScanKey); checkpoint != "3" {
+		t.Errorf("Expected checkpoint 3, got %q", checkpoint)
+	}
+
+	byAddress := poolAddressSet(pools)
+	if _, ok := byAddress[lower(scanPool2)]; !ok {
+		t.Error("Expected the new pool to be scanned")
+	}
+	rescanned, ok := byAddress[lower(scanPool1)]
+	if !ok {
+		t.Fatal("Expected the scanned pool to be refreshed")
+	}
+	if rescanned.Token0 != lower(scanToken) {
+		t.Errorf("Expected stored token0 %s, got %s", lower(scanToken), rescanned.Token0)
+	}
+	// Reserves are refreshed on every scan
+	if rescanned.Reserve0.Cmp(units(10, 18)) != 0 {
+		t.Errorf("Expected refreshed reserve0 %s, got %s", units(10, 18), rescanned.Reserve0)
+	}
+}
+
+func TestFetchTopPoolsResumesFromCheckpoint(t *testing.T) {
+	_, b, store := newScanBootstrap(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// An interrupted scan persisted index 0 only
+	if err := store.InsertPoolMetadata(ctx, []persistence.PoolRecord{{
+		Address: lower(scanPool0),
+		Token0:  lower(aerodrome.WETHAddress),
+		Token1:  lower(aerodrome.USDCAddress),
+		Fee:     0.003,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetSystemState(ctx, factoryScanKey, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	pools, _, err := b.FetchTopPools(ctx, 10)
+	if err != nil {
+		t.Fatalf("Resumed scan: %v", err)
+	}
+	if len(pools) != 2 {
+		t.Errorf("Expected 2 pools after resuming, got %d", len(pools))
+	}
+	if checkpoint, _ := store.GetSystemState(ctx, factoryScanKey); checkpoint != "2" {
+		t.Errorf("Expected checkpoint 2, got %q", checkpoint)
+	}
+}
+
+func TestFetchTopPoolsKeepsUnreadablePoolsToScan(t *testing.T) {
+	node, b, store := newScanBootstrap(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A pool whose metadata can't be read fails the scan before the checkpoint
+	node.SetReverts(scanPool1, true)
+	if _, _, err := b.FetchTopPools(ctx, 10); err == nil || !strings.Contains(err.Error(), lower(scanPool1)) {
+		t.Fatalf("Expected the scan to fail on the unreadable pool, got %v", err)
+	}
+	if checkpoint, _ := store.GetSystemState(ctx, factoryScanKey); checkpoint != "" {
+		t.Errorf("Expected no checkpoint past the unreadable pool, got %q", checkpoint)
+	}
+
+	// Once it can be read, the next scan picks it up
+	node.SetReverts(scanPool1, false)
+	pools, _, err := b.FetchTopPools(ctx, 10)
+	if err != nil {
+		t.Fatalf("FetchTopPools: %v", err)
+	}
+	if _, ok := poolAddressSet(pools)[lower(scanPool1)]; !ok {
+		t.Errorf("Expected the pool scanned once readable, got %+v", pools)
+	}
+	if checkpoint, _ := store.GetSystemState(ctx, factoryScanKey); checkpoint != "2" {
+		t.Errorf("Expected checkpoint 2, got %q", checkpoint)
+	}
+}
+
+func TestFetchTopPoolsScreensBeforeSelection(t *testing.T) {
+	node, b, store := newScanBootstrap(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The deepest pool holds a honeypot token
+	node.AddToken(mocknode.Token{Address: screenHoneypot, Symbol: "HONEY", Decimals: 18, Honeypot: true})
+	node.AddPool(mocknode.Pool{
+		Address: scanPool2, Token0: screenHoneypot, Token1: aerodrome.WETHAddress,
+		Reserve0: units(1_000_000, 18), Reserve1: units(1_000, 18),
+	})
+	b.SetScreener(NewScreener(b.client, store, []string{aerodrome.WETHAddress.Hex()}))
+
+	pools, _, err := b.FetchTopPools(ctx, 2)
+	if err != nil {
+		t.Fatalf("FetchTopPools: %v", err)
+	}
+	selected := poolAddressSet(pools)
+	if _, ok := selected[lower(scanPool2)]; ok || len(pools) != 2 {
+		t.Errorf("Expected the 2 safe pools selected in place of the honeypot pool, got %+v", pools)
+	}
+}
+
+func TestFetchTopPoolsReadsPinnedBlock(t *testing.T) {
+	node, b, _ := newScanBootstrap(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	b.SetBlock(rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(node.Head())))
+
+	// A block mined during bootstrap changes reserves and creates a pool
+	if err := node.SetReserves(scanPool1, units(20_000, 18), units(5, 18)); err != nil {
+		t.Fatal(err)
+	}
+	node.CreatePool(mocknode.Pool{
+		Address: scanPool2, Token0: scanToken, Token1: aerodrome.USDCAddress,
+		Reserve0: units(10_000, 18), Reserve1: units(15_000, 6),
+	})
+	node.MineBlock()
+
+	pools, _, err := b.FetchTopPools(ctx, 10)
+	if err != nil {
+		t.Fatalf("FetchTopPools: %v", err)
+	}
+
+	byAddress := poolAddressSet(pools)
+	if _, ok := byAddress[lower(scanPool2)]; ok {
+		t.Error("Expected the pool created after the pinned block to be skipped")
+	}
+	pool, ok := byAddress[lower(scanPool1)]
+	if !ok {
+		t.Fatal("Expected the pool to be fetched")
+	}
+	if pool.Reserve0.Cmp(units(10_000, 18)) != 0 {
+		t.Errorf("Expected reserve0 %s at the pinned block, got %s", units(10_000, 18), pool.Reserve0)
+	}
+}
+
+func TestFetchTopPoolsConcurrentScan(t *testing.T) {
+	node, b, store := newScanBootstrap(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const extra = 60
+	for i := 0; i < extra; i++ {
+		node.AddPool(mocknode.Pool{
+			Address: common.BigToAddress(big.NewInt(int64(0xb00 + i))), Token0: scanToken, Token1: aerodrome.WETHAddress,
+			Reserve0: units(int64(1000+i), 18), Reserve1: units(1, 18),
+		})
+	}
+
+	// Batches of 8 calls on 4 workers, against a provider that rejects
+	// multicalls of more than 5 calls
+	b.batchSize = 8
+	b.SetWorkers(4)
+	node.SetMaxMulticallSize(5)
+
+	pools, tokens, err := b.FetchTopPools(ctx, 100)
+	if err != nil {
+		t.Fatalf("FetchTopPools: %v", err)
+	}
+	if len(pools) != extra+2 {
+		t.Errorf("Expected %d pools, got %d", extra+2, len(pools))
+	}
+	if len(tokens) != 3 {
+		t.Errorf("Expected 3 tokens, got %d", len(tokens))
+	}
+	if checkpoint, _ := store.GetSystemState(ctx, factoryScanKey); checkpoint != strconv.Itoa(extra+2) {
+		t.Errorf("Expected checkpoint %d, got %q", extra+2, checkpoint)
+	}
+	if limit := b.client.MulticallLimit(); limit < 1 || limit > 5 {
+		t.Errorf("Expected the multicall limit to adapt to at most 5, got %d", limit)
+	}
+}
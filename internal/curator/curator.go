@@ -2,6 +2,7 @@ package curator
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"watcher/internal/graph"
@@ -10,16 +11,39 @@ import (
 	"watcher/internal/persistence"
 	"watcher/pkg/chain/base"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
 )
 
+// maxBootstrapRepins is how many times bootstrap re-pins its reads to a new
+// head after the node pruned the pinned block's state, before reading unpinned.
+const maxBootstrapRepins = 3
+
 // Config holds curator configuration.
 type Config struct {
 	FactoryAddress       string
 	TopPoolsCount        int
 	ReevaluationInterval time.Duration
 	BootstrapBatchSize   int
+	BootstrapWorkers     int      // Batches fetched concurrently during bootstrap and re-evaluation
 	StartTokens          []string // Start tokens for arbitrage - must always be included
+
+	// MaxPathLength is the longest cycle the detector searches. Selection keeps
+	// pools that can be on such a cycle through a start token; 0 keeps all.
+	MaxPathLength int
+
+	// Scoring weights pool selection by TVL and activity. Zero uses DefaultScoringWeights.
+	Scoring ScoringWeights
+
+	// Lists force-include or exclude pools and tokens
+	Lists CurationLists
+
+	// WarmRestartMaxGap is how many blocks behind the head saved graph state
+	// may be and still be loaded instead of bootstrapping; 0 always bootstraps.
+	WarmRestartMaxGap uint64
+
+	// RecordReserves stores the reserves read by bootstrap in reserve history
+	RecordReserves bool
 }
 
 // Curator manages the pool lifecycle including bootstrap, tracking, and evaluation.
@@ -34,9 +58,21 @@ type Curator struct {
 	bootstrap *Bootstrap
 	evaluator *Evaluator
 
-	// bootstrapStartBlock records the block number when bootstrap began
-	// Used for reconciliation after WebSocket subscription starts
-	bootstrapStartBlock uint64
+	// USD prices shared by bootstrap and evaluation
+	pricer *Pricer
+
+	// Token safety classifications
+	screener *Screener
+
+	// Activity-weighted pool ranking
+	scorer *Scorer
+
+	// Curation allow and deny lists
+	policy *Policy
+
+	// bootstrapBlock is the block every bootstrap read was made at
+	// Reconciliation after WebSocket subscription starts at the next block
+	bootstrapBlock uint64
 }
 
 // NewCurator creates a new curator.
@@ -48,6 +84,47 @@ func NewCurator(
 	m *metrics.Metrics,
 	ingestionSvc *ingestion.Service,
 ) *Curator {
+	pricer := NewPricer()
+
+	// Denied pools are kept out of the graph whichever path adds them
+	policy := NewPolicy(store, cfg.Lists)
+	graphManager.SetPoolFilter(func(pool graph.PoolState) bool {
+		return !policy.DeniedPool(pool.Address, pool.Token0, pool.Token1)
+	})
+
+	bootstrap := NewBootstrap(client, cfg.FactoryAddress, cfg.BootstrapBatchSize, cfg.StartTokens)
+	bootstrap.SetPricer(pricer)
+	bootstrap.SetStore(store)
+	bootstrap.SetMaxPathLength(cfg.MaxPathLength)
+	bootstrap.SetPolicy(policy)
+	bootstrap.SetWorkers(cfg.BootstrapWorkers)
+	bootstrap.SetMetrics(m)
+
+	screener := NewScreener(client, store, cfg.StartTokens)
+	screener.SetPolicy(policy)
+	screener.SetWorkers(cfg.BootstrapWorkers)
+	bootstrap.SetScreener(screener)
+
+	scorer := NewScorer(cfg.Scoring, ingestionSvc.Activity(), store)
+	bootstrap.SetScorer(scorer)
+
+	evaluator := NewEvaluator(
+		client,
+		store,
+		graphManager,
+		cfg.FactoryAddress,
+		cfg.TopPoolsCount,
+		cfg.ReevaluationInterval,
+		cfg.StartTokens,
+	)
+	evaluator.SetPricer(pricer)
+	evaluator.SetScreener(screener)
+	evaluator.SetScorer(scorer)
+	evaluator.SetMaxPathLength(cfg.MaxPathLength)
+	evaluator.SetPolicy(policy)
+	evaluator.SetWorkers(cfg.BootstrapWorkers)
+	evaluator.SetIngestion(ingestionSvc)
+
 	return &Curator{
 		config:       cfg,
 		client:       client,
@@ -55,16 +132,12 @@ func NewCurator(
 		graphManager: graphManager,
 		metrics:      m,
 		ingestion:    ingestionSvc,
-		bootstrap:    NewBootstrap(client, cfg.FactoryAddress, cfg.BootstrapBatchSize, cfg.StartTokens),
-		evaluator: NewEvaluator(
-			client,
-			store,
-			graphManager,
-			cfg.FactoryAddress,
-			cfg.TopPoolsCount,
-			cfg.ReevaluationInterval,
-			cfg.StartTokens,
-		),
+		bootstrap:    bootstrap,
+		evaluator:    evaluator,
+		pricer:       pricer,
+		screener:     screener,
+		scorer:       scorer,
+		policy:       policy,
 	}
 }
 
@@ -72,46 +145,73 @@ func NewCurator(
 func (c *Curator) Bootstrap(ctx context.Context) error {
 	startTime := time.Now()
 
-	// Record the current block number BEFORE fetching any data
-	// This is used for reconciliation after WebSocket subscription starts
-	currentBlock, err := c.client.BlockNumber(ctx)
-	if err != nil {
-		log.Warn().Err(err).Msg("Failed to get current block number, reconciliation may be incomplete")
-		currentBlock = 0
-	} else {
-		c.bootstrapStartBlock = currentBlock
-		log.Info().
-			Uint64("block", currentBlock).
-			Msg("Recorded bootstrap start block for reconciliation")
+	// Pin every read to the current head, so the initial graph is the state
+	// at one block and reconciliation can start right after it
+	if err := c.pinBootstrap(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to get current block, bootstrap reads are not pinned and reconciliation may be incomplete")
 	}
+	defer c.bootstrap.SetBlock(base.Latest)
+	pinned := c.bootstrapBlock > 0
 
 	log.Info().
 		Int("target_pools", c.config.TopPoolsCount).
 		Msg("Starting bootstrap")
 
-	// Check if we have cached data
-	poolCount, err := c.store.GetPoolCount(ctx)
-	if err != nil {
-		log.Warn().Err(err).Msg("Failed to check pool count in database")
-		poolCount = 0
-	}
+	// Activity counters from before a restart inform ranking
+	c.scorer.Load(ctx)
 
-	var pools []PoolInfo
-	var tokens map[string]*TokenInfo
+	// Store the configured curation lists and load them with stored entries
+	if err := c.policy.Seed(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to store curation lists")
+	}
+	if err := c.policy.Load(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to load curation lists, using configured lists")
+	}
 
-	if poolCount >= c.config.TopPoolsCount/2 {
-		// Load from cache and refresh reserves
-		log.Info().Int("cached", poolCount).Msg("Loading pools from cache")
-		pools, tokens, err = c.loadFromCacheAndRefresh(ctx)
+	// Resume from saved graph state when reconciliation can catch it up
+	if c.bootstrapBlock > 0 && c.config.WarmRestartMaxGap > 0 {
+		loaded, err := c.warmStart(ctx, c.bootstrapBlock)
 		if err != nil {
-			log.Warn().Err(err).Msg("Cache load failed, performing full bootstrap")
-			pools, tokens, err = c.bootstrap.FetchTopPools(ctx, c.config.TopPoolsCount)
+			log.Warn().Err(err).Msg("Failed to load saved graph state, performing bootstrap")
+		}
+		if loaded {
+			c.setTrackedPools(ctx, c.graphManager.GetTrackedPools(), startTime)
+
+			nodes, edges, pools := c.graphManager.Stats()
+			log.Info().
+				Int("pools", pools).
+				Int("nodes", nodes).
+				Int("edges", edges).
+				Dur("duration", time.Since(startTime)).
+				Msg("Warm restart complete")
+			return nil
 		}
-	} else {
-		// Full bootstrap
-		pools, tokens, err = c.bootstrap.FetchTopPools(ctx, c.config.TopPoolsCount)
 	}
 
+	pools, tokens, err := c.fetchPools(ctx)
+
+	// The node may prune the pinned block's state before a long bootstrap is
+	// done. Re-pin to the head and resume: scanned factory indices are kept,
+	// and reserves are read again at the new block
+	for repins := 0; pinned && isStatePruned(err) && repins < maxBootstrapRepins; repins++ {
+		log.Warn().Err(err).Uint64("block", c.bootstrapBlock).Msg("Pinned block state was pruned, re-pinning bootstrap reads")
+		if err := c.pinBootstrap(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to get current block for re-pinning")
+			break
+		}
+		pools, tokens, err = c.fetchPools(ctx)
+	}
+	if pinned && isStatePruned(err) {
+		// Unpinned reads are all at or after the last pinned block, and
+		// reconciliation replays every Sync after it, so the graph catches up
+		log.Warn().
+			Err(err).
+			Uint64("block", c.bootstrapBlock).
+			Msg("Pinned block state keeps being pruned, bootstrapping from the latest state")
+		c.bootstrap.SetBlock(base.Latest)
+		pinned = false
+		pools, tokens, err = c.fetchPools(ctx)
+	}
 	if err != nil {
 		return err
 	}
@@ -130,11 +230,71 @@ func (c *Curator) Bootstrap(ctx context.Context) error {
 		log.Warn().Err(err).Msg("Failed to persist pools")
 	}
 
+	// Reserve history starts from the bootstrap reserves, so a pool without a
+	// Sync since bootstrap can still be reconstructed
+	if c.config.RecordReserves && pinned {
+		if err := c.store.InsertReserveHistory(ctx, ConvertToReserveRecords(pools, c.bootstrapBlock)); err != nil {
+			log.Warn().Err(err).Msg("Failed to store bootstrap reserves")
+		}
+	}
+
 	// Set tracked pools
 	addresses := make([]string, len(pools))
 	for i, p := range pools {
 		addresses[i] = p.Address
 	}
+	c.setTrackedPools(ctx, addresses, startTime)
+
+	log.Info().
+		Int("pools", len(pools)).
+		Int("tokens", len(tokens)).
+		Dur("duration", time.Since(startTime)).
+		Msg("Bootstrap complete")
+
+	return nil
+}
+
+// pinBootstrap pins every bootstrap read to the current head.
+func (c *Curator) pinBootstrap(ctx context.Context) error {
+	header, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	c.bootstrapBlock = header.Number.Uint64()
+	c.bootstrap.SetBlock(rpc.BlockNumberOrHashWithHash(header.Hash(), false))
+	log.Info().
+		Uint64("block", c.bootstrapBlock).
+		Str("hash", header.Hash().Hex()).
+		Msg("Pinned bootstrap reads to block")
+	return nil
+}
+
+// fetchPools reads the initial pools, from the cache if it holds enough of
+// them, otherwise with a full bootstrap.
+func (c *Curator) fetchPools(ctx context.Context) ([]PoolInfo, map[string]*TokenInfo, error) {
+	poolCount, err := c.store.GetPoolCount(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to check pool count in database")
+		poolCount = 0
+	}
+
+	if poolCount >= c.config.TopPoolsCount/2 {
+		// Load from cache and refresh reserves
+		log.Info().Int("cached", poolCount).Msg("Loading pools from cache")
+		pools, tokens, err := c.loadFromCacheAndRefresh(ctx)
+		if err == nil || isStatePruned(err) {
+			return pools, tokens, err
+		}
+		log.Warn().Err(err).Msg("Cache load failed, performing full bootstrap")
+	}
+
+	return c.bootstrap.FetchTopPools(ctx, c.config.TopPoolsCount)
+}
+
+// setTrackedPools stores the pools the graph was initialized with, starts
+// ingesting their events and records the bootstrap metrics.
+func (c *Curator) setTrackedPools(ctx context.Context, addresses []string, startTime time.Time) {
 	if err := c.store.SetTrackedPools(ctx, addresses); err != nil {
 		log.Warn().Err(err).Msg("Failed to set tracked pools")
 	}
@@ -145,22 +305,15 @@ func (c *Curator) Bootstrap(ctx context.Context) error {
 	// Record metrics
 	if c.metrics != nil {
 		c.metrics.RecordBootstrapLatency(time.Since(startTime))
-		c.metrics.SetPoolsTracked(len(pools))
+		c.metrics.SetPoolsTracked(len(addresses))
 
 		nodes, edges, _ := c.graphManager.Stats()
 		c.metrics.RecordGraphStats(nodes, edges)
 	}
-
-	log.Info().
-		Int("pools", len(pools)).
-		Int("tokens", len(tokens)).
-		Dur("duration", time.Since(startTime)).
-		Msg("Bootstrap complete")
-
-	return nil
 }
 
-// loadFromCacheAndRefresh loads pools from the database and refreshes reserves.
+// loadFromCacheAndRefresh loads the top pools by stored TVL from the database,
+// refreshes their reserves, revalues and ranks them.
 func (c *Curator) loadFromCacheAndRefresh(ctx context.Context) ([]PoolInfo, map[string]*TokenInfo, error) {
 	// Load cached pools
 	cachedPools, err := c.store.GetTopPoolsByTVL(ctx, c.config.TopPoolsCount)
@@ -168,23 +321,53 @@ func (c *Curator) loadFromCacheAndRefresh(ctx context.Context) ([]PoolInfo, map[
 		return nil, nil, err
 	}
 
+	// Pools cached before TVL was persisted can't be ranked
+	if len(cachedPools) == 0 || cachedPools[0].TVL <= 0 {
+		return nil, nil, fmt.Errorf("cached pools have no stored TVL")
+	}
+	storedTVL := make(map[string]float64, len(cachedPools))
+	for _, p := range cachedPools {
+		storedTVL[p.Address] = p.TVL
+	}
+
+	// Allowed pools are tracked even if they aren't among the top pools
+	for _, address := range c.policy.AllowedPools() {
+		if _, ok := storedTVL[address]; ok {
+			continue
+		}
+		record, err := c.store.GetPoolByAddress(ctx, address)
+		if err != nil || record == nil {
+			log.Warn().Err(err).Str("pool", address).Msg("Allowed pool not found in cache")
+			continue
+		}
+		cachedPools = append(cachedPools, *record)
+		storedTVL[address] = record.TVL
+	}
+
 	// Load cached tokens
 	cachedTokens, err := c.store.GetAllTokens(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Convert to internal types
-	poolAddresses := make([]string, len(cachedPools))
+	// Convert to internal types; pool metadata is immutable
+	known := make([]PoolInfo, len(cachedPools))
 	for i, p := range cachedPools {
-		poolAddresses[i] = p.Address
+		known[i] = PoolInfo{
+			Address:  p.Address,
+			Token0:   p.Token0,
+			Token1:   p.Token1,
+			IsStable: p.IsStable,
+			Fee:      p.Fee,
+		}
 	}
 
 	// Refresh reserves via bootstrap
-	pools, err := c.bootstrap.fetchPoolDetails(ctx, poolAddresses)
+	pools, err := c.bootstrap.fetchReserves(ctx, known)
 	if err != nil {
 		return nil, nil, err
 	}
+	pools = c.policy.Filter(pools)
 
 	// Convert tokens
 	tokens := make(map[string]*TokenInfo, len(cachedTokens))
@@ -212,6 +395,9 @@ func (c *Curator) loadFromCacheAndRefresh(ctx context.Context) ([]PoolInfo, map[
 
 	if len(missingTokens) > 0 {
 		newTokens, err := c.bootstrap.fetchTokenInfo(ctx, pools)
+		if isStatePruned(err) {
+			return nil, nil, err
+		}
 		if err == nil {
 			for addr, t := range newTokens {
 				tokens[addr] = t
@@ -219,6 +405,21 @@ func (c *Curator) loadFromCacheAndRefresh(ctx context.Context) ([]PoolInfo, map[
 		}
 	}
 
+	// Exclude or fee-adjust pools with unsafe tokens
+	c.screener.Screen(ctx, pools)
+	pools = c.screener.Apply(pools)
+
+	// Revalue at current reserves, keeping the stored TVL for pools that can't be priced
+	c.pricer.Update(pools, tokens)
+	c.pricer.AssignTVL(pools, tokens)
+	for i := range pools {
+		if pools[i].TVL == 0 {
+			pools[i].TVL = storedTVL[pools[i].Address]
+		}
+	}
+	c.scorer.Score(pools)
+	pools = c.bootstrap.selectPoolsWithStartTokens(pools, tokens, c.config.TopPoolsCount)
+
 	return pools, tokens, nil
 }
 
@@ -274,10 +475,22 @@ func (c *Curator) PoolCount() int {
 	return pools
 }
 
-// BootstrapStartBlock returns the block number recorded at bootstrap start.
-// This is used to determine the range of blocks to reconcile.
-func (c *Curator) BootstrapStartBlock() uint64 {
-	return c.bootstrapStartBlock
+// PoolActivity returns a pool's swap volume, swap count and fee accrual over
+// the ingestion activity window. Returns false if the pool had no activity.
+func (c *Curator) PoolActivity(address string) (ingestion.PoolActivity, bool) {
+	return c.ingestion.Activity().Activity(address, time.Now())
+}
+
+// TokenSafety returns a token's screening classification, if it has been screened.
+func (c *Curator) TokenSafety(token string) (TokenSafety, bool) {
+	return c.screener.Safety(token)
+}
+
+// BootstrapBlock returns the block the bootstrap state was read at, or the
+// block of the saved state after a warm restart, or 0 if reads weren't
+// pinned. Reconciliation starts at the next block.
+func (c *Curator) BootstrapBlock() uint64 {
+	return c.bootstrapBlock
 }
 
 // Client returns the RPC client for use in reconciliation.
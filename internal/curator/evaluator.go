@@ -2,16 +2,32 @@ package curator
 
 import (
 	"context"
+	"math"
 	"math/big"
+	"sort"
+	"strings"
 	"time"
 
 	"watcher/internal/graph"
+	"watcher/internal/ingestion"
 	"watcher/internal/persistence"
 	"watcher/pkg/chain/base"
 
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// minNewPoolTVLUSD is the minimum USD value for a newly created pool to be tracked.
+	minNewPoolTVLUSD = 200
+
+	// evaluationHysteresis is the fraction of the top N past the cutoff within
+	// which already tracked pools are kept, so pools near the cutoff don't flap.
+	evaluationHysteresis = 0.1
+
+	// activitySaveTimeout bounds saving pool activity on shutdown.
+	activitySaveTimeout = 5 * time.Second
+)
+
 // Evaluator periodically re-evaluates pool TVL and updates tracked pools.
 type Evaluator struct {
 	client         *base.Client
@@ -21,6 +37,13 @@ type Evaluator struct {
 	interval       time.Duration
 	factoryAddress string
 	startTokens    []string
+	pricer         *Pricer
+	screener       *Screener
+	scorer         *Scorer
+	policy         *Policy
+	maxPathLength  int
+	workers        int
+	ingestion      *ingestion.Service
 }
 
 // NewEvaluator creates a new pool evaluator.
@@ -41,9 +64,49 @@ func NewEvaluator(
 		interval:       interval,
 		factoryAddress: factoryAddress,
 		startTokens:    startTokens,
+		pricer:         NewPricer(),
 	}
 }
 
+// SetPricer shares a pricer with the evaluator. Re-evaluation updates its
+// prices, which value newly created pools.
+func (e *Evaluator) SetPricer(pricer *Pricer) {
+	e.pricer = pricer
+}
+
+// SetScreener sets the token screener applied to evaluated pools.
+func (e *Evaluator) SetScreener(screener *Screener) {
+	e.screener = screener
+}
+
+// SetScorer ranks evaluated pools by activity-weighted score. Its activity
+// counters are persisted on every evaluation.
+func (e *Evaluator) SetScorer(scorer *Scorer) {
+	e.scorer = scorer
+}
+
+// SetPolicy applies curation allow and deny lists to evaluation and new pools.
+// The lists are reloaded on every evaluation.
+func (e *Evaluator) SetPolicy(policy *Policy) {
+	e.policy = policy
+}
+
+// SetMaxPathLength bounds evaluated selections to pools that can be on a cycle
+// of at most maxPathLength pools through a start token.
+func (e *Evaluator) SetMaxPathLength(maxPathLength int) {
+	e.maxPathLength = maxPathLength
+}
+
+// SetWorkers sets how many batches each evaluation fetches concurrently.
+func (e *Evaluator) SetWorkers(workers int) {
+	e.workers = workers
+}
+
+// SetIngestion sets the ingestion service whose tracked pools follow evaluation.
+func (e *Evaluator) SetIngestion(svc *ingestion.Service) {
+	e.ingestion = svc
+}
+
 // Run starts the periodic evaluation loop.
 func (e *Evaluator) Run(ctx context.Context) error {
 	ticker := time.NewTicker(e.interval)
@@ -57,6 +120,12 @@ func (e *Evaluator) Run(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
+			// Activity since the last evaluation informs ranking after a restart
+			if e.scorer != nil {
+				saveCtx, cancel := context.WithTimeout(context.Background(), activitySaveTimeout)
+				e.scorer.Save(saveCtx)
+				cancel()
+			}
 			return ctx.Err()
 		case <-ticker.C:
 			if err := e.evaluate(ctx); err != nil {
@@ -66,43 +135,88 @@ func (e *Evaluator) Run(ctx context.Context) error {
 	}
 }
 
-// evaluate performs a single evaluation cycle.
+// evaluate performs a single evaluation cycle. It reconciles the tracked set
+// with the current top pools: new top pools are added, pools that fell out are
+// removed, and ingestion is moved to the new set.
 func (e *Evaluator) evaluate(ctx context.Context) error {
 	startTime := time.Now()
 	log.Info().Msg("Starting pool re-evaluation")
 
-	// Fetch fresh pool data
+	// Fetch fresh pool data, ranked past the cutoff for hysteresis
+	margin := hysteresisMargin(e.topPoolsCount)
 	bootstrap := NewBootstrap(e.client, e.factoryAddress, 100, e.startTokens)
-	pools, tokens, err := bootstrap.FetchTopPools(ctx, e.topPoolsCount)
+	bootstrap.SetPricer(e.pricer)
+	bootstrap.SetStore(e.store)
+	bootstrap.SetScreener(e.screener)
+	bootstrap.SetMaxPathLength(e.maxPathLength)
+	bootstrap.SetWorkers(e.workers)
+	if e.policy != nil {
+		if err := e.policy.Load(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to reload curation lists")
+		}
+		bootstrap.SetPolicy(e.policy)
+	}
+	if e.scorer != nil {
+		e.scorer.Save(ctx)
+		bootstrap.SetScorer(e.scorer)
+	}
+	ranked, tokens, err := bootstrap.FetchTopPools(ctx, e.topPoolsCount+margin)
 	if err != nil {
 		return err
 	}
 
+	// Start token and allowed pools are always selected, even past the top N
+	cutoff := e.topPoolsCount
+	mandatoryPools := 0
+	for _, pool := range ranked {
+		if bootstrap.isMandatoryPool(pool) {
+			mandatoryPools++
+		}
+	}
+	if mandatoryPools > cutoff {
+		cutoff = mandatoryPools
+	}
+
+	diff := diffTrackedPools(e.graphManager.GetTrackedPools(), ranked, cutoff, margin)
+
 	// Update persistence
 	if err := e.store.BulkUpsertTokens(ctx, ConvertToPersistenceTokens(tokens)); err != nil {
 		log.Warn().Err(err).Msg("Failed to update tokens in database")
 	}
 
-	if err := e.store.BulkUpsertPools(ctx, ConvertToPersistencePools(pools)); err != nil {
+	if err := e.store.BulkUpsertPools(ctx, ConvertToPersistencePools(diff.kept)); err != nil {
 		log.Warn().Err(err).Msg("Failed to update pools in database")
 	}
 
 	// Update graph
-	graphPools := ConvertToGraphPools(pools)
+	graphPools := ConvertToGraphPools(diff.kept)
 	graphTokens := ConvertToGraphTokens(tokens)
 	e.graphManager.AddPoolBatch(graphPools, graphTokens)
+	if len(diff.removed) > 0 {
+		e.graphManager.RemovePools(diff.removed)
+	}
 
 	// Update tracked pool list
-	addresses := make([]string, len(pools))
-	for i, p := range pools {
+	addresses := make([]string, len(diff.kept))
+	for i, p := range diff.kept {
 		addresses[i] = p.Address
 	}
 	if err := e.store.SetTrackedPools(ctx, addresses); err != nil {
 		log.Warn().Err(err).Msg("Failed to update tracked pools")
 	}
 
+	// Ingestion resubscribes once the tracked set changes
+	if e.ingestion != nil && (len(diff.added) > 0 || len(diff.removed) > 0) {
+		e.ingestion.SetTrackedPools(addresses)
+	}
+
 	log.Info().
-		Int("pools", len(pools)).
+		Int("pools", len(diff.kept)).
+		Int("added", len(diff.added)).
+		Int("removed", len(diff.removed)).
+		Int("retained", diff.retained).
+		Int("retained_by_hysteresis", diff.retainedByHysteresis).
+		Int("cutoff", cutoff).
 		Int("tokens", len(tokens)).
 		Dur("duration", time.Since(startTime)).
 		Msg("Pool re-evaluation complete")
@@ -110,10 +224,80 @@ func (e *Evaluator) evaluate(ctx context.Context) error {
 	return nil
 }
 
+// hysteresisMargin returns how many ranks past the cutoff tracked pools are kept.
+func hysteresisMargin(topN int) int {
+	margin := int(math.Ceil(float64(topN) * evaluationHysteresis))
+	if margin < 1 {
+		margin = 1
+	}
+	return margin
+}
+
+// poolDiff is the change to the tracked set from one evaluation.
+type poolDiff struct {
+	kept    []PoolInfo // pools tracked after evaluation, in rank order
+	added   []string
+	removed []string
+
+	retained             int // tracked pools that stay tracked
+	retainedByHysteresis int // of which ranked past the cutoff
+}
+
+// diffTrackedPools compares ranked pools against the tracked set. The first
+// cutoff ranked pools are selected. Tracked pools ranked within margin past the
+// cutoff are retained; tracked pools ranked lower, or not at all, are removed.
+func diffTrackedPools(tracked []string, ranked []PoolInfo, cutoff, margin int) poolDiff {
+	trackedSet := make(map[string]struct{}, len(tracked))
+	for _, addr := range tracked {
+		trackedSet[strings.ToLower(addr)] = struct{}{}
+	}
+
+	var diff poolDiff
+	keptSet := make(map[string]struct{}, cutoff+margin)
+	for i, pool := range ranked {
+		if i >= cutoff+margin {
+			break
+		}
+
+		addr := strings.ToLower(pool.Address)
+		_, isTracked := trackedSet[addr]
+		switch {
+		case i < cutoff && !isTracked:
+			diff.added = append(diff.added, addr)
+		case isTracked:
+			diff.retained++
+			if i >= cutoff {
+				diff.retainedByHysteresis++
+			}
+		default:
+			// Past the cutoff and not tracked
+			continue
+		}
+
+		diff.kept = append(diff.kept, pool)
+		keptSet[addr] = struct{}{}
+	}
+
+	for addr := range trackedSet {
+		if _, kept := keptSet[addr]; !kept {
+			diff.removed = append(diff.removed, addr)
+		}
+	}
+	sort.Strings(diff.removed)
+
+	return diff
+}
+
 // EvaluateNewPool evaluates a newly created pool.
 func (e *Evaluator) EvaluateNewPool(ctx context.Context, poolAddr, token0, token1 string) (bool, error) {
+	if e.policy != nil && e.policy.DeniedPool(poolAddr, token0, token1) {
+		log.Info().Str("pool", poolAddr).Msg("New pool is denied by curation lists")
+		return false, nil
+	}
+
 	// Fetch pool details (no start token filtering needed for single pool fetch)
 	bootstrap := NewBootstrap(e.client, e.factoryAddress, 100, nil)
+	bootstrap.SetPricer(e.pricer)
 
 	poolInfos, err := bootstrap.fetchPoolDetails(ctx, []string{poolAddr})
 	if err != nil || len(poolInfos) == 0 {
@@ -122,14 +306,6 @@ func (e *Evaluator) EvaluateNewPool(ctx context.Context, poolAddr, token0, token
 
 	pool := poolInfos[0]
 
-	// Check if pool meets minimum TVL
-	// For simplicity, we check if reserves are above a threshold
-	minReserve := big.NewInt(1e17) // ~$100 minimum per side
-	if pool.Reserve0.Cmp(minReserve) < 0 || pool.Reserve1.Cmp(minReserve) < 0 {
-		log.Debug().Str("pool", poolAddr).Msg("New pool below TVL threshold")
-		return false, nil
-	}
-
 	// Fetch token info
 	tokensMap, err := bootstrap.fetchTokenInfo(ctx, poolInfos)
 	if err != nil {
@@ -140,6 +316,35 @@ func (e *Evaluator) EvaluateNewPool(ctx context.Context, poolAddr, token0, token
 		}
 	}
 
+	// Reject pools with unsafe tokens
+	if e.screener != nil {
+		e.screener.Screen(ctx, poolInfos)
+		screened := e.screener.Apply(poolInfos)
+		if len(screened) == 0 {
+			log.Info().Str("pool", poolAddr).Msg("New pool has a blocked token")
+			return false, nil
+		}
+		pool = screened[0]
+	}
+
+	// Check if pool meets minimum TVL. Pools with no priced token fall back to
+	// a raw reserve threshold. Allowed pools are added regardless.
+	tvl := e.pricer.PoolTVL(pool, tokensMap)
+	if e.policy != nil && e.policy.Allowed(pool) {
+		log.Info().Str("pool", poolAddr).Msg("New pool is allowed by curation lists")
+	} else if tvl > 0 {
+		if tvl < minNewPoolTVLUSD {
+			log.Debug().Str("pool", poolAddr).Float64("tvl", tvl).Msg("New pool below TVL threshold")
+			return false, nil
+		}
+	} else {
+		minReserve := big.NewInt(1e17)
+		if pool.Reserve0.Cmp(minReserve) < 0 || pool.Reserve1.Cmp(minReserve) < 0 {
+			log.Debug().Str("pool", poolAddr).Msg("New pool below TVL threshold")
+			return false, nil
+		}
+	}
+
 	// Add to graph
 	graphPool := graph.PoolState{
 		Address:  pool.Address,
@@ -147,7 +352,8 @@ func (e *Evaluator) EvaluateNewPool(ctx context.Context, poolAddr, token0, token
 		Token1:   pool.Token1,
 		Reserve0: pool.Reserve0,
 		Reserve1: pool.Reserve1,
-		Fee:      pool.Fee,
+		Fee:      pool.EffectiveFee(),
+		IsStable: pool.IsStable,
 	}
 
 	token0Info := graph.TokenInfo{
@@ -172,6 +378,7 @@ func (e *Evaluator) EvaluateNewPool(ctx context.Context, poolAddr, token0, token
 		Reserve1: pool.Reserve1.String(),
 		Fee:      pool.Fee,
 		IsStable: pool.IsStable,
+		TVL:      tvl,
 	}); err != nil {
 		log.Warn().Err(err).Msg("Failed to persist new pool")
 	}
@@ -0,0 +1,74 @@
+package curator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func rankedPools(addresses ...string) []PoolInfo {
+	pools := make([]PoolInfo, len(addresses))
+	for i, addr := range addresses {
+		pools[i] = PoolInfo{Address: addr}
+	}
+	return pools
+}
+
+func keptAddresses(diff poolDiff) []string {
+	addresses := make([]string, len(diff.kept))
+	for i, p := range diff.kept {
+		addresses[i] = p.Address
+	}
+	return addresses
+}
+
+func TestDiffTrackedPoolsHysteresis(t *testing.T) {
+	// Top 3 with a margin of 1: rank 4 is kept only if already tracked
+	ranked := rankedPools("0xa", "0xb", "0xc", "0xd", "0xe")
+	tracked := []string{"0xA", "0xd", "0xe", "0xf"}
+
+	diff := diffTrackedPools(tracked, ranked, 3, 1)
+
+	if got, want := keptAddresses(diff), []string{"0xa", "0xb", "0xc", "0xd"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected kept %v, got %v", want, got)
+	}
+	if want := []string{"0xb", "0xc"}; !reflect.DeepEqual(diff.added, want) {
+		t.Errorf("Expected added %v, got %v", want, diff.added)
+	}
+	if want := []string{"0xe", "0xf"}; !reflect.DeepEqual(diff.removed, want) {
+		t.Errorf("Expected removed %v, got %v", want, diff.removed)
+	}
+	if diff.retained != 2 || diff.retainedByHysteresis != 1 {
+		t.Errorf("Expected 2 retained with 1 by hysteresis, got %d and %d", diff.retained, diff.retainedByHysteresis)
+	}
+}
+
+func TestDiffTrackedPoolsUntrackedNearCutoffNotAdded(t *testing.T) {
+	ranked := rankedPools("0xa", "0xb", "0xc")
+
+	diff := diffTrackedPools([]string{"0xa", "0xb"}, ranked, 2, 1)
+
+	if got, want := keptAddresses(diff), []string{"0xa", "0xb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected kept %v, got %v", want, got)
+	}
+	if len(diff.added) != 0 || len(diff.removed) != 0 {
+		t.Errorf("Expected no change, got added %v and removed %v", diff.added, diff.removed)
+	}
+}
+
+func TestHysteresisMargin(t *testing.T) {
+	tests := []struct {
+		topN int
+		want int
+	}{
+		{topN: 1, want: 1},
+		{topN: 10, want: 1},
+		{topN: 15, want: 2},
+		{topN: 500, want: 50},
+	}
+
+	for _, tt := range tests {
+		if got := hysteresisMargin(tt.topN); got != tt.want {
+			t.Errorf("hysteresisMargin(%d) = %d, want %d", tt.topN, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package curator
+
+import (
+	"context"
+	"sync"
+
+	"watcher/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// forEachBatch calls fn for the consecutive batches [start, end) of n items,
+// size items each, on up to workers goroutines. Batches are handed out in
+// order. The first error stops batches not yet started and is returned.
+func forEachBatch(ctx context.Context, n, size, workers int, fn func(ctx context.Context, start, end int) error) error {
+	if n <= 0 {
+		return nil
+	}
+	size = max(size, 1)
+	workers = min(max(workers, 1), (n+size-1)/size)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	starts := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range starts {
+				if err := fn(ctx, start, min(start+size, n)); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for start := 0; start < n; start += size {
+		select {
+		case starts <- start:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(starts)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// stageProgress reports how many items of a bootstrap stage are done, in
+// metrics and in a debug log every tenth of the stage.
+type stageProgress struct {
+	stage   string
+	total   int
+	metrics *metrics.Metrics
+
+	mu      sync.Mutex
+	done    int
+	nextLog int
+}
+
+func newStageProgress(stage string, total int, m *metrics.Metrics) *stageProgress {
+	p := &stageProgress{stage: stage, total: total, metrics: m}
+	p.report()
+	return p
+}
+
+// add records n more items done.
+func (p *stageProgress) add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done += n
+	p.report()
+}
+
+func (p *stageProgress) report() {
+	if p.metrics != nil {
+		p.metrics.SetBootstrapProgress(p.stage, p.done, p.total)
+	}
+	if p.done >= p.nextLog && p.total > 0 {
+		log.Debug().Str("stage", p.stage).Int("done", p.done).Int("total", p.total).Msg("Bootstrap progress")
+		p.nextLog = p.done + max(p.total/10, 1)
+	}
+}
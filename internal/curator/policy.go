@@ -0,0 +1,207 @@
+package curator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"watcher/internal/persistence"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Curation list names, as stored in the curation_lists table.
+const (
+	listAllowPools  = "allow_pools"
+	listDenyPools   = "deny_pools"
+	listAllowTokens = "allow_tokens"
+	listDenyTokens  = "deny_tokens"
+)
+
+// sourceConfig marks curation list entries that come from the config file.
+const sourceConfig = "config"
+
+// CurationLists are pool and token allow and deny lists.
+type CurationLists struct {
+	AllowPools  []string // Always tracked
+	DenyPools   []string // Never tracked
+	AllowTokens []string // Pools with these tokens are always tracked; the tokens aren't screened
+	DenyTokens  []string // Pools with these tokens are never tracked
+}
+
+// entries returns the lists as curation entries with lowercase addresses.
+func (l CurationLists) entries() []persistence.CurationEntry {
+	var entries []persistence.CurationEntry
+	for _, list := range []struct {
+		name      string
+		addresses []string
+	}{
+		{listAllowPools, l.AllowPools},
+		{listDenyPools, l.DenyPools},
+		{listAllowTokens, l.AllowTokens},
+		{listDenyTokens, l.DenyTokens},
+	} {
+		for _, address := range list.addresses {
+			entries = append(entries, persistence.CurationEntry{
+				List:    list.name,
+				Address: strings.ToLower(strings.TrimSpace(address)),
+			})
+		}
+	}
+	return entries
+}
+
+// Policy applies the curation allow and deny lists. Denials win: a pool that
+// is denied, or has a denied token, is never tracked even if allowed.
+//
+// The lists are persisted. Entries from the config file are stored on startup,
+// replacing the previous config entries, and entries added to the
+// curation_lists table directly are kept. The lists are reloaded on every
+// re-evaluation, so they can be changed without a restart.
+type Policy struct {
+	store  *persistence.Store
+	config CurationLists
+
+	mu    sync.RWMutex
+	lists map[string]map[string]struct{}
+}
+
+// NewPolicy creates a curation policy from the configured lists. Until Load is
+// called, only the configured lists apply.
+func NewPolicy(store *persistence.Store, config CurationLists) *Policy {
+	p := &Policy{
+		store:  store,
+		config: config,
+	}
+	p.set(config.entries())
+	return p
+}
+
+// set replaces the lists.
+func (p *Policy) set(entries []persistence.CurationEntry) {
+	lists := map[string]map[string]struct{}{
+		listAllowPools:  {},
+		listDenyPools:   {},
+		listAllowTokens: {},
+		listDenyTokens:  {},
+	}
+	for _, e := range entries {
+		if list, ok := lists[e.List]; ok {
+			list[strings.ToLower(e.Address)] = struct{}{}
+		}
+	}
+
+	p.mu.Lock()
+	p.lists = lists
+	p.mu.Unlock()
+}
+
+// Seed stores the configured lists, replacing previously stored config entries.
+func (p *Policy) Seed(ctx context.Context) error {
+	if p.store == nil {
+		return nil
+	}
+	if err := p.store.ReplaceCurationEntries(ctx, sourceConfig, p.config.entries()); err != nil {
+		return fmt.Errorf("storing configured curation lists: %w", err)
+	}
+	return nil
+}
+
+// Load reloads the lists from the store.
+func (p *Policy) Load(ctx context.Context) error {
+	if p.store == nil {
+		return nil
+	}
+
+	entries, err := p.store.GetCurationEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("loading curation lists: %w", err)
+	}
+	p.set(entries)
+
+	p.mu.RLock()
+	log.Info().
+		Int("allow_pools", len(p.lists[listAllowPools])).
+		Int("deny_pools", len(p.lists[listDenyPools])).
+		Int("allow_tokens", len(p.lists[listAllowTokens])).
+		Int("deny_tokens", len(p.lists[listDenyTokens])).
+		Msg("Loaded curation lists")
+	p.mu.RUnlock()
+	return nil
+}
+
+// contains reports whether address is on a list.
+func (p *Policy) contains(list, address string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.lists[list][strings.ToLower(address)]
+	return ok
+}
+
+// DeniedPool reports whether a pool is denied, directly or by one of its tokens.
+func (p *Policy) DeniedPool(address, token0, token1 string) bool {
+	return p.contains(listDenyPools, address) ||
+		p.contains(listDenyTokens, token0) ||
+		p.contains(listDenyTokens, token1)
+}
+
+// Denied reports whether a pool is denied.
+func (p *Policy) Denied(pool PoolInfo) bool {
+	return p.DeniedPool(pool.Address, pool.Token0, pool.Token1)
+}
+
+// Allowed reports whether a pool must be tracked: it is allowed, directly or by
+// one of its tokens, and not denied.
+func (p *Policy) Allowed(pool PoolInfo) bool {
+	if p.Denied(pool) {
+		return false
+	}
+	return p.contains(listAllowPools, pool.Address) ||
+		p.contains(listAllowTokens, pool.Token0) ||
+		p.contains(listAllowTokens, pool.Token1)
+}
+
+// TokenAllowed reports whether a token is on the token allow list and not denied.
+func (p *Policy) TokenAllowed(token string) bool {
+	return p.contains(listAllowTokens, token) && !p.contains(listDenyTokens, token)
+}
+
+// AllowedPools returns the pools on the pool allow list.
+func (p *Policy) AllowedPools() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	pools := make([]string, 0, len(p.lists[listAllowPools]))
+	for address := range p.lists[listAllowPools] {
+		pools = append(pools, address)
+	}
+	return pools
+}
+
+// Filter returns the pools that aren't denied, in order.
+func (p *Policy) Filter(pools []PoolInfo) []PoolInfo {
+	result := make([]PoolInfo, 0, len(pools))
+	for _, pool := range pools {
+		if !p.Denied(pool) {
+			result = append(result, pool)
+		}
+	}
+	if denied := len(pools) - len(result); denied > 0 {
+		log.Info().Int("denied", denied).Msg("Excluded denied pools")
+	}
+	return result
+}
+
+// splitAllowed splits pools into those that must be tracked and the rest, in order.
+func (p *Policy) splitAllowed(pools []PoolInfo) (allowed, rest []PoolInfo) {
+	for _, pool := range pools {
+		if p.Allowed(pool) {
+			allowed = append(allowed, pool)
+		} else {
+			rest = append(rest, pool)
+		}
+	}
+	return allowed, rest
+}
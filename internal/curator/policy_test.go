@@ -0,0 +1,104 @@
+package curator
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"watcher/internal/persistence"
+)
+
+func TestPolicyDenialsWin(t *testing.T) {
+	p := NewPolicy(nil, CurationLists{
+		AllowPools:  []string{"0xAllowed", "0xboth"},
+		DenyPools:   []string{"0xboth"},
+		AllowTokens: []string{"0xgood"},
+		DenyTokens:  []string{"0xbad"},
+	})
+
+	cases := []struct {
+		pool    PoolInfo
+		allowed bool
+		denied  bool
+	}{
+		{PoolInfo{Address: "0xallowed", Token0: "0xa", Token1: "0xb"}, true, false},
+		{PoolInfo{Address: "0xboth", Token0: "0xa", Token1: "0xb"}, false, true},
+		{PoolInfo{Address: "0xother", Token0: "0xGOOD", Token1: "0xb"}, true, false},
+		{PoolInfo{Address: "0xallowed", Token0: "0xgood", Token1: "0xbad"}, false, true},
+		{PoolInfo{Address: "0xother", Token0: "0xa", Token1: "0xb"}, false, false},
+	}
+	for _, c := range cases {
+		if got := p.Allowed(c.pool); got != c.allowed {
+			t.Errorf("Allowed(%+v) = %v, want %v", c.pool, got, c.allowed)
+		}
+		if got := p.Denied(c.pool); got != c.denied {
+			t.Errorf("Denied(%+v) = %v, want %v", c.pool, got, c.denied)
+		}
+	}
+
+	if !p.TokenAllowed("0xgood") || p.TokenAllowed("0xbad") {
+		t.Error("Expected only 0xgood to be an allowed token")
+	}
+}
+
+func TestPolicyPersistsLists(t *testing.T) {
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "watcher.db"))
+	if err != nil {
+		t.Fatalf("Opening store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	if err := NewPolicy(store, CurationLists{DenyPools: []string{"0xold"}}).Seed(ctx); err != nil {
+		t.Fatalf("Seeding lists: %v", err)
+	}
+	// An entry added to the table by hand
+	if err := store.ReplaceCurationEntries(ctx, "manual", []persistence.CurationEntry{
+		{List: listDenyPools, Address: "0xmanual"},
+	}); err != nil {
+		t.Fatalf("Storing manual entry: %v", err)
+	}
+
+	// On restart the config entries are replaced and manual ones kept
+	p := NewPolicy(store, CurationLists{DenyPools: []string{"0xnew"}})
+	if err := p.Seed(ctx); err != nil {
+		t.Fatalf("Seeding lists: %v", err)
+	}
+	if err := p.Load(ctx); err != nil {
+		t.Fatalf("Loading lists: %v", err)
+	}
+
+	for address, want := range map[string]bool{"0xold": false, "0xnew": true, "0xmanual": true} {
+		if got := p.DeniedPool(address, "0xa", "0xb"); got != want {
+			t.Errorf("DeniedPool(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestSelectPoolsHonoursLists(t *testing.T) {
+	b := NewBootstrap(nil, "", 100, []string{"weth"})
+	policy := NewPolicy(nil, CurationLists{
+		AllowPools: []string{"small"},
+		DenyTokens: []string{"scam"},
+	})
+	b.SetPolicy(policy)
+
+	pools := policy.Filter([]PoolInfo{
+		topologyPool("big", "a", "b", 1000),
+		topologyPool("scammy", "scam", "b", 900),
+		topologyPool("mid", "a", "c", 500),
+		topologyPool("small", "c", "d", 1),
+	})
+
+	selected := b.selectPoolsWithStartTokens(pools, nil, 2)
+
+	var got []string
+	for _, p := range selected {
+		got = append(got, p.Address)
+	}
+	// The allowed pool takes one of the two slots despite its TVL
+	if want := []string{"small", "big"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected selection %v, got %v", want, got)
+	}
+}
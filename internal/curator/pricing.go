@@ -0,0 +1,165 @@
+package curator
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"watcher/pkg/dex/aerodrome"
+)
+
+const (
+	// maxPricingHops bounds how far prices propagate from the anchors.
+	// One hop prices WETH from USDC, the next prices tokens paired with WETH or USDC.
+	maxPricingHops = 3
+
+	// minPricingLiquidityUSD is the minimum value of the priced side of a pool
+	// for it to set its other token's price, so dust pools can't misprice a token.
+	minPricingLiquidityUSD = 10_000
+)
+
+// Pricer values tokens and pools in USD. Prices are anchored on USDC and USDbC
+// at $1 and propagate through pools: WETH is priced from its deepest USDC pool,
+// then each other token from its deepest pool against an already priced token.
+type Pricer struct {
+	anchors map[string]float64
+
+	mu     sync.RWMutex
+	prices map[string]float64
+}
+
+// NewPricer creates a pricer anchored on Base USDC and USDbC.
+func NewPricer() *Pricer {
+	return &Pricer{
+		anchors: map[string]float64{
+			strings.ToLower(aerodrome.USDCAddress.Hex()):  1,
+			strings.ToLower(aerodrome.USDbCAddress.Hex()): 1,
+		},
+		prices: make(map[string]float64),
+	}
+}
+
+// priceCandidate is a token price derived from one pool.
+type priceCandidate struct {
+	price float64
+	depth float64 // USD value of the priced side
+}
+
+// Update recomputes token prices from pools. Tokens that can't be reached from
+// an anchor through pools with enough liquidity are left unpriced.
+func (p *Pricer) Update(pools []PoolInfo, tokens map[string]*TokenInfo) {
+	prices := make(map[string]float64, len(p.anchors))
+	for token, price := range p.anchors {
+		prices[token] = price
+	}
+
+	for hop := 0; hop < maxPricingHops; hop++ {
+		candidates := make(map[string]priceCandidate)
+		for _, pool := range pools {
+			amount0, ok0 := normalizedReserve(pool.Reserve0, tokens[pool.Token0])
+			amount1, ok1 := normalizedReserve(pool.Reserve1, tokens[pool.Token1])
+			if !ok0 || !ok1 {
+				continue
+			}
+
+			price0, priced0 := prices[pool.Token0]
+			price1, priced1 := prices[pool.Token1]
+			switch {
+			case priced0 && !priced1:
+				offerCandidate(candidates, pool.Token1, amount0*price0, amount1)
+			case priced1 && !priced0:
+				offerCandidate(candidates, pool.Token0, amount1*price1, amount0)
+			}
+		}
+
+		if len(candidates) == 0 {
+			break
+		}
+		for token, c := range candidates {
+			prices[token] = c.price
+		}
+	}
+
+	p.mu.Lock()
+	p.prices = prices
+	p.mu.Unlock()
+}
+
+// offerCandidate records the price implied for token by a pool whose priced
+// side is worth depth USD, keeping the deepest pool's price.
+func offerCandidate(candidates map[string]priceCandidate, token string, depth, amount float64) {
+	if depth < minPricingLiquidityUSD || amount <= 0 {
+		return
+	}
+	if c, ok := candidates[token]; ok && c.depth >= depth {
+		return
+	}
+	candidates[token] = priceCandidate{price: depth / amount, depth: depth}
+}
+
+// Price returns a token's USD price.
+func (p *Pricer) Price(token string) (float64, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	price, ok := p.prices[strings.ToLower(token)]
+	return price, ok
+}
+
+// PoolTVL returns a pool's value in USD. A volatile pool holds equal value on
+// both sides, so a pool with one priced token is valued at twice that side.
+// Returns 0 if neither token is priced.
+func (p *Pricer) PoolTVL(pool PoolInfo, tokens map[string]*TokenInfo) float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.poolTVLLocked(pool, tokens)
+}
+
+// AssignTVL sets the TVL of every pool.
+func (p *Pricer) AssignTVL(pools []PoolInfo, tokens map[string]*TokenInfo) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for i := range pools {
+		pools[i].TVL = p.poolTVLLocked(pools[i], tokens)
+	}
+}
+
+func (p *Pricer) poolTVLLocked(pool PoolInfo, tokens map[string]*TokenInfo) float64 {
+	var value float64
+	var sides int
+
+	if price, ok := p.prices[pool.Token0]; ok {
+		if amount, ok := normalizedReserve(pool.Reserve0, tokens[pool.Token0]); ok {
+			value += amount * price
+			sides++
+		}
+	}
+	if price, ok := p.prices[pool.Token1]; ok {
+		if amount, ok := normalizedReserve(pool.Reserve1, tokens[pool.Token1]); ok {
+			value += amount * price
+			sides++
+		}
+	}
+
+	if sides == 1 {
+		return 2 * value
+	}
+	return value
+}
+
+// normalizedReserve converts a raw reserve to whole tokens.
+func normalizedReserve(reserve *big.Int, token *TokenInfo) (float64, bool) {
+	if reserve == nil || token == nil {
+		return 0, false
+	}
+
+	amount, _ := new(big.Float).SetInt(reserve).Float64()
+	amount /= math.Pow10(token.Decimals)
+	if math.IsInf(amount, 0) || math.IsNaN(amount) {
+		return 0, false
+	}
+	return amount, true
+}
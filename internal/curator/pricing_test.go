@@ -0,0 +1,109 @@
+package curator
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"watcher/pkg/dex/aerodrome"
+)
+
+var (
+	testUSDC = strings.ToLower(aerodrome.USDCAddress.Hex())
+	testWETH = strings.ToLower(aerodrome.WETHAddress.Hex())
+	testAERO = "0x00000000000000000000000000000000000000a0"
+	testDust = "0x00000000000000000000000000000000000000d0"
+)
+
+// units returns amount * 10^decimals.
+func units(amount int64, decimals int) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+}
+
+func testTokens() map[string]*TokenInfo {
+	return map[string]*TokenInfo{
+		testUSDC: {Address: testUSDC, Symbol: "USDC", Decimals: 6},
+		testWETH: {Address: testWETH, Symbol: "WETH", Decimals: 18},
+		testAERO: {Address: testAERO, Symbol: "AERO", Decimals: 18},
+		testDust: {Address: testDust, Symbol: "DUST", Decimals: 18},
+	}
+}
+
+func testPool(addr, token0, token1 string, reserve0, reserve1 *big.Int) PoolInfo {
+	return PoolInfo{Address: addr, Token0: token0, Token1: token1, Reserve0: reserve0, Reserve1: reserve1}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func TestPricerPropagatesFromAnchors(t *testing.T) {
+	tokens := testTokens()
+	pools := []PoolInfo{
+		// WETH = $3000
+		testPool("0x01", testWETH, testUSDC, units(100, 18), units(300_000, 6)),
+		// Shallower USDC pool implying WETH = $6000 is ignored
+		testPool("0x02", testWETH, testUSDC, units(1, 18), units(6000, 6)),
+		// AERO = $1.5, priced through WETH
+		testPool("0x03", testAERO, testWETH, units(20_000, 18), units(10, 18)),
+		// Dust pool can't price its token
+		testPool("0x04", testDust, testUSDC, units(1, 18), units(100, 6)),
+	}
+
+	p := NewPricer()
+	p.Update(pools, tokens)
+
+	if price, ok := p.Price(testUSDC); !ok || price != 1 {
+		t.Errorf("Expected USDC at $1, got %v (%v)", price, ok)
+	}
+	if price, ok := p.Price(testWETH); !ok || !approxEqual(price, 3000) {
+		t.Errorf("Expected WETH at $3000, got %v (%v)", price, ok)
+	}
+	if price, ok := p.Price(testAERO); !ok || !approxEqual(price, 1.5) {
+		t.Errorf("Expected AERO at $1.5, got %v (%v)", price, ok)
+	}
+	if price, ok := p.Price(testDust); ok {
+		t.Errorf("Expected DUST unpriced, got %v", price)
+	}
+
+	p.AssignTVL(pools, tokens)
+	if !approxEqual(pools[0].TVL, 600_000) {
+		t.Errorf("Expected WETH/USDC TVL $600000, got %v", pools[0].TVL)
+	}
+	if !approxEqual(pools[2].TVL, 60_000) {
+		t.Errorf("Expected AERO/WETH TVL $60000, got %v", pools[2].TVL)
+	}
+	// Only USDC is priced: valued at twice the USDC side
+	if !approxEqual(pools[3].TVL, 200) {
+		t.Errorf("Expected DUST/USDC TVL $200, got %v", pools[3].TVL)
+	}
+}
+
+func TestPoolTVLUnpriced(t *testing.T) {
+	p := NewPricer()
+	pool := testPool("0x05", testAERO, testDust, units(1000, 18), units(1000, 18))
+
+	if tvl := p.PoolTVL(pool, testTokens()); tvl != 0 {
+		t.Errorf("Expected 0 TVL with no priced token, got %v", tvl)
+	}
+}
+
+func TestSortPoolsByTVL(t *testing.T) {
+	pools := []PoolInfo{
+		{Address: "0x01", TVL: 10},
+		{Address: "0x02", TVL: 300},
+		{Address: "0x03", TVL: 0},
+		{Address: "0x04", TVL: 300},
+		{Address: "0x05", TVL: 20},
+	}
+
+	sortPoolsByTVL(pools)
+
+	want := []string{"0x02", "0x04", "0x05", "0x01", "0x03"}
+	for i, addr := range want {
+		if pools[i].Address != addr {
+			t.Errorf("Position %d: expected %s, got %s", i, addr, pools[i].Address)
+		}
+	}
+}
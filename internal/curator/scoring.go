@@ -0,0 +1,186 @@
+package curator
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"watcher/internal/ingestion"
+	"watcher/internal/persistence"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ScoringWeights weights the components of a pool's curation score.
+type ScoringWeights struct {
+	TVL        float64
+	Syncs      float64
+	Volatility float64
+	Cycles     float64
+}
+
+// DefaultScoringWeights favors TVL, so pools without observed activity rank
+// as they would by TVL alone.
+var DefaultScoringWeights = ScoringWeights{
+	TVL:        0.5,
+	Syncs:      0.2,
+	Volatility: 0.1,
+	Cycles:     0.2,
+}
+
+// IsZero reports whether every weight is zero.
+func (w ScoringWeights) IsZero() bool {
+	return w == ScoringWeights{}
+}
+
+// Scorer ranks pools by a weighted combination of TVL and observed activity:
+// Sync frequency, price volatility and appearances in detected cycles. Each
+// component is normalized to [0, 1] against the largest value among the pools
+// being ranked. TVL, Sync and cycle counts are normalized on a log scale, so
+// one very deep or very busy pool doesn't flatten the rest.
+type Scorer struct {
+	weights  ScoringWeights
+	activity *ingestion.ActivityTracker
+	store    *persistence.Store
+}
+
+// NewScorer creates a pool scorer. Activity comes from the tracker, which may
+// be nil, in which case pools are scored by TVL alone. Counters are persisted
+// to the store if one is given.
+func NewScorer(weights ScoringWeights, activity *ingestion.ActivityTracker, store *persistence.Store) *Scorer {
+	if weights.IsZero() {
+		weights = DefaultScoringWeights
+	}
+	return &Scorer{
+		weights:  weights,
+		activity: activity,
+		store:    store,
+	}
+}
+
+// Score sets the score of every pool.
+func (s *Scorer) Score(pools []PoolInfo) {
+	var all map[string]ingestion.PoolActivity
+	if s.activity != nil {
+		all = s.activity.All(time.Now())
+	}
+
+	var maxTVL, maxVolatility float64
+	var maxSyncs, maxCycles int
+	for _, p := range pools {
+		maxTVL = math.Max(maxTVL, p.TVL)
+		a := all[p.Address]
+		maxSyncs = max(maxSyncs, a.Syncs)
+		maxCycles = max(maxCycles, a.Cycles)
+		maxVolatility = math.Max(maxVolatility, a.Volatility)
+	}
+
+	active := 0
+	for i := range pools {
+		a, ok := all[pools[i].Address]
+		if ok {
+			active++
+		}
+		pools[i].Score = s.weights.TVL*logShare(pools[i].TVL, maxTVL) +
+			s.weights.Syncs*logShare(float64(a.Syncs), float64(maxSyncs)) +
+			s.weights.Volatility*share(a.Volatility, maxVolatility) +
+			s.weights.Cycles*logShare(float64(a.Cycles), float64(maxCycles))
+	}
+
+	log.Debug().
+		Int("pools", len(pools)).
+		Int("active", active).
+		Int("max_syncs", maxSyncs).
+		Int("max_cycles", maxCycles).
+		Float64("max_volatility", maxVolatility).
+		Msg("Scored pools")
+}
+
+// share returns v/top, or 0 if top is 0.
+func share(v, top float64) float64 {
+	if top <= 0 {
+		return 0
+	}
+	return v / top
+}
+
+// logShare returns log(1+v)/log(1+top), or 0 if top is 0.
+func logShare(v, top float64) float64 {
+	if top <= 0 || v <= 0 {
+		return 0
+	}
+	return math.Log1p(v) / math.Log1p(top)
+}
+
+// activityPeriod is the resolution at which activity counters are persisted.
+const activityPeriod = time.Hour
+
+// Save persists the activity counters of every pool active in the window,
+// per period, so they age out after a restart as they would have without it.
+func (s *Scorer) Save(ctx context.Context) {
+	if s.activity == nil || s.store == nil {
+		return
+	}
+
+	counts := s.activity.Counts(time.Now(), activityPeriod)
+	records := make([]persistence.PoolActivityRecord, len(counts))
+	for i, c := range counts {
+		records[i] = persistence.PoolActivityRecord{
+			Address:        c.Pool,
+			PeriodStart:    c.Start,
+			Syncs:          c.Syncs,
+			Swaps:          c.Swaps,
+			Cycles:         c.Cycles,
+			SquaredReturns: c.SquaredReturns,
+			LastSwap:       c.LastSwap,
+		}
+	}
+
+	if err := s.store.SetPoolActivity(ctx, records); err != nil {
+		log.Warn().Err(err).Msg("Failed to persist pool activity")
+		return
+	}
+	log.Debug().Int("periods", len(records)).Msg("Persisted pool activity")
+}
+
+// Load restores persisted activity counters still within the activity window,
+// so scoring after a restart doesn't start from no activity.
+func (s *Scorer) Load(ctx context.Context) {
+	if s.activity == nil || s.store == nil {
+		return
+	}
+
+	records, err := s.store.GetAllPoolActivity(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load pool activity")
+		return
+	}
+
+	cutoff := time.Now().Add(-s.activity.Window())
+	pools := make(map[string]struct{})
+	for _, r := range records {
+		if r.PeriodStart.Add(activityPeriod).Before(cutoff) {
+			continue
+		}
+		s.activity.Restore(ingestion.ActivityCounts{
+			Pool:           r.Address,
+			Start:          r.PeriodStart,
+			Swaps:          r.Swaps,
+			Syncs:          r.Syncs,
+			Cycles:         r.Cycles,
+			SquaredReturns: r.SquaredReturns,
+			LastSwap:       r.LastSwap,
+		})
+		pools[r.Address] = struct{}{}
+	}
+
+	log.Info().Int("pools", len(pools)).Msg("Restored pool activity")
+}
+
+// sortPoolsByScore sorts pools by score descending, in place.
+func sortPoolsByScore(pools []PoolInfo) {
+	sort.SliceStable(pools, func(i, j int) bool {
+		return pools[i].Score > pools[j].Score
+	})
+}
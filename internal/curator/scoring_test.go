@@ -0,0 +1,89 @@
+package curator
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"watcher/internal/ingestion"
+	"watcher/internal/persistence"
+)
+
+// recordActivity records syncs with alternating prices and cycles for a pool.
+func recordActivity(tracker *ingestion.ActivityTracker, pool string, syncs, cycles int) {
+	now := time.Now()
+	for i := 0; i < syncs; i++ {
+		tracker.RecordSync(&ingestion.SyncEvent{
+			PoolAddress: pool,
+			Reserve0:    big.NewInt(1000),
+			Reserve1:    big.NewInt(int64(1000 + 10*(i%2))),
+		}, now)
+	}
+	for i := 0; i < cycles; i++ {
+		tracker.RecordCycle([]string{pool}, now)
+	}
+}
+
+func TestScorerRanksActivePoolsAboveDormant(t *testing.T) {
+	tracker := ingestion.NewActivityTracker(time.Hour)
+	recordActivity(tracker, "0xactive", 50, 5)
+
+	pools := []PoolInfo{
+		{Address: "0xdormant", TVL: 1_000_000},
+		{Address: "0xactive", TVL: 100_000},
+		{Address: "0xsmall", TVL: 1_000},
+	}
+
+	NewScorer(ScoringWeights{}, tracker, nil).Score(pools)
+	sortPoolsByScore(pools)
+	if pools[0].Address != "0xactive" || pools[1].Address != "0xdormant" || pools[2].Address != "0xsmall" {
+		t.Errorf("Expected active, dormant, small; got %s, %s, %s", pools[0].Address, pools[1].Address, pools[2].Address)
+	}
+
+	// With TVL weight only, the ranking is by TVL
+	NewScorer(ScoringWeights{TVL: 1}, tracker, nil).Score(pools)
+	sortPoolsByScore(pools)
+	if pools[0].Address != "0xdormant" || pools[1].Address != "0xactive" {
+		t.Errorf("Expected dormant ranked first by TVL, got %s", pools[0].Address)
+	}
+}
+
+func TestScorerPersistsActivity(t *testing.T) {
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "watcher.db"))
+	if err != nil {
+		t.Fatalf("Opening store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	tracker := ingestion.NewActivityTracker(time.Hour)
+	recordActivity(tracker, "0xactive", 3, 2)
+	NewScorer(ScoringWeights{}, tracker, store).Save(ctx)
+	saved, err := store.GetAllPoolActivity(ctx)
+	if err != nil || len(saved) == 0 {
+		t.Fatalf("Expected saved activity, got %+v (%v)", saved, err)
+	}
+
+	// A restart starts from an empty tracker
+	restored := ingestion.NewActivityTracker(time.Hour)
+	NewScorer(ScoringWeights{}, restored, store).Load(ctx)
+
+	activity, ok := restored.Activity("0xactive", time.Now())
+	if !ok {
+		t.Fatal("Expected restored activity")
+	}
+	want, _ := tracker.Activity("0xactive", time.Now())
+	if activity.Syncs != 3 || activity.Cycles != 2 || activity.Volatility != want.Volatility {
+		t.Errorf("Expected 3 syncs, 2 cycles, volatility %v; got %d, %d, %v",
+			want.Volatility, activity.Syncs, activity.Cycles, activity.Volatility)
+	}
+
+	// Saving restored activity keeps its period, so it still ages out on time
+	NewScorer(ScoringWeights{}, restored, store).Save(ctx)
+	resaved, err := store.GetAllPoolActivity(ctx)
+	if err != nil || len(resaved) != len(saved) || !resaved[0].PeriodStart.Equal(saved[0].PeriodStart) {
+		t.Errorf("Expected the restored activity saved at its period %v, got %+v (%v)", saved[0].PeriodStart, resaved, err)
+	}
+}
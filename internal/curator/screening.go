@@ -0,0 +1,551 @@
+package curator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"watcher/internal/persistence"
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// TokenClass is a token's screening classification.
+type TokenClass string
+
+const (
+	TokenNormal        TokenClass = "normal"
+	TokenFeeOnTransfer TokenClass = "fee_on_transfer"
+	TokenBlocked       TokenClass = "blocked"
+
+	// TokenUnscreenable is a token that couldn't be probed, e.g. because its
+	// deepest pool is too shallow. Its pools are kept as if it were normal,
+	// and it is screened again after unscreenableRetry.
+	TokenUnscreenable TokenClass = "unscreenable"
+)
+
+// Bytecode heuristic flags.
+const (
+	flagRebasing   = "rebasing"
+	flagBlacklist  = "blacklist"
+	flagMutableFee = "mutable_fee"
+	flagProxy      = "proxy"
+)
+
+const (
+	// probeAmountDivisor sizes the probe transfer as a fraction of the pool's balance.
+	probeAmountDivisor = 1000
+
+	// minTransferFee is the measured fee below which a token is normal, absorbing
+	// rounding in the token's own accounting.
+	minTransferFee = 0.0001
+
+	// maxTransferFee is the measured fee above which a token is blocked.
+	maxTransferFee = 0.5
+
+	// unscreenableRetry is how long a token that couldn't be probed is left
+	// before it is screened again.
+	unscreenableRetry = time.Hour
+)
+
+// probeReceiver receives probe transfers. A fresh address, so tokens have no
+// special handling for it.
+var probeReceiver = common.BytesToAddress(crypto.Keccak256([]byte("watcher token screening receiver"))[12:])
+
+// heuristicSignatures maps functions found in token bytecode to flags.
+var heuristicSignatures = map[string]string{
+	"rebase(uint256,int256)":     flagRebasing,
+	"rebase(uint256)":            flagRebasing,
+	"rebase()":                   flagRebasing,
+	"blacklist(address)":         flagBlacklist,
+	"addToBlacklist(address)":    flagBlacklist,
+	"setBlacklist(address,bool)": flagBlacklist,
+	"setBot(address,bool)":       flagBlacklist,
+	"setTaxFee(uint256)":         flagMutableFee,
+	"setFee(uint256)":            flagMutableFee,
+	"setFees(uint256,uint256)":   flagMutableFee,
+	"setBuyTax(uint256)":         flagMutableFee,
+	"setSellTax(uint256)":        flagMutableFee,
+}
+
+// heuristicSelectors maps function selectors to flags.
+var heuristicSelectors = func() map[[4]byte]string {
+	selectors := make(map[[4]byte]string, len(heuristicSignatures))
+	for signature, flag := range heuristicSignatures {
+		var selector [4]byte
+		copy(selector[:], crypto.Keccak256([]byte(signature))[:4])
+		selectors[selector] = flag
+	}
+	return selectors
+}()
+
+// TokenSafety is the result of screening a token.
+type TokenSafety struct {
+	Address   string
+	Class     TokenClass
+	Fee       float64 // Transfer fee as a fraction, for fee-on-transfer tokens
+	Reason    string
+	Flags     []string
+	CheckedAt time.Time
+}
+
+// Screener classifies tokens that can't be traded like plain ERC20s, so cycles
+// through them aren't reported as profitable.
+//
+// Each token is simulated with eth_call state overrides: the probe is installed
+// at the token's deepest pool, transfers part of the pool's balance to a probe
+// receiver, which transfers half of it back. This is the buy and sell transfer
+// pair of a swap round trip, and measures the fee taken on each. Bytecode is
+// scanned for rebasing, blacklist and fee-setter functions and for proxies.
+type Screener struct {
+	client  *base.Client
+	store   *persistence.Store
+	trusted map[string]struct{}
+
+	// Tokens on the curation allow list are trusted too
+	policy *Policy
+
+	// Tokens screened concurrently
+	workers int
+
+	mu      sync.RWMutex
+	results map[string]TokenSafety
+	loaded  bool
+}
+
+// NewScreener creates a token screener. Trusted tokens are never screened.
+func NewScreener(client *base.Client, store *persistence.Store, trusted []string) *Screener {
+	trustedSet := make(map[string]struct{}, len(trusted))
+	for _, token := range trusted {
+		trustedSet[strings.ToLower(token)] = struct{}{}
+	}
+
+	return &Screener{
+		client:  client,
+		store:   store,
+		trusted: trustedSet,
+		workers: 1,
+		results: make(map[string]TokenSafety),
+	}
+}
+
+// SetWorkers sets how many tokens are screened concurrently. Requests share
+// the client's rate limit.
+func (s *Screener) SetWorkers(workers int) {
+	s.workers = max(workers, 1)
+}
+
+// SetPolicy trusts the tokens allowed by the curation policy, and keeps pools
+// it allows even if they have a blocked token.
+func (s *Screener) SetPolicy(policy *Policy) {
+	s.policy = policy
+}
+
+// trustedToken reports whether a token is never screened.
+func (s *Screener) trustedToken(token string) bool {
+	if _, ok := s.trusted[token]; ok {
+		return true
+	}
+	return s.policy != nil && s.policy.TokenAllowed(token)
+}
+
+// Screen classifies every token in pools that hasn't been screened yet, using
+// the deepest of the given pools holding it. Tokens whose simulation fails are
+// classified as unscreenable and screened again once that result expires.
+func (s *Screener) Screen(ctx context.Context, pools []PoolInfo) {
+	s.load(ctx)
+
+	// Deepest pool per unscreened token
+	deepest := make(map[string]PoolInfo)
+	for _, pool := range pools {
+		for _, side := range []struct {
+			token   string
+			reserve *big.Int
+		}{{pool.Token0, pool.Reserve0}, {pool.Token1, pool.Reserve1}} {
+			if safety, ok := s.Safety(side.token); ok && !safety.expired() {
+				continue
+			}
+			if s.trustedToken(side.token) {
+				continue
+			}
+			if best, ok := deepest[side.token]; ok && tokenReserve(best, side.token).Cmp(side.reserve) >= 0 {
+				continue
+			}
+			deepest[side.token] = pool
+		}
+	}
+	if len(deepest) == 0 {
+		return
+	}
+
+	tokens := make([]string, 0, len(deepest))
+	for token := range deepest {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	startTime := time.Now()
+	var (
+		mu       sync.Mutex
+		screened []TokenSafety
+	)
+	err := forEachBatch(ctx, len(tokens), 1, s.workers, func(ctx context.Context, start, end int) error {
+		for _, token := range tokens[start:end] {
+			safety, err := s.screenToken(ctx, token, deepest[token])
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				log.Debug().Err(err).Str("token", token).Msg("Token screening failed")
+				safety = TokenSafety{
+					Address:   token,
+					Class:     TokenUnscreenable,
+					Reason:    err.Error(),
+					CheckedAt: time.Now(),
+				}
+			}
+
+			s.mu.Lock()
+			s.results[token] = safety
+			s.mu.Unlock()
+
+			mu.Lock()
+			screened = append(screened, safety)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("Token screening interrupted")
+	}
+
+	counts := make(map[TokenClass]int)
+	for _, safety := range screened {
+		token := safety.Address
+		counts[safety.Class]++
+
+		if s.store != nil {
+			if err := s.store.UpsertTokenSafety(ctx, persistence.TokenSafetyRecord{
+				Address:   safety.Address,
+				Class:     string(safety.Class),
+				Fee:       safety.Fee,
+				Reason:    safety.Reason,
+				Flags:     strings.Join(safety.Flags, ","),
+				CheckedAt: safety.CheckedAt,
+			}); err != nil {
+				log.Warn().Err(err).Str("token", token).Msg("Failed to persist token safety")
+			}
+		}
+
+		if safety.Class != TokenNormal && safety.Class != TokenUnscreenable {
+			log.Info().
+				Str("token", token).
+				Str("class", string(safety.Class)).
+				Float64("fee", safety.Fee).
+				Str("reason", safety.Reason).
+				Strs("flags", safety.Flags).
+				Msg("Token screened")
+		}
+	}
+
+	event := log.Info()
+	if counts[TokenUnscreenable] > 0 {
+		event = log.Warn()
+	}
+	event.
+		Int("screened", len(screened)-counts[TokenUnscreenable]).
+		Int("normal", counts[TokenNormal]).
+		Int("fee_on_transfer", counts[TokenFeeOnTransfer]).
+		Int("blocked", counts[TokenBlocked]).
+		Int("unscreenable", counts[TokenUnscreenable]).
+		Int("unfinished", len(tokens)-len(screened)).
+		Dur("duration", time.Since(startTime)).
+		Msg("Token screening complete")
+}
+
+// load loads persisted classifications once.
+func (s *Screener) load(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loaded || s.store == nil {
+		return
+	}
+
+	records, err := s.store.GetAllTokenSafety(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load token safety")
+		return
+	}
+	for _, r := range records {
+		var flags []string
+		if r.Flags != "" {
+			flags = strings.Split(r.Flags, ",")
+		}
+		s.results[r.Address] = TokenSafety{
+			Address:   r.Address,
+			Class:     TokenClass(r.Class),
+			Fee:       r.Fee,
+			Reason:    r.Reason,
+			Flags:     flags,
+			CheckedAt: r.CheckedAt,
+		}
+	}
+	s.loaded = true
+
+	log.Debug().Int("tokens", len(records)).Msg("Loaded token safety")
+}
+
+// expired reports whether a token's classification is due to be screened again.
+func (t TokenSafety) expired() bool {
+	return t.Class == TokenUnscreenable && time.Since(t.CheckedAt) >= unscreenableRetry
+}
+
+// Safety returns a token's classification, if it has been screened.
+func (s *Screener) Safety(token string) (TokenSafety, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	safety, ok := s.results[strings.ToLower(token)]
+	return safety, ok
+}
+
+// Apply drops pools with a blocked token and sets the transfer fee of the
+// others. A swap through the pool makes one transfer of each token, so each
+// token's transfer fee compounds with the pool's own fee in EffectiveFee.
+func (s *Screener) Apply(pools []PoolInfo) []PoolInfo {
+	result := make([]PoolInfo, 0, len(pools))
+	blocked := 0
+	adjusted := 0
+
+	for _, pool := range pools {
+		safety0 := s.appliedSafety(pool.Token0)
+		safety1 := s.appliedSafety(pool.Token1)
+		allowed := s.policy != nil && s.policy.Allowed(pool)
+		if !allowed && (safety0.Class == TokenBlocked || safety1.Class == TokenBlocked) {
+			blocked++
+			continue
+		}
+
+		// Kept apart from the pool's own fee, which is stored and rescreened
+		pool.TransferFee = 1 - (1-safety0.Fee)*(1-safety1.Fee)
+		if pool.TransferFee > 0 {
+			adjusted++
+		}
+		result = append(result, pool)
+	}
+
+	if blocked > 0 || adjusted > 0 {
+		log.Info().
+			Int("excluded", blocked).
+			Int("fee_adjusted", adjusted).
+			Int("remaining", len(result)).
+			Msg("Applied token screening to pools")
+	}
+
+	return result
+}
+
+// appliedSafety returns the classification applied to a token's pools.
+// Trusted tokens are treated as normal even if they were screened before.
+func (s *Screener) appliedSafety(token string) TokenSafety {
+	if s.trustedToken(strings.ToLower(token)) {
+		return TokenSafety{}
+	}
+	safety, _ := s.Safety(token)
+	return safety
+}
+
+// screenToken classifies a token by its bytecode and a transfer simulation
+// against pool.
+func (s *Screener) screenToken(ctx context.Context, token string, pool PoolInfo) (TokenSafety, error) {
+	tokenAddr := common.HexToAddress(token)
+
+	code, err := s.client.CodeAt(ctx, tokenAddr)
+	if err != nil {
+		return TokenSafety{}, fmt.Errorf("fetching code: %w", err)
+	}
+
+	safety := TokenSafety{
+		Address:   token,
+		Class:     TokenNormal,
+		Flags:     bytecodeFlags(code),
+		CheckedAt: time.Now(),
+	}
+	if len(code) == 0 {
+		safety.Class = TokenBlocked
+		safety.Reason = "no contract code"
+		return safety, nil
+	}
+	for _, flag := range safety.Flags {
+		if flag == flagRebasing {
+			safety.Class = TokenBlocked
+			safety.Reason = "rebasing token"
+			return safety, nil
+		}
+	}
+
+	amount := new(big.Int).Div(tokenReserve(pool, token), big.NewInt(probeAmountDivisor))
+	if amount.Sign() == 0 {
+		return TokenSafety{}, fmt.Errorf("pool %s balance too small to probe", pool.Address)
+	}
+
+	calls, err := transferProbeCalls(tokenAddr, common.HexToAddress(pool.Address), amount)
+	if err != nil {
+		return TokenSafety{}, err
+	}
+	results, err := s.client.RunProbe(ctx, common.HexToAddress(pool.Address), calls, probeReceiver)
+	if err != nil {
+		return TokenSafety{}, err
+	}
+
+	class, fee, reason, err := classifyTransferProbe(results, amount)
+	if err != nil {
+		return TokenSafety{}, err
+	}
+	safety.Class = class
+	safety.Fee = fee
+	safety.Reason = reason
+	return safety, nil
+}
+
+// transferProbeCalls builds the probe program run as the pool: a transfer of
+// amount to the receiver and a transfer of half of it back, each measured by
+// the recipient's balance before and after.
+func transferProbeCalls(token, pool common.Address, amount *big.Int) ([]base.ContractCall, error) {
+	balanceOf := func(holder common.Address) ([]byte, error) {
+		return aerodrome.ERC20ABI.Pack("balanceOf", holder)
+	}
+
+	receiverBalance, err := balanceOf(probeReceiver)
+	if err != nil {
+		return nil, err
+	}
+	poolBalance, err := balanceOf(pool)
+	if err != nil {
+		return nil, err
+	}
+	buy, err := aerodrome.ERC20ABI.Pack("transfer", probeReceiver, amount)
+	if err != nil {
+		return nil, err
+	}
+	sellData, err := aerodrome.ERC20ABI.Pack("transfer", pool, new(big.Int).Div(amount, big.NewInt(2)))
+	if err != nil {
+		return nil, err
+	}
+	sell, err := base.EncodeProbeProgram([]base.ContractCall{{Target: token, CallData: sellData}})
+	if err != nil {
+		return nil, err
+	}
+
+	return []base.ContractCall{
+		{Target: token, CallData: receiverBalance},
+		{Target: token, CallData: buy},
+		{Target: token, CallData: receiverBalance},
+		{Target: token, CallData: poolBalance},
+		{Target: probeReceiver, CallData: sell},
+		{Target: token, CallData: poolBalance},
+	}, nil
+}
+
+// classifyTransferProbe classifies a token from the results of transferProbeCalls.
+func classifyTransferProbe(results []base.ProbeResult, amount *big.Int) (TokenClass, float64, string, error) {
+	if len(results) != 6 {
+		return "", 0, "", fmt.Errorf("expected 6 probe results, got %d", len(results))
+	}
+
+	buy := results[1]
+	if !buy.Success {
+		return TokenBlocked, 0, "transfer from pool reverted", nil
+	}
+	if buy.ReturnSize > 0 && buy.Word.Sign() == 0 {
+		return TokenBlocked, 0, "transfer from pool returned false", nil
+	}
+
+	received := new(big.Int).Sub(results[2].Word, results[0].Word)
+	if received.Sign() <= 0 {
+		return TokenBlocked, 0, "transfer from pool delivered nothing", nil
+	}
+	if received.Cmp(amount) > 0 {
+		return TokenBlocked, 0, "transfer delivered more than sent", nil
+	}
+
+	// The receiver's program reports whether its transfer back succeeded
+	sell := results[4]
+	if !sell.Success || sell.Word.Sign() == 0 {
+		return TokenBlocked, 0, "transfer into pool reverted", nil
+	}
+
+	sent := new(big.Int).Div(amount, big.NewInt(2))
+	returned := new(big.Int).Sub(results[5].Word, results[3].Word)
+	if returned.Sign() <= 0 {
+		return TokenBlocked, 0, "transfer into pool delivered nothing", nil
+	}
+
+	fee := transferFee(amount, received)
+	if sellFee := transferFee(sent, returned); sellFee > fee {
+		fee = sellFee
+	}
+
+	switch {
+	case fee < minTransferFee:
+		return TokenNormal, 0, "", nil
+	case fee > maxTransferFee:
+		return TokenBlocked, fee, fmt.Sprintf("transfer fee %.2f%%", fee*100), nil
+	default:
+		return TokenFeeOnTransfer, fee, fmt.Sprintf("transfer fee %.2f%%", fee*100), nil
+	}
+}
+
+// transferFee returns the fraction of sent that didn't arrive.
+func transferFee(sent, received *big.Int) float64 {
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(received), new(big.Float).SetInt(sent)).Float64()
+	return 1 - ratio
+}
+
+// bytecodeFlags scans bytecode for PUSH4 selectors of suspicious functions and
+// for DELEGATECALL, which marks an upgradable proxy.
+func bytecodeFlags(code []byte) []string {
+	flagSet := make(map[string]struct{})
+	for i := 0; i < len(code); i++ {
+		op := code[i]
+		switch {
+		case op == 0x63: // PUSH4
+			if i+4 < len(code) {
+				var selector [4]byte
+				copy(selector[:], code[i+1:i+5])
+				if flag, ok := heuristicSelectors[selector]; ok {
+					flagSet[flag] = struct{}{}
+				}
+			}
+			i += 4
+		case op >= 0x60 && op <= 0x7f: // PUSH1..PUSH32
+			i += int(op - 0x5f)
+		case op == 0xf4: // DELEGATECALL
+			flagSet[flagProxy] = struct{}{}
+		}
+	}
+
+	flags := make([]string, 0, len(flagSet))
+	for flag := range flagSet {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// tokenReserve returns a pool's reserve of token.
+func tokenReserve(pool PoolInfo, token string) *big.Int {
+	if pool.Token0 == token {
+		return pool.Reserve0
+	}
+	return pool.Reserve1
+}
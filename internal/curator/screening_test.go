@@ -0,0 +1,197 @@
+package curator
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"watcher/internal/mocknode"
+	"watcher/internal/persistence"
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	screenNormal   = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	screenFOT      = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+	screenHoneypot = common.HexToAddress("0x00000000000000000000000000000000000000c3")
+	screenRebasing = common.HexToAddress("0x00000000000000000000000000000000000000c4")
+)
+
+// codeWithSelectors returns bytecode pushing each function's selector.
+func codeWithSelectors(signatures ...string) []byte {
+	var code []byte
+	for _, signature := range signatures {
+		code = append(code, 0x63)
+		code = append(code, crypto.Keccak256([]byte(signature))[:4]...)
+		code = append(code, 0x50) // POP
+	}
+	return code
+}
+
+func TestScreenerClassifiesTokens(t *testing.T) {
+	node := mocknode.New(scanFactory)
+	t.Cleanup(node.Close)
+
+	node.AddToken(mocknode.Token{Address: aerodrome.WETHAddress, Symbol: "WETH", Decimals: 18})
+	node.AddToken(mocknode.Token{Address: screenNormal, Symbol: "NORM", Decimals: 18})
+	node.AddToken(mocknode.Token{Address: screenFOT, Symbol: "FOT", Decimals: 18, TransferFee: 0.05})
+	node.AddToken(mocknode.Token{Address: screenHoneypot, Symbol: "HONEY", Decimals: 18, Honeypot: true})
+	node.AddToken(mocknode.Token{
+		Address: screenRebasing, Symbol: "REB", Decimals: 18,
+		Code: codeWithSelectors("rebase(uint256,int256)"),
+	})
+
+	var pools []PoolInfo
+	for i, token := range []common.Address{screenNormal, screenFOT, screenHoneypot, screenRebasing} {
+		pool := mocknode.Pool{
+			Address: common.BigToAddress(big.NewInt(int64(0xd01 + i))), Token0: token, Token1: aerodrome.WETHAddress,
+			Reserve0: units(1_000_000, 18), Reserve1: units(100, 18),
+		}
+		node.AddPool(pool)
+		pools = append(pools, PoolInfo{
+			Address: lower(pool.Address), Token0: lower(token), Token1: lower(aerodrome.WETHAddress),
+			Reserve0: pool.Reserve0, Reserve1: pool.Reserve1, Fee: volatilePoolFee,
+		})
+	}
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "watcher.db"))
+	if err != nil {
+		t.Fatalf("Opening store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	screener := NewScreener(client, store, []string{aerodrome.WETHAddress.Hex()})
+	screener.SetWorkers(2)
+	screener.Screen(ctx, pools)
+
+	tests := []struct {
+		token common.Address
+		class TokenClass
+		fee   float64
+	}{
+		{screenNormal, TokenNormal, 0},
+		{screenFOT, TokenFeeOnTransfer, 0.05},
+		{screenHoneypot, TokenBlocked, 0},
+		{screenRebasing, TokenBlocked, 0},
+	}
+	for _, tt := range tests {
+		safety, ok := screener.Safety(lower(tt.token))
+		if !ok {
+			t.Errorf("Expected %s to be screened", tt.token.Hex())
+			continue
+		}
+		if safety.Class != tt.class || math.Abs(safety.Fee-tt.fee) > 1e-6 {
+			t.Errorf("%s: expected %s with fee %v, got %s with fee %v (%s)",
+				tt.token.Hex(), tt.class, tt.fee, safety.Class, safety.Fee, safety.Reason)
+		}
+	}
+	if _, ok := screener.Safety(lower(aerodrome.WETHAddress)); ok {
+		t.Error("Expected the trusted token not to be screened")
+	}
+
+	applied := screener.Apply(pools)
+	if len(applied) != 2 {
+		t.Fatalf("Expected 2 pools after excluding blocked tokens, got %d", len(applied))
+	}
+	if applied[0].EffectiveFee() != volatilePoolFee {
+		t.Errorf("Expected the normal pool fee %v, got %v", volatilePoolFee, applied[0].EffectiveFee())
+	}
+	if want := 1 - (1-volatilePoolFee)*0.95; math.Abs(applied[1].EffectiveFee()-want) > 1e-6 || applied[1].Fee != volatilePoolFee {
+		t.Errorf("Expected the fee-on-transfer pool fee %v over its own fee, got %v (%v)", want, applied[1].EffectiveFee(), applied[1].Fee)
+	}
+
+	// The transfer fee composes with the pool's own fee, and screening again doesn't compound it
+	pools[1].Fee = 0.01
+	reapplied := screener.Apply(screener.Apply(pools))
+	if want := 1 - 0.99*0.95; math.Abs(reapplied[1].EffectiveFee()-want) > 1e-6 {
+		t.Errorf("Expected the fee-on-transfer fee over a 1%% pool fee %v, got %v", want, reapplied[1].EffectiveFee())
+	}
+
+	// Classifications are persisted and reloaded
+	reloaded := NewScreener(client, store, nil)
+	reloaded.load(ctx)
+	if safety, ok := reloaded.Safety(lower(screenFOT)); !ok || safety.Class != TokenFeeOnTransfer {
+		t.Errorf("Expected the persisted fee-on-transfer class, got %+v (%v)", safety, ok)
+	}
+}
+
+func TestScreenerRetriesUnscreenableTokensAfterExpiry(t *testing.T) {
+	node := mocknode.New(scanFactory)
+	t.Cleanup(node.Close)
+
+	node.AddToken(mocknode.Token{Address: aerodrome.WETHAddress, Symbol: "WETH", Decimals: 18})
+	node.AddToken(mocknode.Token{Address: screenNormal, Symbol: "NORM", Decimals: 18})
+	shallow := mocknode.Pool{
+		Address: common.BigToAddress(big.NewInt(0xd11)), Token0: screenNormal, Token1: aerodrome.WETHAddress,
+		Reserve0: big.NewInt(10), Reserve1: units(1, 18),
+	}
+	node.AddPool(shallow)
+	pools := []PoolInfo{{
+		Address: lower(shallow.Address), Token0: lower(screenNormal), Token1: lower(aerodrome.WETHAddress),
+		Reserve0: shallow.Reserve0, Reserve1: shallow.Reserve1, Fee: volatilePoolFee,
+	}}
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	ctx := context.Background()
+	screener := NewScreener(client, nil, []string{aerodrome.WETHAddress.Hex()})
+	screener.Screen(ctx, pools)
+
+	safety, ok := screener.Safety(lower(screenNormal))
+	if !ok || safety.Class != TokenUnscreenable {
+		t.Fatalf("Expected the token of a pool too shallow to probe to be unscreenable, got %+v", safety)
+	}
+	if applied := screener.Apply(pools); len(applied) != 1 || applied[0].TransferFee != 0 {
+		t.Errorf("Expected the pool kept without a transfer fee, got %+v", applied)
+	}
+
+	// Not screened again until the result expires
+	calls := node.Calls("eth_getCode")
+	screener.Screen(ctx, pools)
+	if got := node.Calls("eth_getCode") - calls; got != 0 {
+		t.Errorf("Expected no screening before the result expires, got %d eth_getCode calls", got)
+	}
+
+	safety.CheckedAt = time.Now().Add(-unscreenableRetry)
+	screener.results[lower(screenNormal)] = safety
+	screener.Screen(ctx, pools)
+	if got := node.Calls("eth_getCode") - calls; got != 1 {
+		t.Errorf("Expected the expired result to be screened again, got %d eth_getCode calls", got)
+	}
+}
+
+func TestBytecodeFlags(t *testing.T) {
+	code := codeWithSelectors("blacklist(address)", "setTaxFee(uint256)")
+	code = append(code, 0xf4)                         // DELEGATECALL
+	code = append(code, 0x62, 0xf4, 0x00, 0x00, 0x50) // PUSH3 data is not an opcode
+
+	want := []string{flagBlacklist, flagMutableFee, flagProxy}
+	if got := bytecodeFlags(code); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected flags %v, got %v", want, got)
+	}
+
+	if got := bytecodeFlags([]byte{0x61, 0xf4, 0x00}); len(got) != 0 {
+		t.Errorf("Expected no flags from push data, got %v", got)
+	}
+}
@@ -0,0 +1,184 @@
+package curator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"watcher/internal/graph"
+	"watcher/internal/ingestion"
+	"watcher/internal/persistence"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stateSaveTimeout bounds the final save on shutdown.
+const stateSaveTimeout = 5 * time.Second
+
+// StateSaver persists the graph and the last processed block, so a restart
+// can resume from them instead of bootstrapping.
+type StateSaver struct {
+	store        *persistence.Store
+	graphManager *graph.Manager
+	ingestion    *ingestion.Service
+	interval     time.Duration
+}
+
+// NewStateSaver creates a saver that saves the graph every interval and on shutdown.
+func NewStateSaver(store *persistence.Store, graphManager *graph.Manager, ingestionSvc *ingestion.Service, interval time.Duration) *StateSaver {
+	return &StateSaver{
+		store:        store,
+		graphManager: graphManager,
+		ingestion:    ingestionSvc,
+		interval:     interval,
+	}
+}
+
+// Save persists the graph as of the last processed block. Nothing is saved
+// before a block has been processed.
+func (s *StateSaver) Save(ctx context.Context) error {
+	// Read before the graph: every event up to this block has been handed to
+	// the graph, and pools updated since then keep their positions, so
+	// replaying the blocks after it on restart doesn't apply an event twice
+	block := s.ingestion.LastProcessedBlock()
+	if block == 0 {
+		return nil
+	}
+
+	// Events of processed blocks may still be pending; the state leaves them out
+	s.graphManager.Flush()
+	snapshot, positions := s.graphManager.State()
+
+	state := persistence.GraphState{
+		Block:   block,
+		SavedAt: time.Now(),
+		Pools:   make([]persistence.GraphPoolRecord, 0, len(snapshot.Pools)),
+		Tokens:  make([]persistence.TokenRecord, 0, len(snapshot.Tokens)),
+	}
+	for _, p := range snapshot.Pools {
+		pos := positions[p.Address]
+		state.Pools = append(state.Pools, persistence.GraphPoolRecord{
+			Address:  p.Address,
+			Token0:   p.Token0,
+			Token1:   p.Token1,
+			Reserve0: p.Reserve0.String(),
+			Reserve1: p.Reserve1.String(),
+			Fee:      p.Fee,
+			IsStable: p.IsStable,
+			Block:    pos.Block,
+			LogIndex: pos.LogIndex,
+		})
+	}
+	for _, t := range snapshot.Tokens {
+		state.Tokens = append(state.Tokens, persistence.TokenRecord{
+			Address:  t.Address,
+			Symbol:   t.Symbol,
+			Decimals: t.Decimals,
+		})
+	}
+
+	if err := s.store.SaveGraphState(ctx, state); err != nil {
+		return fmt.Errorf("saving graph state: %w", err)
+	}
+	log.Debug().
+		Uint64("block", block).
+		Int("pools", len(state.Pools)).
+		Msg("Saved graph state")
+	return nil
+}
+
+// Run saves the graph every interval until ctx is cancelled, then once more.
+func (s *StateSaver) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			saveCtx, cancel := context.WithTimeout(context.Background(), stateSaveTimeout)
+			defer cancel()
+			if err := s.Save(saveCtx); err != nil {
+				log.Warn().Err(err).Msg("Failed to save graph state on shutdown")
+			} else {
+				log.Info().Uint64("block", s.ingestion.LastProcessedBlock()).Msg("Saved graph state for warm restart")
+			}
+			return nil
+		case <-ticker.C:
+			if err := s.Save(ctx); err != nil {
+				log.Warn().Err(err).Msg("Failed to save graph state")
+			}
+		}
+	}
+}
+
+// warmStart loads the saved graph if it is at most maxGap blocks behind head.
+// Reconciliation then replays the blocks after it. Returns false, leaving the
+// graph empty, if there is no usable saved state.
+func (c *Curator) warmStart(ctx context.Context, head uint64) (bool, error) {
+	state, err := c.store.LoadGraphState(ctx)
+	if err != nil {
+		return false, err
+	}
+	if state == nil || len(state.Pools) == 0 {
+		log.Info().Msg("No saved graph state, performing bootstrap")
+		return false, nil
+	}
+	if state.Block > head {
+		log.Warn().
+			Uint64("saved_block", state.Block).
+			Uint64("head", head).
+			Msg("Saved graph state is ahead of the chain head, performing bootstrap")
+		return false, nil
+	}
+	if gap := head - state.Block; gap > c.config.WarmRestartMaxGap {
+		log.Info().
+			Uint64("saved_block", state.Block).
+			Uint64("gap_blocks", gap).
+			Uint64("max_gap_blocks", c.config.WarmRestartMaxGap).
+			Msg("Saved graph state is too old, performing bootstrap")
+		return false, nil
+	}
+
+	pools := make([]PoolInfo, 0, len(state.Pools))
+	positions := make(map[string]graph.PoolPosition, len(state.Pools))
+	for _, p := range state.Pools {
+		reserve0, ok0 := new(big.Int).SetString(p.Reserve0, 10)
+		reserve1, ok1 := new(big.Int).SetString(p.Reserve1, 10)
+		if !ok0 || !ok1 {
+			return false, fmt.Errorf("invalid saved reserves for pool %s", p.Address)
+		}
+		pools = append(pools, PoolInfo{
+			Address:  p.Address,
+			Token0:   p.Token0,
+			Token1:   p.Token1,
+			Reserve0: reserve0,
+			Reserve1: reserve1,
+			Fee:      p.Fee,
+			IsStable: p.IsStable,
+		})
+		if p.Block > 0 {
+			positions[p.Address] = graph.PoolPosition{Block: p.Block, LogIndex: p.LogIndex}
+		}
+	}
+	tokens := make(map[string]*TokenInfo, len(state.Tokens))
+	for _, t := range state.Tokens {
+		tokens[t.Address] = &TokenInfo{
+			Address:  t.Address,
+			Symbol:   t.Symbol,
+			Decimals: t.Decimals,
+		}
+	}
+
+	c.graphManager.AddPoolBatch(ConvertToGraphPools(pools), ConvertToGraphTokens(tokens))
+	c.graphManager.RestorePositions(positions)
+	c.pricer.Update(pools, tokens)
+	c.bootstrapBlock = state.Block
+
+	log.Info().
+		Uint64("saved_block", state.Block).
+		Uint64("gap_blocks", head-state.Block).
+		Time("saved_at", state.SavedAt).
+		Msg("Loaded saved graph state")
+	return true, nil
+}
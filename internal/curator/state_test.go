@@ -0,0 +1,201 @@
+package curator
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"watcher/internal/graph"
+	"watcher/internal/ingestion"
+	"watcher/internal/mocknode"
+	"watcher/internal/persistence"
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+)
+
+// newWarmCurator creates a curator with its own graph and ingestion service,
+// as a process starting against node and store would.
+func newWarmCurator(t *testing.T, node *mocknode.Node, store *persistence.Store) (*Curator, *graph.Manager, *ingestion.Service) {
+	t.Helper()
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	graphManager := graph.NewManager(nil)
+	t.Cleanup(graphManager.Close)
+	ingestionSvc := ingestion.NewService(nil, scanFactory.Hex(), graphManager, nil)
+
+	c := NewCurator(Config{
+		FactoryAddress:     scanFactory.Hex(),
+		TopPoolsCount:      10,
+		BootstrapBatchSize: 100,
+		StartTokens:        []string{aerodrome.WETHAddress.Hex()},
+		WarmRestartMaxGap:  10,
+		RecordReserves:     true,
+	}, client, store, graphManager, nil, ingestionSvc)
+	return c, graphManager, ingestionSvc
+}
+
+func TestBootstrapWarmStart(t *testing.T) {
+	ctx := context.Background()
+	node, _, store := newScanBootstrap(t)
+
+	// Nothing saved yet: full bootstrap at the head
+	first, firstGraph, firstIngestion := newWarmCurator(t, node, store)
+	if err := first.Bootstrap(ctx); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	savedBlock := node.Head()
+	if first.BootstrapBlock() != savedBlock || first.PoolCount() != 2 {
+		t.Fatalf("Expected a full bootstrap of 2 pools at block %d, got %d pools at block %d",
+			savedBlock, first.PoolCount(), first.BootstrapBlock())
+	}
+
+	// The bootstrap reserves are the end of the bootstrap block in reserve history
+	history, err := store.GetReservesAtBlock(ctx, []string{lower(scanPool0)}, savedBlock)
+	if err != nil || history[lower(scanPool0)].Reserve1 != units(300_000, 6).String() {
+		t.Errorf("Expected the bootstrap reserves in reserve history, got %+v (%v)", history, err)
+	}
+
+	// An update applied after bootstrap is saved with its position
+	firstIngestion.SetReconciler(ingestion.NewReconciler(first.Client(), firstGraph), savedBlock)
+	firstGraph.ProcessUpdate(graph.ReserveUpdate{
+		PoolAddress: lower(scanPool0), Reserve0: units(90, 18), Reserve1: units(330_000, 6),
+		BlockNumber: savedBlock, LogIndex: 3,
+	})
+	firstGraph.Flush()
+	if err := NewStateSaver(store, firstGraph, firstIngestion, 0).Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Within the gap: the saved graph is loaded without reading pools
+	node.MineBlock()
+	node.MineBlock()
+	calls := node.Calls("eth_call")
+	warm, warmGraph, warmIngestion := newWarmCurator(t, node, store)
+	if err := warm.Bootstrap(ctx); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if warm.BootstrapBlock() != savedBlock {
+		t.Errorf("Expected reconciliation to start after the saved block %d, got %d", savedBlock, warm.BootstrapBlock())
+	}
+	if got := node.Calls("eth_call") - calls; got != 0 {
+		t.Errorf("Expected no pool reads on a warm restart, got %d eth_call calls", got)
+	}
+	if warm.PoolCount() != 2 || !warmIngestion.IsTracked(lower(scanPool1)) {
+		t.Errorf("Expected the 2 saved pools to be tracked, got %d", warm.PoolCount())
+	}
+
+	// Replaying the saved block doesn't apply its updates twice
+	warmGraph.ProcessUpdate(graph.ReserveUpdate{
+		PoolAddress: lower(scanPool0), Reserve0: units(1, 18), Reserve1: units(1, 6),
+		BlockNumber: savedBlock, LogIndex: 1,
+	})
+	warmGraph.Flush()
+	if got := warmGraph.GetCurrentSnapshot(0).Pools[lower(scanPool0)].Reserve1; got.Cmp(units(330_000, 6)) != 0 {
+		t.Errorf("Expected the saved reserves, got reserve1 %s", got)
+	}
+
+	// Beyond the gap: full bootstrap at the head
+	for range 10 {
+		node.MineBlock()
+	}
+	cold, _, _ := newWarmCurator(t, node, store)
+	if err := cold.Bootstrap(ctx); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if cold.BootstrapBlock() != node.Head() {
+		t.Errorf("Expected a full bootstrap at block %d, got %d", node.Head(), cold.BootstrapBlock())
+	}
+	reserves := cold.graphManager.GetCurrentSnapshot(0).Pools[lower(scanPool0)].Reserve1
+	if want := units(300_000, 6); reserves.Cmp(want) != 0 {
+		t.Errorf("Expected reserves read from the node, got reserve1 %s", reserves)
+	}
+}
+
+func TestStateSaverIncludesPendingUpdates(t *testing.T) {
+	ctx := context.Background()
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "watcher.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	graphManager := graph.NewManager(nil)
+	t.Cleanup(graphManager.Close)
+	graphManager.AddPool(
+		graph.PoolState{Address: "0xpool", Token0: "0xt0", Token1: "0xt1", Reserve0: big.NewInt(10), Reserve1: big.NewInt(20), Fee: 0.0005, IsStable: true},
+		graph.TokenInfo{Address: "0xt0", Symbol: "T0", Decimals: 18},
+		graph.TokenInfo{Address: "0xt1", Symbol: "T1", Decimals: 18},
+	)
+	ingestionSvc := ingestion.NewService(nil, "", graphManager, nil)
+	ingestionSvc.SetReconciler(ingestion.NewReconciler(nil, graphManager), 100)
+
+	// Handed to the graph for a processed block, but not yet applied
+	graphManager.ProcessUpdate(graph.ReserveUpdate{
+		PoolAddress: "0xpool", Reserve0: big.NewInt(11), Reserve1: big.NewInt(19), BlockNumber: 100, LogIndex: 2,
+	})
+	if err := NewStateSaver(store, graphManager, ingestionSvc, 0).Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	state, err := store.LoadGraphState(ctx)
+	if err != nil || state == nil || len(state.Pools) != 1 {
+		t.Fatalf("Expected the saved pool, got %+v (%v)", state, err)
+	}
+	pool := state.Pools[0]
+	if state.Block != 100 || pool.Reserve0 != "11" || pool.Block != 100 || pool.LogIndex != 2 {
+		t.Errorf("Expected the pending update saved at block 100, got block %d and %+v", state.Block, pool)
+	}
+	if !pool.IsStable {
+		t.Errorf("Expected the pool saved as stable, got %+v", pool)
+	}
+}
+
+func TestBootstrapRepinsWhenStateIsPruned(t *testing.T) {
+	ctx := context.Background()
+
+	// The pinned block's state is pruned by the first read: re-pinned to the new head
+	node, _, store := newScanBootstrap(t)
+	node.SetStateRetention(1)
+	var prune sync.Once
+	node.SetCallHook(func(method string) {
+		if method == "eth_call" {
+			prune.Do(func() { node.MineBlock() })
+		}
+	})
+	c, _, _ := newWarmCurator(t, node, store)
+	if err := c.Bootstrap(ctx); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if c.BootstrapBlock() != node.Head() || c.PoolCount() != 2 {
+		t.Errorf("Expected 2 pools bootstrapped at the new head %d, got %d pools at block %d",
+			node.Head(), c.PoolCount(), c.BootstrapBlock())
+	}
+
+	// Pruned on every read: bootstrapped unpinned from the last pinned block
+	node, _, store = newScanBootstrap(t)
+	node.SetStateRetention(1)
+	node.SetCallHook(func(method string) {
+		if method == "eth_call" {
+			node.MineBlock()
+		}
+	})
+	c, _, _ = newWarmCurator(t, node, store)
+	if err := c.Bootstrap(ctx); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if c.PoolCount() != 2 {
+		t.Errorf("Expected 2 pools bootstrapped unpinned, got %d", c.PoolCount())
+	}
+	if want := uint64(mocknode.GenesisBlock + maxBootstrapRepins); c.BootstrapBlock() != want {
+		t.Errorf("Expected reconciliation from the last pinned block %d, got %d", want, c.BootstrapBlock())
+	}
+	if history, _ := store.GetReservesAtBlock(ctx, []string{lower(scanPool0)}, node.Head()); len(history) != 0 {
+		t.Errorf("Expected unpinned reserves kept out of reserve history, got %+v", history)
+	}
+}
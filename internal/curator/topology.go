@@ -0,0 +1,384 @@
+package curator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxSelectionRounds bounds how often selection re-checks reachability after
+// replacing stranded pools.
+const maxSelectionRounds = 5
+
+// poolGraph is the undirected multigraph of tokens with pools as edges, used
+// to find the pools that can take part in an arbitrage cycle.
+type poolGraph struct {
+	pools  []PoolInfo
+	ends   [][2]int // Token IDs of each pool
+	tokens map[string]int
+	adj    [][]int // Pool indices by token ID
+}
+
+func newPoolGraph(pools []PoolInfo) *poolGraph {
+	g := &poolGraph{
+		pools:  pools,
+		ends:   make([][2]int, len(pools)),
+		tokens: make(map[string]int),
+	}
+	for i, p := range pools {
+		u, v := g.tokenID(p.Token0), g.tokenID(p.Token1)
+		g.ends[i] = [2]int{u, v}
+		g.adj[u] = append(g.adj[u], i)
+		g.adj[v] = append(g.adj[v], i)
+	}
+	return g
+}
+
+// tokenID returns a token's ID, adding it if needed.
+func (g *poolGraph) tokenID(token string) int {
+	token = strings.ToLower(token)
+	id, ok := g.tokens[token]
+	if !ok {
+		id = len(g.adj)
+		g.tokens[token] = id
+		g.adj = append(g.adj, nil)
+	}
+	return id
+}
+
+// other returns the token of pool e at the other end from token u.
+func (g *poolGraph) other(e, u int) int {
+	if g.ends[e][0] == u {
+		return g.ends[e][1]
+	}
+	return g.ends[e][0]
+}
+
+// startIDs returns the IDs of the start tokens present in the graph.
+func (g *poolGraph) startIDs(startTokens map[string]struct{}) []int {
+	var ids []int
+	for token := range startTokens {
+		if id, ok := g.tokens[token]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// blocks returns the biconnected components of the graph as lists of pool
+// indices (Hopcroft-Tarjan). A pool is on a simple cycle through a token
+// exactly when its block contains the token and has more than one pool.
+func (g *poolGraph) blocks() [][]int {
+	n := len(g.adj)
+	disc := make([]int, n)
+	low := make([]int, n)
+	for i := range disc {
+		disc[i] = -1
+	}
+
+	var result [][]int
+	var stack []int
+	clock := 0
+
+	var visit func(u, parent int)
+	visit = func(u, parent int) {
+		disc[u] = clock
+		low[u] = clock
+		clock++
+
+		for _, e := range g.adj[u] {
+			if e == parent {
+				continue
+			}
+			v := g.other(e, u)
+			if disc[v] == -1 {
+				stack = append(stack, e)
+				visit(v, e)
+				low[u] = min(low[u], low[v])
+				if low[v] >= disc[u] {
+					// u separates the block below it; pop the block's pools
+					var block []int
+					for {
+						top := stack[len(stack)-1]
+						stack = stack[:len(stack)-1]
+						block = append(block, top)
+						if top == e {
+							break
+						}
+					}
+					result = append(result, block)
+				}
+			} else if disc[v] < disc[u] {
+				// Back edge, including a parallel pool to the parent
+				stack = append(stack, e)
+				low[u] = min(low[u], disc[v])
+			}
+		}
+	}
+
+	for u := range g.adj {
+		if disc[u] == -1 {
+			visit(u, -1)
+		}
+	}
+	return result
+}
+
+// cyclePools reports for each pool whether it can be on a cycle of at most
+// maxLen pools through a start token. A pool qualifies when it shares a block
+// with a start token and the distances within the block from the start token
+// to its two tokens, plus the pool itself, fit in maxLen. The distance sum is
+// a lower bound on the shortest such cycle, so no usable pool is dropped.
+func (g *poolGraph) cyclePools(startTokens map[string]struct{}, maxLen int) []bool {
+	usable := make([]bool, len(g.pools))
+	starts := g.startIDs(startTokens)
+
+	for _, block := range g.blocks() {
+		if len(block) < 2 {
+			continue // A bridge is on no cycle
+		}
+
+		// Block adjacency
+		adj := make(map[int][]int)
+		for _, e := range block {
+			adj[g.ends[e][0]] = append(adj[g.ends[e][0]], e)
+			adj[g.ends[e][1]] = append(adj[g.ends[e][1]], e)
+		}
+
+		for _, s := range starts {
+			if _, ok := adj[s]; !ok {
+				continue
+			}
+			dist := map[int]int{s: 0}
+			queue := []int{s}
+			for len(queue) > 0 {
+				u := queue[0]
+				queue = queue[1:]
+				for _, e := range adj[u] {
+					v := g.other(e, u)
+					if _, seen := dist[v]; !seen {
+						dist[v] = dist[u] + 1
+						queue = append(queue, v)
+					}
+				}
+			}
+			for _, e := range block {
+				if dist[g.ends[e][0]]+dist[g.ends[e][1]]+1 <= maxLen {
+					usable[e] = true
+				}
+			}
+		}
+	}
+	return usable
+}
+
+// shortestPath returns the pools of a shortest path of at most maxLen pools
+// from token from to token to, avoiding the given pools and tokens, or false.
+func (g *poolGraph) shortestPath(from, to, maxLen int, avoidPools, avoidTokens map[int]bool) ([]int, bool) {
+	if from == to {
+		return nil, true
+	}
+
+	via := map[int]int{from: -1} // Token -> pool reaching it
+	depth := map[int]int{from: 0}
+	queue := []int{from}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		if depth[u] == maxLen {
+			continue
+		}
+		for _, e := range g.adj[u] {
+			v := g.other(e, u)
+			if avoidPools[e] || (avoidTokens[v] && v != to) {
+				continue
+			}
+			if _, seen := via[v]; seen {
+				continue
+			}
+			via[v] = e
+			depth[v] = depth[u] + 1
+			if v == to {
+				var path []int
+				for t := to; t != from; t = g.other(via[t], t) {
+					path = append(path, via[t])
+				}
+				return path, true
+			}
+			queue = append(queue, v)
+		}
+	}
+	return nil, false
+}
+
+// shortestCycle returns the pools of a short simple cycle of at most maxLen
+// pools through pool e and a start token, or false if none is found. The
+// cycle is the shortest path from the start token to one end of e, e, and the
+// shortest path back from the other end that avoids the first path.
+func (g *poolGraph) shortestCycle(e int, startTokens map[string]struct{}, maxLen int) ([]int, bool) {
+	var best []int
+	for _, s := range g.startIDs(startTokens) {
+		for _, ends := range [][2]int{g.ends[e], {g.ends[e][1], g.ends[e][0]}} {
+			u, v := ends[0], ends[1]
+			out, ok := g.shortestPath(s, u, maxLen-1, map[int]bool{e: true}, nil)
+			if !ok {
+				continue
+			}
+
+			avoidPools := map[int]bool{e: true}
+			avoidTokens := map[int]bool{u: true}
+			for _, p := range out {
+				avoidPools[p] = true
+				avoidTokens[g.ends[p][0]] = true
+				avoidTokens[g.ends[p][1]] = true
+			}
+			delete(avoidTokens, s)
+			if avoidTokens[v] {
+				continue // The path out already passes through v
+			}
+			back, ok := g.shortestPath(v, s, maxLen-1-len(out), avoidPools, avoidTokens)
+			if !ok || len(out)+len(back) == 0 {
+				continue
+			}
+
+			cycle := append(append([]int{e}, out...), back...)
+			if best == nil || len(cycle) < len(best) {
+				best = cycle
+			}
+		}
+	}
+	return best, best != nil
+}
+
+// reachablePools returns the pools that can be on a cycle of at most
+// b.maxPathLength pools through a start token, in their original order.
+func (b *Bootstrap) reachablePools(pools []PoolInfo) []PoolInfo {
+	usable := newPoolGraph(pools).cyclePools(b.startTokens, b.maxPathLength)
+
+	result := make([]PoolInfo, 0, len(pools))
+	for i, p := range pools {
+		if usable[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// closeCycles refines the first selected of the ranked candidates so every
+// selected pool is on a bounded cycle through a start token within the
+// selection. Stranded pools are kept if the bridging pools that close one of
+// their cycles fit in the budget, and take their rank. Otherwise they're
+// replaced by the next ranked candidates. Returns the selection in rank order.
+func (b *Bootstrap) closeCycles(ranked []PoolInfo, selected, budget int) []PoolInfo {
+	candidates := newPoolGraph(ranked)
+
+	// Effective rank; bridging pools move up to the rank of the pool they serve
+	rank := make([]int, len(ranked))
+	chosen := make(map[int]bool, budget)
+	for i := range ranked {
+		rank[i] = i
+		if i < selected {
+			chosen[i] = true
+		}
+	}
+	next := selected
+
+	stranded, bridged := 0, 0
+	for round := 0; round < maxSelectionRounds; round++ {
+		indices := sortedIndices(chosen, rank)
+		usable := newPoolGraph(poolsAt(ranked, indices)).cyclePools(b.startTokens, b.maxPathLength)
+
+		var roundStranded []int
+		for i, idx := range indices {
+			if !usable[i] {
+				roundStranded = append(roundStranded, idx)
+				delete(chosen, idx)
+			}
+		}
+		if len(roundStranded) == 0 {
+			break
+		}
+		stranded += len(roundStranded)
+
+		// Bridge stranded pools, best ranked first
+		free := budget - len(chosen)
+		for _, idx := range roundStranded {
+			if free <= 0 {
+				break
+			}
+			cycle, ok := candidates.shortestCycle(idx, b.startTokens, b.maxPathLength)
+			if !ok {
+				continue
+			}
+			var missing []int
+			for _, p := range cycle {
+				if !chosen[p] {
+					missing = append(missing, p)
+				}
+			}
+			if len(missing) > free {
+				continue
+			}
+			for _, p := range missing {
+				chosen[p] = true
+				rank[p] = min(rank[p], rank[idx])
+				if p != idx {
+					bridged++
+				}
+			}
+			free -= len(missing)
+		}
+
+		// Fill the rest with the next ranked candidates
+		for ; free > 0 && next < len(ranked); next++ {
+			if !chosen[next] {
+				chosen[next] = true
+				free--
+			}
+		}
+	}
+
+	// Drop anything still stranded after the last round
+	indices := sortedIndices(chosen, rank)
+	usable := newPoolGraph(poolsAt(ranked, indices)).cyclePools(b.startTokens, b.maxPathLength)
+	result := make([]PoolInfo, 0, len(indices))
+	for i, idx := range indices {
+		if usable[i] {
+			result = append(result, ranked[idx])
+		}
+	}
+
+	log.Info().
+		Int("stranded", stranded).
+		Int("bridging_pools_added", bridged).
+		Int("selected", len(result)).
+		Msg("Pool selection: closed cycles")
+
+	return result
+}
+
+// sortedIndices returns the chosen indices by effective rank, then index.
+func sortedIndices(chosen map[int]bool, rank []int) []int {
+	indices := make([]int, 0, len(chosen))
+	for idx := range chosen {
+		indices = append(indices, idx)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		if rank[indices[i]] != rank[indices[j]] {
+			return rank[indices[i]] < rank[indices[j]]
+		}
+		return indices[i] < indices[j]
+	})
+	return indices
+}
+
+// poolsAt returns the pools at the given indices.
+func poolsAt(pools []PoolInfo, indices []int) []PoolInfo {
+	result := make([]PoolInfo, len(indices))
+	for i, idx := range indices {
+		result[i] = pools[idx]
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package curator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func topologyPool(address, token0, token1 string, tvl float64) PoolInfo {
+	return PoolInfo{Address: address, Token0: token0, Token1: token1, TVL: tvl}
+}
+
+func TestCyclePools(t *testing.T) {
+	pools := []PoolInfo{
+		// Triangle through WETH
+		topologyPool("tri1", "weth", "usdc", 1),
+		topologyPool("tri2", "usdc", "a", 1),
+		topologyPool("tri3", "a", "weth", 1),
+		// Leaf pool of a token with a single pool
+		topologyPool("leaf", "weth", "l", 1),
+		// Cycle not through a start token, hanging off WETH by a bridge
+		topologyPool("bridge", "weth", "b", 1),
+		topologyPool("far1", "b", "c", 1),
+		topologyPool("far2", "c", "d", 1),
+		topologyPool("far3", "d", "b", 1),
+		// Two pools of one pair form a two-pool cycle
+		topologyPool("pair1", "weth", "p", 1),
+		topologyPool("pair2", "p", "weth", 1),
+		// Five-pool cycle, longer than the bound. The distance bound only rules
+		// out the pool farthest from WETH.
+		topologyPool("long1", "weth", "x1", 1),
+		topologyPool("long2", "x1", "x2", 1),
+		topologyPool("long3", "x2", "x3", 1),
+		topologyPool("long4", "x3", "x4", 1),
+		topologyPool("long5", "x4", "weth", 1),
+	}
+
+	usable := newPoolGraph(pools).cyclePools(map[string]struct{}{"weth": {}}, 4)
+
+	var got []string
+	for i, ok := range usable {
+		if ok {
+			got = append(got, pools[i].Address)
+		}
+	}
+	want := []string{"tri1", "tri2", "tri3", "pair1", "pair2", "long1", "long2", "long4", "long5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected usable pools %v, got %v", want, got)
+	}
+}
+
+func TestShortestCycleIsSimple(t *testing.T) {
+	// The shortest path from WETH to a and to b shares the WETH-hub pool, so
+	// the cycle through a-b must use the other route back
+	pools := []PoolInfo{
+		topologyPool("ab", "a", "b", 1),
+		topologyPool("hub", "weth", "h", 1),
+		topologyPool("ha", "h", "a", 1),
+		topologyPool("hb", "h", "b", 1),
+		topologyPool("wb", "weth", "b", 1),
+	}
+
+	cycle, ok := newPoolGraph(pools).shortestCycle(0, map[string]struct{}{"weth": {}}, 4)
+	if !ok {
+		t.Fatal("Expected a cycle")
+	}
+	var got []string
+	for _, e := range cycle {
+		got = append(got, pools[e].Address)
+	}
+	if want := []string{"ab", "ha", "hub", "wb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected cycle %v, got %v", want, got)
+	}
+
+	if _, ok := newPoolGraph(pools).shortestCycle(0, map[string]struct{}{"weth": {}}, 3); ok {
+		t.Error("Expected no cycle of at most 3 pools")
+	}
+}
+
+func TestSelectPoolsClosesCycles(t *testing.T) {
+	b := NewBootstrap(nil, "", 100, []string{"weth"})
+	b.SetMaxPathLength(3)
+
+	pools := []PoolInfo{
+		topologyPool("wa", "weth", "a", 100),
+		topologyPool("ab", "a", "b", 90),
+		topologyPool("leaf", "weth", "l", 95),
+		topologyPool("wd", "weth", "d", 80),
+		topologyPool("de", "d", "e", 70),
+		topologyPool("ew", "e", "weth", 60),
+		// Low ranked, but closes the cycle through wa and ab
+		topologyPool("bw", "b", "weth", 10),
+	}
+
+	selected := b.selectPoolsWithStartTokens(pools, nil, 3)
+
+	var got []string
+	for _, p := range selected {
+		got = append(got, p.Address)
+	}
+	// The leaf is dropped. The four WETH pools are mandatory but stranded
+	// alone; the budget of four fits one closed triangle, taking the best
+	// ranked stranded pool with its bridging pools.
+	if want := []string{"wa", "bw", "ab"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected selection %v, got %v", want, got)
+	}
+}
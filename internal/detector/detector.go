@@ -3,6 +3,7 @@ package detector
 import (
 	"context"
 	"math/big"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,12 +30,26 @@ type Opportunity struct {
 	// EstimatedProfitWei is the estimated profit in wei of the starting token
 	EstimatedProfitWei *big.Int
 
+	// HopAmountsWei are the simulated amounts: the input of each hop, then the
+	// cycle output (len = len(Path))
+	HopAmountsWei []*big.Int
+
 	// DetectedAtBlock is the block number when this opportunity was detected
 	DetectedAtBlock uint64
 
 	// DetectionLatency is the time from snapshot creation to opportunity detection
 	DetectionLatency time.Duration
 
+	// CapitalMode is how the input amount has to be funded
+	CapitalMode CapitalMode
+
+	// CapitalRequiredWei is the amount of start token that must be held up front
+	// (zero when the opportunity is funded by a flash swap or flash loan)
+	CapitalRequiredWei *big.Int
+
+	// FlashSwap is the zero-capital execution variant, if one exists
+	FlashSwap *FlashSwapResult
+
 	// Cycle contains the raw cycle data
 	Cycle *Cycle
 }
@@ -48,6 +63,9 @@ type Detector struct {
 	startTokens   []string
 	startTokenIdx map[int]bool
 
+	// Start tokens held in inventory (lowercase); nil means all are held
+	inventory map[string]struct{}
+
 	// Results channel
 	opportunitiesCh chan *Opportunity
 
@@ -61,15 +79,29 @@ type Config struct {
 	MaxPathLength   int
 	NumWorkers      int
 	StartTokens     []string
+
+	// InventoryTokens lists the start tokens held in inventory. Cycles starting
+	// from any other token are only reported when they can be flash-swapped.
+	// Nil means every start token is held.
+	InventoryTokens []string
 }
 
 // NewDetector creates a new arbitrage detector.
 func NewDetector(cfg Config, snapshotCh <-chan *graph.Snapshot, m *metrics.Metrics) *Detector {
+	var inventory map[string]struct{}
+	if cfg.InventoryTokens != nil {
+		inventory = make(map[string]struct{}, len(cfg.InventoryTokens))
+		for _, token := range cfg.InventoryTokens {
+			inventory[strings.ToLower(token)] = struct{}{}
+		}
+	}
+
 	return &Detector{
 		config:          cfg,
 		metrics:         m,
 		startTokens:     cfg.StartTokens,
 		startTokenIdx:   make(map[int]bool),
+		inventory:       inventory,
 		opportunitiesCh: make(chan *Opportunity, 100),
 		snapshotCh:      snapshotCh,
 	}
@@ -255,16 +287,43 @@ func (d *Detector) createOpportunity(snap *graph.Snapshot, cycle *Cycle, detecti
 		path[i] = token
 	}
 
-	return &Opportunity{
+	opp := &Opportunity{
 		Path:               path,
 		Pools:              cycle.PoolAddresses(),
 		MaxInputWei:        result.MaxInputWei,
 		ProfitFactor:       result.ProfitFactor,
 		EstimatedProfitWei: result.EstimatedProfitWei,
+		HopAmountsWei:      result.IntermediateAmounts,
 		DetectedAtBlock:    snap.BlockNumber,
 		DetectionLatency:   detectionTime,
 		Cycle:              cycle,
+		CapitalMode:        CapitalInventory,
+		CapitalRequiredWei: result.MaxInputWei,
+		FlashSwap:          result.FlashSwap,
+	}
+
+	// Without inventory in the start token the cycle has to borrow its input
+	if !d.holdsInventory(path[0].Address) {
+		flash := result.FlashSwap
+		if flash == nil || !flash.IsProfitable {
+			return nil
+		}
+		opp.CapitalMode = flash.Mode
+		opp.CapitalRequiredWei = big.NewInt(0)
+		opp.ProfitFactor = flash.ProfitFactor
+		opp.EstimatedProfitWei = flash.ProfitWei
+	}
+
+	return opp
+}
+
+// holdsInventory returns true if the start token is held in inventory.
+func (d *Detector) holdsInventory(token string) bool {
+	if d.inventory == nil {
+		return true
 	}
+	_, ok := d.inventory[strings.ToLower(token)]
+	return ok
 }
 
 // logOpportunity logs a detected opportunity.
@@ -304,6 +363,8 @@ func (d *Detector) logOpportunity(opp *Opportunity) {
 		Str("profit_wei", opp.EstimatedProfitWei.String()).
 		Dur("detection_latency", opp.DetectionLatency).
 		Int("path_length", len(opp.Path)-1).
+		Str("capital_mode", opp.CapitalMode.String()).
+		Str("capital_required_wei", opp.CapitalRequiredWei.String()).
 		Msg("🎯 ARBITRAGE OPPORTUNITY DETECTED")
 }
 
@@ -475,3 +475,203 @@ func TestCycleSetDeduplication(t *testing.T) {
 		t.Errorf("Expected 2 cycles in set, got %d", set.Count())
 	}
 }
+
+// createFlashSwapGraph creates a two-pool cycle with a 10% price gap between the pools
+// plus an unrelated deep pool holding the start token.
+func createFlashSwapGraph() *graph.Graph {
+	g := graph.NewGraph()
+
+	tokens := []graph.TokenInfo{
+		{Address: "0x0000000000000000000000000000000000000001", Symbol: "WETH", Decimals: 18},
+		{Address: "0x0000000000000000000000000000000000000002", Symbol: "USDC", Decimals: 6},
+		{Address: "0x0000000000000000000000000000000000000003", Symbol: "DAI", Decimals: 18},
+	}
+	for _, t := range tokens {
+		g.AddToken(t)
+	}
+
+	pools := []graph.PoolState{
+		{
+			Address:  "0xpool1",
+			Token0:   tokens[0].Address,
+			Token1:   tokens[1].Address,
+			Reserve0: bigInt("1000000000000000000000"), // 1000e18
+			Reserve1: big.NewInt(1000000000),           // 1000e6
+			Fee:      0.003,
+		},
+		{
+			Address:  "0xpool2",
+			Token0:   tokens[1].Address,
+			Token1:   tokens[0].Address,
+			Reserve0: big.NewInt(1000000000),           // 1000e6
+			Reserve1: bigInt("1100000000000000000000"), // 1100e18
+			Fee:      0.003,
+		},
+		{
+			Address:  "0xlender",
+			Token0:   tokens[0].Address,
+			Token1:   tokens[2].Address,
+			Reserve0: bigInt("100000000000000000000000"), // 100000e18
+			Reserve1: bigInt("100000000000000000000000"),
+			Fee:      0.003,
+		},
+	}
+	for _, p := range pools {
+		g.AddPool(p)
+	}
+
+	return g
+}
+
+// TestFlashSwapSimulation verifies the zero-capital variant of a profitable cycle.
+func TestFlashSwapSimulation(t *testing.T) {
+	snap := createFlashSwapGraph().CreateSnapshot(1)
+	wethIdx, _ := snap.GetTokenIndex("0x0000000000000000000000000000000000000001")
+	usdcIdx, _ := snap.GetTokenIndex("0x0000000000000000000000000000000000000002")
+
+	var edges []graph.Edge
+	for _, e := range snap.GetEdgesFrom(wethIdx) {
+		if e.PoolAddr == "0xpool1" {
+			edges = append(edges, e)
+		}
+	}
+	for _, e := range snap.GetEdgesFrom(usdcIdx) {
+		if e.PoolAddr == "0xpool2" {
+			edges = append(edges, e)
+		}
+	}
+	cycle := NewCycle(edges)
+
+	result := SimulateCycle(cycle, snap, 1.001)
+	if result == nil || !result.IsProfitable {
+		t.Fatal("Expected profitable simulation")
+	}
+
+	flash := result.FlashSwap
+	if flash == nil {
+		t.Fatal("Expected flash swap variant")
+	}
+
+	// Borrowing from the first pool costs nothing beyond the swap itself,
+	// so it must beat a flash loan from the external pool.
+	if flash.Mode != CapitalFlashSwap {
+		t.Errorf("Expected flash swap mode, got %s", flash.Mode)
+	}
+	if flash.SourcePool != "0xpool1" {
+		t.Errorf("Expected first pool as source, got %s", flash.SourcePool)
+	}
+	if flash.RepayWei.Cmp(result.MaxInputWei) != 0 {
+		t.Errorf("Expected repayment %s to equal input %s", flash.RepayWei, result.MaxInputWei)
+	}
+	if flash.ProfitWei.Cmp(result.EstimatedProfitWei) != 0 {
+		t.Errorf("Expected flash profit %s to equal inventory profit %s", flash.ProfitWei, result.EstimatedProfitWei)
+	}
+	if flash.FeeWei.Sign() <= 0 {
+		t.Error("Expected non-zero fee on the repaid input")
+	}
+	if !flash.IsProfitable {
+		t.Error("Expected flash swap to be profitable")
+	}
+}
+
+// TestFlashLoanSourceSkipsCyclePools verifies the flash loan source is the
+// deepest pool of the start token outside the cycle, even when the cycle's own
+// pools are deeper.
+func TestFlashLoanSourceSkipsCyclePools(t *testing.T) {
+	g := createFlashSwapGraph()
+	weth := "0x0000000000000000000000000000000000000001"
+	g.AddPool(graph.PoolState{
+		Address:  "0xshallow",
+		Token0:   weth,
+		Token1:   "0x0000000000000000000000000000000000000003",
+		Reserve0: bigInt("10000000000000000000"), // 10e18
+		Reserve1: bigInt("10000000000000000000"),
+		Fee:      0.01,
+	})
+	g.UpdateReserves("0xlender", bigInt("1000000000000000000"), bigInt("1000000000000000000")) // 1e18
+	snap := g.CreateSnapshot(1)
+
+	wethIdx, _ := snap.GetTokenIndex(weth)
+	usdcIdx, _ := snap.GetTokenIndex("0x0000000000000000000000000000000000000002")
+	var edges []graph.Edge
+	for _, e := range snap.GetEdgesFrom(wethIdx) {
+		if e.PoolAddr == "0xpool1" {
+			edges = append(edges, e)
+		}
+	}
+	for _, e := range snap.GetEdgesFrom(usdcIdx) {
+		if e.PoolAddr == "0xpool2" {
+			edges = append(edges, e)
+		}
+	}
+
+	source, reserve, fee, ok := findFlashLoanSource(NewCycle(edges), snap)
+	if !ok || source != "0xshallow" {
+		t.Fatalf("Expected 0xshallow as the source, got %q (%v)", source, ok)
+	}
+	if reserve.Cmp(bigInt("10000000000000000000")) != 0 || fee != 0.01 {
+		t.Errorf("Expected the source's WETH reserve and fee, got %s and %v", reserve, fee)
+	}
+}
+
+// TestFlashLoanRepayment verifies the external flash loan repayment includes the pool fee.
+func TestFlashLoanRepayment(t *testing.T) {
+	amount := bigInt("1000000000000000000") // 1e18
+	repay := flashLoanRepayment(amount, 0.003)
+
+	// repay * 0.997 must cover the borrowed amount
+	covered := applyFee(repay, 0.003)
+	if covered.Cmp(amount) < 0 {
+		t.Errorf("Repayment %s does not cover borrowed %s after fee", repay, amount)
+	}
+
+	// And it should not overpay by more than rounding
+	fee := new(big.Int).Sub(repay, amount)
+	expectedFee := bigInt("3009027081243731") // 1e18 / 0.997 - 1e18
+	diff := new(big.Int).Sub(fee, expectedFee)
+	if diff.Abs(diff).Cmp(big.NewInt(2)) > 0 {
+		t.Errorf("Unexpected flash loan fee: got %s, expected ~%s", fee, expectedFee)
+	}
+}
+
+// TestCapitalModeSelection verifies opportunities report how they are funded.
+func TestCapitalModeSelection(t *testing.T) {
+	snap := createFlashSwapGraph().CreateSnapshot(1)
+	weth := "0x0000000000000000000000000000000000000001"
+
+	cfg := Config{
+		MinProfitFactor: 1.001,
+		MaxPathLength:   4,
+		NumWorkers:      1,
+		StartTokens:     []string{weth},
+	}
+
+	// Default: every start token is held
+	opps := NewDetector(cfg, nil, nil).DetectOnce(snap)
+	if len(opps) == 0 {
+		t.Fatal("Expected an opportunity")
+	}
+	for _, opp := range opps {
+		if opp.CapitalMode != CapitalInventory {
+			t.Errorf("Expected inventory mode, got %s", opp.CapitalMode)
+		}
+		if opp.CapitalRequiredWei.Cmp(opp.MaxInputWei) != 0 {
+			t.Errorf("Expected capital required to equal max input")
+		}
+	}
+
+	// No inventory: the same cycle must be funded by a flash swap
+	cfg.InventoryTokens = []string{}
+	opps = NewDetector(cfg, nil, nil).DetectOnce(snap)
+	if len(opps) == 0 {
+		t.Fatal("Expected an opportunity funded by flash swap")
+	}
+	for _, opp := range opps {
+		if opp.CapitalMode != CapitalFlashSwap {
+			t.Errorf("Expected flash swap mode, got %s", opp.CapitalMode)
+		}
+		if opp.CapitalRequiredWei.Sign() != 0 {
+			t.Errorf("Expected zero capital, got %s", opp.CapitalRequiredWei)
+		}
+	}
+}
@@ -6,6 +6,64 @@ import (
 	"watcher/internal/graph"
 )
 
+// CapitalMode describes how the input amount of an opportunity is funded.
+type CapitalMode int
+
+const (
+	// CapitalInventory funds the first hop from start tokens already held.
+	CapitalInventory CapitalMode = iota
+
+	// CapitalFlashSwap borrows the first hop's output from the first pool of the
+	// cycle and repays that pool with the start token once the cycle completes.
+	CapitalFlashSwap
+
+	// CapitalFlashLoan borrows the start token from a pool outside the cycle and
+	// repays it, plus that pool's fee, once the cycle completes.
+	CapitalFlashLoan
+)
+
+// String returns the name used for the capital mode in logs and metrics.
+func (m CapitalMode) String() string {
+	switch m {
+	case CapitalInventory:
+		return "inventory"
+	case CapitalFlashSwap:
+		return "flash_swap"
+	case CapitalFlashLoan:
+		return "flash_loan"
+	default:
+		return "unknown"
+	}
+}
+
+// FlashSwapResult describes the zero-capital execution variant of a cycle.
+type FlashSwapResult struct {
+	// Mode is CapitalFlashSwap or CapitalFlashLoan
+	Mode CapitalMode
+
+	// SourcePool is the pool the borrowed amount is taken from
+	SourcePool string
+
+	// BorrowedWei is the amount received up front (first hop output for a flash
+	// swap, start token for a flash loan)
+	BorrowedWei *big.Int
+
+	// RepayWei is the amount of start token owed to SourcePool at the end
+	RepayWei *big.Int
+
+	// FeeWei is the part of RepayWei that is paid as pool fee
+	FeeWei *big.Int
+
+	// ProfitWei is the cycle output minus RepayWei
+	ProfitWei *big.Int
+
+	// ProfitFactor is the cycle output divided by RepayWei
+	ProfitFactor float64
+
+	// IsProfitable indicates if the variant clears the minimum profit factor
+	IsProfitable bool
+}
+
 // SimulationResult contains the results of simulating an arbitrage opportunity.
 type SimulationResult struct {
 	// MaxInput is the maximum input amount that can traverse the entire cycle
@@ -28,6 +86,10 @@ type SimulationResult struct {
 
 	// IntermediateAmounts are the amounts at each step
 	IntermediateAmounts []*big.Int
+
+	// FlashSwap is the best zero-capital variant of the cycle, or nil if the
+	// cycle cannot be funded by borrowing
+	FlashSwap *FlashSwapResult
 }
 
 // SimulateCycle simulates executing an arbitrage cycle with actual AMM math.
@@ -65,7 +127,116 @@ func SimulateCycle(cycle *Cycle, snap *graph.Snapshot, minProfitFactor float64)
 		ProfitFactor:        profitFactor,
 		IsProfitable:        profitFactor >= minProfitFactor,
 		IntermediateAmounts: amounts,
+		FlashSwap:           simulateFlashSwap(cycle, snap, amounts, minProfitFactor),
+	}
+}
+
+// simulateFlashSwap evaluates funding the cycle without holding the start token.
+// Two sources are considered and the more profitable one is returned:
+//   - a flash swap on the first pool, which hands out the first hop's output
+//     before payment and is repaid with the start token at the end of the cycle
+//     (the pool fee is already part of the first hop's input amount)
+//   - a flash loan of the start token from the deepest pool outside the cycle,
+//     repaid in the same token with that pool's fee on top
+func simulateFlashSwap(cycle *Cycle, snap *graph.Snapshot, amounts []*big.Int, minProfitFactor float64) *FlashSwapResult {
+	if len(amounts) != len(cycle.Edges)+1 {
+		return nil
 	}
+
+	input := amounts[0]
+	output := amounts[len(amounts)-1]
+	first := cycle.Edges[0]
+
+	// Flash swap: the first pool's fee is charged on the repaid input
+	swapFee := new(big.Int).Sub(input, applyFee(input, first.Fee))
+	best := newFlashSwapResult(CapitalFlashSwap, first.PoolAddr, amounts[1], input, swapFee, output, minProfitFactor)
+
+	// Flash loan from an external pool holding the start token
+	if source, reserve, fee, ok := findFlashLoanSource(cycle, snap); ok && input.Cmp(reserve) < 0 {
+		repay := flashLoanRepayment(input, fee)
+		if repay != nil {
+			loanFee := new(big.Int).Sub(repay, input)
+			loan := newFlashSwapResult(CapitalFlashLoan, source, input, repay, loanFee, output, minProfitFactor)
+			if loan.ProfitWei.Cmp(best.ProfitWei) > 0 {
+				best = loan
+			}
+		}
+	}
+
+	return best
+}
+
+// newFlashSwapResult computes profit figures for a borrowed execution.
+func newFlashSwapResult(mode CapitalMode, source string, borrowed, repay, fee, output *big.Int, minProfitFactor float64) *FlashSwapResult {
+	profit := new(big.Int).Sub(output, repay)
+
+	profitFactorFloat := new(big.Float).Quo(new(big.Float).SetInt(output), new(big.Float).SetInt(repay))
+	profitFactor, _ := profitFactorFloat.Float64()
+
+	return &FlashSwapResult{
+		Mode:         mode,
+		SourcePool:   source,
+		BorrowedWei:  new(big.Int).Set(borrowed),
+		RepayWei:     new(big.Int).Set(repay),
+		FeeWei:       fee,
+		ProfitWei:    profit,
+		ProfitFactor: profitFactor,
+		IsProfitable: profit.Sign() > 0 && profitFactor >= minProfitFactor,
+	}
+}
+
+// findFlashLoanSource returns the pool outside the cycle with the largest reserve
+// of the cycle's start token, along with that reserve and the pool fee.
+func findFlashLoanSource(cycle *Cycle, snap *graph.Snapshot) (string, *big.Int, float64, bool) {
+	if snap == nil {
+		return "", nil, 0, false
+	}
+
+	startToken, ok := snap.GetToken(cycle.StartToken())
+	if !ok {
+		return "", nil, 0, false
+	}
+
+	// Deepest first, so the first pool outside the cycle is the best source
+	for _, addr := range snap.DeepestPools(startToken.Address) {
+		inCycle := false
+		for _, e := range cycle.Edges {
+			if e.PoolAddr == addr {
+				inCycle = true
+				break
+			}
+		}
+		if inCycle {
+			continue
+		}
+
+		pool := snap.Pools[addr]
+		return addr, pool.Reserve(startToken.Address), pool.Fee, true
+	}
+
+	return "", nil, 0, false
+}
+
+// flashLoanRepayment returns the amount that must be returned to a constant
+// product pool after borrowing amount of one of its tokens. The pool only checks
+// its invariant on the fee-adjusted input, so repay * (1 - fee) >= amount.
+func flashLoanRepayment(amount *big.Int, feeRate float64) *big.Int {
+	feeMultiplier := int64((1 - feeRate) * 10000)
+	if feeMultiplier <= 0 {
+		return nil
+	}
+
+	// repay = ceil(amount * 10000 / feeMultiplier)
+	repay := new(big.Int).Mul(amount, big.NewInt(10000))
+	repay.Add(repay, big.NewInt(feeMultiplier-1))
+	return repay.Div(repay, big.NewInt(feeMultiplier))
+}
+
+// applyFee returns amount * (1 - feeRate) using the same precision as calculateSwapOutput.
+func applyFee(amount *big.Int, feeRate float64) *big.Int {
+	feeMultiplier := int64((1 - feeRate) * 10000)
+	result := new(big.Int).Mul(amount, big.NewInt(feeMultiplier))
+	return result.Div(result, big.NewInt(10000))
 }
 
 // calculateMaxInput determines the maximum input amount for a cycle.
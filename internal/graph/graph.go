@@ -48,6 +48,19 @@ type PoolState struct {
 	Reserve0 *big.Int
 	Reserve1 *big.Int
 	Fee      float64
+	IsStable bool
+}
+
+// Reserve returns the pool's reserve of token, or nil if it doesn't hold it.
+func (p PoolState) Reserve(token string) *big.Int {
+	switch token {
+	case p.Token0:
+		return p.Reserve0
+	case p.Token1:
+		return p.Reserve1
+	default:
+		return nil
+	}
 }
 
 // NewGraph creates a new empty graph.
@@ -113,6 +126,7 @@ func (g *Graph) addPoolLocked(pool PoolState) {
 		Reserve0: new(big.Int).Set(pool.Reserve0),
 		Reserve1: new(big.Int).Set(pool.Reserve1),
 		Fee:      pool.Fee,
+		IsStable: pool.IsStable,
 	}
 
 	// Calculate weights and create edges
@@ -146,6 +160,40 @@ func (g *Graph) addPoolLocked(pool PoolState) {
 	})
 }
 
+// RemovePool removes a pool and both of its edges from the graph.
+// Its tokens stay in the graph. Returns false if the pool was not present.
+func (g *Graph) RemovePool(address string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.removePoolLocked(address)
+}
+
+// removePoolLocked removes a pool without acquiring the lock.
+func (g *Graph) removePoolLocked(address string) bool {
+	pool, exists := g.pools[address]
+	if !exists {
+		return false
+	}
+	delete(g.pools, address)
+
+	for _, token := range []string{pool.Token0, pool.Token1} {
+		idx, ok := g.tokenIndex[token]
+		if !ok {
+			continue
+		}
+		edges := g.adjacency[idx][:0]
+		for _, e := range g.adjacency[idx] {
+			if e.PoolAddr != address {
+				edges = append(edges, e)
+			}
+		}
+		g.adjacency[idx] = edges
+	}
+
+	return true
+}
+
 // updateEdge updates an existing edge or adds a new one.
 func (g *Graph) updateEdge(from, to int, edge Edge) {
 	// Look for existing edge from the same pool
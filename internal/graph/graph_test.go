@@ -369,6 +369,35 @@ func TestGetTokenIndex(t *testing.T) {
 	}
 }
 
+func TestSnapshotDeepestPools(t *testing.T) {
+	g := NewGraph()
+	for _, p := range []PoolState{
+		{Address: "0xp1", Token0: "0xa", Token1: "0xb", Reserve0: big.NewInt(100), Reserve1: big.NewInt(1)},
+		{Address: "0xp2", Token0: "0xb", Token1: "0xa", Reserve0: big.NewInt(1), Reserve1: big.NewInt(500)},
+		{Address: "0xp3", Token0: "0xa", Token1: "0xc", Reserve0: big.NewInt(300), Reserve1: big.NewInt(1)},
+		{Address: "0xp0", Token0: "0xa", Token1: "0xc", Reserve0: big.NewInt(100), Reserve1: big.NewInt(1)},
+		{Address: "0xp4", Token0: "0xa", Token1: "0xc", Reserve0: big.NewInt(0), Reserve1: big.NewInt(1)},
+	} {
+		g.AddPool(p)
+	}
+	snap := g.CreateSnapshot(1)
+
+	got := snap.DeepestPools("0xa")
+	want := []string{"0xp2", "0xp3", "0xp0"} // 0xp0 and 0xp1 tie, the lower address wins
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+	if got := snap.DeepestPools("0xmissing"); len(got) != 0 {
+		t.Errorf("Expected no pools for an unknown token, got %v", got)
+	}
+}
+
 func BenchmarkAddPool(b *testing.B) {
 	g := NewGraph()
 
@@ -2,7 +2,9 @@ package graph
 
 import (
 	"context"
+	"math"
 	"math/big"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +22,44 @@ type ReserveUpdate struct {
 	BlockNumber uint64
 	LogIndex    uint
 	Timestamp   time.Time
+
+	// Block the update was emitted in. BlockTime is zero if the header was not yet known.
+	BlockHash string
+	BlockTime time.Time
+}
+
+// BlockHeader identifies a block announced by the chain head.
+type BlockHeader struct {
+	Number uint64
+	Hash   string
+	Time   time.Time
+}
+
+// eventPosition orders events within the chain.
+type eventPosition struct {
+	block    uint64
+	logIndex uint
+}
+
+// after returns true if p comes after other.
+func (p eventPosition) after(other eventPosition) bool {
+	if p.block != other.block {
+		return p.block > other.block
+	}
+	return p.logIndex > other.logIndex
+}
+
+// revertWindowBlocks is how many blocks of applied updates each pool keeps,
+// so updates removed by a reorg can be undone.
+const revertWindowBlocks = 64
+
+// reserveChange is an applied update and the reserves and position it replaced.
+type reserveChange struct {
+	pos          eventPosition
+	prevPos      eventPosition
+	hadPrevPos   bool
+	prevReserve0 *big.Int
+	prevReserve1 *big.Int
 }
 
 // Manager handles graph state updates and snapshot creation.
@@ -40,21 +80,93 @@ type Manager struct {
 	// Last snapshot info
 	lastSnapshotBlock uint64
 
+	// Highest block closed by a header
+	lastClosedBlock uint64
+
+	// Position of the last update applied to each pool, so replayed or
+	// backfilled events never roll reserves back
+	poolPositions map[string]eventPosition
+
+	// Recent updates applied to each pool, oldest first, so a reorg can restore
+	// the reserves they replaced
+	poolChanges map[string][]reserveChange
+
 	// Flush timer for ensuring snapshots are created even without new blocks
 	flushTimer *time.Timer
 	flushDelay time.Duration
+
+	// poolsAdded, if set, is called with every pool added to the graph
+	poolsAdded func(pools []PoolState, tokens map[string]TokenInfo)
+
+	// poolsRemoved, if set, is called with every pool removed from the graph
+	poolsRemoved func(addresses []string)
+
+	// poolFilter, if set, rejects pools it returns false for
+	poolFilter func(pool PoolState) bool
+
+	// updatesApplied, if set, is called with the updates applied to the graph
+	updatesApplied func(updates []ReserveUpdate)
+
+	// updatesReverted, if set, is called with the applied updates a reorg undid
+	updatesReverted func(updates []ReserveUpdate)
 }
 
 // NewManager creates a new graph manager.
 func NewManager(m *metrics.Metrics) *Manager {
 	return &Manager{
-		graph:      NewGraph(),
-		metrics:    m,
-		snapshotCh: make(chan *Snapshot, 10),
-		flushDelay: 2 * time.Second, // Flush after 2 seconds of no new block
+		graph:         NewGraph(),
+		metrics:       m,
+		snapshotCh:    make(chan *Snapshot, 10),
+		flushDelay:    2 * time.Second, // Flush after 2 seconds of no new block
+		poolPositions: make(map[string]eventPosition),
+		poolChanges:   make(map[string][]reserveChange),
 	}
 }
 
+// SetPoolsAddedHook sets a function called with every pool added to the graph,
+// in the order they are added. Used to record sessions.
+func (m *Manager) SetPoolsAddedHook(fn func(pools []PoolState, tokens map[string]TokenInfo)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.poolsAdded = fn
+}
+
+// SetPoolsRemovedHook sets a function called with the pools removed from the
+// graph, in the order they are removed. Used to record sessions.
+func (m *Manager) SetPoolsRemovedHook(fn func(addresses []string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.poolsRemoved = fn
+}
+
+// SetUpdatesAppliedHook sets a function called with the reserve updates
+// applied to the graph, in chain order, once per applied batch. Stale updates
+// and updates for unknown pools are left out. It's called with the manager's
+// lock held, so it must not block.
+func (m *Manager) SetUpdatesAppliedHook(fn func(updates []ReserveUpdate)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updatesApplied = fn
+}
+
+// SetUpdatesRevertedHook sets a function called with the applied updates a
+// reorg undid, newest last. Only the pool, block and log index are set. It's
+// called with the manager's lock held, so it must not block.
+func (m *Manager) SetUpdatesRevertedHook(fn func(updates []ReserveUpdate)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updatesReverted = fn
+}
+
+// SetPoolFilter sets a function deciding whether a pool may be added to the
+// graph. Pools it returns false for are dropped by AddPool and AddPoolBatch.
+// It's called with lowercase addresses.
+func (m *Manager) SetPoolFilter(fn func(pool PoolState) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.poolFilter = fn
+}
+
 // Graph returns the underlying graph for direct manipulation during bootstrap.
 func (m *Manager) Graph() *Graph {
 	return m.graph
@@ -91,8 +203,10 @@ func (m *Manager) ProcessUpdate(update ReserveUpdate) {
 		m.applyPendingUpdatesLocked()
 	}
 
-	// Set current block
-	m.pendingBlock = update.BlockNumber
+	// Set current block (late updates from an earlier block join the current batch)
+	if update.BlockNumber > m.pendingBlock {
+		m.pendingBlock = update.BlockNumber
+	}
 
 	// Add to pending updates
 	m.pendingUpdates = append(m.pendingUpdates, update)
@@ -119,14 +233,55 @@ func (m *Manager) applyPendingUpdatesLocked() {
 	}
 
 	startTime := time.Now()
-	blockNum := m.pendingUpdates[0].BlockNumber
+	blockNum := m.pendingBlock
+	var blockHash string
+	var blockTime time.Time
 	updatedCount := 0
 	notFoundCount := 0
+	staleCount := 0
+	var applied []ReserveUpdate
+
+	// Apply in chain order; late or backfilled updates may have been appended out of order
+	sort.SliceStable(m.pendingUpdates, func(i, j int) bool {
+		a, b := m.pendingUpdates[i], m.pendingUpdates[j]
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		return a.LogIndex < b.LogIndex
+	})
 
 	// Apply all updates to the graph
 	for _, update := range m.pendingUpdates {
+		// Skip updates older than what the pool already reflects
+		pos := eventPosition{block: update.BlockNumber, logIndex: update.LogIndex}
+		if last, ok := m.poolPositions[update.PoolAddress]; ok && !pos.after(last) {
+			staleCount++
+			continue
+		}
+
+		if update.BlockNumber == blockNum && update.BlockHash != "" {
+			blockHash = update.BlockHash
+		}
+		if update.BlockNumber == blockNum && !update.BlockTime.IsZero() {
+			blockTime = update.BlockTime
+		}
+
+		change := reserveChange{pos: pos}
+		change.prevPos, change.hadPrevPos = m.poolPositions[update.PoolAddress]
+		if pool, ok := m.graph.GetPool(update.PoolAddress); ok {
+			change.prevReserve0, change.prevReserve1 = pool.Reserve0, pool.Reserve1
+		}
+
 		if m.graph.UpdateReserves(update.PoolAddress, update.Reserve0, update.Reserve1) {
 			updatedCount++
+			m.poolPositions[update.PoolAddress] = pos
+			m.recordChangeLocked(update.PoolAddress, change)
+			if m.updatesApplied != nil {
+				applied = append(applied, update)
+			}
+			if m.metrics != nil && !update.BlockTime.IsZero() {
+				m.metrics.RecordEventLatency(update.BlockTime)
+			}
 		} else {
 			notFoundCount++
 			log.Debug().
@@ -135,12 +290,18 @@ func (m *Manager) applyPendingUpdatesLocked() {
 		}
 	}
 
+	if len(applied) > 0 {
+		m.updatesApplied(applied)
+	}
+
 	// Clear pending updates
 	m.pendingUpdates = m.pendingUpdates[:0]
 
 	// Create snapshot
 	snapshotStart := time.Now()
 	snapshot := m.graph.CreateSnapshot(blockNum)
+	snapshot.BlockHash = blockHash
+	snapshot.BlockTime = blockTime
 	snapshotDuration := time.Since(snapshotStart)
 
 	// Update metrics
@@ -158,6 +319,7 @@ func (m *Manager) applyPendingUpdatesLocked() {
 			Uint64("block", blockNum).
 			Int("updates_applied", updatedCount).
 			Int("updates_not_found", notFoundCount).
+			Int("updates_stale", staleCount).
 			Dur("apply_time", time.Since(startTime)).
 			Dur("snapshot_time", snapshotDuration).
 			Int("nodes", snapshot.NumNodes()).
@@ -170,6 +332,17 @@ func (m *Manager) applyPendingUpdatesLocked() {
 	}
 }
 
+// recordChangeLocked remembers an applied update of a pool, dropping those
+// more than revertWindowBlocks blocks before it. Must be called with m.mu held.
+func (m *Manager) recordChangeLocked(poolAddress string, change reserveChange) {
+	changes := append(m.poolChanges[poolAddress], change)
+	expired := 0
+	for expired < len(changes) && changes[expired].pos.block+revertWindowBlocks < change.pos.block {
+		expired++
+	}
+	m.poolChanges[poolAddress] = changes[expired:]
+}
+
 // Flush forces application of any pending updates and creates a snapshot.
 func (m *Manager) Flush() {
 	m.mu.Lock()
@@ -183,6 +356,54 @@ func (m *Manager) Flush() {
 	}
 }
 
+// CloseBlock marks a block as complete once its header is seen.
+// Pending updates up to and including the block are stamped with the header's
+// hash and timestamp, applied, and published as a snapshot. The flush timer
+// remains as a fallback for blocks whose header never arrives.
+func (m *Manager) CloseBlock(header BlockHeader) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if header.Number > m.lastClosedBlock {
+		m.lastClosedBlock = header.Number
+	}
+
+	// Nothing changed, or the pending batch belongs to a later block
+	if len(m.pendingUpdates) == 0 || m.pendingBlock > header.Number {
+		return
+	}
+
+	for i := range m.pendingUpdates {
+		update := &m.pendingUpdates[i]
+		if update.BlockNumber != header.Number {
+			continue
+		}
+		if update.BlockHash == "" {
+			update.BlockHash = header.Hash
+		}
+		if update.BlockTime.IsZero() {
+			update.BlockTime = header.Time
+		}
+	}
+
+	if m.flushTimer != nil {
+		m.flushTimer.Stop()
+	}
+
+	log.Debug().
+		Uint64("block", header.Number).
+		Int("pending_count", len(m.pendingUpdates)).
+		Msg("Block closed by header, applying pending updates")
+	m.applyPendingUpdatesLocked()
+}
+
+// LastClosedBlock returns the highest block closed by a header.
+func (m *Manager) LastClosedBlock() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastClosedBlock
+}
+
 // AddPool adds a pool to the graph with initial state.
 func (m *Manager) AddPool(pool PoolState, token0Info, token1Info TokenInfo) {
 	m.mu.Lock()
@@ -195,12 +416,24 @@ func (m *Manager) AddPool(pool PoolState, token0Info, token1Info TokenInfo) {
 	token0Info.Address = strings.ToLower(token0Info.Address)
 	token1Info.Address = strings.ToLower(token1Info.Address)
 
+	if m.poolFilter != nil && !m.poolFilter(pool) {
+		log.Info().Str("pool", pool.Address).Msg("Pool rejected by filter")
+		return
+	}
+
 	// Add tokens first
 	m.graph.addTokenLocked(token0Info)
 	m.graph.addTokenLocked(token1Info)
 
 	// Add pool
 	m.graph.addPoolLocked(pool)
+
+	if m.poolsAdded != nil {
+		m.poolsAdded([]PoolState{pool}, map[string]TokenInfo{
+			token0Info.Address: token0Info,
+			token1Info.Address: token1Info,
+		})
+	}
 }
 
 // AddPoolBatch adds multiple pools efficiently.
@@ -209,6 +442,22 @@ func (m *Manager) AddPoolBatch(pools []PoolState, tokenInfos map[string]TokenInf
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Normalize addresses and drop filtered pools
+	accepted := make([]PoolState, 0, len(pools))
+	for _, pool := range pools {
+		pool.Address = strings.ToLower(pool.Address)
+		pool.Token0 = strings.ToLower(pool.Token0)
+		pool.Token1 = strings.ToLower(pool.Token1)
+		if m.poolFilter != nil && !m.poolFilter(pool) {
+			continue
+		}
+		accepted = append(accepted, pool)
+	}
+	if rejected := len(pools) - len(accepted); rejected > 0 {
+		log.Info().Int("rejected", rejected).Msg("Pools rejected by filter")
+	}
+	pools = accepted
+
 	// First, collect only the tokens that are used by the pools
 	usedTokens := make(map[string]struct{})
 	for _, pool := range pools {
@@ -236,12 +485,13 @@ func (m *Manager) AddPoolBatch(pools []PoolState, tokenInfos map[string]TokenInf
 
 	// Add all pools
 	for _, pool := range pools {
-		pool.Address = strings.ToLower(pool.Address)
-		pool.Token0 = strings.ToLower(pool.Token0)
-		pool.Token1 = strings.ToLower(pool.Token1)
 		m.graph.addPoolLocked(pool)
 	}
 
+	if m.poolsAdded != nil {
+		m.poolsAdded(pools, tokenInfos)
+	}
+
 	// Update metrics
 	if m.metrics != nil {
 		m.metrics.RecordGraphStats(m.graph.NumNodes(), m.graph.NumEdges())
@@ -257,6 +507,168 @@ func (m *Manager) AddPoolBatch(pools []PoolState, tokenInfos map[string]TokenInf
 		Msg("Added pool batch to graph")
 }
 
+// RemovePools removes pools from the graph, along with any of their pending
+// updates. Returns the number of pools removed.
+func (m *Manager) RemovePools(addresses []string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := make([]string, 0, len(addresses))
+	removedSet := make(map[string]struct{}, len(addresses))
+	for _, addr := range addresses {
+		addr = strings.ToLower(addr)
+		if m.graph.removePoolLocked(addr) {
+			removed = append(removed, addr)
+			removedSet[addr] = struct{}{}
+			delete(m.poolPositions, addr)
+			delete(m.poolChanges, addr)
+		}
+	}
+	if len(removed) == 0 {
+		return 0
+	}
+
+	pending := m.pendingUpdates[:0]
+	for _, update := range m.pendingUpdates {
+		if _, removed := removedSet[update.PoolAddress]; !removed {
+			pending = append(pending, update)
+		}
+	}
+	m.pendingUpdates = pending
+
+	if m.poolsRemoved != nil {
+		m.poolsRemoved(removed)
+	}
+
+	// Update metrics
+	if m.metrics != nil {
+		m.metrics.RecordGraphStats(m.graph.NumNodes(), m.graph.NumEdges())
+		m.metrics.SetPoolsTracked(m.graph.NumPools())
+	}
+
+	log.Info().
+		Int("pools", len(removed)).
+		Int("total_edges", m.graph.NumEdges()).
+		Msg("Removed pools from graph")
+
+	return len(removedSet)
+}
+
+// RevertUpdate undoes a pool's update that a reorg removed, along with any
+// applied after it, so the canonical chain's updates are not skipped as stale.
+// A pending update at that position is dropped. The pool's reserves go back to
+// those before the removed update, so a canonical block without a Sync for the
+// pool leaves it as it was before the reorg. Updates older than
+// revertWindowBlocks can't be undone; the pool then keeps its reserves until
+// the next update.
+func (m *Manager) RevertUpdate(poolAddress string, blockNumber uint64, logIndex uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	poolAddress = strings.ToLower(poolAddress)
+	removed := eventPosition{block: blockNumber, logIndex: logIndex}
+
+	pending := m.pendingUpdates[:0]
+	for _, update := range m.pendingUpdates {
+		if update.PoolAddress == poolAddress && update.BlockNumber == blockNumber && update.LogIndex == logIndex {
+			continue
+		}
+		pending = append(pending, update)
+	}
+	m.pendingUpdates = pending
+
+	last, ok := m.poolPositions[poolAddress]
+	if !ok || removed.after(last) {
+		return
+	}
+
+	// Undo every recorded update from the removed one on
+	changes := m.poolChanges[poolAddress]
+	first := len(changes)
+	for first > 0 && !removed.after(changes[first-1].pos) {
+		first--
+	}
+	if first < len(changes) && (!changes[first].hadPrevPos || removed.after(changes[first].prevPos)) {
+		undone := changes[first]
+		if undone.prevReserve0 != nil && undone.prevReserve1 != nil {
+			m.graph.UpdateReserves(poolAddress, undone.prevReserve0, undone.prevReserve1)
+		}
+		if undone.hadPrevPos {
+			m.poolPositions[poolAddress] = undone.prevPos
+		} else {
+			delete(m.poolPositions, poolAddress)
+		}
+		m.poolChanges[poolAddress] = changes[:first]
+
+		if m.updatesReverted != nil {
+			reverted := make([]ReserveUpdate, 0, len(changes)-first)
+			for _, change := range changes[first:] {
+				reverted = append(reverted, ReserveUpdate{
+					PoolAddress: poolAddress,
+					BlockNumber: change.pos.block,
+					LogIndex:    change.pos.logIndex,
+				})
+			}
+			m.updatesReverted(reverted)
+		}
+
+		log.Info().
+			Str("pool", poolAddress).
+			Uint64("block", blockNumber).
+			Uint("log_index", logIndex).
+			Int("updates_reverted", len(changes)-first).
+			Msg("Reverted pool reserves for update removed by reorg")
+		return
+	}
+
+	// Too old to undo: keep the reserves, but let the canonical updates apply
+	if blockNumber == 0 {
+		delete(m.poolPositions, poolAddress)
+	} else {
+		// Just before the removed block: every update in it applies again
+		m.poolPositions[poolAddress] = eventPosition{block: blockNumber - 1, logIndex: math.MaxUint}
+	}
+	log.Warn().
+		Str("pool", poolAddress).
+		Uint64("block", blockNumber).
+		Uint("log_index", logIndex).
+		Msg("Reverted pool position for update removed by reorg; reserves before it are no longer known")
+}
+
+// PoolPosition is the chain position of the last update applied to a pool.
+type PoolPosition struct {
+	Block    uint64
+	LogIndex uint
+}
+
+// State returns a snapshot of the graph as applied, without pending updates,
+// and the position of each pool's last applied update. Used to persist the
+// graph for warm restarts.
+func (m *Manager) State() (*Snapshot, map[string]PoolPosition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := make(map[string]PoolPosition, len(m.poolPositions))
+	for addr, pos := range m.poolPositions {
+		positions[addr] = PoolPosition{Block: pos.block, LogIndex: pos.logIndex}
+	}
+	return m.graph.CreateSnapshot(m.lastClosedBlock), positions
+}
+
+// RestorePositions sets the position of the last update applied to pools in
+// the graph, so updates replayed after a warm restart aren't applied twice.
+func (m *Manager) RestorePositions(positions map[string]PoolPosition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for addr, pos := range positions {
+		addr = strings.ToLower(addr)
+		if m.graph.HasPool(addr) {
+			m.poolPositions[addr] = eventPosition{block: pos.Block, logIndex: pos.LogIndex}
+		}
+	}
+}
+
 // GetCurrentSnapshot creates and returns a snapshot without going through the channel.
 func (m *Manager) GetCurrentSnapshot(blockNumber uint64) *Snapshot {
 	m.mu.Lock()
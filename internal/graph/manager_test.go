@@ -0,0 +1,351 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestManager() *Manager {
+	m := NewManager(nil)
+	m.AddPool(
+		PoolState{
+			Address:  "0xpool1",
+			Token0:   "0x0001",
+			Token1:   "0x0002",
+			Reserve0: bigInt("1000000000000000000"),
+			Reserve1: bigInt("2000000000000000000"),
+			Fee:      0.003,
+		},
+		TokenInfo{Address: "0x0001", Symbol: "TOK0", Decimals: 18},
+		TokenInfo{Address: "0x0002", Symbol: "TOK1", Decimals: 18},
+	)
+	return m
+}
+
+func TestCloseBlockCreatesStampedSnapshot(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("3000000000000000000"),
+		Reserve1:    bigInt("4000000000000000000"),
+		BlockNumber: 100,
+	})
+
+	blockTime := time.Unix(1700000000, 0)
+	m.CloseBlock(BlockHeader{Number: 100, Hash: "0xabc", Time: blockTime})
+
+	select {
+	case snap := <-m.SnapshotCh():
+		if snap.BlockNumber != 100 {
+			t.Errorf("Expected block 100, got %d", snap.BlockNumber)
+		}
+		if snap.BlockHash != "0xabc" {
+			t.Errorf("Expected block hash 0xabc, got %s", snap.BlockHash)
+		}
+		if !snap.BlockTime.Equal(blockTime) {
+			t.Errorf("Expected block time %v, got %v", blockTime, snap.BlockTime)
+		}
+		if snap.Pools["0xpool1"].Reserve0.Cmp(bigInt("3000000000000000000")) != 0 {
+			t.Errorf("Expected updated reserve0, got %s", snap.Pools["0xpool1"].Reserve0)
+		}
+	default:
+		t.Fatal("Expected a snapshot when the block header closes the block")
+	}
+
+	if m.LastClosedBlock() != 100 {
+		t.Errorf("Expected last closed block 100, got %d", m.LastClosedBlock())
+	}
+}
+
+func TestCloseBlockIgnoresEarlierHeader(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("3000000000000000000"),
+		Reserve1:    bigInt("4000000000000000000"),
+		BlockNumber: 101,
+	})
+
+	// A header for an earlier block must not close the pending batch
+	m.CloseBlock(BlockHeader{Number: 100, Hash: "0xabc", Time: time.Now()})
+
+	select {
+	case <-m.SnapshotCh():
+		t.Fatal("Did not expect a snapshot for an earlier block header")
+	default:
+	}
+}
+
+func TestCloseBlockWithoutUpdates(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.CloseBlock(BlockHeader{Number: 100, Hash: "0xabc", Time: time.Now()})
+
+	select {
+	case <-m.SnapshotCh():
+		t.Fatal("Did not expect a snapshot for a block without updates")
+	default:
+	}
+}
+
+func TestStaleUpdatesDoNotRollBackReserves(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("5000000000000000000"),
+		Reserve1:    bigInt("6000000000000000000"),
+		BlockNumber: 200,
+		LogIndex:    1,
+	})
+	m.Flush()
+	<-m.SnapshotCh()
+
+	// A backfilled event from an earlier block arrives afterwards
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("3000000000000000000"),
+		Reserve1:    bigInt("4000000000000000000"),
+		BlockNumber: 150,
+	})
+	m.Flush()
+	snap := <-m.SnapshotCh()
+
+	if snap.Pools["0xpool1"].Reserve0.Cmp(bigInt("5000000000000000000")) != 0 {
+		t.Errorf("Expected reserves from block 200 to be kept, got reserve0 %s", snap.Pools["0xpool1"].Reserve0)
+	}
+}
+
+func TestPendingUpdatesAppliedInChainOrder(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	// Same block, delivered out of log order
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("7000000000000000000"),
+		Reserve1:    bigInt("8000000000000000000"),
+		BlockNumber: 300,
+		LogIndex:    5,
+	})
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("3000000000000000000"),
+		Reserve1:    bigInt("4000000000000000000"),
+		BlockNumber: 300,
+		LogIndex:    2,
+	})
+	m.Flush()
+	snap := <-m.SnapshotCh()
+
+	if snap.Pools["0xpool1"].Reserve0.Cmp(bigInt("7000000000000000000")) != 0 {
+		t.Errorf("Expected reserves from log index 5, got reserve0 %s", snap.Pools["0xpool1"].Reserve0)
+	}
+}
+
+func TestRemovePoolsDropsEdgesAndPendingUpdates(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.AddPool(
+		PoolState{
+			Address:  "0xpool2",
+			Token0:   "0x0002",
+			Token1:   "0x0003",
+			Reserve0: bigInt("1000000000000000000"),
+			Reserve1: bigInt("1000000000000000000"),
+			Fee:      0.003,
+		},
+		TokenInfo{Address: "0x0002", Symbol: "TOK1", Decimals: 18},
+		TokenInfo{Address: "0x0003", Symbol: "TOK2", Decimals: 18},
+	)
+	m.ProcessUpdate(ReserveUpdate{
+		PoolAddress: "0xpool1",
+		Reserve0:    bigInt("3000000000000000000"),
+		Reserve1:    bigInt("4000000000000000000"),
+		BlockNumber: 100,
+	})
+
+	if removed := m.RemovePools([]string{"0xPOOL1", "0xmissing"}); removed != 1 {
+		t.Fatalf("Expected 1 pool removed, got %d", removed)
+	}
+	if m.HasPool("0xpool1") {
+		t.Error("Expected 0xpool1 to be removed")
+	}
+
+	_, edges, pools := m.Stats()
+	if pools != 1 || edges != 2 {
+		t.Errorf("Expected 1 pool with 2 edges, got %d pools and %d edges", pools, edges)
+	}
+
+	snap := m.GetCurrentSnapshot(100)
+	if _, ok := snap.Pools["0xpool1"]; ok {
+		t.Error("Expected the removed pool's pending update to be dropped")
+	}
+	for _, e := range snap.Adjacency[1] {
+		if e.PoolAddr == "0xpool1" {
+			t.Errorf("Expected no edges from 0xpool1, found %+v", e)
+		}
+	}
+}
+
+func TestPoolFilterRejectsPools(t *testing.T) {
+	m := NewManager(nil)
+	defer m.Close()
+	m.SetPoolFilter(func(pool PoolState) bool {
+		return pool.Address != "0xdenied" && pool.Token0 != "0xbad" && pool.Token1 != "0xbad"
+	})
+
+	pool := func(address, token0, token1 string) PoolState {
+		return PoolState{
+			Address:  address,
+			Token0:   token0,
+			Token1:   token1,
+			Reserve0: bigInt("1000000000000000000"),
+			Reserve1: bigInt("1000000000000000000"),
+			Fee:      0.003,
+		}
+	}
+	m.AddPoolBatch([]PoolState{
+		pool("0xok", "0x0001", "0x0002"),
+		pool("0xDENIED", "0x0001", "0x0002"),
+		pool("0xtainted", "0x0001", "0xBAD"),
+	}, nil)
+	m.AddPool(pool("0xdenied", "0x0001", "0x0002"), TokenInfo{Address: "0x0001"}, TokenInfo{Address: "0x0002"})
+
+	if !m.HasPool("0xok") {
+		t.Error("Expected the allowed pool to be added")
+	}
+	for _, address := range []string{"0xdenied", "0xtainted"} {
+		if m.HasPool(address) {
+			t.Errorf("Expected %s to be rejected", address)
+		}
+	}
+	if _, _, pools := m.Stats(); pools != 1 {
+		t.Errorf("Expected 1 pool, got %d", pools)
+	}
+}
+
+func TestUpdatesAppliedHookSkipsStaleAndUnknown(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	var applied []ReserveUpdate
+	m.SetUpdatesAppliedHook(func(updates []ReserveUpdate) {
+		applied = append(applied, updates...)
+	})
+
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xPOOL1", Reserve0: bigInt("5"), Reserve1: bigInt("6"), BlockNumber: 200, LogIndex: 2})
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("3"), Reserve1: bigInt("4"), BlockNumber: 200, LogIndex: 1})
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xunknown", Reserve0: bigInt("1"), Reserve1: bigInt("1"), BlockNumber: 200})
+	m.Flush()
+	<-m.SnapshotCh()
+
+	// Older than what the pool already reflects
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("7"), Reserve1: bigInt("8"), BlockNumber: 150})
+	m.Flush()
+	<-m.SnapshotCh()
+
+	if len(applied) != 2 {
+		t.Fatalf("Expected 2 applied updates, got %d", len(applied))
+	}
+	if applied[0].LogIndex != 1 || applied[1].LogIndex != 2 || applied[1].PoolAddress != "0xpool1" {
+		t.Errorf("Expected the applied updates in chain order with lowercase addresses, got %+v", applied)
+	}
+}
+
+func TestStateExcludesPendingAndRestoredPositionsSkipStale(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("5"), Reserve1: bigInt("6"), BlockNumber: 200, LogIndex: 2})
+	m.CloseBlock(BlockHeader{Number: 200})
+	<-m.SnapshotCh()
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("7"), Reserve1: bigInt("8"), BlockNumber: 201})
+
+	snapshot, positions := m.State()
+	if snapshot.BlockNumber != 200 || snapshot.Pools["0xpool1"].Reserve1.String() != "6" {
+		t.Errorf("Expected the state closed at block 200 without the pending update, got block %d reserves %+v",
+			snapshot.BlockNumber, snapshot.Pools["0xpool1"])
+	}
+	if pos := positions["0xpool1"]; pos.Block != 200 || pos.LogIndex != 2 {
+		t.Errorf("Expected the position of the applied update, got %+v", pos)
+	}
+
+	// A graph restored from the state skips updates it already reflects
+	restored := newTestManager()
+	defer restored.Close()
+	restored.RestorePositions(map[string]PoolPosition{"0xPOOL1": {Block: 200, LogIndex: 2}, "0xunknown": {Block: 1}})
+	restored.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("3"), Reserve1: bigInt("4"), BlockNumber: 200, LogIndex: 1})
+	restored.Flush()
+	<-restored.SnapshotCh()
+
+	if got := restored.GetCurrentSnapshot(0).Pools["0xpool1"].Reserve1.String(); got != "2000000000000000000" {
+		t.Errorf("Expected the replayed update to be skipped as stale, got reserve1 %s", got)
+	}
+	if _, positions := restored.State(); len(positions) != 1 {
+		t.Errorf("Expected positions only for pools in the graph, got %+v", positions)
+	}
+}
+
+func TestRevertUpdateRestoresPreviousReserves(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	var reverted []ReserveUpdate
+	m.SetUpdatesRevertedHook(func(updates []ReserveUpdate) {
+		reverted = append(reverted, updates...)
+	})
+
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("5"), Reserve1: bigInt("6"), BlockNumber: 199, LogIndex: 0})
+	m.Flush()
+	<-m.SnapshotCh()
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("7"), Reserve1: bigInt("8"), BlockNumber: 200, LogIndex: 2})
+	m.Flush()
+	<-m.SnapshotCh()
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("9"), Reserve1: bigInt("10"), BlockNumber: 201, LogIndex: 0})
+	m.Flush()
+	<-m.SnapshotCh()
+
+	// The reorg drops blocks 200 and 201; the canonical chain has no Sync for the pool
+	m.RevertUpdate("0xPOOL1", 200, 2)
+	m.RevertUpdate("0xpool1", 201, 0)
+
+	snap := m.GetCurrentSnapshot(0)
+	if snap.Pools["0xpool1"].Reserve0.Cmp(bigInt("5")) != 0 {
+		t.Errorf("Expected reserves from block 199 to be restored, got reserve0 %s", snap.Pools["0xpool1"].Reserve0)
+	}
+	if len(reverted) != 2 || reverted[0].BlockNumber != 200 || reverted[1].BlockNumber != 201 {
+		t.Errorf("Expected both updates reported reverted once, got %+v", reverted)
+	}
+
+	// The canonical update at the reverted position applies
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("11"), Reserve1: bigInt("12"), BlockNumber: 200, LogIndex: 1})
+	m.Flush()
+	snap = <-m.SnapshotCh()
+	if snap.Pools["0xpool1"].Reserve0.Cmp(bigInt("11")) != 0 {
+		t.Errorf("Expected the canonical update to apply, got reserve0 %s", snap.Pools["0xpool1"].Reserve0)
+	}
+}
+
+func TestRevertUpdateRestoresBootstrapReserves(t *testing.T) {
+	m := newTestManager()
+	defer m.Close()
+
+	m.ProcessUpdate(ReserveUpdate{PoolAddress: "0xpool1", Reserve0: bigInt("7"), Reserve1: bigInt("8"), BlockNumber: 200})
+	m.Flush()
+	<-m.SnapshotCh()
+
+	m.RevertUpdate("0xpool1", 200, 0)
+
+	snap := m.GetCurrentSnapshot(0)
+	if snap.Pools["0xpool1"].Reserve0.Cmp(bigInt("1000000000000000000")) != 0 {
+		t.Errorf("Expected the reserves the pool was added with, got reserve0 %s", snap.Pools["0xpool1"].Reserve0)
+	}
+}
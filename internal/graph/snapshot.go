@@ -2,9 +2,15 @@ package graph
 
 import (
 	"math/big"
+	"sync"
 	"time"
 )
 
+// deepestPoolsPerToken is how many of each token's deepest pools a snapshot
+// indexes. A simple cycle holds its start token in at most two pools, so at
+// least one indexed pool is outside it.
+const deepestPoolsPerToken = 3
+
 // Snapshot represents an immutable point-in-time view of the graph.
 // Used for concurrent detection while new events are being processed.
 type Snapshot struct {
@@ -21,6 +27,14 @@ type Snapshot struct {
 	// Metadata
 	BlockNumber uint64
 	CreatedAt   time.Time
+
+	// Block hash and timestamp from the chain head, if known
+	BlockHash string
+	BlockTime time.Time
+
+	// Deepest pools of each token, built on first use
+	deepestOnce sync.Once
+	deepest     map[string][]string
 }
 
 // CreateSnapshot creates an immutable snapshot of the current graph state.
@@ -72,6 +86,7 @@ func (g *Graph) CreateSnapshot(blockNumber uint64) *Snapshot {
 			Reserve0: new(big.Int).Set(pool.Reserve0),
 			Reserve1: new(big.Int).Set(pool.Reserve1),
 			Fee:      pool.Fee,
+			IsStable: pool.IsStable,
 		}
 	}
 
@@ -125,6 +140,50 @@ func (s *Snapshot) GetPool(address string) (PoolState, bool) {
 	return pool, exists
 }
 
+// DeepestPools returns up to deepestPoolsPerToken pools holding token,
+// deepest first by their reserve of it, ties broken by address. The index is
+// built once per snapshot.
+func (s *Snapshot) DeepestPools(token string) []string {
+	s.deepestOnce.Do(s.indexDeepestPools)
+	return s.deepest[token]
+}
+
+// indexDeepestPools builds the deepest pools of every token.
+func (s *Snapshot) indexDeepestPools() {
+	s.deepest = make(map[string][]string)
+	deeper := func(token, a, b string) bool {
+		ra, rb := s.Pools[a].Reserve(token), s.Pools[b].Reserve(token)
+		if c := ra.Cmp(rb); c != 0 {
+			return c > 0
+		}
+		return a < b
+	}
+
+	for addr, pool := range s.Pools {
+		for _, token := range []string{pool.Token0, pool.Token1} {
+			if reserve := pool.Reserve(token); reserve == nil || reserve.Sign() <= 0 {
+				continue
+			}
+
+			top := s.deepest[token]
+			i := len(top)
+			for i > 0 && deeper(token, addr, top[i-1]) {
+				i--
+			}
+			if i >= deepestPoolsPerToken {
+				continue
+			}
+			top = append(top, "")
+			copy(top[i+1:], top[i:])
+			top[i] = addr
+			if len(top) > deepestPoolsPerToken {
+				top = top[:deepestPoolsPerToken]
+			}
+			s.deepest[token] = top
+		}
+	}
+}
+
 // GetAllEdges returns all edges in the snapshot as a flat slice.
 func (s *Snapshot) GetAllEdges() []Edge {
 	var all []Edge
@@ -0,0 +1,377 @@
+package ingestion
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultActivityWindow is how far back pool activity is aggregated
+	defaultActivityWindow = 24 * time.Hour
+
+	// activityBucketSize is the resolution of the rolling window
+	activityBucketSize = time.Minute
+)
+
+// PoolActivity is a pool's aggregated activity over the rolling window.
+// Amounts are in raw token units.
+type PoolActivity struct {
+	Pool   string
+	Window time.Duration
+
+	Swaps  int
+	Mints  int
+	Burns  int
+	Syncs  int
+	Cycles int // Detected arbitrage cycles through the pool
+
+	// Volatility is the realized volatility of the pool price (reserve1/reserve0):
+	// the square root of the summed squared log returns between Syncs.
+	Volatility float64
+
+	// Volume is amountIn + amountOut per token
+	Volume0 *big.Int
+	Volume1 *big.Int
+
+	// Fees accrued to liquidity providers per token
+	Fees0 *big.Int
+	Fees1 *big.Int
+
+	LastSwap time.Time
+}
+
+// activityBucket aggregates one bucket of the rolling window.
+type activityBucket struct {
+	start time.Time
+
+	swaps  int
+	mints  int
+	burns  int
+	syncs  int
+	cycles int
+
+	// Sum of squared log price returns
+	squaredReturns float64
+
+	volume0 *big.Int
+	volume1 *big.Int
+	fees0   *big.Int
+	fees1   *big.Int
+}
+
+func newActivityBucket(start time.Time) *activityBucket {
+	return &activityBucket{
+		start:   start,
+		volume0: new(big.Int),
+		volume1: new(big.Int),
+		fees0:   new(big.Int),
+		fees1:   new(big.Int),
+	}
+}
+
+// poolActivity holds a pool's buckets, oldest first.
+type poolActivity struct {
+	buckets  []*activityBucket
+	lastSwap time.Time
+
+	// Price at the last Sync, 0 if none
+	lastPrice float64
+}
+
+// bucket returns the bucket for t, appending one if needed.
+// Events older than the newest bucket are added to it.
+func (p *poolActivity) bucket(t time.Time) *activityBucket {
+	start := t.Truncate(activityBucketSize)
+	if n := len(p.buckets); n > 0 && !start.After(p.buckets[n-1].start) {
+		return p.buckets[n-1]
+	}
+
+	b := newActivityBucket(start)
+	p.buckets = append(p.buckets, b)
+	return b
+}
+
+// insertBucket returns the bucket for t, inserting one in order if needed.
+func (p *poolActivity) insertBucket(t time.Time) *activityBucket {
+	start := t.Truncate(activityBucketSize)
+	i := sort.Search(len(p.buckets), func(i int) bool { return !p.buckets[i].start.Before(start) })
+	if i < len(p.buckets) && p.buckets[i].start.Equal(start) {
+		return p.buckets[i]
+	}
+
+	b := newActivityBucket(start)
+	p.buckets = append(p.buckets, nil)
+	copy(p.buckets[i+1:], p.buckets[i:])
+	p.buckets[i] = b
+	return b
+}
+
+// prune drops buckets that ended before cutoff.
+func (p *poolActivity) prune(cutoff time.Time) {
+	i := 0
+	for i < len(p.buckets) && p.buckets[i].start.Add(activityBucketSize).Before(cutoff) {
+		i++
+	}
+	p.buckets = p.buckets[i:]
+}
+
+// ActivityTracker keeps per-pool rolling volume, swap counts and fee accrual
+// from Swap, Mint, Burn and Fees events, Sync counts and price volatility from
+// Sync events, and how often each pool appeared in detected cycles.
+type ActivityTracker struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	pools map[string]*poolActivity
+}
+
+// NewActivityTracker creates a tracker aggregating over window.
+func NewActivityTracker(window time.Duration) *ActivityTracker {
+	if window <= 0 {
+		window = defaultActivityWindow
+	}
+	return &ActivityTracker{
+		window: window,
+		pools:  make(map[string]*poolActivity),
+	}
+}
+
+// Window returns the aggregation window.
+func (t *ActivityTracker) Window() time.Duration {
+	return t.window
+}
+
+// poolLocked returns the activity of a pool, creating it if needed. Must be called with t.mu held.
+func (t *ActivityTracker) poolLocked(address string) *poolActivity {
+	address = strings.ToLower(address)
+	p, ok := t.pools[address]
+	if !ok {
+		p = &poolActivity{}
+		t.pools[address] = p
+	}
+	return p
+}
+
+// RecordSwap adds a swap that happened at the given time.
+func (t *ActivityTracker) RecordSwap(event *SwapEvent, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.poolLocked(event.PoolAddress)
+	b := p.bucket(at)
+	b.swaps++
+	b.volume0.Add(b.volume0, event.Amount0In)
+	b.volume0.Add(b.volume0, event.Amount0Out)
+	b.volume1.Add(b.volume1, event.Amount1In)
+	b.volume1.Add(b.volume1, event.Amount1Out)
+
+	if at.After(p.lastSwap) {
+		p.lastSwap = at
+	}
+}
+
+// RecordLiquidity adds a Mint, Burn or Fees event that happened at the given time.
+func (t *ActivityTracker) RecordLiquidity(event *LiquidityEvent, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.poolLocked(event.PoolAddress).bucket(at)
+	switch event.Type {
+	case LiquidityMint:
+		b.mints++
+	case LiquidityBurn:
+		b.burns++
+	case LiquidityFees:
+		b.fees0.Add(b.fees0, event.Amount0)
+		b.fees1.Add(b.fees1, event.Amount1)
+	}
+}
+
+// RecordSync adds a Sync that happened at the given time, and the log return of
+// the pool price since the previous Sync.
+func (t *ActivityTracker) RecordSync(event *SyncEvent, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.poolLocked(event.PoolAddress)
+	b := p.bucket(at)
+	b.syncs++
+
+	price := reservePrice(event.Reserve0, event.Reserve1)
+	if price > 0 && p.lastPrice > 0 {
+		r := math.Log(price / p.lastPrice)
+		b.squaredReturns += r * r
+	}
+	p.lastPrice = price
+}
+
+// RecordCycle adds a detected arbitrage cycle through pools at the given time.
+func (t *ActivityTracker) RecordCycle(pools []string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]struct{}, len(pools))
+	for _, pool := range pools {
+		pool = strings.ToLower(pool)
+		if _, ok := seen[pool]; ok {
+			continue
+		}
+		seen[pool] = struct{}{}
+		t.poolLocked(pool).bucket(at).cycles++
+	}
+}
+
+// ActivityCounts are a pool's activity counters over one period of the
+// window, as persisted across restarts. Volumes and fees aren't persisted.
+type ActivityCounts struct {
+	Pool           string
+	Start          time.Time
+	Swaps          int
+	Syncs          int
+	Cycles         int
+	SquaredReturns float64 // Sum of squared log price returns
+	LastSwap       time.Time
+}
+
+// Counts returns the activity counters of every pool in the window ending at
+// now, summed over periods of the given length.
+func (t *ActivityTracker) Counts(now time.Time, period time.Duration) []ActivityCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked(now)
+	var counts []ActivityCounts
+	for address, p := range t.pools {
+		for _, b := range p.buckets {
+			start := b.start.Truncate(period)
+			if n := len(counts); n == 0 || counts[n-1].Pool != address || !counts[n-1].Start.Equal(start) {
+				counts = append(counts, ActivityCounts{Pool: address, Start: start, LastSwap: p.lastSwap})
+			}
+			c := &counts[len(counts)-1]
+			c.Swaps += b.swaps
+			c.Syncs += b.syncs
+			c.Cycles += b.cycles
+			c.SquaredReturns += b.squaredReturns
+		}
+	}
+	return counts
+}
+
+// Restore adds counters persisted before a restart at the time they were
+// recorded, so they leave the window when they would have without the restart.
+func (t *ActivityTracker) Restore(counts ActivityCounts) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.poolLocked(counts.Pool)
+	b := p.insertBucket(counts.Start)
+	b.swaps += counts.Swaps
+	b.syncs += counts.Syncs
+	b.cycles += counts.Cycles
+	b.squaredReturns += counts.SquaredReturns
+
+	if counts.LastSwap.After(p.lastSwap) {
+		p.lastSwap = counts.LastSwap
+	}
+}
+
+// reservePrice returns reserve1/reserve0, or 0 if either reserve is empty.
+func reservePrice(reserve0, reserve1 *big.Int) float64 {
+	if reserve0 == nil || reserve1 == nil || reserve0.Sign() <= 0 || reserve1.Sign() <= 0 {
+		return 0
+	}
+	price, _ := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0)).Float64()
+	return price
+}
+
+// Activity returns a pool's activity over the window ending at now.
+// Returns false if the pool has no activity in the window.
+func (t *ActivityTracker) Activity(address string, now time.Time) (PoolActivity, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	address = strings.ToLower(address)
+	p, ok := t.pools[address]
+	if !ok {
+		return PoolActivity{}, false
+	}
+
+	p.prune(now.Add(-t.window))
+	if len(p.buckets) == 0 {
+		return PoolActivity{}, false
+	}
+	return t.summarize(address, p), true
+}
+
+// All returns the activity of every pool active in the window ending at now.
+func (t *ActivityTracker) All(now time.Time) map[string]PoolActivity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked(now)
+	all := make(map[string]PoolActivity, len(t.pools))
+	for address, p := range t.pools {
+		all[address] = t.summarize(address, p)
+	}
+	return all
+}
+
+// Prune drops activity older than the window ending at now and returns the
+// number of pools with at least one swap left in the window.
+func (t *ActivityTracker) Prune(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked(now)
+	cutoff := now.Add(-t.window)
+	active := 0
+	for _, p := range t.pools {
+		if !p.lastSwap.Before(cutoff) {
+			active++
+		}
+	}
+	return active
+}
+
+// pruneLocked drops old buckets and pools without any. Must be called with t.mu held.
+func (t *ActivityTracker) pruneLocked(now time.Time) {
+	cutoff := now.Add(-t.window)
+	for address, p := range t.pools {
+		p.prune(cutoff)
+		if len(p.buckets) == 0 {
+			delete(t.pools, address)
+		}
+	}
+}
+
+// summarize sums a pool's buckets. Must be called with t.mu held.
+func (t *ActivityTracker) summarize(address string, p *poolActivity) PoolActivity {
+	activity := PoolActivity{
+		Pool:     address,
+		Window:   t.window,
+		Volume0:  new(big.Int),
+		Volume1:  new(big.Int),
+		Fees0:    new(big.Int),
+		Fees1:    new(big.Int),
+		LastSwap: p.lastSwap,
+	}
+	squaredReturns := 0.0
+	for _, b := range p.buckets {
+		activity.Swaps += b.swaps
+		activity.Mints += b.mints
+		activity.Burns += b.burns
+		activity.Syncs += b.syncs
+		activity.Cycles += b.cycles
+		squaredReturns += b.squaredReturns
+		activity.Volume0.Add(activity.Volume0, b.volume0)
+		activity.Volume1.Add(activity.Volume1, b.volume1)
+		activity.Fees0.Add(activity.Fees0, b.fees0)
+		activity.Fees1.Add(activity.Fees1, b.fees1)
+	}
+	activity.Volatility = math.Sqrt(squaredReturns)
+	return activity
+}
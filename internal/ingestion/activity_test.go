@@ -0,0 +1,162 @@
+package ingestion
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const activityPool = "0x1234567890123456789012345678901234567890"
+
+func testSwap(amount0In, amount1Out int64) *SwapEvent {
+	return &SwapEvent{
+		PoolAddress: activityPool,
+		Amount0In:   big.NewInt(amount0In),
+		Amount1In:   big.NewInt(0),
+		Amount0Out:  big.NewInt(0),
+		Amount1Out:  big.NewInt(amount1Out),
+	}
+}
+
+func TestActivityTrackerAggregatesWindow(t *testing.T) {
+	tracker := NewActivityTracker(time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker.RecordSwap(testSwap(100, 200), start)
+	tracker.RecordLiquidity(&LiquidityEvent{Type: LiquidityMint, PoolAddress: activityPool, Amount0: big.NewInt(1), Amount1: big.NewInt(1)}, start)
+	tracker.RecordSwap(testSwap(50, 80), start.Add(30*time.Minute))
+	tracker.RecordLiquidity(&LiquidityEvent{Type: LiquidityFees, PoolAddress: activityPool, Amount0: big.NewInt(3), Amount1: big.NewInt(0)}, start.Add(30*time.Minute))
+
+	activity, ok := tracker.Activity("0x1234567890123456789012345678901234567890", start.Add(45*time.Minute))
+	require.True(t, ok)
+	require.Equal(t, 2, activity.Swaps)
+	require.Equal(t, 1, activity.Mints)
+	require.Equal(t, big.NewInt(150), activity.Volume0)
+	require.Equal(t, big.NewInt(280), activity.Volume1)
+	require.Equal(t, big.NewInt(3), activity.Fees0)
+	require.Equal(t, start.Add(30*time.Minute), activity.LastSwap)
+
+	// The first swap falls out of the window
+	activity, ok = tracker.Activity(activityPool, start.Add(80*time.Minute))
+	require.True(t, ok)
+	require.Equal(t, 1, activity.Swaps)
+	require.Equal(t, 0, activity.Mints)
+	require.Equal(t, big.NewInt(50), activity.Volume0)
+
+	// Everything falls out of the window
+	_, ok = tracker.Activity(activityPool, start.Add(3*time.Hour))
+	require.False(t, ok)
+}
+
+func TestActivityTrackerPruneCountsActivePools(t *testing.T) {
+	tracker := NewActivityTracker(time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker.RecordSwap(testSwap(1, 1), start)
+	// A pool with only liquidity events is tracked but not active
+	tracker.RecordLiquidity(&LiquidityEvent{Type: LiquidityBurn, PoolAddress: "0xother", Amount0: big.NewInt(1), Amount1: big.NewInt(1)}, start)
+
+	require.Equal(t, 1, tracker.Prune(start.Add(time.Minute)))
+	require.Len(t, tracker.All(start.Add(time.Minute)), 2)
+
+	require.Equal(t, 0, tracker.Prune(start.Add(2*time.Hour)))
+	require.Empty(t, tracker.All(start.Add(2*time.Hour)))
+}
+
+func TestActivityTrackerSyncsVolatilityAndCycles(t *testing.T) {
+	tracker := NewActivityTracker(time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	sync := func(reserve0, reserve1 int64) *SyncEvent {
+		return &SyncEvent{PoolAddress: activityPool, Reserve0: big.NewInt(reserve0), Reserve1: big.NewInt(reserve1)}
+	}
+	// Price 2, then 4, then 2: two log returns of ln 2
+	tracker.RecordSync(sync(100, 200), start)
+	tracker.RecordSync(sync(100, 400), start.Add(time.Minute))
+	tracker.RecordSync(sync(200, 400), start.Add(2*time.Minute))
+
+	// A pool repeated within one cycle counts once
+	tracker.RecordCycle([]string{activityPool, "0xother", activityPool}, start.Add(2*time.Minute))
+	tracker.RecordCycle([]string{"0x1234567890123456789012345678901234567890"}, start.Add(3*time.Minute))
+
+	activity, ok := tracker.Activity(activityPool, start.Add(5*time.Minute))
+	require.True(t, ok)
+	require.Equal(t, 3, activity.Syncs)
+	require.Equal(t, 2, activity.Cycles)
+	require.InDelta(t, math.Sqrt2*math.Ln2, activity.Volatility, 1e-9)
+
+	other, ok := tracker.Activity("0xother", start.Add(5*time.Minute))
+	require.True(t, ok)
+	require.Equal(t, 1, other.Cycles)
+}
+
+func TestActivityTrackerRestore(t *testing.T) {
+	tracker := NewActivityTracker(time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker.RecordSwap(testSwap(1, 1), start.Add(30*time.Minute))
+	tracker.RecordSync(&SyncEvent{PoolAddress: activityPool, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)}, start.Add(30*time.Minute))
+	tracker.Restore(ActivityCounts{Pool: activityPool, Start: start, Syncs: 10, Swaps: 4, Cycles: 2, SquaredReturns: 0.09})
+
+	activity, ok := tracker.Activity(activityPool, start.Add(31*time.Minute))
+	require.True(t, ok)
+	require.Equal(t, 11, activity.Syncs)
+	require.Equal(t, 5, activity.Swaps)
+	require.Equal(t, 2, activity.Cycles)
+	require.InDelta(t, 0.3, activity.Volatility, 1e-9)
+
+	// Counts are summed per period, at the period's start
+	counts := tracker.Counts(start.Add(31*time.Minute), 20*time.Minute)
+	require.Len(t, counts, 2)
+	require.Equal(t, ActivityCounts{Pool: activityPool, Start: start, Syncs: 10, Swaps: 4, Cycles: 2, SquaredReturns: 0.09,
+		LastSwap: start.Add(30 * time.Minute)}, counts[0])
+	require.Equal(t, start.Add(20*time.Minute), counts[1].Start)
+	require.Equal(t, 1, counts[1].Syncs)
+
+	// Restored counters are kept at their own time and age out then
+	activity, ok = tracker.Activity(activityPool, start.Add(61*time.Minute+30*time.Second))
+	require.True(t, ok)
+	require.Equal(t, 1, activity.Syncs)
+	require.Equal(t, 1, activity.Swaps)
+}
+
+func TestServiceRecordsSwapsForTrackedPools(t *testing.T) {
+	service := NewService(nil, "", nil, nil)
+	service.SetTrackedPools([]string{activityPool})
+
+	swap := func(address, logIndex string) *LogEntry {
+		return &LogEntry{
+			Address: address,
+			Topics: []string{
+				SwapEventTopic.Hex(),
+				"0x000000000000000000000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+				"0x000000000000000000000000bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			},
+			Data: "0x" +
+				"00000000000000000000000000000000000000000000000000000000000003e8" +
+				"0000000000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000000" +
+				"00000000000000000000000000000000000000000000000000000000000007d0",
+			BlockNumber: "0x64",
+			BlockHash:   "0xhash",
+			LogIndex:    logIndex,
+		}
+	}
+
+	now := time.Now()
+	service.processLogEntry(nil, swap(activityPool, "0x0"), now)
+	service.processLogEntry(nil, swap(activityPool, "0x0"), now) // duplicate delivery
+	service.processLogEntry(nil, swap("0x9999999999999999999999999999999999999999", "0x1"), now)
+
+	activity, ok := service.Activity().Activity(activityPool, now)
+	require.True(t, ok)
+	require.Equal(t, 1, activity.Swaps)
+	require.Equal(t, big.NewInt(1000), activity.Volume0)
+	require.Equal(t, big.NewInt(2000), activity.Volume1)
+
+	_, ok = service.Activity().Activity("0x9999999999999999999999999999999999999999", now)
+	require.False(t, ok, "untracked pool")
+}
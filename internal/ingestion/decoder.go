@@ -6,6 +6,8 @@ import (
 	"strings"
 	"time"
 
+	"watcher/internal/graph"
+
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -19,6 +21,19 @@ var (
 
 	// PoolCreated(address,address,bool,address,uint256) - Emitted when a new pool is created
 	PoolCreatedEventTopic = crypto.Keccak256Hash([]byte("PoolCreated(address,address,bool,address,uint256)"))
+
+	// Swap(address indexed sender, address indexed to, uint256 amount0In, uint256 amount1In,
+	// uint256 amount0Out, uint256 amount1Out) - Emitted on every trade
+	SwapEventTopic = crypto.Keccak256Hash([]byte("Swap(address,address,uint256,uint256,uint256,uint256)"))
+
+	// Mint(address indexed sender, uint256 amount0, uint256 amount1) - Emitted when liquidity is added
+	MintEventTopic = crypto.Keccak256Hash([]byte("Mint(address,uint256,uint256)"))
+
+	// Burn(address indexed sender, address indexed to, uint256 amount0, uint256 amount1) - Emitted when liquidity is removed
+	BurnEventTopic = crypto.Keccak256Hash([]byte("Burn(address,address,uint256,uint256)"))
+
+	// Fees(address indexed sender, uint256 amount0, uint256 amount1) - Emitted when swap fees accrue
+	FeesEventTopic = crypto.Keccak256Hash([]byte("Fees(address,uint256,uint256)"))
 )
 
 // SyncEvent represents a decoded Sync event.
@@ -29,7 +44,11 @@ type SyncEvent struct {
 	BlockNumber uint64
 	LogIndex    uint
 	TxHash      string
+	BlockHash   string
 	Timestamp   time.Time
+
+	// Block timestamp, zero until the block header has been seen
+	BlockTime time.Time
 }
 
 // PoolCreatedEvent represents a decoded PoolCreated event.
@@ -44,6 +63,43 @@ type PoolCreatedEvent struct {
 	TxHash      string
 }
 
+// SwapEvent represents a decoded Swap event.
+type SwapEvent struct {
+	PoolAddress string
+	Sender      string
+	To          string
+	Amount0In   *big.Int
+	Amount1In   *big.Int
+	Amount0Out  *big.Int
+	Amount1Out  *big.Int
+	BlockNumber uint64
+	LogIndex    uint
+	TxHash      string
+}
+
+// LiquidityEventType distinguishes the pool events that carry a pair of token amounts.
+type LiquidityEventType string
+
+const (
+	LiquidityMint LiquidityEventType = "mint"
+	LiquidityBurn LiquidityEventType = "burn"
+	LiquidityFees LiquidityEventType = "fees"
+)
+
+// LiquidityEvent represents a decoded Mint, Burn or Fees event.
+// To is only set for Burn.
+type LiquidityEvent struct {
+	Type        LiquidityEventType
+	PoolAddress string
+	Sender      string
+	To          string
+	Amount0     *big.Int
+	Amount1     *big.Int
+	BlockNumber uint64
+	LogIndex    uint
+	TxHash      string
+}
+
 // LogEntry represents a raw log entry from the WebSocket.
 type LogEntry struct {
 	Address          string   `json:"address"`
@@ -57,10 +113,20 @@ type LogEntry struct {
 	Removed          bool     `json:"removed"`
 }
 
+// HeaderEntry represents a raw block header from a newHeads subscription.
+type HeaderEntry struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+}
+
 // Decoder handles event decoding.
 type Decoder struct {
 	syncABI        abi.Arguments
 	poolCreatedABI abi.Arguments
+	swapABI        abi.Arguments
+	amountsABI     abi.Arguments
 }
 
 // NewDecoder creates a new event decoder.
@@ -86,9 +152,25 @@ func NewDecoder() *Decoder {
 		{Type: uint256Type, Name: "index"},
 	}
 
+	// Swap event: sender and to are indexed, the four amounts are in data
+	swapABI := abi.Arguments{
+		{Type: uint256Type, Name: "amount0In"},
+		{Type: uint256Type, Name: "amount1In"},
+		{Type: uint256Type, Name: "amount0Out"},
+		{Type: uint256Type, Name: "amount1Out"},
+	}
+
+	// Mint, Burn and Fees events: addresses are indexed, two amounts in data
+	amountsABI := abi.Arguments{
+		{Type: uint256Type, Name: "amount0"},
+		{Type: uint256Type, Name: "amount1"},
+	}
+
 	return &Decoder{
 		syncABI:        syncABI,
 		poolCreatedABI: poolCreatedABI,
+		swapABI:        swapABI,
+		amountsABI:     amountsABI,
 	}
 }
 
@@ -142,6 +224,7 @@ func (d *Decoder) DecodeSyncEvent(log *LogEntry) (*SyncEvent, error) {
 		BlockNumber: blockNum,
 		LogIndex:    logIdx,
 		TxHash:      log.TransactionHash,
+		BlockHash:   strings.ToLower(log.BlockHash),
 		Timestamp:   time.Now(),
 	}, nil
 }
@@ -210,6 +293,125 @@ func (d *Decoder) DecodePoolCreatedEvent(log *LogEntry) (*PoolCreatedEvent, erro
 	}, nil
 }
 
+// DecodeSwapEvent decodes a Swap event from a log entry.
+func (d *Decoder) DecodeSwapEvent(log *LogEntry) (*SwapEvent, error) {
+	if len(log.Topics) < 3 {
+		return nil, fmt.Errorf("insufficient topics for Swap: %d", len(log.Topics))
+	}
+
+	// Verify event signature
+	topic := common.HexToHash(log.Topics[0])
+	if topic != SwapEventTopic {
+		return nil, fmt.Errorf("not a Swap event: %s", log.Topics[0])
+	}
+
+	data := common.FromHex(log.Data)
+	if len(data) < 128 {
+		return nil, fmt.Errorf("data too short for Swap: %d bytes", len(data))
+	}
+
+	values, err := d.swapABI.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking Swap data: %w", err)
+	}
+
+	amounts := make([]*big.Int, len(values))
+	for i, value := range values {
+		amount, ok := value.(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s type", d.swapABI[i].Name)
+		}
+		amounts[i] = amount
+	}
+
+	blockNum, logIdx, err := logPosition(log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SwapEvent{
+		PoolAddress: strings.ToLower(log.Address),
+		Sender:      strings.ToLower(common.HexToAddress(log.Topics[1]).Hex()),
+		To:          strings.ToLower(common.HexToAddress(log.Topics[2]).Hex()),
+		Amount0In:   amounts[0],
+		Amount1In:   amounts[1],
+		Amount0Out:  amounts[2],
+		Amount1Out:  amounts[3],
+		BlockNumber: blockNum,
+		LogIndex:    logIdx,
+		TxHash:      log.TransactionHash,
+	}, nil
+}
+
+// DecodeLiquidityEvent decodes a Mint, Burn or Fees event from a log entry.
+func (d *Decoder) DecodeLiquidityEvent(log *LogEntry) (*LiquidityEvent, error) {
+	if len(log.Topics) < 2 {
+		return nil, fmt.Errorf("insufficient topics: %d", len(log.Topics))
+	}
+
+	event := &LiquidityEvent{
+		PoolAddress: strings.ToLower(log.Address),
+		Sender:      strings.ToLower(common.HexToAddress(log.Topics[1]).Hex()),
+		TxHash:      log.TransactionHash,
+	}
+
+	// Verify event signature
+	switch common.HexToHash(log.Topics[0]) {
+	case MintEventTopic:
+		event.Type = LiquidityMint
+	case FeesEventTopic:
+		event.Type = LiquidityFees
+	case BurnEventTopic:
+		if len(log.Topics) < 3 {
+			return nil, fmt.Errorf("insufficient topics for Burn: %d", len(log.Topics))
+		}
+		event.Type = LiquidityBurn
+		event.To = strings.ToLower(common.HexToAddress(log.Topics[2]).Hex())
+	default:
+		return nil, fmt.Errorf("not a Mint, Burn or Fees event: %s", log.Topics[0])
+	}
+
+	data := common.FromHex(log.Data)
+	if len(data) < 64 {
+		return nil, fmt.Errorf("data too short for %s: %d bytes", event.Type, len(data))
+	}
+
+	values, err := d.amountsABI.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking %s data: %w", event.Type, err)
+	}
+
+	var ok bool
+	if event.Amount0, ok = values[0].(*big.Int); !ok {
+		return nil, fmt.Errorf("invalid amount0 type")
+	}
+	if event.Amount1, ok = values[1].(*big.Int); !ok {
+		return nil, fmt.Errorf("invalid amount1 type")
+	}
+
+	event.BlockNumber, event.LogIndex, err = logPosition(log)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// logPosition parses a log's block number and log index.
+func logPosition(log *LogEntry) (uint64, uint, error) {
+	blockNum, err := hexToUint64(log.BlockNumber)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing block number: %w", err)
+	}
+
+	logIdx, err := hexToUint(log.LogIndex)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing log index: %w", err)
+	}
+
+	return blockNum, logIdx, nil
+}
+
 // IsSyncEvent checks if a log entry is a Sync event.
 func IsSyncEvent(log *LogEntry) bool {
 	if len(log.Topics) < 1 {
@@ -226,6 +428,26 @@ func IsPoolCreatedEvent(log *LogEntry) bool {
 	return common.HexToHash(log.Topics[0]) == PoolCreatedEventTopic
 }
 
+// IsSwapEvent checks if a log entry is a Swap event.
+func IsSwapEvent(log *LogEntry) bool {
+	if len(log.Topics) < 1 {
+		return false
+	}
+	return common.HexToHash(log.Topics[0]) == SwapEventTopic
+}
+
+// IsLiquidityEvent checks if a log entry is a Mint, Burn or Fees event.
+func IsLiquidityEvent(log *LogEntry) bool {
+	if len(log.Topics) < 1 {
+		return false
+	}
+	switch common.HexToHash(log.Topics[0]) {
+	case MintEventTopic, BurnEventTopic, FeesEventTopic:
+		return true
+	}
+	return false
+}
+
 func hexToUint64(s string) (uint64, error) {
 	s = strings.TrimPrefix(s, "0x")
 	var val uint64
@@ -239,3 +461,26 @@ func hexToUint(s string) (uint, error) {
 	_, err := fmt.Sscanf(s, "%x", &val)
 	return val, err
 }
+
+// DecodeHeader decodes a newHeads notification into a block header.
+func (d *Decoder) DecodeHeader(header *HeaderEntry) (graph.BlockHeader, error) {
+	if header.Hash == "" {
+		return graph.BlockHeader{}, fmt.Errorf("header missing hash")
+	}
+
+	number, err := hexToUint64(header.Number)
+	if err != nil {
+		return graph.BlockHeader{}, fmt.Errorf("parsing block number: %w", err)
+	}
+
+	timestamp, err := hexToUint64(header.Timestamp)
+	if err != nil {
+		return graph.BlockHeader{}, fmt.Errorf("parsing timestamp: %w", err)
+	}
+
+	return graph.BlockHeader{
+		Number: number,
+		Hash:   strings.ToLower(header.Hash),
+		Time:   time.Unix(int64(timestamp), 0),
+	}, nil
+}
@@ -276,3 +276,114 @@ func TestTrackedPoolMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeHeader(t *testing.T) {
+	decoder := NewDecoder()
+
+	header, err := decoder.DecodeHeader(&HeaderEntry{
+		Number:     "0x64",
+		Hash:       "0xABCDEF",
+		ParentHash: "0x1234",
+		Timestamp:  "0x6553f100",
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), header.Number)
+	require.Equal(t, "0xabcdef", header.Hash)
+	require.Equal(t, int64(0x6553f100), header.Time.Unix())
+
+	_, err = decoder.DecodeHeader(&HeaderEntry{Number: "0x64", Timestamp: "0x1"})
+	require.Error(t, err, "header without hash")
+}
+
+func TestDecodeSwapEvent(t *testing.T) {
+	decoder := NewDecoder()
+
+	logEntry := &LogEntry{
+		Address: "0x1234567890123456789012345678901234567890",
+		Topics: []string{
+			SwapEventTopic.Hex(),
+			"0x000000000000000000000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", // sender
+			"0x000000000000000000000000bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", // to
+		},
+		Data: "0x" +
+			"0000000000000000000000000000000000000000000000000de0b6b3a7640000" + // amount0In = 1e18
+			"0000000000000000000000000000000000000000000000000000000000000000" + // amount1In
+			"0000000000000000000000000000000000000000000000000000000000000000" + // amount0Out
+			"0000000000000000000000000000000000000000000000001bc16d674ec80000", // amount1Out = 2e18
+		BlockNumber:     "0x1234",
+		TransactionHash: "0xabcd",
+		LogIndex:        "0x2",
+	}
+
+	require.True(t, IsSwapEvent(logEntry))
+	require.False(t, IsSyncEvent(logEntry))
+
+	event, err := decoder.DecodeSwapEvent(logEntry)
+	require.NoError(t, err)
+	require.Equal(t, "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", event.Sender)
+	require.Equal(t, "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", event.To)
+	require.Equal(t, big.NewInt(1000000000000000000), event.Amount0In)
+	require.Equal(t, 0, event.Amount1In.Sign())
+	require.Equal(t, 0, event.Amount0Out.Sign())
+	require.Equal(t, big.NewInt(2000000000000000000), event.Amount1Out)
+	require.Equal(t, uint64(0x1234), event.BlockNumber)
+	require.Equal(t, uint(2), event.LogIndex)
+
+	logEntry.Data = "0x0de0b6b3a7640000"
+	_, err = decoder.DecodeSwapEvent(logEntry)
+	require.Error(t, err, "data too short")
+}
+
+func TestDecodeLiquidityEvent(t *testing.T) {
+	decoder := NewDecoder()
+
+	sender := "0x000000000000000000000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	to := "0x000000000000000000000000bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	data := "0x" +
+		"00000000000000000000000000000000000000000000000000000000000003e8" + // amount0 = 1000
+		"00000000000000000000000000000000000000000000000000000000000007d0" // amount1 = 2000
+
+	tests := []struct {
+		name     string
+		topics   []string
+		wantType LiquidityEventType
+		wantTo   string
+	}{
+		{"mint", []string{MintEventTopic.Hex(), sender}, LiquidityMint, ""},
+		{"burn", []string{BurnEventTopic.Hex(), sender, to}, LiquidityBurn, "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+		{"fees", []string{FeesEventTopic.Hex(), sender}, LiquidityFees, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logEntry := &LogEntry{
+				Address:     "0x1234567890123456789012345678901234567890",
+				Topics:      tt.topics,
+				Data:        data,
+				BlockNumber: "0x10",
+				LogIndex:    "0x1",
+			}
+			require.True(t, IsLiquidityEvent(logEntry))
+
+			event, err := decoder.DecodeLiquidityEvent(logEntry)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantType, event.Type)
+			require.Equal(t, "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", event.Sender)
+			require.Equal(t, tt.wantTo, event.To)
+			require.Equal(t, big.NewInt(1000), event.Amount0)
+			require.Equal(t, big.NewInt(2000), event.Amount1)
+		})
+	}
+
+	_, err := decoder.DecodeLiquidityEvent(&LogEntry{
+		Topics: []string{BurnEventTopic.Hex(), sender},
+		Data:   data,
+	})
+	require.Error(t, err, "burn without recipient topic")
+
+	_, err = decoder.DecodeLiquidityEvent(&LogEntry{
+		Topics: []string{SyncEventTopic.Hex(), sender},
+		Data:   data,
+	})
+	require.Error(t, err, "not a liquidity event")
+}
@@ -0,0 +1,78 @@
+package ingestion
+
+import (
+	"strings"
+	"time"
+)
+
+// defaultDedupCapacity bounds how many recent logs are remembered for deduplication.
+// Base produces a block every 2 seconds, so this covers several minutes of Sync traffic.
+const defaultDedupCapacity = 50000
+
+// headerLogIndex is the log index used to key block headers in the deduper.
+// No real log in a block can have this index.
+const headerLogIndex = ^uint(0)
+
+// logKey uniquely identifies a log within the canonical chain.
+type logKey struct {
+	blockHash string
+	logIndex  uint
+	removed   bool // Keys a log's removal by a reorg, apart from its delivery
+}
+
+// newLogKey builds a dedup key from a raw log entry.
+// Returns false if the entry lacks the fields needed to identify it.
+func newLogKey(entry *LogEntry) (logKey, bool) {
+	if entry.BlockHash == "" || entry.LogIndex == "" {
+		return logKey{}, false
+	}
+
+	idx, err := hexToUint(entry.LogIndex)
+	if err != nil {
+		return logKey{}, false
+	}
+
+	return logKey{
+		blockHash: strings.ToLower(entry.BlockHash),
+		logIndex:  idx,
+	}, true
+}
+
+// logDeduper remembers recently seen logs so that each event is applied once,
+// even when several providers deliver it. Oldest entries are evicted first.
+type logDeduper struct {
+	seen  map[logKey]time.Time
+	order []logKey
+	next  int
+}
+
+// newLogDeduper creates a deduper remembering up to capacity logs.
+func newLogDeduper(capacity int) *logDeduper {
+	return &logDeduper{
+		seen:  make(map[logKey]time.Time, capacity),
+		order: make([]logKey, capacity),
+	}
+}
+
+// Check records the key as seen at receivedAt.
+// If the key was already seen, it returns the time of the first delivery and true.
+func (d *logDeduper) Check(key logKey, receivedAt time.Time) (time.Time, bool) {
+	if first, exists := d.seen[key]; exists {
+		return first, true
+	}
+
+	// Evict the oldest entry once the ring is full
+	if evicted := d.order[d.next]; evicted != (logKey{}) {
+		delete(d.seen, evicted)
+	}
+	d.order[d.next] = key
+	d.next = (d.next + 1) % len(d.order)
+
+	d.seen[key] = receivedAt
+	return receivedAt, false
+}
+
+// Len returns the number of remembered logs.
+func (d *logDeduper) Len() int {
+	return len(d.seen)
+}
@@ -0,0 +1,172 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"watcher/internal/graph"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogDeduperFirstDeliveryWins(t *testing.T) {
+	dedup := newLogDeduper(10)
+	key := logKey{blockHash: "0xabc", logIndex: 3}
+
+	first := time.Unix(1000, 0)
+	seenAt, duplicate := dedup.Check(key, first)
+	require.False(t, duplicate)
+	require.Equal(t, first, seenAt)
+
+	// A later delivery of the same log reports the original receive time
+	seenAt, duplicate = dedup.Check(key, first.Add(150*time.Millisecond))
+	require.True(t, duplicate)
+	require.Equal(t, first, seenAt)
+
+	// Same index in a different block is a different log
+	_, duplicate = dedup.Check(logKey{blockHash: "0xdef", logIndex: 3}, first)
+	require.False(t, duplicate)
+}
+
+func TestLogDeduperEvictsOldest(t *testing.T) {
+	dedup := newLogDeduper(2)
+	now := time.Now()
+
+	dedup.Check(logKey{blockHash: "0x1", logIndex: 0}, now)
+	dedup.Check(logKey{blockHash: "0x2", logIndex: 0}, now)
+	dedup.Check(logKey{blockHash: "0x3", logIndex: 0}, now)
+	require.Equal(t, 2, dedup.Len())
+
+	// The first key was evicted and is treated as new again
+	_, duplicate := dedup.Check(logKey{blockHash: "0x1", logIndex: 0}, now)
+	require.False(t, duplicate)
+
+	_, duplicate = dedup.Check(logKey{blockHash: "0x3", logIndex: 0}, now)
+	require.True(t, duplicate)
+}
+
+func TestNewLogKey(t *testing.T) {
+	key, ok := newLogKey(&LogEntry{BlockHash: "0xABC", LogIndex: "0x1f"})
+	require.True(t, ok)
+	require.Equal(t, logKey{blockHash: "0xabc", logIndex: 31}, key)
+
+	_, ok = newLogKey(&LogEntry{LogIndex: "0x1"})
+	require.False(t, ok, "missing block hash")
+
+	_, ok = newLogKey(&LogEntry{BlockHash: "0xabc"})
+	require.False(t, ok, "missing log index")
+}
+
+func TestNewProvidersNaming(t *testing.T) {
+	providers := newProviders([]string{
+		"wss://base.example.com/v2/secret-key",
+		"wss://base.example.com/v2/other-key",
+		"wss://other.example.org/ws",
+		"::not a url",
+	})
+	require.Len(t, providers, 4)
+
+	require.Equal(t, "base.example.com", providers[0].name)
+	require.Equal(t, "base.example.com#2", providers[1].name)
+	require.Equal(t, "other.example.org", providers[2].name)
+	require.Equal(t, "provider-3", providers[3].name)
+}
+
+func TestProcessMessageAppliesFirstDeliveryOnly(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	service := NewService([]string{"ws://a", "ws://b"}, "", graphManager, nil)
+	service.SetTrackedPools([]string{"0x1234567890123456789012345678901234567890"})
+
+	notification := map[string]interface{}{
+		"subscription": "0x1",
+		"result": LogEntry{
+			Address: "0x1234567890123456789012345678901234567890",
+			Topics:  []string{SyncEventTopic.Hex()},
+			Data: "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+				"0000000000000000000000000000000000000000000000001bc16d674ec80000",
+			BlockNumber: "0x64",
+			BlockHash:   "0xblockhash",
+			LogIndex:    "0x2",
+		},
+	}
+	raw, err := json.Marshal(notification)
+	require.NoError(t, err)
+
+	now := time.Now()
+	n := Notification{Subscription: "0x1", Kind: SubscriptionLogs, Params: raw}
+	service.processMessage(service.providers[0], n, now)
+	service.processMessage(service.providers[1], n, now.Add(50*time.Millisecond))
+
+	require.Len(t, service.SyncEvents(), 1, "duplicate delivery must not be applied")
+	require.Equal(t, uint64(100), service.providers[0].lastBlock.Load())
+	require.Equal(t, uint64(100), service.providers[1].lastBlock.Load())
+}
+
+func TestProcessMessageHeaderClosesBlock(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	pool := "0x1234567890123456789012345678901234567890"
+	graphManager.AddPool(
+		graph.PoolState{
+			Address:  pool,
+			Token0:   "0xtoken0",
+			Token1:   "0xtoken1",
+			Reserve0: big.NewInt(1000000),
+			Reserve1: big.NewInt(2000000),
+			Fee:      0.003,
+		},
+		graph.TokenInfo{Address: "0xtoken0", Symbol: "TKN0", Decimals: 18},
+		graph.TokenInfo{Address: "0xtoken1", Symbol: "TKN1", Decimals: 18},
+	)
+
+	service := NewService([]string{"ws://a", "ws://b"}, "", graphManager, nil)
+	service.SetTrackedPools([]string{pool})
+
+	syncLog, err := json.Marshal(map[string]interface{}{
+		"subscription": "0x1",
+		"result": LogEntry{
+			Address: pool,
+			Topics:  []string{SyncEventTopic.Hex()},
+			Data: "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+				"0000000000000000000000000000000000000000000000001bc16d674ec80000",
+			BlockNumber: "0x64",
+			BlockHash:   "0xblockhash",
+			LogIndex:    "0x0",
+		},
+	})
+	require.NoError(t, err)
+
+	header, err := json.Marshal(map[string]interface{}{
+		"subscription": "0x2",
+		"result": HeaderEntry{
+			Number:     "0x64",
+			Hash:       "0xblockhash",
+			ParentHash: "0xparent",
+			Timestamp:  "0x6553f100",
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	logs := Notification{Subscription: "0x1", Kind: SubscriptionLogs, Params: syncLog}
+	heads := Notification{Subscription: "0x2", Kind: SubscriptionNewHeads, Params: header}
+	service.processMessage(service.providers[0], logs, now)
+	service.processMessage(service.providers[0], heads, now)
+	service.processMessage(service.providers[1], heads, now) // duplicate header
+
+	select {
+	case snap := <-graphManager.SnapshotCh():
+		require.Equal(t, uint64(100), snap.BlockNumber)
+		require.Equal(t, "0xblockhash", snap.BlockHash)
+		require.Equal(t, int64(0x6553f100), snap.BlockTime.Unix())
+	default:
+		t.Fatal("expected the header to close block 100")
+	}
+	require.Len(t, graphManager.SnapshotCh(), 0, "duplicate header must not create another snapshot")
+	require.Equal(t, uint64(100), service.providers[1].lastBlock.Load())
+}
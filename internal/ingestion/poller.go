@@ -0,0 +1,98 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"watcher/internal/graph"
+	"watcher/pkg/chain/base"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Mode selects how the ingestion service receives events.
+type Mode string
+
+const (
+	// ModeWebSocket streams logs over eth_subscribe, falling back to polling
+	// if a poller is configured and every provider fails.
+	ModeWebSocket Mode = "websocket"
+
+	// ModeHTTP polls eth_blockNumber and eth_getLogs over HTTP RPC.
+	ModeHTTP Mode = "http"
+)
+
+// Poller fetches new logs over HTTP RPC for environments without WebSocket access.
+type Poller struct {
+	client *base.Client
+}
+
+// NewPoller creates a new poller.
+func NewPoller(client *base.Client) *Poller {
+	return &Poller{client: client}
+}
+
+// Head returns the current block number.
+func (p *Poller) Head(ctx context.Context) (uint64, error) {
+	head, err := p.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting block number: %w", err)
+	}
+	return head, nil
+}
+
+// Header returns the header of the given block.
+func (p *Poller) Header(ctx context.Context, number uint64) (graph.BlockHeader, error) {
+	header, err := p.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return graph.BlockHeader{}, fmt.Errorf("getting header %d: %w", number, err)
+	}
+
+	return graph.BlockHeader{
+		Number: number,
+		Hash:   strings.ToLower(header.Hash().Hex()),
+		Time:   time.Unix(int64(header.Time), 0),
+	}, nil
+}
+
+// Poll fetches logs with the given topics from the given addresses for the block range.
+// Logs are returned in (block, logIndex) order.
+func (p *Poller) Poll(ctx context.Context, addresses []string, topics []common.Hash, fromBlock, toBlock uint64) ([]*LogEntry, error) {
+	contracts := make([]common.Address, len(addresses))
+	for i, addr := range addresses {
+		contracts[i] = common.HexToAddress(addr)
+	}
+
+	return fetchLogs(ctx, p.client, contracts, topics, fromBlock, toBlock)
+}
+
+// PollSplit is Poll for queries the node may reject as too large, such as
+// topics without an address filter: a rejected range is halved until each
+// part fits. Logs are returned in (block, logIndex) order.
+func (p *Poller) PollSplit(ctx context.Context, addresses []string, topics []common.Hash, fromBlock, toBlock uint64) ([]*LogEntry, error) {
+	logs, err := p.Poll(ctx, addresses, topics, fromBlock, toBlock)
+	if err == nil || !isRangeTooLarge(err) || fromBlock >= toBlock {
+		return logs, err
+	}
+
+	mid := fromBlock + (toBlock-fromBlock)/2
+	log.Debug().
+		Err(err).
+		Uint64("from_block", fromBlock).
+		Uint64("to_block", toBlock).
+		Msg("Log range too large, splitting")
+
+	lower, err := p.PollSplit(ctx, addresses, topics, fromBlock, mid)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := p.PollSplit(ctx, addresses, topics, mid+1, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	return append(lower, upper...), nil
+}
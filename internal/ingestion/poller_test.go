@@ -0,0 +1,140 @@
+package ingestion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"watcher/internal/graph"
+	"watcher/internal/mocknode"
+	"watcher/pkg/chain/base"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHTTPModeRequiresPoller(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	service := NewService(nil, "", graphManager, nil)
+	service.SetMode(ModeHTTP)
+
+	err := service.Run(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "http polling not configured")
+}
+
+func TestRunWebSocketModeWithoutProvidersOrFallback(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	service := NewService(nil, "", graphManager, nil)
+
+	err := service.Run(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no websocket providers configured")
+}
+
+func TestPolledLogDedupedAgainstWebSocket(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	pool := "0x1234567890123456789012345678901234567890"
+	service := NewService([]string{"ws://a"}, "", graphManager, nil)
+	service.SetTrackedPools([]string{pool})
+
+	logEntry := &LogEntry{
+		Address: pool,
+		Topics:  []string{SyncEventTopic.Hex()},
+		Data: "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+			"0000000000000000000000000000000000000000000000001bc16d674ec80000",
+		BlockNumber: "0x64",
+		BlockHash:   "0xblockhash",
+		LogIndex:    "0x0",
+	}
+
+	// Delivered over WebSocket, then again by the poller after fallback
+	now := time.Now()
+	service.processLogEntry(service.providers[0], logEntry, now)
+	service.processLogEntry(nil, logEntry, now.Add(time.Second))
+
+	require.Len(t, service.SyncEvents(), 1)
+	require.Equal(t, uint64(100), service.LastBlockNumber())
+}
+
+func TestPollStartBlock(t *testing.T) {
+	ctx := context.Background()
+	node := mocknode.New(common.HexToAddress("0x00000000000000000000000000000000000000f0"))
+	defer node.Close()
+
+	client, err := base.NewClient(node.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	service := NewService(nil, "", graphManager, nil)
+	service.SetPoller(NewPoller(client), time.Second)
+
+	// Nothing processed and no start block: the current head is polled, not skipped
+	from, err := service.pollStartBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, node.Head(), from)
+
+	// Blocks mined since Run started are not skipped
+	service.startBlock = node.Head()
+	node.MineBlock()
+	node.MineBlock()
+	from, err = service.pollStartBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(mocknode.GenesisBlock), from)
+
+	service.markProcessed(mocknode.GenesisBlock + 1)
+	from, err = service.pollStartBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(mocknode.GenesisBlock+2), from)
+}
+
+func TestFallbackPollingSwitchesBackToWebSocket(t *testing.T) {
+	node := mocknode.New(common.HexToAddress("0x00000000000000000000000000000000000000f0"))
+	defer node.Close()
+
+	client, err := base.NewClient(node.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	service := NewService([]string{"ws://127.0.0.1:1", node.WSURL()}, "", graphManager, nil)
+	service.SetPoller(NewPoller(client), time.Hour)
+	service.probeInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = service.runPolling(ctx)
+	require.ErrorIs(t, err, errWebSocketRecovered)
+}
+
+func TestHTTPModePollingDoesNotProbeWebSocket(t *testing.T) {
+	node := mocknode.New(common.HexToAddress("0x00000000000000000000000000000000000000f0"))
+	defer node.Close()
+
+	client, err := base.NewClient(node.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	service := NewService([]string{node.WSURL()}, "", graphManager, nil)
+	service.SetMode(ModeHTTP)
+	service.SetPoller(NewPoller(client), time.Hour)
+	service.probeInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = service.runPolling(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
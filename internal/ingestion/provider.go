@@ -0,0 +1,109 @@
+package ingestion
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// provider is a single WebSocket endpoint feeding the ingestion service.
+// Each provider keeps its own connection and reconnects independently.
+type provider struct {
+	name string
+	url  string
+
+	mu     sync.Mutex
+	client *WSClient
+
+	// Active log subscription and the tracked-set version it was built from.
+	// subMu serialises subscription changes on this provider.
+	subMu         sync.Mutex
+	logsSubID     string
+	filterVersion uint64
+
+	// Highest block number delivered by this provider
+	lastBlock atomic.Uint64
+	connected atomic.Bool
+}
+
+// providerMessage is a subscription notification tagged with its source.
+type providerMessage struct {
+	provider     *provider
+	notification Notification
+	receivedAt   time.Time
+}
+
+// providerReady is sent once a provider has subscribed.
+// The provider waits for ack to be closed before it starts streaming.
+type providerReady struct {
+	provider *provider
+	ack      chan struct{}
+}
+
+// newProviders creates one provider per URL.
+// Providers are named after their host so API keys in the path never reach logs or metrics.
+func newProviders(urls []string) []*provider {
+	providers := make([]*provider, 0, len(urls))
+	used := make(map[string]int, len(urls))
+
+	for i, rawURL := range urls {
+		name := fmt.Sprintf("provider-%d", i)
+		if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+			name = u.Host
+		}
+
+		// Disambiguate several endpoints on the same host
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, used[name])
+		}
+
+		providers = append(providers, &provider{
+			name: name,
+			url:  rawURL,
+		})
+	}
+
+	return providers
+}
+
+// setClient sets the provider's active client (nil when disconnected).
+func (p *provider) setClient(client *WSClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.client = client
+}
+
+// getClient returns the provider's active client, or nil when disconnected.
+func (p *provider) getClient() *WSClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.client
+}
+
+// resetSubscription forgets the log subscription after a disconnect.
+func (p *provider) resetSubscription() {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	p.logsSubID = ""
+	p.filterVersion = 0
+}
+
+// needsResubscribe returns true if the provider is subscribed with a filter older than version.
+func (p *provider) needsResubscribe(version uint64) bool {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	return p.logsSubID != "" && p.filterVersion < version
+}
+
+// observeBlock records a block number delivered by this provider.
+func (p *provider) observeBlock(block uint64) {
+	for {
+		current := p.lastBlock.Load()
+		if block <= current || p.lastBlock.CompareAndSwap(current, block) {
+			return
+		}
+	}
+}
@@ -2,16 +2,20 @@ package ingestion
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"watcher/internal/graph"
-	"watcher/pkg/chain/base"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,23 +23,84 @@ const (
 	// maxBlockRange limits the number of blocks to query in a single getLogs call
 	// to avoid RPC timeouts on large ranges
 	maxBlockRange = 1000
+
+	// maxConcurrentFetches bounds parallel getLogs calls. The client's own rate
+	// limiter still spaces the requests out.
+	maxConcurrentFetches = 4
+
+	// maxFetchAttempts is how often a range is tried before it is reported unfetchable
+	maxFetchAttempts = 4
+
+	// initialFetchBackoff is the delay before the first retry, doubled each attempt
+	initialFetchBackoff = 500 * time.Millisecond
 )
 
+// LogClient is the RPC client used to fetch historical logs.
+// *base.Client implements it.
+type LogClient interface {
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// BlockRange is an inclusive range of blocks.
+type BlockRange struct {
+	From uint64
+	To   uint64
+}
+
+// String returns the range as "from-to".
+func (br BlockRange) String() string {
+	return fmt.Sprintf("%d-%d", br.From, br.To)
+}
+
+// UnfetchedRangesError reports block ranges whose logs could not be fetched.
+// Events in these ranges were not applied.
+type UnfetchedRangesError struct {
+	Ranges []BlockRange
+	Err    error
+}
+
+func (e *UnfetchedRangesError) Error() string {
+	ranges := make([]string, len(e.Ranges))
+	for i, br := range e.Ranges {
+		ranges[i] = br.String()
+	}
+	return fmt.Sprintf("could not fetch logs for blocks %s: %v", strings.Join(ranges, ", "), e.Err)
+}
+
+func (e *UnfetchedRangesError) Unwrap() error {
+	return e.Err
+}
+
 // Reconciler fetches historical events to fill gaps between bootstrap and streaming.
 type Reconciler struct {
-	client       *base.Client
+	client       LogClient
 	decoder      *Decoder
 	graphManager *graph.Manager
 	trackedPools map[string]struct{}
+
+	// filter, if set, decides whether an event is applied (e.g. to skip events already streamed)
+	filter func(*SyncEvent) bool
+
+	// applied, if set, is called with every update applied to the graph
+	applied func(graph.ReserveUpdate)
+
+	// Fetch tuning, overridable in tests
+	chunkSize    uint64
+	concurrency  int
+	fetchBackoff time.Duration
 }
 
 // NewReconciler creates a new reconciler.
-func NewReconciler(client *base.Client, graphManager *graph.Manager) *Reconciler {
+func NewReconciler(client LogClient, graphManager *graph.Manager) *Reconciler {
 	return &Reconciler{
 		client:       client,
 		decoder:      NewDecoder(),
 		graphManager: graphManager,
 		trackedPools: make(map[string]struct{}),
+		chunkSize:    maxBlockRange,
+		concurrency:  maxConcurrentFetches,
+		fetchBackoff: initialFetchBackoff,
 	}
 }
 
@@ -47,6 +112,17 @@ func (r *Reconciler) SetTrackedPools(addresses []string) {
 	}
 }
 
+// SetFilter sets a function that decides whether a fetched event is applied.
+// Events for which it returns false are counted as found but not applied.
+func (r *Reconciler) SetFilter(filter func(*SyncEvent) bool) {
+	r.filter = filter
+}
+
+// SetAppliedHook sets a function called with every update the reconciler applies.
+func (r *Reconciler) SetAppliedHook(fn func(graph.ReserveUpdate)) {
+	r.applied = fn
+}
+
 // ReconcileResult contains statistics from reconciliation.
 type ReconcileResult struct {
 	FromBlock      uint64
@@ -60,6 +136,12 @@ type ReconcileResult struct {
 // Reconcile fetches and applies historical Sync events from fromBlock to toBlock.
 // This fills the gap between bootstrap (which fetches reserves at a point in time)
 // and WebSocket streaming (which only receives future events).
+//
+// Chunks are fetched concurrently and applied in (block, logIndex) order once all
+// have been fetched. Ranges the node rejects as too large are split in half;
+// other failures are retried with backoff. If a range still cannot be fetched,
+// the events that were fetched are applied and an *UnfetchedRangesError is
+// returned so the caller does not treat the range as processed.
 func (r *Reconciler) Reconcile(ctx context.Context, fromBlock, toBlock uint64) (*ReconcileResult, error) {
 	if fromBlock > toBlock {
 		return &ReconcileResult{FromBlock: fromBlock, ToBlock: toBlock}, nil
@@ -88,67 +170,67 @@ func (r *Reconciler) Reconcile(ctx context.Context, fromBlock, toBlock uint64) (
 		return result, nil
 	}
 
+	events, unfetched, err := r.fetchRange(ctx, poolAddresses, fromBlock, toBlock)
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	result.EventsFound = len(events)
+
+	// Apply in chain order regardless of which chunk finished first
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+		return events[i].LogIndex < events[j].LogIndex
+	})
+
 	// Track which pools received updates
 	poolsUpdated := make(map[string]struct{})
 
-	// Query in chunks to avoid RPC limits
-	for chunkStart := fromBlock; chunkStart <= toBlock; chunkStart += maxBlockRange {
-		select {
-		case <-ctx.Done():
-			return result, ctx.Err()
-		default:
-		}
+	for _, event := range events {
+		poolAddr := strings.ToLower(event.PoolAddress)
 
-		chunkEnd := chunkStart + maxBlockRange - 1
-		if chunkEnd > toBlock {
-			chunkEnd = toBlock
+		// Only apply if pool is tracked
+		if _, tracked := r.trackedPools[poolAddr]; !tracked {
+			continue
 		}
 
-		events, err := r.fetchSyncEvents(ctx, poolAddresses, chunkStart, chunkEnd)
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Uint64("from", chunkStart).
-				Uint64("to", chunkEnd).
-				Msg("Failed to fetch events for block range, continuing")
+		// Skip events already applied from the stream
+		if r.filter != nil && !r.filter(event) {
 			continue
 		}
 
-		result.EventsFound += len(events)
-
-		// Apply events to graph
-		for _, event := range events {
-			poolAddr := strings.ToLower(event.PoolAddress)
-
-			// Only apply if pool is tracked
-			if _, tracked := r.trackedPools[poolAddr]; !tracked {
-				continue
-			}
-
-			update := graph.ReserveUpdate{
-				PoolAddress: event.PoolAddress,
-				Reserve0:    event.Reserve0,
-				Reserve1:    event.Reserve1,
-				BlockNumber: event.BlockNumber,
-				LogIndex:    event.LogIndex,
-				Timestamp:   event.Timestamp,
-			}
-			r.graphManager.ProcessUpdate(update)
-			result.EventsApplied++
-			poolsUpdated[poolAddr] = struct{}{}
+		update := graph.ReserveUpdate{
+			PoolAddress: event.PoolAddress,
+			Reserve0:    event.Reserve0,
+			Reserve1:    event.Reserve1,
+			BlockNumber: event.BlockNumber,
+			LogIndex:    event.LogIndex,
+			Timestamp:   event.Timestamp,
+			BlockHash:   event.BlockHash,
 		}
-
-		if chunkEnd < toBlock {
-			log.Debug().
-				Uint64("chunk_end", chunkEnd).
-				Int("events_so_far", result.EventsFound).
-				Msg("Reconciliation progress")
+		r.graphManager.ProcessUpdate(update)
+		if r.applied != nil {
+			r.applied(update)
 		}
+		result.EventsApplied++
+		poolsUpdated[poolAddr] = struct{}{}
 	}
 
 	result.PoolsUpdated = len(poolsUpdated)
 	result.Duration = time.Since(startTime)
 
+	if len(unfetched) > 0 {
+		rangeErr := &UnfetchedRangesError{Ranges: unfetched, Err: err}
+		log.Error().
+			Err(rangeErr).
+			Uint64("from_block", fromBlock).
+			Uint64("to_block", toBlock).
+			Int("events_applied", result.EventsApplied).
+			Msg("Reconciliation incomplete - some block ranges could not be fetched")
+		return result, rangeErr
+	}
+
 	log.Info().
 		Uint64("from_block", fromBlock).
 		Uint64("to_block", toBlock).
@@ -161,47 +243,181 @@ func (r *Reconciler) Reconcile(ctx context.Context, fromBlock, toBlock uint64) (
 	return result, nil
 }
 
-// fetchSyncEvents fetches Sync events from the blockchain for the given block range.
-func (r *Reconciler) fetchSyncEvents(ctx context.Context, addresses []common.Address, fromBlock, toBlock uint64) ([]*SyncEvent, error) {
-	query := ethereum.FilterQuery{
-		FromBlock: big.NewInt(int64(fromBlock)),
-		ToBlock:   big.NewInt(int64(toBlock)),
-		Addresses: addresses,
-		Topics:    [][]common.Hash{{SyncEventTopic}},
+// fetchRange fetches Sync events for [fromBlock, toBlock] in chunks using a
+// bounded worker pool. Returns the events fetched, the ranges that could not be
+// fetched, and the last error seen for them.
+func (r *Reconciler) fetchRange(ctx context.Context, addresses []common.Address, fromBlock, toBlock uint64) ([]*SyncEvent, []BlockRange, error) {
+	var chunks []BlockRange
+	for chunkStart := fromBlock; chunkStart <= toBlock; chunkStart += r.chunkSize {
+		chunkEnd := chunkStart + r.chunkSize - 1
+		if chunkEnd > toBlock {
+			chunkEnd = toBlock
+		}
+		chunks = append(chunks, BlockRange{From: chunkStart, To: chunkEnd})
+		if chunkEnd == toBlock {
+			break
+		}
 	}
 
-	logs, err := r.client.FilterLogs(ctx, query)
-	if err != nil {
-		return nil, fmt.Errorf("filtering logs: %w", err)
+	workers := r.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(chunks) {
+		workers = len(chunks)
 	}
 
-	events := make([]*SyncEvent, 0, len(logs))
-	for _, ethLog := range logs {
-		// Skip removed logs (reorgs)
-		if ethLog.Removed {
-			continue
+	var (
+		mu        sync.Mutex
+		events    []*SyncEvent
+		unfetched []BlockRange
+		lastErr   error
+		done      int
+	)
+
+	chunkCh := make(chan BlockRange)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunkCh {
+				fetched, failed, err := r.fetchChunk(ctx, addresses, chunk)
+
+				mu.Lock()
+				events = append(events, fetched...)
+				unfetched = append(unfetched, failed...)
+				if err != nil {
+					lastErr = err
+				}
+				done++
+				progress := done
+				mu.Unlock()
+
+				log.Debug().
+					Str("range", chunk.String()).
+					Int("events", len(fetched)).
+					Int("chunks_done", progress).
+					Int("chunks_total", len(chunks)).
+					Msg("Reconciliation progress")
+			}
+		}()
+	}
+
+	for _, chunk := range chunks {
+		select {
+		case chunkCh <- chunk:
+		case <-ctx.Done():
 		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(chunkCh)
+	wg.Wait()
+
+	sort.Slice(unfetched, func(i, j int) bool { return unfetched[i].From < unfetched[j].From })
+	return events, unfetched, lastErr
+}
 
-		// Convert to LogEntry for decoder
-		logEntry := &LogEntry{
-			Address:         strings.ToLower(ethLog.Address.Hex()),
-			Topics:          make([]string, len(ethLog.Topics)),
-			Data:            fmt.Sprintf("0x%x", ethLog.Data),
-			BlockNumber:     fmt.Sprintf("0x%x", ethLog.BlockNumber),
-			TransactionHash: ethLog.TxHash.Hex(),
-			LogIndex:        fmt.Sprintf("0x%x", ethLog.Index),
-			Removed:         ethLog.Removed,
+// fetchChunk fetches a single range, retrying transient errors with backoff and
+// splitting the range in half when the node rejects it as too large.
+func (r *Reconciler) fetchChunk(ctx context.Context, addresses []common.Address, br BlockRange) ([]*SyncEvent, []BlockRange, error) {
+	backoff := r.fetchBackoff
+
+	var err error
+	for attempt := 1; attempt <= maxFetchAttempts; attempt++ {
+		if ctx.Err() != nil {
+			return nil, []BlockRange{br}, ctx.Err()
+		}
+
+		var events []*SyncEvent
+		events, err = r.fetchSyncEvents(ctx, addresses, br.From, br.To)
+		if err == nil {
+			return events, nil, nil
 		}
-		for i, topic := range ethLog.Topics {
-			logEntry.Topics[i] = topic.Hex()
+		if ctx.Err() != nil {
+			return nil, []BlockRange{br}, ctx.Err()
 		}
 
+		// Too many results for one call: split and fetch each half on its own
+		if isRangeTooLarge(err) && br.From < br.To {
+			mid := br.From + (br.To-br.From)/2
+			log.Debug().
+				Err(err).
+				Str("range", br.String()).
+				Msg("Log range too large, splitting")
+
+			lower, lowerFailed, lowerErr := r.fetchChunk(ctx, addresses, BlockRange{From: br.From, To: mid})
+			upper, upperFailed, upperErr := r.fetchChunk(ctx, addresses, BlockRange{From: mid + 1, To: br.To})
+			if upperErr == nil {
+				upperErr = lowerErr
+			}
+			return append(lower, upper...), append(lowerFailed, upperFailed...), upperErr
+		}
+
+		if attempt == maxFetchAttempts {
+			break
+		}
+
+		log.Warn().
+			Err(err).
+			Str("range", br.String()).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("Failed to fetch logs, retrying")
+
+		select {
+		case <-ctx.Done():
+			return nil, []BlockRange{br}, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return nil, []BlockRange{br}, err
+}
+
+// isRangeTooLarge returns true if a getLogs error means the range must be narrowed,
+// e.g. too many results or a block range above the provider's limit.
+func isRangeTooLarge(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32005 {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"too many results",
+		"query returned more than",
+		"response size exceeded",
+		"block range",
+		"range too large",
+		"range is too large",
+		"exceed maximum block range",
+	} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchSyncEvents fetches Sync events from the blockchain for the given block range.
+func (r *Reconciler) fetchSyncEvents(ctx context.Context, addresses []common.Address, fromBlock, toBlock uint64) ([]*SyncEvent, error) {
+	logEntries, err := fetchLogs(ctx, r.client, addresses, []common.Hash{SyncEventTopic}, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*SyncEvent, 0, len(logEntries))
+	for _, logEntry := range logEntries {
 		event, err := r.decoder.DecodeSyncEvent(logEntry)
 		if err != nil {
 			log.Debug().
 				Err(err).
 				Str("pool", logEntry.Address).
-				Uint64("block", ethLog.BlockNumber).
+				Str("block", logEntry.BlockNumber).
 				Msg("Failed to decode Sync event during reconciliation")
 			continue
 		}
@@ -212,6 +428,52 @@ func (r *Reconciler) fetchSyncEvents(ctx context.Context, addresses []common.Add
 	return events, nil
 }
 
+// fetchLogs fetches logs matching any of the topics from the given addresses and
+// converts them to LogEntry for the decoder. Removed logs are skipped.
+func fetchLogs(ctx context.Context, client LogClient, addresses []common.Address, topics []common.Hash, fromBlock, toBlock uint64) ([]*LogEntry, error) {
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: addresses,
+		Topics:    [][]common.Hash{topics},
+	}
+
+	logs, err := client.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("filtering logs: %w", err)
+	}
+
+	entries := make([]*LogEntry, 0, len(logs))
+	for i := range logs {
+		// Skip removed logs (reorgs)
+		if logs[i].Removed {
+			continue
+		}
+		entries = append(entries, newLogEntry(&logs[i]))
+	}
+
+	return entries, nil
+}
+
+// newLogEntry converts a go-ethereum log to the LogEntry format used by the decoder.
+func newLogEntry(ethLog *types.Log) *LogEntry {
+	logEntry := &LogEntry{
+		Address:          strings.ToLower(ethLog.Address.Hex()),
+		Topics:           make([]string, len(ethLog.Topics)),
+		Data:             fmt.Sprintf("0x%x", ethLog.Data),
+		BlockNumber:      fmt.Sprintf("0x%x", ethLog.BlockNumber),
+		TransactionHash:  ethLog.TxHash.Hex(),
+		TransactionIndex: fmt.Sprintf("0x%x", ethLog.TxIndex),
+		BlockHash:        ethLog.BlockHash.Hex(),
+		LogIndex:         fmt.Sprintf("0x%x", ethLog.Index),
+		Removed:          ethLog.Removed,
+	}
+	for i, topic := range ethLog.Topics {
+		logEntry.Topics[i] = topic.Hex()
+	}
+	return logEntry
+}
+
 // GetCurrentBlock returns the current block number from the RPC.
 func (r *Reconciler) GetCurrentBlock(ctx context.Context) (uint64, error) {
 	return r.client.BlockNumber(ctx)
@@ -2,12 +2,18 @@ package ingestion
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 	"testing"
 	"time"
 
 	"watcher/internal/graph"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -92,14 +98,14 @@ func TestServiceSetReconciler(t *testing.T) {
 	graphManager := graph.NewManager(nil)
 	defer graphManager.Close()
 
-	service := NewService("ws://test", "", graphManager, nil)
+	service := NewService([]string{"ws://test"}, "", graphManager, nil)
 	reconciler := NewReconciler(nil, graphManager)
 
 	service.SetReconciler(reconciler, 12345)
 
 	require.NotNil(t, service.reconciler)
-	require.Equal(t, uint64(12345), service.bootstrapStartBlock)
-	require.False(t, service.reconciliationDone)
+	require.Equal(t, uint64(12345), service.bootstrapBlock)
+	require.Equal(t, uint64(12345), service.LastProcessedBlock(), "first backfill starts after the bootstrap block")
 }
 
 // TestServiceRunReconciliationSkipsWhenNotConfigured verifies reconciliation is skipped
@@ -108,7 +114,7 @@ func TestServiceRunReconciliationSkipsWhenNotConfigured(t *testing.T) {
 	graphManager := graph.NewManager(nil)
 	defer graphManager.Close()
 
-	service := NewService("ws://test", "", graphManager, nil)
+	service := NewService([]string{"ws://test"}, "", graphManager, nil)
 	// Don't set reconciler
 
 	ctx := context.Background()
@@ -117,21 +123,153 @@ func TestServiceRunReconciliationSkipsWhenNotConfigured(t *testing.T) {
 	require.NoError(t, err)
 }
 
-// TestServiceRunReconciliationSkipsWhenAlreadyDone verifies reconciliation is skipped
-// when already completed.
-func TestServiceRunReconciliationSkipsWhenAlreadyDone(t *testing.T) {
+// TestBackfillRange verifies the backfill range after the last processed block.
+func TestBackfillRange(t *testing.T) {
+	from, to, ok := backfillRange(100, 150)
+	require.True(t, ok)
+	require.Equal(t, uint64(101), from)
+	require.Equal(t, uint64(150), to)
+
+	// Already at head
+	_, _, ok = backfillRange(150, 150)
+	require.False(t, ok)
+
+	// Head behind (lagging RPC node)
+	_, _, ok = backfillRange(151, 150)
+	require.False(t, ok)
+
+	// Nothing processed yet
+	_, _, ok = backfillRange(0, 150)
+	require.False(t, ok)
+}
+
+// TestServiceTracksLastProcessedBlock verifies streamed events and headers advance
+// the block that the next backfill starts after.
+func TestServiceTracksLastProcessedBlock(t *testing.T) {
 	graphManager := graph.NewManager(nil)
 	defer graphManager.Close()
 
-	service := NewService("ws://test", "", graphManager, nil)
-	reconciler := NewReconciler(nil, graphManager)
-	service.SetReconciler(reconciler, 12345)
-	service.reconciliationDone = true // Mark as already done
+	service := NewService([]string{"ws://test"}, "", graphManager, nil)
+	service.SetReconciler(NewReconciler(nil, graphManager), 100)
+	service.SetTrackedPools([]string{"0x1234567890123456789012345678901234567890"})
+
+	service.processLogEntry(nil, &LogEntry{
+		Address: "0x1234567890123456789012345678901234567890",
+		Topics:  []string{SyncEventTopic.Hex()},
+		Data: "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+			"0000000000000000000000000000000000000000000000001bc16d674ec80000",
+		BlockNumber: "0xc8", // 200
+		BlockHash:   "0xblock200",
+		LogIndex:    "0x0",
+	}, time.Now())
+	require.Equal(t, uint64(199), service.LastProcessedBlock())
+
+	// The header may arrive before the rest of its block's logs
+	service.processHeader(nil, &HeaderEntry{Number: "0xc8", Hash: "0xblock200", Timestamp: "0x1"}, time.Now())
+	require.Equal(t, uint64(199), service.LastProcessedBlock())
+
+	service.processHeader(nil, &HeaderEntry{Number: "0xc9", Hash: "0xblock201", Timestamp: "0x2"}, time.Now())
+	require.Equal(t, uint64(200), service.LastProcessedBlock())
+}
 
-	ctx := context.Background()
-	err := service.runReconciliation(ctx)
+// TestRemovedSyncRevertsPoolPosition verifies that after a reorg removes a
+// Sync, the canonical Sync at the same block is applied rather than skipped.
+func TestRemovedSyncRevertsPoolPosition(t *testing.T) {
+	const pool = "0x1234567890123456789012345678901234567890"
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	graphManager.AddPool(
+		graph.PoolState{Address: pool, Token0: "0x0001", Token1: "0x0002", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), Fee: 0.003},
+		graph.TokenInfo{Address: "0x0001", Symbol: "A", Decimals: 18},
+		graph.TokenInfo{Address: "0x0002", Symbol: "B", Decimals: 18},
+	)
+
+	service := NewService([]string{"ws://test"}, "", graphManager, nil)
+	service.SetTrackedPools([]string{pool})
+
+	syncEntry := func(blockHash, logIndex string, reserve1 byte, removed bool) *LogEntry {
+		return &LogEntry{
+			Address: pool,
+			Topics:  []string{SyncEventTopic.Hex()},
+			Data: "0x0000000000000000000000000000000000000000000000000000000000000001" +
+				"00000000000000000000000000000000000000000000000000000000000000" + fmt.Sprintf("%02x", reserve1),
+			BlockNumber: "0xc8", // 200
+			BlockHash:   blockHash,
+			LogIndex:    logIndex,
+			Removed:     removed,
+		}
+	}
 
-	require.NoError(t, err)
+	// The orphaned fork's Sync is applied, then removed by the reorg
+	service.processLogEntry(nil, syncEntry("0xorphaned", "0x2", 7, false), time.Now())
+	graphManager.Flush()
+	service.processLogEntry(nil, syncEntry("0xorphaned", "0x2", 7, true), time.Now())
+
+	// The canonical Sync has a lower log index in the same block
+	service.processLogEntry(nil, syncEntry("0xcanonical", "0x1", 9, false), time.Now())
+	graphManager.Flush()
+
+	snapshot := graphManager.GetCurrentSnapshot(0)
+	require.Equal(t, "9", snapshot.Pools[pool].Reserve1.String())
+}
+
+// TestRemovedSyncRestoresReservesOnce verifies that a Sync removed by a reorg
+// without a canonical replacement restores the pool's earlier reserves, and
+// that another provider's copy of the removal doesn't revert later updates.
+func TestRemovedSyncRestoresReservesOnce(t *testing.T) {
+	const pool = "0x1234567890123456789012345678901234567890"
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	graphManager.AddPool(
+		graph.PoolState{Address: pool, Token0: "0x0001", Token1: "0x0002", Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), Fee: 0.003},
+		graph.TokenInfo{Address: "0x0001", Symbol: "A", Decimals: 18},
+		graph.TokenInfo{Address: "0x0002", Symbol: "B", Decimals: 18},
+	)
+
+	service := NewService([]string{"ws://a", "ws://b"}, "", graphManager, nil)
+	service.SetTrackedPools([]string{pool})
+
+	syncEntry := func(block, blockHash string, reserve1 byte, removed bool) *LogEntry {
+		return &LogEntry{
+			Address: pool,
+			Topics:  []string{SyncEventTopic.Hex()},
+			Data: "0x0000000000000000000000000000000000000000000000000000000000000001" +
+				"00000000000000000000000000000000000000000000000000000000000000" + fmt.Sprintf("%02x", reserve1),
+			BlockNumber: block,
+			BlockHash:   blockHash,
+			LogIndex:    "0x0",
+			Removed:     removed,
+		}
+	}
+
+	service.processLogEntry(service.providers[0], syncEntry("0xc8", "0xorphaned", 7, false), time.Now())
+	graphManager.Flush()
+	service.processLogEntry(service.providers[0], syncEntry("0xc8", "0xorphaned", 7, true), time.Now())
+	require.Equal(t, "1", graphManager.GetCurrentSnapshot(0).Pools[pool].Reserve1.String())
+
+	// The canonical chain moves on, then the second provider reports the same removal
+	service.processLogEntry(service.providers[0], syncEntry("0xc9", "0xcanonical", 9, false), time.Now())
+	graphManager.Flush()
+	service.processLogEntry(service.providers[1], syncEntry("0xc8", "0xorphaned", 7, true), time.Now())
+	require.Equal(t, "9", graphManager.GetCurrentSnapshot(0).Pools[pool].Reserve1.String())
+}
+
+// TestReconcilerFilterSkipsStreamedEvents verifies backfilled events already delivered
+// by a provider are not applied twice.
+func TestReconcilerFilterSkipsStreamedEvents(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	service := NewService([]string{"ws://test"}, "", graphManager, nil)
+	service.SetReconciler(NewReconciler(nil, graphManager), 100)
+
+	streamed := &SyncEvent{BlockHash: "0xblock", LogIndex: 3}
+	_, duplicate := service.dedup.Check(logKey{blockHash: "0xblock", logIndex: 3}, time.Now())
+	require.False(t, duplicate)
+
+	require.False(t, service.firstDelivery(streamed), "already streamed")
+	require.True(t, service.firstDelivery(&SyncEvent{BlockHash: "0xblock", LogIndex: 4}))
+	require.False(t, service.firstDelivery(&SyncEvent{BlockHash: "0xblock", LogIndex: 4}), "second backfill of the same event")
 }
 
 // TestMaxBlockRangeConstant verifies the max block range is reasonable.
@@ -215,3 +353,175 @@ func TestGraphManagerIntegration(t *testing.T) {
 	require.Equal(t, 2, edges)
 	require.Equal(t, 1, pools)
 }
+
+// fakeLogClient serves Sync logs for tests, rejecting wide ranges like a real node.
+type fakeLogClient struct {
+	mu       sync.Mutex
+	logs     []types.Log
+	maxRange uint64
+	failing  map[uint64]int // block -> failures left for ranges starting there
+	always   map[uint64]bool
+	queries  []BlockRange
+}
+
+func (c *fakeLogClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	c.queries = append(c.queries, BlockRange{From: from, To: to})
+
+	if c.maxRange > 0 && to-from+1 > c.maxRange {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	for block := from; block <= to; block++ {
+		if c.always[block] {
+			return nil, errors.New("internal error")
+		}
+	}
+	if c.failing[from] > 0 {
+		c.failing[from]--
+		return nil, errors.New("connection reset")
+	}
+
+	var logs []types.Log
+	for _, l := range c.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+func (c *fakeLogClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return 0, nil
+}
+
+func syncLog(pool string, block uint64, index uint, reserve0 int64) types.Log {
+	data := make([]byte, 64)
+	big.NewInt(reserve0).FillBytes(data[:32])
+	big.NewInt(1000).FillBytes(data[32:])
+	return types.Log{
+		Address:     common.HexToAddress(pool),
+		Topics:      []common.Hash{SyncEventTopic},
+		Data:        data,
+		BlockNumber: block,
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(block)),
+		Index:       index,
+	}
+}
+
+func newTestReconciler(client LogClient, graphManager *graph.Manager) *Reconciler {
+	reconciler := NewReconciler(client, graphManager)
+	reconciler.chunkSize = 10
+	reconciler.fetchBackoff = time.Millisecond
+	reconciler.SetTrackedPools([]string{"0x1234567890123456789012345678901234567890"})
+	return reconciler
+}
+
+// TestReconcileAppliesInChainOrder verifies chunks fetched concurrently are applied
+// in (block, logIndex) order, splitting ranges the node rejects as too large.
+func TestReconcileAppliesInChainOrder(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	pool := "0x1234567890123456789012345678901234567890"
+	client := &fakeLogClient{
+		maxRange: 4,
+		failing:  map[uint64]int{111: 1},
+		logs: []types.Log{
+			syncLog(pool, 135, 0, 5),
+			syncLog(pool, 101, 7, 2),
+			syncLog(pool, 101, 2, 1),
+			syncLog(pool, 118, 1, 3),
+			syncLog(pool, 129, 4, 4),
+		},
+	}
+	reconciler := newTestReconciler(client, graphManager)
+
+	var applied []graph.ReserveUpdate
+	reconciler.SetAppliedHook(func(update graph.ReserveUpdate) {
+		applied = append(applied, update)
+	})
+
+	result, err := reconciler.Reconcile(context.Background(), 100, 139)
+	require.NoError(t, err)
+	require.Equal(t, 5, result.EventsFound)
+	require.Equal(t, 5, result.EventsApplied)
+
+	require.Len(t, applied, 5)
+	for i, reserve0 := range []int64{1, 2, 3, 4, 5} {
+		require.Equal(t, reserve0, applied[i].Reserve0.Int64(), "update %d out of order", i)
+	}
+
+	// Every query stayed within the node's limit once split
+	var accepted int
+	for _, q := range client.queries {
+		if q.To-q.From+1 <= client.maxRange {
+			accepted++
+		}
+	}
+	require.Greater(t, accepted, 4)
+}
+
+// TestReconcileReportsUnfetchableRanges verifies a range that keeps failing is
+// reported instead of skipped, while the rest is still applied.
+func TestReconcileReportsUnfetchableRanges(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	pool := "0x1234567890123456789012345678901234567890"
+	client := &fakeLogClient{
+		always: map[uint64]bool{115: true},
+		logs: []types.Log{
+			syncLog(pool, 105, 0, 1),
+			syncLog(pool, 125, 0, 2),
+		},
+	}
+	reconciler := newTestReconciler(client, graphManager)
+
+	result, err := reconciler.Reconcile(context.Background(), 100, 129)
+	require.Error(t, err)
+
+	var rangeErr *UnfetchedRangesError
+	require.ErrorAs(t, err, &rangeErr)
+	require.Equal(t, []BlockRange{{From: 110, To: 119}}, rangeErr.Ranges)
+	require.Equal(t, 2, result.EventsApplied)
+}
+
+// TestRunReconciliationRetriesUnfetchedRange verifies the next backfill starts at a
+// range that failed before, even after streaming moved past it.
+func TestRunReconciliationRetriesUnfetchedRange(t *testing.T) {
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	client := &fakeLogClient{always: map[uint64]bool{115: true}}
+	service := NewService([]string{"ws://test"}, "", graphManager, nil)
+	service.SetTrackedPools([]string{"0x1234567890123456789012345678901234567890"})
+	reconciler := newTestReconciler(&headClient{fakeLogClient: client, head: 129}, graphManager)
+	service.SetReconciler(reconciler, 99) // Bootstrap read block 99; backfill starts at 100
+
+	require.Error(t, service.runReconciliation(context.Background()))
+	require.Equal(t, uint64(110), service.unfetchedFrom)
+	require.Equal(t, uint64(129), service.LastProcessedBlock())
+
+	// The node recovers; the retry covers the gap and clears it
+	client.mu.Lock()
+	client.always = nil
+	client.queries = nil
+	client.mu.Unlock()
+
+	require.NoError(t, service.runReconciliation(context.Background()))
+	require.Equal(t, uint64(0), service.unfetchedFrom)
+	require.Contains(t, client.queries, BlockRange{From: 110, To: 119})
+}
+
+// headClient reports a fixed chain head.
+type headClient struct {
+	*fakeLogClient
+	head uint64
+}
+
+func (c *headClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.head, nil
+}
@@ -0,0 +1,194 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"watcher/internal/graph"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultRecordMaxBytes is the size at which a recording file is rotated
+	defaultRecordMaxBytes = 100 << 20
+
+	recordFilePrefix = "ingest-"
+	recordFileSuffix = ".jsonl"
+)
+
+// Record kinds besides the subscription kinds (logs, newHeads).
+const (
+	// recordKindUpdate is a reserve update applied by backfill
+	recordKindUpdate = "update"
+	// recordKindPools is a batch of pools added to the graph
+	recordKindPools = "pools"
+	// recordKindRemoved is a batch of pools removed from the graph
+	recordKindRemoved = "removed"
+	// recordKindTracked is a change to the tracked pool set
+	recordKindTracked = "tracked"
+	// recordKindPolled is a block range fetched by HTTP polling
+	recordKindPolled = "polled"
+)
+
+// Record is one line of a recording.
+type Record struct {
+	ReceivedAt   time.Time       `json:"received_at"`
+	Provider     string          `json:"provider,omitempty"`
+	Subscription string          `json:"subscription,omitempty"`
+	Kind         string          `json:"kind"`
+	Params       json.RawMessage `json:"params"`
+}
+
+// recordedPools is the payload of a pools record.
+type recordedPools struct {
+	Pools  []graph.PoolState          `json:"pools"`
+	Tokens map[string]graph.TokenInfo `json:"tokens"`
+}
+
+// recordedRemoved is the payload of a removed record.
+type recordedRemoved struct {
+	Pools []string `json:"pools"`
+}
+
+// recordedPoll is the payload of a polled record: the range's logs and the
+// header of its last block.
+type recordedPoll struct {
+	Header graph.BlockHeader `json:"header"`
+	Logs   []*LogEntry       `json:"logs"`
+}
+
+// recordedTracked is the payload of a tracked record.
+type recordedTracked struct {
+	Pools   []string `json:"pools"`
+	Replace bool     `json:"replace"`
+}
+
+// Recorder appends everything that changes ingestion state to rotating JSONL
+// files, so a session can be replayed: raw notifications with their receive
+// time, polled logs, backfilled updates, pools added to and removed from the graph and
+// tracked-set changes.
+type Recorder struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	file    *os.File
+	written int64
+	seq     int
+}
+
+// NewRecorder creates a recorder writing to dir. Files are rotated once they
+// exceed maxBytes; zero uses the default of 100 MiB.
+func NewRecorder(dir string, maxBytes int64) (*Recorder, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultRecordMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating recording directory: %w", err)
+	}
+
+	r := &Recorder{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+	if err := r.rotateLocked(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends a record, rotating the file first if it is full.
+func (r *Recorder) Record(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("recorder closed")
+	}
+	if r.written > 0 && r.written+int64(len(line)) > r.maxBytes {
+		if err := r.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(line)
+	r.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing record: %w", err)
+	}
+	return nil
+}
+
+// RecordPools records pools added to the graph. Used as the graph manager's pools hook.
+func (r *Recorder) RecordPools(pools []graph.PoolState, tokens map[string]graph.TokenInfo) {
+	r.recordPayload(recordKindPools, recordedPools{Pools: pools, Tokens: tokens})
+}
+
+// RecordRemovedPools records pools removed from the graph. Used as the graph
+// manager's pools removed hook.
+func (r *Recorder) RecordRemovedPools(addresses []string) {
+	r.recordPayload(recordKindRemoved, recordedRemoved{Pools: addresses})
+}
+
+// recordPayload records a non-notification record, logging failures.
+func (r *Recorder) recordPayload(kind string, payload interface{}) {
+	params, err := json.Marshal(payload)
+	if err == nil {
+		err = r.Record(Record{ReceivedAt: time.Now(), Kind: kind, Params: params})
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("kind", kind).Msg("Failed to record ingestion event")
+	}
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeLocked()
+}
+
+func (r *Recorder) closeLocked() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// rotateLocked closes the current file and opens the next one.
+// File names sort in recording order.
+func (r *Recorder) rotateLocked() error {
+	if err := r.closeLocked(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close recording file")
+	}
+
+	r.seq++
+	name := fmt.Sprintf("%s%s-%04d%s",
+		recordFilePrefix, time.Now().UTC().Format("20060102T150405.000Z"), r.seq, recordFileSuffix)
+	path := filepath.Join(r.dir, name)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening recording file: %w", err)
+	}
+
+	r.file = file
+	r.written = 0
+
+	log.Info().Str("path", path).Msg("Recording ingestion stream")
+	return nil
+}
@@ -0,0 +1,137 @@
+package ingestion
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressSet is a set of contract addresses a handler accepts logs from.
+type AddressSet interface {
+	// Addresses returns the addresses to subscribe to (lowercase)
+	Addresses() []string
+	// Contains reports whether a log from address should be handled
+	Contains(address string) bool
+}
+
+// StaticAddresses is a fixed AddressSet, such as a factory contract.
+type StaticAddresses map[string]struct{}
+
+// NewStaticAddresses creates a fixed address set. Empty addresses are ignored.
+func NewStaticAddresses(addresses ...string) StaticAddresses {
+	set := make(StaticAddresses, len(addresses))
+	for _, addr := range addresses {
+		if addr != "" {
+			set[strings.ToLower(addr)] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Addresses returns the addresses in the set.
+func (s StaticAddresses) Addresses() []string {
+	addresses := make([]string, 0, len(s))
+	for addr := range s {
+		addresses = append(addresses, addr)
+	}
+	return addresses
+}
+
+// Contains reports whether address is in the set.
+func (s StaticAddresses) Contains(address string) bool {
+	_, ok := s[strings.ToLower(address)]
+	return ok
+}
+
+// EventHandler handles the logs of one event topic.
+type EventHandler struct {
+	// Name labels the event in logs and metrics, e.g. "sync"
+	Name string
+
+	// Topic is the event signature hash (topic 0)
+	Topic common.Hash
+
+	// Addresses are the contracts the event is subscribed from and accepted from
+	Addresses AddressSet
+
+	// Decode decodes a log into an event
+	Decode func(log *LogEntry) (interface{}, error)
+
+	// Handle processes a decoded event. receivedAt is when the log was delivered.
+	Handle func(event interface{}, receivedAt time.Time)
+}
+
+// EventRegistry maps event topics to their handlers. Subscriptions and polls
+// are built from the registered topics and address sets.
+type EventRegistry struct {
+	mu       sync.RWMutex
+	handlers map[common.Hash]*EventHandler
+	topics   []common.Hash
+}
+
+// NewEventRegistry creates an empty registry.
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		handlers: make(map[common.Hash]*EventHandler),
+	}
+}
+
+// Register adds a handler. Each topic can have only one handler.
+func (r *EventRegistry) Register(handler EventHandler) error {
+	if handler.Name == "" || handler.Decode == nil || handler.Handle == nil {
+		return fmt.Errorf("event handler for %s needs a name, decoder and callback", handler.Topic.Hex())
+	}
+	if handler.Addresses == nil {
+		return fmt.Errorf("event handler %s has no address set", handler.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.handlers[handler.Topic]; ok {
+		return fmt.Errorf("topic %s already handled by %s", handler.Topic.Hex(), existing.Name)
+	}
+	r.handlers[handler.Topic] = &handler
+	r.topics = append(r.topics, handler.Topic)
+	return nil
+}
+
+// Handler returns the handler for a topic.
+func (r *EventRegistry) Handler(topic common.Hash) (*EventHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[topic]
+	return handler, ok
+}
+
+// Topics returns the registered topics in registration order.
+func (r *EventRegistry) Topics() []common.Hash {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	topics := make([]common.Hash, len(r.topics))
+	copy(topics, r.topics)
+	return topics
+}
+
+// Addresses returns the union of every handler's address set.
+func (r *EventRegistry) Addresses() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]struct{})
+	var addresses []string
+	for _, topic := range r.topics {
+		for _, addr := range r.handlers[topic].Addresses.Addresses() {
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = struct{}{}
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+	return addresses
+}
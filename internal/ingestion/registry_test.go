@@ -0,0 +1,63 @@
+package ingestion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRegistryRejectsDuplicateTopics(t *testing.T) {
+	registry := NewEventRegistry()
+	handler := EventHandler{
+		Name:      "sync",
+		Topic:     SyncEventTopic,
+		Addresses: NewStaticAddresses("0xpool"),
+		Decode:    func(*LogEntry) (interface{}, error) { return nil, nil },
+		Handle:    func(interface{}, time.Time) {},
+	}
+
+	require.NoError(t, registry.Register(handler))
+	require.Error(t, registry.Register(handler), "duplicate topic")
+
+	handler.Topic = PoolCreatedEventTopic
+	handler.Decode = nil
+	require.Error(t, registry.Register(handler), "missing decoder")
+}
+
+func TestServiceDispatchesRegisteredHandlers(t *testing.T) {
+	service := NewService(nil, "0xFactory", nil, nil)
+	service.SetTrackedPools([]string{"0xpool"})
+
+	require.ElementsMatch(t, []string{"0xpool", "0xfactory"}, service.subscriptionAddresses())
+	require.Contains(t, service.subscriptionTopics(), SwapEventTopic.Hex())
+
+	// A DEX adapter registers its own event and addresses
+	topic := crypto.Keccak256Hash([]byte("Custom(uint256)"))
+	var handled []string
+	require.NoError(t, service.RegisterEventHandler(EventHandler{
+		Name:      "custom",
+		Topic:     topic,
+		Addresses: NewStaticAddresses("0xAdapter"),
+		Decode: func(l *LogEntry) (interface{}, error) {
+			if l.Data == "0x" {
+				return nil, errors.New("empty data")
+			}
+			return l.Data, nil
+		},
+		Handle: func(e interface{}, _ time.Time) { handled = append(handled, e.(string)) },
+	}))
+
+	// The new handler is included in the next subscription
+	require.Contains(t, service.subscriptionTopics(), topic.Hex())
+	require.Contains(t, service.subscriptionAddresses(), "0xadapter")
+	require.True(t, service.trackedVersion.Load() > 1, "handler change schedules a resubscription")
+
+	now := time.Now()
+	service.dispatch(&LogEntry{Address: "0xadapter", Topics: []string{topic.Hex()}, Data: "0x01"}, now)
+	service.dispatch(&LogEntry{Address: "0xpool", Topics: []string{topic.Hex()}, Data: "0x02"}, now)
+	service.dispatch(&LogEntry{Address: "0xadapter", Topics: []string{topic.Hex()}, Data: "0x"}, now)
+	require.Equal(t, []string{"0x01"}, handled, "other addresses and decode failures are not handled")
+}
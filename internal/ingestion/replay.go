@@ -0,0 +1,274 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"watcher/internal/graph"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SetRecorder records every notification, polled block range, backfilled
+// update and tracked-set change. Set it before bootstrap so the recording starts with the initial state.
+func (s *Service) SetRecorder(recorder *Recorder) {
+	s.recorder = recorder
+}
+
+// recordMessage records a notification as it is handed to processMessage.
+func (s *Service) recordMessage(msg providerMessage) {
+	if s.recorder == nil {
+		return
+	}
+
+	err := s.recorder.Record(Record{
+		ReceivedAt:   msg.receivedAt,
+		Provider:     msg.provider.name,
+		Subscription: msg.notification.Subscription,
+		Kind:         string(msg.notification.Kind),
+		Params:       msg.notification.Params,
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to record notification")
+	}
+}
+
+// recordUpdate records an update applied by backfill.
+func (s *Service) recordUpdate(update graph.ReserveUpdate) {
+	if s.recorder != nil {
+		s.recorder.recordPayload(recordKindUpdate, update)
+	}
+}
+
+// recordPoll records a polled block range.
+func (s *Service) recordPoll(header graph.BlockHeader, logEntries []*LogEntry, receivedAt time.Time) {
+	if s.recorder == nil {
+		return
+	}
+
+	params, err := json.Marshal(recordedPoll{Header: header, Logs: logEntries})
+	if err == nil {
+		err = s.recorder.Record(Record{ReceivedAt: receivedAt, Kind: recordKindPolled, Params: params})
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to record polled logs")
+	}
+}
+
+// recordTracked records a tracked-set change.
+func (s *Service) recordTracked(addresses []string, replace bool) {
+	if s.recorder != nil {
+		s.recorder.recordPayload(recordKindTracked, recordedTracked{Pools: addresses, Replace: replace})
+	}
+}
+
+// Replay feeds a recording back through the service. path is a recording file
+// or a directory of them, replayed in name order. With realtime set, records
+// are spaced by their original receive times; otherwise they are replayed as
+// fast as the detector keeps up. Pending updates are flushed at the end.
+func (s *Service) Replay(ctx context.Context, path string, realtime bool) error {
+	files, err := recordingFiles(path)
+	if err != nil {
+		return err
+	}
+
+	replayer := &replayer{
+		service:   s,
+		realtime:  realtime,
+		providers: make(map[string]*provider),
+	}
+
+	startTime := time.Now()
+	for _, file := range files {
+		if err := replayer.replayFile(ctx, file); err != nil {
+			return err
+		}
+	}
+
+	s.graphManager.Flush()
+
+	log.Info().
+		Int("files", len(files)).
+		Int("records", replayer.records).
+		Dur("duration", time.Since(startTime)).
+		Msg("Replay complete")
+
+	return nil
+}
+
+// recordingFiles returns the recording files at path in replay order.
+func recordingFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, recordFilePrefix+"*"+recordFileSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("listing recordings: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recordings found in %s", path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// replayer holds the state of a replay across files.
+type replayer struct {
+	service  *Service
+	realtime bool
+
+	// Providers by recorded name
+	providers map[string]*provider
+
+	// First record's receive time and when it was replayed, for realtime pacing
+	firstReceived time.Time
+	startedAt     time.Time
+
+	records int
+}
+
+// replayFile replays the records of a single file.
+func (r *replayer) replayFile(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening recording: %w", err)
+	}
+	defer file.Close()
+
+	log.Info().Str("path", path).Msg("Replaying recording")
+
+	decoder := json.NewDecoder(file)
+	for {
+		var rec Record
+		if err := decoder.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading %s after %d records: %w", path, r.records, err)
+		}
+
+		if err := r.wait(ctx, rec.ReceivedAt); err != nil {
+			return err
+		}
+		if err := r.apply(rec); err != nil {
+			return fmt.Errorf("replaying %s record from %s: %w", rec.Kind, path, err)
+		}
+		r.records++
+	}
+}
+
+// wait paces realtime replays, and in every mode waits until the detector can
+// take another snapshot so none are discarded.
+func (r *replayer) wait(ctx context.Context, receivedAt time.Time) error {
+	if r.realtime {
+		if r.firstReceived.IsZero() {
+			r.firstReceived = receivedAt
+			r.startedAt = time.Now()
+		}
+		if delay := time.Until(r.startedAt.Add(receivedAt.Sub(r.firstReceived))); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	snapshots := r.service.graphManager.SnapshotCh()
+	for len(snapshots) == cap(snapshots) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return ctx.Err()
+}
+
+// apply applies a single record the same way it was applied when recorded.
+func (r *replayer) apply(rec Record) error {
+	s := r.service
+
+	switch rec.Kind {
+	case string(SubscriptionLogs), string(SubscriptionNewHeads):
+		n := Notification{
+			Subscription: rec.Subscription,
+			Kind:         SubscriptionKind(rec.Kind),
+			Params:       rec.Params,
+		}
+		s.processMessage(r.provider(rec.Provider), n, rec.ReceivedAt)
+
+	case recordKindPolled:
+		var poll recordedPoll
+		if err := json.Unmarshal(rec.Params, &poll); err != nil {
+			return err
+		}
+		s.applyPoll(poll.Header, poll.Logs, rec.ReceivedAt)
+
+	case recordKindUpdate:
+		var update graph.ReserveUpdate
+		if err := json.Unmarshal(rec.Params, &update); err != nil {
+			return err
+		}
+		// Mark the log as delivered so a later streamed copy is dropped, as during backfill
+		if update.BlockHash != "" {
+			s.dedup.Check(logKey{blockHash: strings.ToLower(update.BlockHash), logIndex: update.LogIndex}, rec.ReceivedAt)
+		}
+		s.graphManager.ProcessUpdate(update)
+
+	case recordKindPools:
+		var added recordedPools
+		if err := json.Unmarshal(rec.Params, &added); err != nil {
+			return err
+		}
+		s.graphManager.AddPoolBatch(added.Pools, added.Tokens)
+
+	case recordKindRemoved:
+		var removed recordedRemoved
+		if err := json.Unmarshal(rec.Params, &removed); err != nil {
+			return err
+		}
+		s.graphManager.RemovePools(removed.Pools)
+
+	case recordKindTracked:
+		var tracked recordedTracked
+		if err := json.Unmarshal(rec.Params, &tracked); err != nil {
+			return err
+		}
+		if tracked.Replace {
+			s.SetTrackedPools(tracked.Pools)
+		} else {
+			for _, addr := range tracked.Pools {
+				s.AddTrackedPool(addr)
+			}
+		}
+
+	default:
+		log.Warn().Str("kind", rec.Kind).Msg("Skipping unknown record kind")
+	}
+
+	return nil
+}
+
+// provider returns the replay provider with the recorded name.
+func (r *replayer) provider(name string) *provider {
+	p, ok := r.providers[name]
+	if !ok {
+		p = &provider{name: name}
+		r.providers[name] = p
+	}
+	return p
+}
@@ -0,0 +1,282 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"watcher/internal/graph"
+	"watcher/internal/mocknode"
+	"watcher/pkg/chain/base"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+const replayPool = "0x1234567890123456789012345678901234567890"
+
+func syncNotification(t *testing.T, block, logIndex string, reserve0 string) Notification {
+	params, err := json.Marshal(map[string]interface{}{
+		"subscription": "0x1",
+		"result": LogEntry{
+			Address:     replayPool,
+			Topics:      []string{SyncEventTopic.Hex()},
+			Data:        "0x" + reserve0 + "0000000000000000000000000000000000000000000000001bc16d674ec80000",
+			BlockNumber: block,
+			BlockHash:   "0xhash" + block,
+			LogIndex:    logIndex,
+		},
+	})
+	require.NoError(t, err)
+	return Notification{Subscription: "0x1", Kind: SubscriptionLogs, Params: params}
+}
+
+func headerNotification(t *testing.T, block string) Notification {
+	params, err := json.Marshal(map[string]interface{}{
+		"subscription": "0x2",
+		"result": HeaderEntry{
+			Number:     block,
+			Hash:       "0xhash" + block,
+			ParentHash: "0xparent",
+			Timestamp:  "0x6553f100",
+		},
+	})
+	require.NoError(t, err)
+	return Notification{Subscription: "0x2", Kind: SubscriptionNewHeads, Params: params}
+}
+
+// recordSession runs a short session through a recording service and returns its snapshots.
+func recordSession(t *testing.T, dir string) []*graph.Snapshot {
+	recorder, err := NewRecorder(dir, 0)
+	require.NoError(t, err)
+	defer recorder.Close()
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	graphManager.SetPoolsAddedHook(recorder.RecordPools)
+
+	service := NewService([]string{"ws://a", "ws://b"}, "", graphManager, nil)
+	service.SetRecorder(recorder)
+
+	// Bootstrap
+	graphManager.AddPool(
+		graph.PoolState{
+			Address:  replayPool,
+			Token0:   "0xtoken0",
+			Token1:   "0xtoken1",
+			Reserve0: big.NewInt(1000000),
+			Reserve1: big.NewInt(2000000),
+			Fee:      0.003,
+		},
+		graph.TokenInfo{Address: "0xtoken0", Symbol: "TKN0", Decimals: 18},
+		graph.TokenInfo{Address: "0xtoken1", Symbol: "TKN1", Decimals: 18},
+	)
+	service.SetTrackedPools([]string{replayPool})
+
+	// Backfill applies an update from before streaming started
+	service.recordUpdate(graph.ReserveUpdate{
+		PoolAddress: replayPool,
+		Reserve0:    big.NewInt(1500000),
+		Reserve1:    big.NewInt(2000000),
+		BlockNumber: 99,
+		BlockHash:   "0xhash0x63",
+	})
+	graphManager.ProcessUpdate(graph.ReserveUpdate{
+		PoolAddress: replayPool,
+		Reserve0:    big.NewInt(1500000),
+		Reserve1:    big.NewInt(2000000),
+		BlockNumber: 99,
+		BlockHash:   "0xhash0x63",
+	})
+
+	now := time.Now()
+	messages := []providerMessage{
+		{provider: service.providers[0], notification: syncNotification(t, "0x64", "0x0", "0000000000000000000000000000000000000000000000000de0b6b3a7640000"), receivedAt: now},
+		{provider: service.providers[1], notification: syncNotification(t, "0x64", "0x0", "0000000000000000000000000000000000000000000000000de0b6b3a7640000"), receivedAt: now.Add(time.Millisecond)},
+		{provider: service.providers[0], notification: headerNotification(t, "0x64"), receivedAt: now.Add(2 * time.Millisecond)},
+		{provider: service.providers[1], notification: syncNotification(t, "0x65", "0x3", "00000000000000000000000000000000000000000000000029a2241af62c0000"), receivedAt: now.Add(3 * time.Millisecond)},
+		{provider: service.providers[1], notification: headerNotification(t, "0x65"), receivedAt: now.Add(4 * time.Millisecond)},
+	}
+	for _, msg := range messages {
+		service.recordMessage(msg)
+		service.processMessage(msg.provider, msg.notification, msg.receivedAt)
+	}
+
+	return drainSnapshots(graphManager)
+}
+
+func drainSnapshots(graphManager *graph.Manager) []*graph.Snapshot {
+	var snapshots []*graph.Snapshot
+	for {
+		select {
+		case snap := <-graphManager.SnapshotCh():
+			snapshots = append(snapshots, snap)
+		default:
+			return snapshots
+		}
+	}
+}
+
+func TestReplayReproducesSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	recorded := recordSession(t, dir)
+	require.Len(t, recorded, 3, "backfilled block 99, then blocks 100 and 101 closed by headers")
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	service := NewService(nil, "", graphManager, nil)
+
+	require.NoError(t, service.Replay(context.Background(), dir, false))
+	replayed := drainSnapshots(graphManager)
+
+	require.Len(t, replayed, len(recorded))
+	for i := range recorded {
+		require.Equal(t, recorded[i].BlockNumber, replayed[i].BlockNumber)
+		require.Equal(t, recorded[i].BlockHash, replayed[i].BlockHash)
+		require.Equal(t, recorded[i].BlockTime, replayed[i].BlockTime)
+		require.Equal(t, 0, recorded[i].Pools[replayPool].Reserve0.Cmp(replayed[i].Pools[replayPool].Reserve0))
+		require.Equal(t, 0, recorded[i].Pools[replayPool].Reserve1.Cmp(replayed[i].Pools[replayPool].Reserve1))
+	}
+	require.True(t, service.IsTracked(replayPool))
+}
+
+func TestReplayReproducesPolledLogs(t *testing.T) {
+	ctx := context.Background()
+	pool := common.HexToAddress(replayPool)
+	token0 := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	token1 := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	node := mocknode.New(common.HexToAddress("0x00000000000000000000000000000000000000f0"))
+	defer node.Close()
+	node.AddPool(mocknode.Pool{Address: pool, Token0: token0, Token1: token1, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000)})
+
+	client, err := base.NewClient(node.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, 0)
+	require.NoError(t, err)
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	graphManager.SetPoolsAddedHook(recorder.RecordPools)
+	service := NewService(nil, "", graphManager, nil)
+	service.SetRecorder(recorder)
+	service.SetPoller(NewPoller(client), time.Second)
+
+	graphManager.AddPool(
+		graph.PoolState{Address: replayPool, Token0: "0xtoken0", Token1: "0xtoken1", Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000), Fee: 0.003},
+		graph.TokenInfo{Address: "0xtoken0", Symbol: "TKN0", Decimals: 18},
+		graph.TokenInfo{Address: "0xtoken1", Symbol: "TKN1", Decimals: 18},
+	)
+	service.SetTrackedPools([]string{replayPool})
+
+	require.NoError(t, node.SetReserves(pool, big.NewInt(1100), big.NewInt(1900)))
+	node.MineBlock()
+	_, err = service.pollOnce(ctx, mocknode.GenesisBlock+1)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+	recorded := drainSnapshots(graphManager)
+	require.Len(t, recorded, 1)
+
+	replayGraph := graph.NewManager(nil)
+	defer replayGraph.Close()
+	replayService := NewService(nil, "", replayGraph, nil)
+	require.NoError(t, replayService.Replay(ctx, dir, false))
+
+	replayed := drainSnapshots(replayGraph)
+	require.Len(t, replayed, 1)
+	require.Equal(t, recorded[0].BlockNumber, replayed[0].BlockNumber)
+	require.True(t, recorded[0].BlockTime.Equal(replayed[0].BlockTime))
+	require.Equal(t, 0, big.NewInt(1100).Cmp(replayed[0].Pools[replayPool].Reserve0))
+	require.Equal(t, uint64(mocknode.GenesisBlock+1), replayService.LastProcessedBlock())
+}
+
+func TestReplayRemovesPools(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, 0)
+	require.NoError(t, err)
+
+	recording := graph.NewManager(nil)
+	defer recording.Close()
+	recording.SetPoolsAddedHook(recorder.RecordPools)
+	recording.SetPoolsRemovedHook(recorder.RecordRemovedPools)
+
+	recording.AddPoolBatch([]graph.PoolState{
+		{Address: replayPool, Token0: "0xtoken0", Token1: "0xtoken1", Reserve0: big.NewInt(1), Reserve1: big.NewInt(2), Fee: 0.003},
+		{Address: "0xother", Token0: "0xtoken0", Token1: "0xtoken1", Reserve0: big.NewInt(3), Reserve1: big.NewInt(4), Fee: 0.003},
+	}, nil)
+	require.Equal(t, 1, recording.RemovePools([]string{replayPool, "0xmissing"}))
+	require.NoError(t, recorder.Close())
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	service := NewService(nil, "", graphManager, nil)
+	require.NoError(t, service.Replay(context.Background(), dir, false))
+
+	pools := graphManager.GetCurrentSnapshot(0).Pools
+	require.NotContains(t, pools, replayPool)
+	require.Contains(t, pools, "0xother")
+}
+
+func TestRecorderRotatesFiles(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, 200)
+	require.NoError(t, err)
+
+	params := json.RawMessage(`{"subscription":"0x1","result":{"number":"0x1"}}`)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, recorder.Record(Record{
+			ReceivedAt: time.Now(),
+			Provider:   "a",
+			Kind:       string(SubscriptionNewHeads),
+			Params:     params,
+		}))
+	}
+	require.NoError(t, recorder.Close())
+
+	files, err := recordingFiles(dir)
+	require.NoError(t, err)
+	require.Greater(t, len(files), 1)
+
+	// Files are append-only JSONL, each within the size limit
+	for _, file := range files {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(200))
+		require.Equal(t, recordFileSuffix, filepath.Ext(file))
+	}
+
+	require.Error(t, recorder.Record(Record{Kind: string(SubscriptionLogs)}), "closed recorder")
+}
+
+func TestReplayRealtimeHonoursCancellation(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, 0)
+	require.NoError(t, err)
+
+	start := time.Now()
+	for _, offset := range []time.Duration{0, time.Hour} {
+		require.NoError(t, recorder.Record(Record{
+			ReceivedAt: start.Add(offset),
+			Kind:       string(SubscriptionNewHeads),
+			Params:     headerNotification(t, "0x1").Params,
+		}))
+	}
+	require.NoError(t, recorder.Close())
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+	service := NewService(nil, "", graphManager, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The second record is an hour later, so a realtime replay waits for it
+	err = service.Replay(ctx, dir, true)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
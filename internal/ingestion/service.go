@@ -3,14 +3,18 @@ package ingestion
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"watcher/internal/graph"
 	"watcher/internal/metrics"
+	"watcher/internal/supervisor"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,13 +22,32 @@ const (
 	maxReconnectAttempts = 10
 	initialBackoff       = 1 * time.Second
 	maxBackoff           = 30 * time.Second
+
+	// resubscribeDebounce batches bursts of tracked-set changes into one resubscription
+	resubscribeDebounce = 2 * time.Second
+
+	// maxRecentHeaders bounds how many block headers are kept for stamping late logs
+	maxRecentHeaders = 128
+
+	// backfillRetryDelay is the wait before retrying block ranges that could not be fetched
+	backfillRetryDelay = 30 * time.Second
+
+	// webSocketProbeInterval is how often fallback polling checks whether a WebSocket provider is back
+	webSocketProbeInterval = time.Minute
+
+	// webSocketProbeTimeout bounds a single probe connection attempt
+	webSocketProbeTimeout = 10 * time.Second
 )
 
+// errWebSocketRecovered stops fallback polling once a WebSocket provider accepts connections again.
+var errWebSocketRecovered = errors.New("websocket provider recovered")
+
 // Service handles event ingestion from the blockchain.
+// It holds one WebSocket connection per provider and applies each log from
+// whichever provider delivers it first.
 type Service struct {
-	wsURL   string
-	client  *WSClient
-	decoder *Decoder
+	providers []*provider
+	decoder   *Decoder
 
 	graphManager *graph.Manager
 	metrics      *metrics.Metrics
@@ -34,28 +57,64 @@ type Service struct {
 	trackedPools   map[string]struct{}
 	factoryAddress string
 
+	// Incremented on every tracked-set or handler change; providers resubscribe when behind
+	trackedVersion atomic.Uint64
+	resubscribeCh  chan struct{}
+
 	// Event channels
 	syncEvents        chan *SyncEvent
 	poolCreatedEvents chan *PoolCreatedEvent
 
+	// Provider fan-in
+	messages chan providerMessage
+	ready    chan providerReady
+	dedup    *logDeduper
+
 	// State
 	lastBlockNumber uint64
 
-	// Reconciliation
-	reconciler           *Reconciler
-	bootstrapStartBlock  uint64
-	reconciliationDone   bool
+	// Recent block headers by number, for stamping Sync events with block time
+	headers         map[uint64]graph.BlockHeader
+	lastHeaderBlock uint64
+
+	// HTTP polling
+	mode          Mode
+	poller        *Poller
+	pollInterval  time.Duration
+	probeInterval time.Duration
+
+	// Head when Run started; polling starts here if no block has been processed yet
+	startBlock uint64
+
+	// Reconciliation. Every (re)subscription backfills from lastProcessedBlock+1 to head.
+	reconciler         *Reconciler
+	bootstrapBlock     uint64
+	lastProcessedBlock atomic.Uint64 // Read by state persistence from other goroutines
+	backfillCh         chan struct{}
+
+	// First block of the earliest range a backfill failed to fetch, 0 if none.
+	// The next backfill starts there even if streaming has moved past it.
+	unfetchedFrom uint64
+
+	// Optional recording of everything that changes ingestion state
+	recorder *Recorder
+
+	// Rolling per-pool volume, swap counts and fees
+	activity *ActivityTracker
+
+	// Handlers by event topic; subscriptions are built from it
+	events *EventRegistry
 }
 
-// NewService creates a new ingestion service.
+// NewService creates a new ingestion service with one provider per WebSocket URL.
 func NewService(
-	wsURL string,
+	wsURLs []string,
 	factoryAddress string,
 	graphManager *graph.Manager,
 	m *metrics.Metrics,
 ) *Service {
-	return &Service{
-		wsURL:             wsURL,
+	s := &Service{
+		providers:         newProviders(wsURLs),
 		decoder:           NewDecoder(),
 		graphManager:      graphManager,
 		metrics:           m,
@@ -63,14 +122,118 @@ func NewService(
 		factoryAddress:    strings.ToLower(factoryAddress),
 		syncEvents:        make(chan *SyncEvent, 1000),
 		poolCreatedEvents: make(chan *PoolCreatedEvent, 100),
+		messages:          make(chan providerMessage, 1000),
+		ready:             make(chan providerReady),
+		dedup:             newLogDeduper(defaultDedupCapacity),
+		headers:           make(map[uint64]graph.BlockHeader),
+		resubscribeCh:     make(chan struct{}, 1),
+		backfillCh:        make(chan struct{}, 1),
+		probeInterval:     webSocketProbeInterval,
+		mode:              ModeWebSocket,
+		activity:          NewActivityTracker(defaultActivityWindow),
+		events:            NewEventRegistry(),
+	}
+	s.registerEventHandlers()
+	return s
+}
+
+// registerEventHandlers registers the Aerodrome V2 pool and factory events.
+func (s *Service) registerEventHandlers() {
+	tracked := trackedAddresses{s}
+	factory := NewStaticAddresses(s.factoryAddress)
+
+	handlers := []EventHandler{
+		{
+			Name:      "sync",
+			Topic:     SyncEventTopic,
+			Addresses: tracked,
+			Decode:    func(l *LogEntry) (interface{}, error) { return s.decoder.DecodeSyncEvent(l) },
+			Handle:    func(e interface{}, receivedAt time.Time) { s.processSyncEvent(e.(*SyncEvent), receivedAt) },
+		},
+		{
+			Name:      "pool_created",
+			Topic:     PoolCreatedEventTopic,
+			Addresses: factory,
+			Decode:    func(l *LogEntry) (interface{}, error) { return s.decoder.DecodePoolCreatedEvent(l) },
+			Handle:    func(e interface{}, _ time.Time) { s.processPoolCreatedEvent(e.(*PoolCreatedEvent)) },
+		},
+		{
+			Name:      "swap",
+			Topic:     SwapEventTopic,
+			Addresses: tracked,
+			Decode:    func(l *LogEntry) (interface{}, error) { return s.decoder.DecodeSwapEvent(l) },
+			Handle:    func(e interface{}, receivedAt time.Time) { s.processSwapEvent(e.(*SwapEvent), receivedAt) },
+		},
+	}
+	for _, liquidity := range []struct {
+		eventType LiquidityEventType
+		topic     common.Hash
+	}{
+		{LiquidityMint, MintEventTopic},
+		{LiquidityBurn, BurnEventTopic},
+		{LiquidityFees, FeesEventTopic},
+	} {
+		handlers = append(handlers, EventHandler{
+			Name:      string(liquidity.eventType),
+			Topic:     liquidity.topic,
+			Addresses: tracked,
+			Decode:    func(l *LogEntry) (interface{}, error) { return s.decoder.DecodeLiquidityEvent(l) },
+			Handle:    func(e interface{}, receivedAt time.Time) { s.processLiquidityEvent(e.(*LiquidityEvent), receivedAt) },
+		})
+	}
+
+	for _, handler := range handlers {
+		if err := s.events.Register(handler); err != nil {
+			panic(err)
+		}
 	}
 }
 
+// RegisterEventHandler adds a handler for another event topic, e.g. from a DEX
+// adapter. Connected providers resubscribe to include its topic and addresses.
+func (s *Service) RegisterEventHandler(handler EventHandler) error {
+	if err := s.events.Register(handler); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.trackedChangedLocked()
+	s.mu.Unlock()
+	return nil
+}
+
+// trackedAddresses is the tracked pool set as an AddressSet.
+type trackedAddresses struct {
+	s *Service
+}
+
+// Addresses returns the tracked pools.
+func (t trackedAddresses) Addresses() []string {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	addresses := make([]string, 0, len(t.s.trackedPools))
+	for addr := range t.s.trackedPools {
+		addresses = append(addresses, addr)
+	}
+	return addresses
+}
+
+// Contains reports whether address is a tracked pool.
+func (t trackedAddresses) Contains(address string) bool {
+	return t.s.IsTracked(address)
+}
+
 // SyncEvents returns the channel for receiving Sync events.
 func (s *Service) SyncEvents() <-chan *SyncEvent {
 	return s.syncEvents
 }
 
+// Activity returns the per-pool activity tracker fed by Swap, Mint, Burn and Fees events.
+func (s *Service) Activity() *ActivityTracker {
+	return s.activity
+}
+
 // PoolCreatedEvents returns the channel for receiving PoolCreated events.
 func (s *Service) PoolCreatedEvents() <-chan *PoolCreatedEvent {
 	return s.poolCreatedEvents
@@ -85,6 +248,8 @@ func (s *Service) SetTrackedPools(addresses []string) {
 	for _, addr := range addresses {
 		s.trackedPools[strings.ToLower(addr)] = struct{}{}
 	}
+	s.trackedChangedLocked()
+	s.recordTracked(addresses, true)
 
 	log.Info().Int("count", len(addresses)).Msg("Updated tracked pools")
 }
@@ -94,7 +259,25 @@ func (s *Service) AddTrackedPool(address string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.trackedPools[strings.ToLower(address)] = struct{}{}
+	address = strings.ToLower(address)
+	if _, exists := s.trackedPools[address]; exists {
+		return
+	}
+	s.trackedPools[address] = struct{}{}
+	s.trackedChangedLocked()
+	s.recordTracked([]string{address}, false)
+}
+
+// trackedChangedLocked records a tracked-set change and schedules a resubscription.
+// Must be called with s.mu held.
+func (s *Service) trackedChangedLocked() {
+	s.trackedVersion.Add(1)
+
+	select {
+	case s.resubscribeCh <- struct{}{}:
+	default:
+		// A resubscription is already scheduled
+	}
 }
 
 // IsTracked returns true if the pool is being tracked.
@@ -114,190 +297,762 @@ func (s *Service) TrackedPoolCount() int {
 	return len(s.trackedPools)
 }
 
-// Run starts the ingestion service with automatic reconnection.
+// SetMode selects the ingestion source. Defaults to ModeWebSocket.
+func (s *Service) SetMode(mode Mode) {
+	s.mode = mode
+}
+
+// SetPoller configures HTTP polling. In ModeWebSocket the poller is used as a
+// fallback once every WebSocket provider has given up reconnecting.
+func (s *Service) SetPoller(poller *Poller, interval time.Duration) {
+	s.poller = poller
+	s.pollInterval = interval
+}
+
+// Run starts ingestion from the configured source and processes events.
+// Configuration errors are returned as supervisor.Permanent; any other error
+// means every source gave up and the caller may restart the service.
 func (s *Service) Run(ctx context.Context) error {
+	if s.poller != nil && s.lastProcessedBlock.Load() == 0 {
+		head, err := s.poller.Head(ctx)
+		if err != nil {
+			if s.mode == ModeHTTP {
+				return fmt.Errorf("getting start block: %w", err)
+			}
+			log.Warn().Err(err).Msg("Failed to get start block for HTTP polling fallback")
+		}
+		s.startBlock = head
+	}
+
+	if s.mode == ModeHTTP {
+		return s.runPolling(ctx)
+	}
+
+	for {
+		err := s.runWebSocket(ctx)
+		if err == nil || ctx.Err() != nil || s.poller == nil {
+			return err
+		}
+
+		log.Warn().Err(err).Msg("WebSocket ingestion failed, falling back to HTTP polling")
+		err = s.runPolling(ctx)
+		if !errors.Is(err, errWebSocketRecovered) {
+			return err
+		}
+
+		// Reconnecting backfills from the last block polled
+		log.Info().Msg("WebSocket recovered, switching back from HTTP polling")
+	}
+}
+
+// runWebSocket starts one connection per provider and processes their events.
+// It keeps running as long as at least one provider is still reconnecting.
+func (s *Service) runWebSocket(ctx context.Context) error {
+	if len(s.providers) == 0 {
+		return supervisor.Permanent(fmt.Errorf("no websocket providers configured"))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go s.runResubscriber(ctx)
+
+	exhausted := make(chan *provider, len(s.providers))
+	for _, p := range s.providers {
+		go func(p *provider) {
+			s.runProvider(ctx, p)
+			exhausted <- p
+		}(p)
+	}
+
+	failed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case p := <-exhausted:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			log.Error().
+				Str("provider", p.name).
+				Int("failed_providers", failed).
+				Int("total_providers", len(s.providers)).
+				Msg("WebSocket provider gave up reconnecting")
+			if failed == len(s.providers) {
+				return fmt.Errorf("max reconnection attempts reached on all %d providers", failed)
+			}
+
+		case r := <-s.ready:
+			// Run reconciliation AFTER subscription is confirmed but BEFORE processing messages.
+			// This runs on every (re)connect, so events emitted while offline are not lost.
+			if err := s.runReconciliation(ctx); err != nil {
+				// Don't fail - stream now and retry the missing ranges later
+				log.Error().Err(err).Str("provider", r.provider.name).Msg("Reconciliation failed, continuing with potentially stale data")
+				s.scheduleBackfillRetry(ctx)
+			}
+			close(r.ack)
+
+		case <-s.backfillCh:
+			if err := s.runReconciliation(ctx); err != nil {
+				log.Error().Err(err).Msg("Backfill failed")
+				s.scheduleBackfillRetry(ctx)
+			}
+
+		case msg := <-s.messages:
+			s.recordMessage(msg)
+			s.processMessage(msg.provider, msg.notification, msg.receivedAt)
+		}
+	}
+}
+
+// runProvider keeps a single provider connected with exponential backoff.
+// Returns when the context is canceled or the provider fails maxReconnectAttempts times in a row.
+func (s *Service) runProvider(ctx context.Context, p *provider) {
 	for attempt := 0; attempt < maxReconnectAttempts; attempt++ {
 		if attempt > 0 {
 			backoff := calculateBackoff(attempt)
 			log.Info().
+				Str("provider", p.name).
 				Int("attempt", attempt).
 				Dur("backoff", backoff).
 				Msg("Reconnecting to WebSocket")
 
 			select {
 			case <-ctx.Done():
-				return ctx.Err()
+				return
 			case <-time.After(backoff):
 			}
 		}
 
-		err := s.runOnce(ctx)
-		if err == nil || ctx.Err() != nil {
-			return err
+		connected, err := s.runOnce(ctx, p)
+		if ctx.Err() != nil {
+			return
 		}
 
-		log.Error().Err(err).Msg("WebSocket connection error")
+		log.Error().Err(err).Str("provider", p.name).Msg("WebSocket connection error")
 
-		if s.metrics != nil {
-			s.metrics.SetWebSocketConnected(false)
+		// A session that got as far as subscribing resets the failure count
+		if connected {
+			attempt = 0
+		}
+	}
+}
+
+// runOnce runs a single provider connection until an error occurs or context is canceled.
+// Returns true if the connection was established and subscribed.
+func (s *Service) runOnce(ctx context.Context, p *provider) (bool, error) {
+	client := NewWSClient(p.url)
+
+	if err := client.Connect(ctx); err != nil {
+		return false, fmt.Errorf("connecting to websocket: %w", err)
+	}
+	defer client.Close()
+
+	p.setClient(client)
+	s.setProviderConnected(p, true)
+	defer func() {
+		p.setClient(nil)
+		p.resetSubscription()
+		s.setProviderConnected(p, false)
+		if ctx.Err() == nil && s.metrics != nil {
+			s.metrics.RecordProviderDisconnect(p.name)
+		}
+	}()
+
+	// Start message reader; subscription confirmations are delivered through it
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.ReadMessages(ctx)
+	}()
+
+	// Start ping loop
+	go client.StartPingLoop(ctx)
+
+	// Subscribe to events
+	if err := s.subscribeProvider(ctx, p); err != nil {
+		return false, fmt.Errorf("subscribing to events: %w", err)
+	}
+
+	// Block headers close each block and carry its timestamp
+	if _, err := client.SubscribeNewHeads(ctx); err != nil {
+		return false, fmt.Errorf("subscribing to new heads: %w", err)
+	}
+
+	// Wait for the service to finish reconciliation before streaming.
+	// Notifications arriving meanwhile are held back, not dropped.
+	var backlog []providerMessage
+	ack := make(chan struct{})
+	readyCh := s.ready
+	for waiting := true; waiting; {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case err := <-errCh:
+			return true, connectionError(err)
+		case readyCh <- providerReady{provider: p, ack: ack}:
+			readyCh = nil
+		case <-ack:
+			waiting = false
+		case n := <-client.Messages():
+			backlog = append(backlog, providerMessage{provider: p, notification: n, receivedAt: time.Now()})
+		}
+	}
+
+	for _, msg := range backlog {
+		select {
+		case s.messages <- msg:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+
+	// Forward messages to the service
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+
+		case err := <-errCh:
+			return true, connectionError(err)
+
+		case n := <-client.Messages():
+			select {
+			case s.messages <- providerMessage{provider: p, notification: n, receivedAt: time.Now()}:
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
 		}
 	}
+}
 
-	return fmt.Errorf("max reconnection attempts reached")
+// connectionError returns the error that ended a connection's read loop.
+func connectionError(err error) error {
+	if err == nil {
+		return fmt.Errorf("connection closed")
+	}
+	return err
 }
 
-// runOnce runs the ingestion service until an error occurs or context is canceled.
-func (s *Service) runOnce(ctx context.Context) error {
-	s.client = NewWSClient(s.wsURL)
+// setProviderConnected updates the provider's connection state and metrics.
+func (s *Service) setProviderConnected(p *provider, connected bool) {
+	p.connected.Store(connected)
 
-	if err := s.client.Connect(ctx); err != nil {
-		return fmt.Errorf("connecting to websocket: %w", err)
+	if s.metrics == nil {
+		return
 	}
-	defer s.client.Close()
+	s.metrics.SetProviderConnected(p.name, connected)
 
-	if s.metrics != nil {
-		s.metrics.SetWebSocketConnected(true)
+	anyConnected := false
+	for _, other := range s.providers {
+		if other.connected.Load() {
+			anyConnected = true
+			break
+		}
 	}
+	s.metrics.SetWebSocketConnected(anyConnected)
+}
 
-	// Subscribe to events
-	if err := s.subscribe(ctx); err != nil {
-		return fmt.Errorf("subscribing to events: %w", err)
+// ConnectedProviders returns the number of providers with a live connection.
+func (s *Service) ConnectedProviders() int {
+	count := 0
+	for _, p := range s.providers {
+		if p.connected.Load() {
+			count++
+		}
+	}
+	return count
+}
+
+// runPolling polls for new blocks over HTTP and processes their logs.
+// Polling resumes from the last block seen, so switching over from WebSocket
+// leaves no gap; logs already applied are dropped by the deduper.
+// As a WebSocket fallback it probes the providers and returns
+// errWebSocketRecovered once one accepts connections again.
+func (s *Service) runPolling(ctx context.Context) error {
+	if s.poller == nil {
+		return supervisor.Permanent(fmt.Errorf("http polling not configured"))
 	}
 
-	// Run reconciliation AFTER subscription is confirmed but BEFORE processing messages.
-	// This ensures we don't miss any events between bootstrap and streaming.
 	if err := s.runReconciliation(ctx); err != nil {
-		// Log warning but don't fail - reconciliation is best-effort
-		log.Warn().Err(err).Msg("Reconciliation failed, continuing with potentially stale data")
+		log.Error().Err(err).Msg("Reconciliation failed, continuing with potentially stale data")
+		s.scheduleBackfillRetry(ctx)
 	}
 
-	// Start ping loop
-	go s.client.StartPingLoop(ctx)
+	nextBlock, err := s.pollStartBlock(ctx)
+	if err != nil {
+		return err
+	}
 
-	// Start message reader
-	errCh := make(chan error, 1)
-	go func() {
-		errCh <- s.client.ReadMessages(ctx)
-	}()
+	log.Info().
+		Uint64("from_block", nextBlock).
+		Dur("interval", s.pollInterval).
+		Msg("Starting HTTP polling ingestion")
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	var probe <-chan time.Time
+	if s.mode != ModeHTTP && len(s.providers) > 0 {
+		probeTicker := time.NewTicker(s.probeInterval)
+		defer probeTicker.Stop()
+		probe = probeTicker.C
+	}
 
-	// Process messages
+	failures := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-probe:
+			if s.webSocketAvailable(ctx) {
+				return errWebSocketRecovered
+			}
+			continue
+		case <-s.backfillCh:
+			if err := s.runReconciliation(ctx); err != nil {
+				log.Error().Err(err).Msg("Backfill failed")
+				s.scheduleBackfillRetry(ctx)
+			}
+			continue
+		case <-ticker.C:
+		}
 
-		case err := <-errCh:
-			return err
+		next, err := s.pollOnce(ctx, nextBlock)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures++
+			log.Warn().Err(err).Int("failures", failures).Msg("Polling failed")
+			if failures >= maxReconnectAttempts {
+				return fmt.Errorf("polling failed %d times in a row: %w", failures, err)
+			}
+			continue
+		}
 
-		case msg := <-s.client.Messages():
-			s.processMessage(msg)
+		failures = 0
+		nextBlock = next
+	}
+}
+
+// pollStartBlock is the first block to poll: the one after the last processed
+// block (the bootstrap block until anything streams), or the head when Run
+// started if nothing has been processed at all.
+func (s *Service) pollStartBlock(ctx context.Context) (uint64, error) {
+	if last := s.lastProcessedBlock.Load(); last > 0 {
+		return last + 1, nil
+	}
+	if s.startBlock > 0 {
+		return s.startBlock, nil
+	}
+
+	head, err := s.poller.Head(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting start block: %w", err)
+	}
+	return head, nil
+}
+
+// webSocketAvailable reports whether any provider accepts a WebSocket connection.
+func (s *Service) webSocketAvailable(ctx context.Context) bool {
+	for _, p := range s.providers {
+		probeCtx, cancel := context.WithTimeout(ctx, webSocketProbeTimeout)
+		client := NewWSClient(p.url)
+		err := client.Connect(probeCtx)
+		cancel()
+		if err == nil {
+			client.Close()
+			return true
 		}
+		log.Debug().Err(err).Str("provider", p.name).Msg("WebSocket provider still unavailable")
 	}
+	return false
 }
 
-// subscribe subscribes to Sync and PoolCreated events.
-func (s *Service) subscribe(ctx context.Context) error {
-	s.mu.RLock()
-	addresses := make([]string, 0, len(s.trackedPools)+1)
-	for addr := range s.trackedPools {
-		addresses = append(addresses, addr)
+// pollOnce processes logs from fromBlock up to the current head, at most maxBlockRange blocks.
+// Returns the next block to poll.
+func (s *Service) pollOnce(ctx context.Context, fromBlock uint64) (uint64, error) {
+	head, err := s.poller.Head(ctx)
+	if err != nil {
+		return fromBlock, err
 	}
-	s.mu.RUnlock()
+	if head < fromBlock {
+		return fromBlock, nil
+	}
+
+	toBlock := head
+	if toBlock-fromBlock >= maxBlockRange {
+		toBlock = fromBlock + maxBlockRange - 1
+	}
+
+	header, err := s.poller.Header(ctx, toBlock)
+	if err != nil {
+		return fromBlock, err
+	}
+
+	logEntries, err := s.poller.Poll(ctx, s.subscriptionAddresses(), s.events.Topics(), fromBlock, toBlock)
+	if err != nil {
+		return fromBlock, err
+	}
+
+	receivedAt := time.Now()
+	s.recordPoll(header, logEntries, receivedAt)
+	s.applyPoll(header, logEntries, receivedAt)
+
+	return toBlock + 1, nil
+}
+
+// applyPoll processes the logs of a polled block range, then closes it at
+// its last block's header.
+func (s *Service) applyPoll(header graph.BlockHeader, logEntries []*LogEntry, receivedAt time.Time) {
+	// Record the closing header first so its block's events carry the block timestamp
+	s.recordHeader(header)
+
+	for _, logEntry := range logEntries {
+		s.processLogEntry(nil, logEntry, receivedAt)
+	}
+	s.graphManager.CloseBlock(header)
+	s.markProcessed(header.Number)
+}
+
+// subscriptionAddresses returns the union of the registered handlers' address sets:
+// the tracked pools plus the factory address.
+func (s *Service) subscriptionAddresses() []string {
+	return s.events.Addresses()
+}
+
+// subscriptionTopics returns the registered topics as hex strings.
+func (s *Service) subscriptionTopics() []string {
+	registered := s.events.Topics()
+	topics := make([]string, len(registered))
+	for i, topic := range registered {
+		topics[i] = topic.Hex()
+	}
+	return topics
+}
 
-	// Add factory address for PoolCreated events
-	if s.factoryAddress != "" {
-		addresses = append(addresses, s.factoryAddress)
+// subscribeProvider subscribes a provider to the registered event topics for the current
+// tracked set. An existing log subscription is only removed after the new one is confirmed,
+// so no events are missed; logs delivered by both are dropped by the deduper.
+func (s *Service) subscribeProvider(ctx context.Context, p *provider) error {
+	client := p.getClient()
+	if client == nil {
+		return fmt.Errorf("not connected")
 	}
 
-	// Subscribe to both Sync and PoolCreated events
-	topics := []string{
-		SyncEventTopic.Hex(),
-		PoolCreatedEventTopic.Hex(),
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	version := s.trackedVersion.Load()
+	addresses := s.subscriptionAddresses()
+
+	subID, err := client.Subscribe(ctx, addresses, s.subscriptionTopics())
+	if err != nil {
+		return err
 	}
 
-	return s.client.Subscribe(ctx, addresses, topics)
+	oldSubID := p.logsSubID
+	p.logsSubID = subID
+	p.filterVersion = version
+
+	if oldSubID != "" {
+		if err := client.Unsubscribe(ctx, oldSubID); err != nil {
+			log.Warn().Err(err).Str("provider", p.name).Msg("Failed to unsubscribe previous filter")
+		}
+	}
+
+	return nil
 }
 
-// Resubscribe updates the subscription with new addresses.
+// Resubscribe updates the subscription of every connected provider whose filter
+// is older than the current tracked set.
 func (s *Service) Resubscribe(ctx context.Context) error {
-	if s.client == nil || !s.client.IsConnected() {
+	connected := 0
+	resubscribed := 0
+	for _, p := range s.providers {
+		client := p.getClient()
+		if client == nil || !client.IsConnected() {
+			continue
+		}
+		connected++
+
+		// Not subscribed yet (its initial subscription will use the current set), or already current
+		if !p.needsResubscribe(s.trackedVersion.Load()) {
+			continue
+		}
+
+		if err := s.subscribeProvider(ctx, p); err != nil {
+			return fmt.Errorf("resubscribing %s: %w", p.name, err)
+		}
+		resubscribed++
+	}
+
+	if connected == 0 {
 		return fmt.Errorf("not connected")
 	}
 
-	// Unsubscribe first
-	if err := s.client.Unsubscribe(ctx); err != nil {
-		log.Warn().Err(err).Msg("Failed to unsubscribe")
+	if resubscribed > 0 {
+		log.Info().
+			Int("providers", resubscribed).
+			Int("tracked_pools", s.TrackedPoolCount()).
+			Msg("Resubscribed with updated tracked pools")
+
+		// Backfill so newly tracked pools catch up to head
+		select {
+		case s.backfillCh <- struct{}{}:
+		default:
+		}
 	}
+	return nil
+}
+
+// runResubscriber resubscribes providers after tracked-set changes settle.
+func (s *Service) runResubscriber(ctx context.Context) {
+	timer := time.NewTimer(resubscribeDebounce)
+	timer.Stop()
+	defer timer.Stop()
 
-	// Resubscribe with new addresses
-	return s.subscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.resubscribeCh:
+			timer.Reset(resubscribeDebounce)
+		case <-timer.C:
+			if err := s.Resubscribe(ctx); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Msg("Automatic resubscription failed")
+			}
+		}
+	}
 }
 
-// processMessage processes a raw WebSocket message.
-func (s *Service) processMessage(raw json.RawMessage) {
-	log.Debug().RawJSON("message", raw).Msg("Received WebSocket message")
+// processMessage processes a raw WebSocket message delivered by provider p.
+// Logs already delivered by another provider are dropped after recording how far
+// behind the first delivery this provider was.
+func (s *Service) processMessage(p *provider, n Notification, receivedAt time.Time) {
+	log.Debug().RawJSON("message", n.Params).Str("kind", string(n.Kind)).Msg("Received WebSocket message")
 
 	// Parse subscription notification
 	var notification struct {
-		Subscription string   `json:"subscription"`
-		Result       LogEntry `json:"result"`
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
 	}
 
-	if err := json.Unmarshal(raw, &notification); err != nil {
+	if err := json.Unmarshal(n.Params, &notification); err != nil {
 		log.Warn().Err(err).Msg("Failed to parse notification")
 		return
 	}
 
-	logEntry := &notification.Result
+	switch n.Kind {
+	case SubscriptionNewHeads:
+		var header HeaderEntry
+		if err := json.Unmarshal(notification.Result, &header); err != nil {
+			log.Warn().Err(err).Msg("Failed to parse block header")
+			return
+		}
+		s.processHeader(p, &header, receivedAt)
+
+	case SubscriptionLogs:
+		var logEntry LogEntry
+		if err := json.Unmarshal(notification.Result, &logEntry); err != nil {
+			log.Warn().Err(err).Msg("Failed to parse log entry")
+			return
+		}
+		s.processLogEntry(p, &logEntry, receivedAt)
+
+	default:
+		log.Debug().Str("kind", string(n.Kind)).Msg("Received notification of unknown kind")
+	}
+}
+
+// processHeader closes the block announced by a newHeads notification.
+// Like logs, each header is applied from whichever provider delivers it first.
+func (s *Service) processHeader(p *provider, entry *HeaderEntry, receivedAt time.Time) {
+	header, err := s.decoder.DecodeHeader(entry)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to decode block header")
+		return
+	}
+
+	if p != nil {
+		s.observeProviderBlock(p, header.Number)
+	}
 
-	// Skip removed logs (chain reorg)
+	// Headers share the log deduper, keyed past any real log index
+	first, duplicate := s.dedup.Check(logKey{blockHash: header.Hash, logIndex: headerLogIndex}, receivedAt)
+	if p != nil && s.metrics != nil {
+		s.metrics.RecordProviderDelivery(p.name, !duplicate, receivedAt.Sub(first))
+	}
+	if duplicate {
+		return
+	}
+
+	log.Debug().
+		Uint64("block", header.Number).
+		Str("hash", header.Hash).
+		Time("block_time", header.Time).
+		Msg("Received block header")
+
+	s.recordHeader(header)
+	s.graphManager.CloseBlock(header)
+
+	// Headers and logs arrive on separate subscriptions, so this block's logs
+	// may still be in flight; only the blocks before it are complete
+	if header.Number > 0 {
+		s.markProcessed(header.Number - 1)
+	}
+
+	activePools := s.activity.Prune(header.Time)
+	if s.metrics != nil {
+		s.metrics.SetActivePools(activePools)
+	}
+}
+
+// recordHeader remembers a header so later logs from its block can be stamped.
+func (s *Service) recordHeader(header graph.BlockHeader) {
+	s.headers[header.Number] = header
+	if header.Number > s.lastHeaderBlock {
+		s.lastHeaderBlock = header.Number
+	}
+
+	// Drop headers too old to matter
+	if len(s.headers) > maxRecentHeaders {
+		for number := range s.headers {
+			if number+maxRecentHeaders <= s.lastHeaderBlock {
+				delete(s.headers, number)
+			}
+		}
+	}
+}
+
+// processLogEntry applies a log delivered by provider p, or by the poller when p is nil.
+func (s *Service) processLogEntry(p *provider, logEntry *LogEntry, receivedAt time.Time) {
+	// Removed logs (chain reorg) are not applied, but the canonical logs
+	// replacing a removed Sync must not be skipped as stale
 	if logEntry.Removed {
 		log.Debug().
 			Str("tx", logEntry.TransactionHash).
 			Msg("Skipping removed log")
+		s.revertRemovedSync(logEntry, receivedAt)
 		return
 	}
 
-	// Log what type of event we received
-	if IsSyncEvent(logEntry) {
-		log.Info().
-			Str("address", logEntry.Address).
-			Str("block", logEntry.BlockNumber).
-			Msg("Received Sync event from WebSocket")
-		s.processSyncEvent(logEntry)
-	} else if IsPoolCreatedEvent(logEntry) {
-		log.Info().
-			Str("address", logEntry.Address).
-			Str("block", logEntry.BlockNumber).
-			Msg("Received PoolCreated event from WebSocket")
-		s.processPoolCreatedEvent(logEntry)
-	} else {
+	if p != nil {
+		if block, err := hexToUint64(logEntry.BlockNumber); err == nil {
+			s.observeProviderBlock(p, block)
+		}
+	}
+
+	// Race-to-first: only the first delivery of a log is applied
+	if key, ok := newLogKey(logEntry); ok {
+		first, duplicate := s.dedup.Check(key, receivedAt)
+		if p != nil && s.metrics != nil {
+			s.metrics.RecordProviderDelivery(p.name, !duplicate, receivedAt.Sub(first))
+		}
+		if duplicate {
+			return
+		}
+	}
+
+	s.dispatch(logEntry, receivedAt)
+}
+
+// revertRemovedSync rolls back the pool reserves and position of a Sync log
+// removed by a reorg. Each removal is applied once, whichever provider delivers it.
+func (s *Service) revertRemovedSync(logEntry *LogEntry, receivedAt time.Time) {
+	if len(logEntry.Topics) == 0 || common.HexToHash(logEntry.Topics[0]) != SyncEventTopic {
+		return
+	}
+	if !s.IsTracked(logEntry.Address) {
+		return
+	}
+	if key, ok := newLogKey(logEntry); ok {
+		key.removed = true
+		if _, duplicate := s.dedup.Check(key, receivedAt); duplicate {
+			return
+		}
+	}
+	block, err := hexToUint64(logEntry.BlockNumber)
+	if err != nil {
+		return
+	}
+	index, err := hexToUint(logEntry.LogIndex)
+	if err != nil {
+		return
+	}
+	s.graphManager.RevertUpdate(logEntry.Address, block, index)
+}
+
+// dispatch decodes a log with the handler registered for its topic and hands the event to it.
+func (s *Service) dispatch(logEntry *LogEntry, receivedAt time.Time) {
+	if len(logEntry.Topics) == 0 {
+		log.Debug().Str("address", logEntry.Address).Msg("Received log without topics")
+		return
+	}
+
+	handler, ok := s.events.Handler(common.HexToHash(logEntry.Topics[0]))
+	if !ok {
 		log.Debug().
 			Str("address", logEntry.Address).
-			Int("topics", len(logEntry.Topics)).
+			Str("topic", logEntry.Topics[0]).
 			Msg("Received unknown event type")
+		return
 	}
-}
-
-// processSyncEvent decodes and processes a Sync event.
-func (s *Service) processSyncEvent(logEntry *LogEntry) {
-	// Normalize address for comparison
-	normalizedAddr := strings.ToLower(logEntry.Address)
 
-	// Check if we're tracking this pool
-	if !s.IsTracked(normalizedAddr) {
+	if !handler.Addresses.Contains(logEntry.Address) {
 		log.Debug().
-			Str("pool", normalizedAddr).
-			Msg("Sync event for untracked pool, skipping")
+			Str("event", handler.Name).
+			Str("address", logEntry.Address).
+			Msg("Event from untracked address, skipping")
 		return
 	}
 
-	event, err := s.decoder.DecodeSyncEvent(logEntry)
+	event, err := handler.Decode(logEntry)
 	if err != nil {
-		log.Warn().Err(err).Str("pool", normalizedAddr).Msg("Failed to decode Sync event")
+		log.Warn().
+			Err(err).
+			Str("event", handler.Name).
+			Str("address", logEntry.Address).
+			Msg("Failed to decode event")
+		if s.metrics != nil {
+			s.metrics.RecordDecodeFailure(handler.Name)
+		}
+		return
+	}
+
+	if s.metrics != nil {
+		s.metrics.RecordEventReceived(handler.Name)
+	}
+	handler.Handle(event, receivedAt)
+}
+
+// observeProviderBlock tracks the provider's head and updates block lag for all providers.
+func (s *Service) observeProviderBlock(p *provider, block uint64) {
+	p.observeBlock(block)
+
+	if s.metrics == nil {
 		return
 	}
 
+	var best uint64
+	for _, other := range s.providers {
+		if b := other.lastBlock.Load(); b > best {
+			best = b
+		}
+	}
+	for _, other := range s.providers {
+		if b := other.lastBlock.Load(); b > 0 {
+			s.metrics.SetProviderBlockLag(other.name, best-b)
+		}
+	}
+}
+
+// processSyncEvent applies a Sync event from a tracked pool to the graph and
+// adds it to the pool's activity.
+func (s *Service) processSyncEvent(event *SyncEvent, receivedAt time.Time) {
 	log.Info().
 		Str("pool", event.PoolAddress).
 		Uint64("block", event.BlockNumber).
@@ -305,13 +1060,13 @@ func (s *Service) processSyncEvent(logEntry *LogEntry) {
 		Str("reserve1", event.Reserve1.String()).
 		Msg("Decoded Sync event, sending to graph manager")
 
-	// Update metrics
-	if s.metrics != nil {
-		s.metrics.RecordEventReceived("sync")
-		s.metrics.RecordEventLatency(event.Timestamp)
+	// Stamp with the block timestamp if the header already arrived.
+	// Otherwise the graph manager stamps it when the header closes the block.
+	if header, ok := s.headers[event.BlockNumber]; ok && (event.BlockHash == "" || event.BlockHash == header.Hash) {
+		event.BlockTime = header.Time
 	}
 
-	// Update graph
+	// Update graph (event latency is recorded by the graph manager once the block time is known)
 	update := graph.ReserveUpdate{
 		PoolAddress: event.PoolAddress,
 		Reserve0:    event.Reserve0,
@@ -319,13 +1074,19 @@ func (s *Service) processSyncEvent(logEntry *LogEntry) {
 		BlockNumber: event.BlockNumber,
 		LogIndex:    event.LogIndex,
 		Timestamp:   event.Timestamp,
+		BlockHash:   event.BlockHash,
+		BlockTime:   event.BlockTime,
 	}
 	s.graphManager.ProcessUpdate(update)
+	s.activity.RecordSync(event, s.eventTime(event.BlockNumber, receivedAt))
 
-	// Track block number
+	// Track block number. A log from block N means every earlier block has been delivered.
 	if event.BlockNumber > s.lastBlockNumber {
 		s.lastBlockNumber = event.BlockNumber
 	}
+	if event.BlockNumber > 0 {
+		s.markProcessed(event.BlockNumber - 1)
+	}
 
 	// Send to channel for additional processing if needed
 	select {
@@ -335,19 +1096,26 @@ func (s *Service) processSyncEvent(logEntry *LogEntry) {
 	}
 }
 
-// processPoolCreatedEvent decodes and processes a PoolCreated event.
-func (s *Service) processPoolCreatedEvent(logEntry *LogEntry) {
-	event, err := s.decoder.DecodePoolCreatedEvent(logEntry)
-	if err != nil {
-		log.Warn().Err(err).Msg("Failed to decode PoolCreated event")
-		return
-	}
+// processSwapEvent adds a Swap event from a tracked pool to the pool's activity.
+func (s *Service) processSwapEvent(event *SwapEvent, receivedAt time.Time) {
+	s.activity.RecordSwap(event, s.eventTime(event.BlockNumber, receivedAt))
+}
 
-	// Update metrics
-	if s.metrics != nil {
-		s.metrics.RecordEventReceived("pool_created")
+// processLiquidityEvent adds a Mint, Burn or Fees event from a tracked pool to the pool's activity.
+func (s *Service) processLiquidityEvent(event *LiquidityEvent, receivedAt time.Time) {
+	s.activity.RecordLiquidity(event, s.eventTime(event.BlockNumber, receivedAt))
+}
+
+// eventTime returns the block time of a block if its header was seen, otherwise receivedAt.
+func (s *Service) eventTime(block uint64, receivedAt time.Time) time.Time {
+	if header, ok := s.headers[block]; ok {
+		return header.Time
 	}
+	return receivedAt
+}
 
+// processPoolCreatedEvent hands a PoolCreated event from the factory to the curator.
+func (s *Service) processPoolCreatedEvent(event *PoolCreatedEvent) {
 	// Send to channel for curator to handle
 	select {
 	case s.poolCreatedEvents <- event:
@@ -367,23 +1135,53 @@ func (s *Service) LastBlockNumber() uint64 {
 	return s.lastBlockNumber
 }
 
-// SetReconciler configures the reconciler for filling the bootstrap-to-streaming gap.
-// bootstrapStartBlock is the block number recorded when bootstrap began.
-func (s *Service) SetReconciler(reconciler *Reconciler, bootstrapStartBlock uint64) {
+// SetReconciler configures the reconciler for filling gaps in the event stream.
+// bootstrapBlock is the block the bootstrap state was read at; the first
+// backfill starts after it.
+func (s *Service) SetReconciler(reconciler *Reconciler, bootstrapBlock uint64) {
 	s.reconciler = reconciler
-	s.bootstrapStartBlock = bootstrapStartBlock
-	s.reconciliationDone = false
+	s.bootstrapBlock = bootstrapBlock
+	s.lastProcessedBlock.Store(bootstrapBlock)
+
+	// Backfilled logs that were also streamed are applied only once
+	reconciler.SetFilter(s.firstDelivery)
+	reconciler.SetAppliedHook(s.recordUpdate)
 }
 
-// runReconciliation fetches and applies historical events from bootstrapStartBlock to current.
-func (s *Service) runReconciliation(ctx context.Context) error {
-	if s.reconciler == nil || s.bootstrapStartBlock == 0 {
-		log.Debug().Msg("Skipping reconciliation - not configured")
-		return nil
+// LastProcessedBlock returns the last block whose events have all been applied.
+func (s *Service) LastProcessedBlock() uint64 {
+	return s.lastProcessedBlock.Load()
+}
+
+// markProcessed records that all events up to and including block have been applied.
+func (s *Service) markProcessed(block uint64) {
+	if block > s.lastProcessedBlock.Load() {
+		s.lastProcessedBlock.Store(block)
 	}
+}
+
+// firstDelivery reports whether a backfilled Sync event has not been seen before.
+func (s *Service) firstDelivery(event *SyncEvent) bool {
+	if event.BlockHash == "" {
+		return true
+	}
+	_, duplicate := s.dedup.Check(logKey{blockHash: event.BlockHash, logIndex: event.LogIndex}, time.Now())
+	return !duplicate
+}
 
-	if s.reconciliationDone {
-		log.Debug().Msg("Skipping reconciliation - already done")
+// backfillRange returns the block range to backfill after the last processed block.
+// Returns false if there is nothing to backfill.
+func backfillRange(lastProcessed, head uint64) (from, to uint64, ok bool) {
+	if lastProcessed == 0 || lastProcessed >= head {
+		return 0, 0, false
+	}
+	return lastProcessed + 1, head, true
+}
+
+// runReconciliation fetches and applies events from the last processed block to head.
+func (s *Service) runReconciliation(ctx context.Context) error {
+	if s.reconciler == nil || s.lastProcessedBlock.Load() == 0 {
+		log.Debug().Msg("Skipping reconciliation - not configured")
 		return nil
 	}
 
@@ -393,6 +1191,21 @@ func (s *Service) runReconciliation(ctx context.Context) error {
 		return fmt.Errorf("getting current block for reconciliation: %w", err)
 	}
 
+	// Ranges a previous backfill could not fetch are retried first
+	lastProcessed := s.lastProcessedBlock.Load()
+	if s.unfetchedFrom > 0 && s.unfetchedFrom-1 < lastProcessed {
+		lastProcessed = s.unfetchedFrom - 1
+	}
+
+	fromBlock, toBlock, ok := backfillRange(lastProcessed, currentBlock)
+	if !ok {
+		log.Debug().
+			Uint64("last_processed_block", s.lastProcessedBlock.Load()).
+			Uint64("current_block", currentBlock).
+			Msg("Skipping reconciliation - already up to date")
+		return nil
+	}
+
 	// Set tracked pools on reconciler
 	s.mu.RLock()
 	addresses := make([]string, 0, len(s.trackedPools))
@@ -403,12 +1216,23 @@ func (s *Service) runReconciliation(ctx context.Context) error {
 	s.reconciler.SetTrackedPools(addresses)
 
 	// Run reconciliation
-	result, err := s.reconciler.Reconcile(ctx, s.bootstrapStartBlock, currentBlock)
+	result, err := s.reconciler.Reconcile(ctx, fromBlock, toBlock)
 	if err != nil {
+		// Fetched events were applied; remember where the gap starts for the retry
+		var rangeErr *UnfetchedRangesError
+		if errors.As(err, &rangeErr) && len(rangeErr.Ranges) > 0 {
+			s.unfetchedFrom = rangeErr.Ranges[0].From
+			s.graphManager.Flush()
+			s.markProcessed(toBlock)
+		}
 		return fmt.Errorf("reconciliation failed: %w", err)
 	}
 
-	s.reconciliationDone = true
+	// Backfilled updates may still be pending; apply them before the blocks
+	// count as processed, so a saved state at toBlock includes them
+	s.unfetchedFrom = 0
+	s.graphManager.Flush()
+	s.markProcessed(toBlock)
 
 	log.Info().
 		Uint64("from_block", result.FromBlock).
@@ -421,6 +1245,19 @@ func (s *Service) runReconciliation(ctx context.Context) error {
 	return nil
 }
 
+// scheduleBackfillRetry triggers another backfill after backfillRetryDelay.
+func (s *Service) scheduleBackfillRetry(ctx context.Context) {
+	time.AfterFunc(backfillRetryDelay, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case s.backfillCh <- struct{}{}:
+		default:
+		}
+	})
+}
+
 func calculateBackoff(attempt int) time.Duration {
 	backoff := initialBackoff * (1 << uint(attempt))
 	if backoff > maxBackoff {
@@ -17,22 +17,71 @@ const (
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
 	maxMessageSize = 1024 * 1024 // 1MB
+	requestTimeout = 30 * time.Second
 )
 
+// SubscriptionKind identifies what an eth_subscribe subscription delivers.
+type SubscriptionKind string
+
+const (
+	SubscriptionLogs     SubscriptionKind = "logs"
+	SubscriptionNewHeads SubscriptionKind = "newHeads"
+)
+
+// Notification is an eth_subscription message tagged with the subscription it belongs to.
+type Notification struct {
+	Subscription string
+	Kind         SubscriptionKind
+
+	// Params is the raw eth_subscription params object.
+	Params json.RawMessage
+}
+
+// RPCError is an error returned by the node in a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// rpcResponse is a JSON-RPC response delivered to a waiting caller.
+type rpcResponse struct {
+	result json.RawMessage
+	err    error
+}
+
+// pendingRequest is an in-flight JSON-RPC request awaiting its response.
+type pendingRequest struct {
+	respCh chan rpcResponse
+
+	// subscribe is set for eth_subscribe requests so the subscription is
+	// registered before any of its notifications are dispatched.
+	subscribe SubscriptionKind
+}
+
 // WSClient manages a WebSocket connection to an Ethereum node.
+// Requests are correlated with responses by ID, and notifications are
+// dispatched by subscription ID, so several subscriptions can share a connection.
 type WSClient struct {
 	url  string
 	conn *websocket.Conn
 	mu   sync.Mutex
 
-	// Subscription tracking
-	subscriptionID string
-	requestID      atomic.Int64
+	// Request/response correlation
+	requestID atomic.Int64
+	pendingMu sync.Mutex
+	pending   map[int64]*pendingRequest
+
+	// Active subscriptions by ID
+	subsMu        sync.RWMutex
+	subscriptions map[string]SubscriptionKind
 
 	// Message handling
-	msgCh     chan json.RawMessage
-	reconnect chan struct{}
-	done      chan struct{}
+	msgCh chan Notification
+	done  chan struct{}
 
 	// State
 	connected atomic.Bool
@@ -41,10 +90,11 @@ type WSClient struct {
 // NewWSClient creates a new WebSocket client.
 func NewWSClient(url string) *WSClient {
 	return &WSClient{
-		url:       url,
-		msgCh:     make(chan json.RawMessage, 1000),
-		reconnect: make(chan struct{}, 1),
-		done:      make(chan struct{}),
+		url:           url,
+		pending:       make(map[int64]*pendingRequest),
+		subscriptions: make(map[string]SubscriptionKind),
+		msgCh:         make(chan Notification, 1000),
+		done:          make(chan struct{}),
 	}
 }
 
@@ -92,17 +142,64 @@ func (c *WSClient) IsConnected() bool {
 	return c.connected.Load()
 }
 
-// Subscribe subscribes to log events for the given addresses and topics.
-func (c *WSClient) Subscribe(ctx context.Context, addresses []string, topics []string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// call sends a JSON-RPC request and waits for its response.
+// ReadMessages must be running for the response to be delivered.
+func (c *WSClient) call(ctx context.Context, method string, params []interface{}, subscribe SubscriptionKind) (json.RawMessage, error) {
+	id := c.requestID.Add(1)
+	pending := &pendingRequest{
+		respCh:    make(chan rpcResponse, 1),
+		subscribe: subscribe,
+	}
+
+	c.pendingMu.Lock()
+	c.pending[id] = pending
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
 
+	req := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}
+
+	c.mu.Lock()
 	if c.conn == nil {
-		return fmt.Errorf("not connected")
+		c.mu.Unlock()
+		return nil, fmt.Errorf("not connected")
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err := c.conn.WriteJSON(req)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("writing %s request: %w", method, err)
 	}
 
-	id := c.requestID.Add(1)
+	timer := time.NewTimer(requestTimeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-pending.respCh:
+		if resp.err != nil {
+			return nil, fmt.Errorf("%s: %w", method, resp.err)
+		}
+		return resp.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, fmt.Errorf("%s: connection closed", method)
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: timed out after %s", method, requestTimeout)
+	}
+}
 
+// Subscribe subscribes to log events for the given addresses and topics.
+// Returns the subscription ID once the node has confirmed it.
+func (c *WSClient) Subscribe(ctx context.Context, addresses []string, topics []string) (string, error) {
 	// Build filter params
 	filter := map[string]interface{}{
 		"topics": []interface{}{topics},
@@ -111,54 +208,74 @@ func (c *WSClient) Subscribe(ctx context.Context, addresses []string, topics []s
 		filter["address"] = addresses
 	}
 
-	req := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      id,
-		"method":  "eth_subscribe",
-		"params":  []interface{}{"logs", filter},
-	}
-
-	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := c.conn.WriteJSON(req); err != nil {
-		return fmt.Errorf("writing subscribe request: %w", err)
+	subID, err := c.subscribe(ctx, SubscriptionLogs, []interface{}{"logs", filter})
+	if err != nil {
+		return "", err
 	}
 
 	log.Info().
-		Int64("id", id).
+		Str("subscription_id", subID).
 		Int("addresses", len(addresses)).
 		Strs("topics", topics).
-		Msg("Sent subscription request")
+		Msg("Log subscription confirmed")
 
-	return nil
+	return subID, nil
 }
 
-// Unsubscribe removes a subscription.
-func (c *WSClient) Unsubscribe(ctx context.Context) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// SubscribeNewHeads subscribes to new block headers.
+// Returns the subscription ID once the node has confirmed it.
+func (c *WSClient) SubscribeNewHeads(ctx context.Context) (string, error) {
+	subID, err := c.subscribe(ctx, SubscriptionNewHeads, []interface{}{"newHeads"})
+	if err != nil {
+		return "", err
+	}
 
-	if c.conn == nil || c.subscriptionID == "" {
-		return nil
+	log.Info().Str("subscription_id", subID).Msg("newHeads subscription confirmed")
+	return subID, nil
+}
+
+// subscribe sends an eth_subscribe request and returns the confirmed subscription ID.
+func (c *WSClient) subscribe(ctx context.Context, kind SubscriptionKind, params []interface{}) (string, error) {
+	result, err := c.call(ctx, "eth_subscribe", params, kind)
+	if err != nil {
+		return "", err
 	}
 
-	id := c.requestID.Add(1)
-	req := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      id,
-		"method":  "eth_unsubscribe",
-		"params":  []interface{}{c.subscriptionID},
+	var subID string
+	if err := json.Unmarshal(result, &subID); err != nil || subID == "" {
+		return "", fmt.Errorf("invalid subscription id: %s", string(result))
 	}
+	return subID, nil
+}
 
-	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := c.conn.WriteJSON(req); err != nil {
-		return fmt.Errorf("writing unsubscribe request: %w", err)
+// Unsubscribe removes a subscription.
+// Notifications for it are dropped from this point on.
+func (c *WSClient) Unsubscribe(ctx context.Context, subID string) error {
+	if subID == "" {
+		return nil
+	}
+
+	c.subsMu.Lock()
+	delete(c.subscriptions, subID)
+	c.subsMu.Unlock()
+
+	if _, err := c.call(ctx, "eth_unsubscribe", []interface{}{subID}, ""); err != nil {
+		return err
 	}
 
-	c.subscriptionID = ""
+	log.Info().Str("subscription_id", subID).Msg("Unsubscribed")
 	return nil
 }
 
-// ReadMessages reads messages from the WebSocket and sends them to the channel.
+// Subscriptions returns the number of active subscriptions.
+func (c *WSClient) Subscriptions() int {
+	c.subsMu.RLock()
+	defer c.subsMu.RUnlock()
+	return len(c.subscriptions)
+}
+
+// ReadMessages reads messages from the WebSocket, delivers responses to waiting
+// callers and sends subscription notifications to the message channel.
 // Returns when the connection is closed or an error occurs.
 func (c *WSClient) ReadMessages(ctx context.Context) error {
 	for {
@@ -181,6 +298,7 @@ func (c *WSClient) ReadMessages(ctx context.Context) error {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			c.connected.Store(false)
+			c.failPending(fmt.Errorf("connection lost: %w", err))
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				return nil
 			}
@@ -194,10 +312,7 @@ func (c *WSClient) ReadMessages(ctx context.Context) error {
 			Result  json.RawMessage `json:"result"`
 			Method  string          `json:"method"`
 			Params  json.RawMessage `json:"params"`
-			Error   *struct {
-				Code    int    `json:"code"`
-				Message string `json:"message"`
-			} `json:"error"`
+			Error   *RPCError       `json:"error"`
 		}
 
 		if err := json.Unmarshal(message, &msg); err != nil {
@@ -205,40 +320,89 @@ func (c *WSClient) ReadMessages(ctx context.Context) error {
 			continue
 		}
 
-		// Handle subscription response
-		if msg.ID != nil && msg.Result != nil {
-			var subID string
-			if err := json.Unmarshal(msg.Result, &subID); err == nil && subID != "" {
-				c.mu.Lock()
-				c.subscriptionID = subID
-				c.mu.Unlock()
-				log.Info().Str("subscription_id", subID).Msg("Subscription confirmed")
-			}
-			continue
-		}
-
-		// Handle errors
-		if msg.Error != nil {
-			log.Error().
-				Int("code", msg.Error.Code).
-				Str("message", msg.Error.Message).
-				Msg("WebSocket error")
+		// Handle responses to our requests
+		if msg.ID != nil {
+			c.handleResponse(*msg.ID, msg.Result, msg.Error)
 			continue
 		}
 
 		// Handle subscription notifications
 		if msg.Method == "eth_subscription" && msg.Params != nil {
-			select {
-			case c.msgCh <- msg.Params:
-			default:
-				log.Warn().Msg("Message channel full, discarding message")
-			}
+			c.dispatchNotification(msg.Params)
+		}
+	}
+}
+
+// handleResponse delivers a response to the caller waiting on its request ID.
+func (c *WSClient) handleResponse(id int64, result json.RawMessage, rpcErr *RPCError) {
+	c.pendingMu.Lock()
+	pending, ok := c.pending[id]
+	c.pendingMu.Unlock()
+
+	if !ok {
+		log.Debug().Int64("id", id).Msg("Response for unknown request")
+		return
+	}
+
+	if rpcErr != nil {
+		pending.respCh <- rpcResponse{err: rpcErr}
+		return
+	}
+
+	// Register subscriptions before returning, so no notification for it is dropped
+	if pending.subscribe != "" {
+		var subID string
+		if err := json.Unmarshal(result, &subID); err == nil && subID != "" {
+			c.subsMu.Lock()
+			c.subscriptions[subID] = pending.subscribe
+			c.subsMu.Unlock()
+		}
+	}
+
+	pending.respCh <- rpcResponse{result: result}
+}
+
+// dispatchNotification routes a notification by its subscription ID.
+func (c *WSClient) dispatchNotification(params json.RawMessage) {
+	var header struct {
+		Subscription string `json:"subscription"`
+	}
+	if err := json.Unmarshal(params, &header); err != nil {
+		log.Warn().Err(err).Msg("Failed to parse subscription notification")
+		return
+	}
+
+	c.subsMu.RLock()
+	kind, ok := c.subscriptions[header.Subscription]
+	c.subsMu.RUnlock()
+
+	if !ok {
+		log.Debug().Str("subscription_id", header.Subscription).Msg("Notification for unknown subscription, discarding")
+		return
+	}
+
+	select {
+	case c.msgCh <- Notification{Subscription: header.Subscription, Kind: kind, Params: params}:
+	default:
+		log.Warn().Msg("Message channel full, discarding message")
+	}
+}
+
+// failPending fails every in-flight request with err.
+func (c *WSClient) failPending(err error) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	for _, pending := range c.pending {
+		select {
+		case pending.respCh <- rpcResponse{err: err}:
+		default:
 		}
 	}
 }
 
-// Messages returns the channel for received messages.
-func (c *WSClient) Messages() <-chan json.RawMessage {
+// Messages returns the channel for received subscription notifications.
+func (c *WSClient) Messages() <-chan Notification {
 	return c.msgCh
 }
 
@@ -0,0 +1,223 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"watcher/internal/graph"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is a minimal eth_subscribe endpoint for WSClient tests.
+type fakeNode struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	requests []fakeRequest
+	nextSub  int
+	failNext bool
+}
+
+type fakeRequest struct {
+	ID     int64             `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	node := &fakeNode{}
+	upgrader := websocket.Upgrader{}
+
+	node.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		node.mu.Lock()
+		node.conn = conn
+		node.mu.Unlock()
+
+		for {
+			var req fakeRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			node.handle(req)
+		}
+	}))
+	t.Cleanup(node.server.Close)
+
+	return node
+}
+
+func (n *fakeNode) url() string {
+	return "ws" + strings.TrimPrefix(n.server.URL, "http")
+}
+
+func (n *fakeNode) handle(req fakeRequest) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.requests = append(n.requests, req)
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	switch {
+	case n.failNext:
+		n.failNext = false
+		resp["error"] = map[string]interface{}{"code": -32005, "message": "limit exceeded"}
+	case req.Method == "eth_subscribe":
+		n.nextSub++
+		resp["result"] = fmt.Sprintf("0xsub%d", n.nextSub)
+	default:
+		resp["result"] = true
+	}
+	n.conn.WriteJSON(resp)
+}
+
+func (n *fakeNode) notify(subID string, result interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_subscription",
+		"params":  map[string]interface{}{"subscription": subID, "result": result},
+	})
+}
+
+func (n *fakeNode) methods() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	methods := make([]string, len(n.requests))
+	for i, req := range n.requests {
+		methods[i] = req.Method
+	}
+	return methods
+}
+
+func connectFakeNode(t *testing.T, node *fakeNode) (*WSClient, context.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	client := NewWSClient(node.url())
+	require.NoError(t, client.Connect(ctx))
+	t.Cleanup(func() { client.Close() })
+
+	go client.ReadMessages(ctx)
+	return client, ctx
+}
+
+func nextNotification(t *testing.T, client *WSClient) Notification {
+	select {
+	case n := <-client.Messages():
+		return n
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return Notification{}
+	}
+}
+
+func TestWSClientRoutesNotificationsBySubscription(t *testing.T) {
+	node := newFakeNode(t)
+	client, ctx := connectFakeNode(t, node)
+
+	logsID, err := client.Subscribe(ctx, []string{"0xpool"}, []string{SyncEventTopic.Hex()})
+	require.NoError(t, err)
+	headsID, err := client.SubscribeNewHeads(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, logsID, headsID)
+	require.Equal(t, 2, client.Subscriptions())
+
+	node.notify("0xunknown", map[string]string{"address": "0xpool"})
+	node.notify(headsID, map[string]string{"number": "0x1"})
+	node.notify(logsID, map[string]string{"address": "0xpool"})
+
+	// The unknown subscription is discarded; the rest arrive in order with their kind
+	n := nextNotification(t, client)
+	require.Equal(t, SubscriptionNewHeads, n.Kind)
+	require.Equal(t, headsID, n.Subscription)
+
+	n = nextNotification(t, client)
+	require.Equal(t, SubscriptionLogs, n.Kind)
+	require.Equal(t, logsID, n.Subscription)
+}
+
+func TestWSClientUnsubscribeDropsLaterNotifications(t *testing.T) {
+	node := newFakeNode(t)
+	client, ctx := connectFakeNode(t, node)
+
+	oldID, err := client.Subscribe(ctx, nil, []string{SyncEventTopic.Hex()})
+	require.NoError(t, err)
+	newID, err := client.Subscribe(ctx, nil, []string{SyncEventTopic.Hex()})
+	require.NoError(t, err)
+	require.NoError(t, client.Unsubscribe(ctx, oldID))
+	require.Equal(t, 1, client.Subscriptions())
+
+	node.notify(oldID, map[string]string{"address": "0xold"})
+	node.notify(newID, map[string]string{"address": "0xnew"})
+
+	n := nextNotification(t, client)
+	require.Equal(t, newID, n.Subscription)
+	require.Equal(t, []string{"eth_subscribe", "eth_subscribe", "eth_unsubscribe"}, node.methods())
+}
+
+func TestWSClientReturnsRPCErrors(t *testing.T) {
+	node := newFakeNode(t)
+	client, ctx := connectFakeNode(t, node)
+
+	node.mu.Lock()
+	node.failNext = true
+	node.mu.Unlock()
+
+	_, err := client.Subscribe(ctx, nil, []string{SyncEventTopic.Hex()})
+	require.Error(t, err)
+
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32005, rpcErr.Code)
+	require.Equal(t, 0, client.Subscriptions())
+}
+
+func TestResubscribeSubscribesBeforeUnsubscribing(t *testing.T) {
+	node := newFakeNode(t)
+	client, ctx := connectFakeNode(t, node)
+
+	graphManager := graph.NewManager(nil)
+	defer graphManager.Close()
+
+	service := NewService([]string{node.url()}, "", graphManager, nil)
+	service.SetTrackedPools([]string{"0xpool1"})
+
+	p := service.providers[0]
+	p.setClient(client)
+	require.NoError(t, service.subscribeProvider(ctx, p))
+	oldID := p.logsSubID
+
+	// Unchanged tracked set: nothing to do
+	require.NoError(t, service.Resubscribe(ctx))
+	require.Equal(t, []string{"eth_subscribe"}, node.methods())
+
+	service.AddTrackedPool("0xpool2")
+	require.NoError(t, service.Resubscribe(ctx))
+
+	require.Equal(t, []string{"eth_subscribe", "eth_subscribe", "eth_unsubscribe"}, node.methods())
+	require.NotEqual(t, oldID, p.logsSubID)
+	require.False(t, p.needsResubscribe(service.trackedVersion.Load()))
+
+	// The new filter covers the added pool
+	node.mu.Lock()
+	filter := string(node.requests[1].Params[1])
+	node.mu.Unlock()
+	require.Contains(t, filter, "0xpool2")
+}
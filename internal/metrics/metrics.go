@@ -15,6 +15,7 @@ import (
 type Metrics struct {
 	// Event metrics
 	EventsReceived *prometheus.CounterVec
+	DecodeFailures *prometheus.CounterVec
 	EventLatency   prometheus.Histogram
 
 	// Graph metrics
@@ -38,7 +39,28 @@ type Metrics struct {
 	LastBlockSeen    prometheus.Gauge
 	BootstrapLatency prometheus.Histogram
 
-	server *http.Server
+	// Bootstrap progress metrics
+	BootstrapItems     *prometheus.GaugeVec
+	BootstrapItemsDone *prometheus.GaugeVec
+	MulticallLimit     prometheus.Gauge
+
+	// Provider metrics
+	ProviderConnected       *prometheus.GaugeVec
+	ProviderDisconnects     *prometheus.CounterVec
+	ProviderLag             *prometheus.HistogramVec
+	ProviderFirstDeliveries *prometheus.CounterVec
+	ProviderBlockLag        *prometheus.GaugeVec
+
+	// Pool activity metrics
+	ActivePools prometheus.Gauge
+
+	// Component metrics
+	ComponentState    *prometheus.GaugeVec
+	ComponentUp       *prometheus.GaugeVec
+	ComponentRestarts *prometheus.CounterVec
+
+	server      *http.Server
+	healthCheck func() (bool, []byte)
 }
 
 // New creates and registers all Prometheus metrics.
@@ -51,6 +73,13 @@ func New() *Metrics {
 			},
 			[]string{"type"},
 		),
+		DecodeFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "arb_decode_failures_total",
+				Help: "Total number of logs that failed to decode by event topic",
+			},
+			[]string{"topic"},
+		),
 		EventLatency: prometheus.NewHistogram(
 			prometheus.HistogramOpts{
 				Name:    "arb_event_latency_seconds",
@@ -128,11 +157,95 @@ func New() *Metrics {
 				Buckets: prometheus.ExponentialBuckets(1, 2, 10), // 1s to ~17 minutes
 			},
 		),
+		ProviderConnected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "arb_provider_connected",
+				Help: "WebSocket provider connection status (1=connected, 0=disconnected)",
+			},
+			[]string{"provider"},
+		),
+		ProviderDisconnects: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "arb_provider_disconnects_total",
+				Help: "Total number of WebSocket disconnects per provider",
+			},
+			[]string{"provider"},
+		),
+		ProviderLag: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "arb_provider_lag_seconds",
+				Help:    "Delay of each provider's delivery behind the first delivery of the same log",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms to ~16s
+			},
+			[]string{"provider"},
+		),
+		ProviderFirstDeliveries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "arb_provider_first_deliveries_total",
+				Help: "Total number of logs a provider delivered before any other provider",
+			},
+			[]string{"provider"},
+		),
+		ProviderBlockLag: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "arb_provider_block_lag",
+				Help: "Blocks a provider is behind the most advanced provider",
+			},
+			[]string{"provider"},
+		),
+		BootstrapItems: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "arb_bootstrap_items",
+				Help: "Items to fetch in the current bootstrap stage (scan, reserves, tokens)",
+			},
+			[]string{"stage"},
+		),
+		BootstrapItemsDone: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "arb_bootstrap_items_done",
+				Help: "Items fetched so far in the current bootstrap stage (scan, reserves, tokens)",
+			},
+			[]string{"stage"},
+		),
+		MulticallLimit: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "arb_rpc_multicall_limit",
+				Help: "Largest multicall sent to the RPC provider after it rejected one as too large (0=unlimited)",
+			},
+		),
+		ActivePools: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "arb_active_pools",
+				Help: "Tracked pools with at least one swap in the activity window",
+			},
+		),
+		ComponentState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "arb_component_state",
+				Help: "Circuit-breaker state per component (0=closed, 1=half-open, 2=open, 3=stopped)",
+			},
+			[]string{"component"},
+		),
+		ComponentUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "arb_component_up",
+				Help: "Component running status (1=running, 0=down)",
+			},
+			[]string{"component"},
+		),
+		ComponentRestarts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "arb_component_restarts_total",
+				Help: "Total number of restarts per component",
+			},
+			[]string{"component"},
+		),
 	}
 
 	// Register all metrics
 	prometheus.MustRegister(
 		m.EventsReceived,
+		m.DecodeFailures,
 		m.EventLatency,
 		m.GraphNodes,
 		m.GraphEdges,
@@ -145,6 +258,18 @@ func New() *Metrics {
 		m.WebSocketStatus,
 		m.LastBlockSeen,
 		m.BootstrapLatency,
+		m.BootstrapItems,
+		m.BootstrapItemsDone,
+		m.MulticallLimit,
+		m.ProviderConnected,
+		m.ProviderDisconnects,
+		m.ProviderLag,
+		m.ProviderFirstDeliveries,
+		m.ProviderBlockLag,
+		m.ActivePools,
+		m.ComponentState,
+		m.ComponentUp,
+		m.ComponentRestarts,
 	)
 
 	return m
@@ -155,8 +280,18 @@ func (m *Metrics) StartServer(port int, path string) error {
 	mux := http.NewServeMux()
 	mux.Handle(path, promhttp.Handler())
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+		if m.healthCheck == nil {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+			return
+		}
+
+		healthy, body := m.healthCheck()
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(body)
 	})
 
 	m.server = &http.Server{
@@ -174,6 +309,13 @@ func (m *Metrics) StartServer(port int, path string) error {
 	return nil
 }
 
+// SetHealthCheck sets the function behind /health.
+// It returns overall health and the response body; unhealthy responds with 503.
+// Must be called before StartServer.
+func (m *Metrics) SetHealthCheck(fn func() (bool, []byte)) {
+	m.healthCheck = fn
+}
+
 // Shutdown gracefully stops the metrics server.
 func (m *Metrics) Shutdown(ctx context.Context) error {
 	if m.server != nil {
@@ -187,6 +329,11 @@ func (m *Metrics) RecordEventReceived(eventType string) {
 	m.EventsReceived.WithLabelValues(eventType).Inc()
 }
 
+// RecordDecodeFailure increments the decode failure counter for the given event topic.
+func (m *Metrics) RecordDecodeFailure(topic string) {
+	m.DecodeFailures.WithLabelValues(topic).Inc()
+}
+
 // RecordEventLatency records the latency from block timestamp to processing.
 func (m *Metrics) RecordEventLatency(blockTime time.Time) {
 	latency := time.Since(blockTime).Seconds()
@@ -247,3 +394,62 @@ func (m *Metrics) SetLastBlockSeen(block uint64) {
 func (m *Metrics) RecordBootstrapLatency(d time.Duration) {
 	m.BootstrapLatency.Observe(d.Seconds())
 }
+
+// SetBootstrapProgress sets how many of a bootstrap stage's items are done.
+func (m *Metrics) SetBootstrapProgress(stage string, done, total int) {
+	m.BootstrapItems.WithLabelValues(stage).Set(float64(total))
+	m.BootstrapItemsDone.WithLabelValues(stage).Set(float64(done))
+}
+
+// SetMulticallLimit sets the largest multicall the RPC provider accepts.
+func (m *Metrics) SetMulticallLimit(limit int) {
+	m.MulticallLimit.Set(float64(limit))
+}
+
+// SetProviderConnected sets the connection status of a WebSocket provider.
+func (m *Metrics) SetProviderConnected(provider string, connected bool) {
+	if connected {
+		m.ProviderConnected.WithLabelValues(provider).Set(1)
+	} else {
+		m.ProviderConnected.WithLabelValues(provider).Set(0)
+	}
+}
+
+// RecordProviderDisconnect increments the disconnect counter for a provider.
+func (m *Metrics) RecordProviderDisconnect(provider string) {
+	m.ProviderDisconnects.WithLabelValues(provider).Inc()
+}
+
+// RecordProviderDelivery records a log delivery from a provider.
+// first is true if the provider won the race; lag is how far behind the winner it was.
+func (m *Metrics) RecordProviderDelivery(provider string, first bool, lag time.Duration) {
+	if first {
+		m.ProviderFirstDeliveries.WithLabelValues(provider).Inc()
+	}
+	m.ProviderLag.WithLabelValues(provider).Observe(lag.Seconds())
+}
+
+// SetProviderBlockLag sets how many blocks a provider is behind the best provider.
+func (m *Metrics) SetProviderBlockLag(provider string, blocks uint64) {
+	m.ProviderBlockLag.WithLabelValues(provider).Set(float64(blocks))
+}
+
+// SetActivePools sets the number of pools with swaps in the activity window.
+func (m *Metrics) SetActivePools(count int) {
+	m.ActivePools.Set(float64(count))
+}
+
+// SetComponentHealth sets the circuit-breaker state and running status of a supervised component.
+func (m *Metrics) SetComponentHealth(component string, state int, running bool) {
+	m.ComponentState.WithLabelValues(component).Set(float64(state))
+	if running {
+		m.ComponentUp.WithLabelValues(component).Set(1)
+	} else {
+		m.ComponentUp.WithLabelValues(component).Set(0)
+	}
+}
+
+// RecordComponentRestart increments the restart counter for a supervised component.
+func (m *Metrics) RecordComponentRestart(component string) {
+	m.ComponentRestarts.WithLabelValues(component).Inc()
+}
@@ -0,0 +1,1216 @@
+// Package mocknode is an in-process Ethereum JSON-RPC node for end-to-end tests.
+//
+// A Node serves HTTP and WebSocket JSON-RPC on one address. It answers
+// eth_call against scripted Aerodrome V2 factory, pool and ERC20 state, either
+// directly or batched through Multicall3 aggregate3, at the latest block or an
+// earlier one given by number or hash, runs base.ProbeCode
+// programs installed by state override against scripted token balances and
+// transfer behaviour, serves eth_getCode, eth_getLogs and
+// block headers from the blocks it has mined, and emits scripted logs and
+// headers to eth_subscribe subscribers as blocks are mined.
+package mocknode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultChainID is Base mainnet
+	DefaultChainID = 8453
+
+	// GenesisBlock is the number of the head block when the node starts
+	GenesisBlock = 1000
+)
+
+// JSON-RPC error codes returned by the node.
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeNotFound       = -32000
+	codeReverted       = 3
+	codeLimitExceeded  = -32005
+)
+
+// Aerodrome V2 event topics emitted by SetReserves and CreatePool.
+var (
+	syncTopic        = crypto.Keccak256Hash([]byte("Sync(uint256,uint256)"))
+	poolCreatedTopic = crypto.Keccak256Hash([]byte("PoolCreated(address,address,bool,address,uint256)"))
+)
+
+// Subscription kinds accepted by eth_subscribe.
+const (
+	SubscriptionLogs     = "logs"
+	SubscriptionNewHeads = "newHeads"
+)
+
+// Token is an ERC20 token served by the node. Pools hold their reserves as
+// token balances.
+type Token struct {
+	Address  common.Address
+	Symbol   string
+	Decimals uint8
+
+	// TransferFee is the fraction of every transfer taken as a fee
+	TransferFee float64
+	// Honeypot makes transfers into a pool revert, so the token can't be sold
+	Honeypot bool
+	// Code is served by eth_getCode. Defaults to a placeholder.
+	Code []byte
+}
+
+// placeholderCode is served by eth_getCode for contracts without scripted code.
+var placeholderCode = []byte{0x00}
+
+// Pool is an Aerodrome V2 pool served by the node and listed by its factory.
+type Pool struct {
+	Address  common.Address
+	Token0   common.Address
+	Token1   common.Address
+	Reserve0 *big.Int
+	Reserve1 *big.Int
+	Stable   bool
+}
+
+// Node is a scripted JSON-RPC node. Create it with New and stop it with Close.
+type Node struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	chainID  *big.Int
+	factory  common.Address
+
+	mu sync.Mutex
+
+	// Chain: mined headers by number and their logs in chain order
+	headers map[uint64]*types.Header
+	head    uint64
+	logs    []types.Log
+	pending []types.Log
+
+	// Contract state
+	pools     map[common.Address]*Pool
+	poolOrder []common.Address
+	tokens    map[common.Address]*Token
+
+	// Pool changes made by each mined block and queued for the next one,
+	// undone to serve calls at earlier blocks
+	changes        map[uint64][]stateChange
+	pendingChanges []stateChange
+
+	// Subscriptions by ID
+	subs    map[string]*subscription
+	nextSub int
+
+	// Number of requests per method
+	calls map[string]int
+
+	// Largest aggregate3 batch accepted, 0 for no limit
+	maxMulticall int
+
+	// Number of recent blocks whose state calls can read, 0 for every block
+	stateRetention uint64
+
+	// Most logs one eth_getLogs may return, 0 for no limit
+	maxLogResults int
+
+	// Contracts whose every call reverts
+	reverting map[common.Address]struct{}
+
+	// Called with the method of every request before it is answered
+	callHook func(method string)
+}
+
+// stateChange records a pool's state before a block changed it.
+type stateChange struct {
+	pool common.Address
+	prev *Pool // nil if the block created the pool
+}
+
+// subscription is an eth_subscribe subscription on one WebSocket connection.
+type subscription struct {
+	id        string
+	kind      string
+	conn      *wsConn
+	addresses map[common.Address]struct{}
+	topics    map[common.Hash]struct{}
+}
+
+// matches reports whether a log passes the subscription's filter.
+func (s *subscription) matches(l *types.Log) bool {
+	return matchesFilter(l, s.addresses, s.topics)
+}
+
+// wsConn serializes writes to a WebSocket connection.
+type wsConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *wsConn) writeJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// New starts a node whose factory is at factory. The head block is GenesisBlock.
+func New(factory common.Address) *Node {
+	n := &Node{
+		chainID: big.NewInt(DefaultChainID),
+		factory: factory,
+		headers: make(map[uint64]*types.Header),
+		pools:   make(map[common.Address]*Pool),
+		tokens:  make(map[common.Address]*Token),
+		changes: make(map[uint64][]stateChange),
+		subs:    make(map[string]*subscription),
+		calls:   make(map[string]int),
+
+		reverting: make(map[common.Address]struct{}),
+	}
+	n.head = GenesisBlock
+	n.headers[GenesisBlock] = n.newHeader(GenesisBlock, common.Hash{})
+
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	return n
+}
+
+// URL returns the HTTP JSON-RPC endpoint.
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// WSURL returns the WebSocket JSON-RPC endpoint.
+func (n *Node) WSURL() string {
+	return "ws" + strings.TrimPrefix(n.server.URL, "http")
+}
+
+// Close stops the server and closes every connection.
+func (n *Node) Close() {
+	n.server.CloseClientConnections()
+	n.server.Close()
+}
+
+// AddToken adds an ERC20 token.
+func (n *Node) AddToken(token Token) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	t := token
+	n.tokens[token.Address] = &t
+}
+
+// AddPool adds a pool to the factory without emitting an event. The pool is
+// part of the state at every block.
+func (n *Node) AddPool(pool Pool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.addPoolLocked(pool)
+}
+
+func (n *Node) addPoolLocked(pool Pool) {
+	p := pool
+	p.Reserve0 = new(big.Int).Set(pool.Reserve0)
+	p.Reserve1 = new(big.Int).Set(pool.Reserve1)
+	if _, exists := n.pools[p.Address]; !exists {
+		n.poolOrder = append(n.poolOrder, p.Address)
+	}
+	n.pools[p.Address] = &p
+}
+
+// CreatePool adds a pool to the factory and queues its PoolCreated event for the next block.
+func (n *Node) CreatePool(pool Pool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.recordChangeLocked(pool.Address)
+	n.addPoolLocked(pool)
+
+	stable := common.Hash{}
+	if pool.Stable {
+		stable[31] = 1
+	}
+	data := append(common.LeftPadBytes(pool.Address.Bytes(), 32),
+		common.LeftPadBytes(big.NewInt(int64(len(n.poolOrder))).Bytes(), 32)...)
+
+	n.pending = append(n.pending, types.Log{
+		Address: n.factory,
+		Topics: []common.Hash{
+			poolCreatedTopic,
+			common.BytesToHash(pool.Token0.Bytes()),
+			common.BytesToHash(pool.Token1.Bytes()),
+			stable,
+		},
+		Data: data,
+	})
+}
+
+// SetReserves updates a pool's reserves and queues its Sync event for the next block.
+func (n *Node) SetReserves(pool common.Address, reserve0, reserve1 *big.Int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	p, ok := n.pools[pool]
+	if !ok {
+		return fmt.Errorf("unknown pool %s", pool.Hex())
+	}
+	n.recordChangeLocked(pool)
+	p.Reserve0 = new(big.Int).Set(reserve0)
+	p.Reserve1 = new(big.Int).Set(reserve1)
+
+	n.pending = append(n.pending, types.Log{
+		Address: pool,
+		Topics:  []common.Hash{syncTopic},
+		Data:    append(common.LeftPadBytes(reserve0.Bytes(), 32), common.LeftPadBytes(reserve1.Bytes(), 32)...),
+	})
+	return nil
+}
+
+// recordChangeLocked records a pool's state before the next block changes it.
+func (n *Node) recordChangeLocked(pool common.Address) {
+	change := stateChange{pool: pool}
+	if p, ok := n.pools[pool]; ok {
+		prev := *p
+		change.prev = &prev
+	}
+	n.pendingChanges = append(n.pendingChanges, change)
+}
+
+// SetMaxMulticallSize makes aggregate3 batches of more than size calls fail
+// as out of gas, like a provider with a call gas cap. 0 removes the limit.
+func (n *Node) SetMaxMulticallSize(size int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.maxMulticall = size
+}
+
+// SetStateRetention keeps the state of only the last blocks blocks, like a
+// non-archive node. Calls at earlier blocks fail with "missing trie node".
+// 0 keeps every block.
+func (n *Node) SetStateRetention(blocks uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.stateRetention = blocks
+}
+
+// SetMaxLogResults makes eth_getLogs calls matching more than max logs fail
+// with "query returned more than max results", like a provider's result cap.
+// 0 removes the limit.
+func (n *Node) SetMaxLogResults(max int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.maxLogResults = max
+}
+
+// SetReverts makes every call to target revert, or stops it.
+func (n *Node) SetReverts(target common.Address, revert bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if revert {
+		n.reverting[target] = struct{}{}
+	} else {
+		delete(n.reverting, target)
+	}
+}
+
+// SetCallHook sets a function called with the method of every request before
+// it is answered. It's called without the node's lock, so it may mine blocks.
+func (n *Node) SetCallHook(fn func(method string)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.callHook = fn
+}
+
+// AddLog queues an arbitrary log for the next block.
+func (n *Node) AddLog(l types.Log) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.pending = append(n.pending, l)
+}
+
+// MineBlock mines a block holding every queued log. Matching logs are sent to
+// log subscribers, then the header to newHeads subscribers.
+func (n *Node) MineBlock() *types.Header {
+	n.mu.Lock()
+	number := n.head + 1
+	header := n.newHeader(number, n.headers[n.head].Hash())
+	hash := header.Hash()
+
+	logs := n.pending
+	n.pending = nil
+	for i := range logs {
+		logs[i].BlockNumber = number
+		logs[i].BlockHash = hash
+		logs[i].Index = uint(i)
+		logs[i].TxIndex = uint(i)
+		logs[i].TxHash = crypto.Keccak256Hash(hash.Bytes(), big.NewInt(int64(i)).Bytes())
+	}
+
+	n.headers[number] = header
+	n.head = number
+	n.logs = append(n.logs, logs...)
+	n.changes[number] = n.pendingChanges
+	n.pendingChanges = nil
+
+	subs := make([]*subscription, 0, len(n.subs))
+	for _, sub := range n.subs {
+		subs = append(subs, sub)
+	}
+	n.mu.Unlock()
+
+	for i := range logs {
+		for _, sub := range subs {
+			if sub.kind == SubscriptionLogs && sub.matches(&logs[i]) {
+				sub.notify(logs[i])
+			}
+		}
+	}
+	for _, sub := range subs {
+		if sub.kind == SubscriptionNewHeads {
+			sub.notify(header)
+		}
+	}
+
+	return header
+}
+
+// Head returns the number of the latest mined block.
+func (n *Node) Head() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.head
+}
+
+// Calls returns how many requests for method the node has served.
+func (n *Node) Calls(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+// WaitForSubscriptions blocks until at least count subscriptions of kind are active.
+func (n *Node) WaitForSubscriptions(ctx context.Context, kind string, count int) error {
+	for {
+		n.mu.Lock()
+		active := 0
+		for _, sub := range n.subs {
+			if sub.kind == kind {
+				active++
+			}
+		}
+		n.mu.Unlock()
+
+		if active >= count {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %d %s subscriptions, have %d: %w", count, kind, active, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// newHeader builds a header stamped with the current time.
+func (n *Node) newHeader(number uint64, parent common.Hash) *types.Header {
+	return &types.Header{
+		ParentHash:  parent,
+		UncleHash:   types.EmptyUncleHash,
+		Root:        types.EmptyRootHash,
+		TxHash:      types.EmptyTxsHash,
+		ReceiptHash: types.EmptyReceiptsHash,
+		Difficulty:  new(big.Int),
+		Number:      new(big.Int).SetUint64(number),
+		GasLimit:    30_000_000,
+		Time:        uint64(time.Now().Unix()),
+	}
+}
+
+// rpcRequest is a JSON-RPC request.
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcResponse is a JSON-RPC response.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// serveHTTP serves JSON-RPC over HTTP, including batches, and upgrades WebSocket requests.
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		n.serveWebSocket(w, r)
+		return
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		var reqs []rpcRequest
+		if err := json.Unmarshal(raw, &reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resps := make([]rpcResponse, len(reqs))
+		for i, req := range reqs {
+			resps[i] = n.handle(req, nil)
+		}
+		json.NewEncoder(w).Encode(resps)
+		return
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(n.handle(req, nil))
+}
+
+// serveWebSocket serves JSON-RPC on a WebSocket connection until it closes.
+// Subscriptions made on the connection end with it.
+func (n *Node) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	c, err := n.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn := &wsConn{conn: c}
+	defer func() {
+		c.Close()
+		n.mu.Lock()
+		for id, sub := range n.subs {
+			if sub.conn == conn {
+				delete(n.subs, id)
+			}
+		}
+		n.mu.Unlock()
+	}()
+
+	for {
+		var req rpcRequest
+		if err := c.ReadJSON(&req); err != nil {
+			return
+		}
+		if err := conn.writeJSON(n.handle(req, conn)); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers a single request. conn is nil for HTTP requests.
+func (n *Node) handle(req rpcRequest, conn *wsConn) rpcResponse {
+	n.mu.Lock()
+	hook := n.callHook
+	n.mu.Unlock()
+	if hook != nil {
+		hook(req.Method)
+	}
+
+	result, err := n.dispatch(req, conn)
+
+	// Counted once answered, so a counted request saw the state at that time
+	n.mu.Lock()
+	n.calls[req.Method]++
+	n.mu.Unlock()
+
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	resp.Result = result
+	return resp
+}
+
+func (n *Node) dispatch(req rpcRequest, conn *wsConn) (interface{}, error) {
+	var params []json.RawMessage
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+
+	switch req.Method {
+	case "eth_chainId":
+		return (*hexutil.Big)(n.chainID), nil
+	case "eth_blockNumber":
+		return hexutil.Uint64(n.Head()), nil
+	case "eth_call":
+		return n.ethCall(params)
+	case "eth_getCode":
+		return n.getCode(params)
+	case "eth_getLogs":
+		return n.getLogs(params)
+	case "eth_getBlockByNumber":
+		return n.getBlockByNumber(params)
+	case "eth_subscribe":
+		if conn == nil {
+			return nil, &rpcError{Code: codeMethodNotFound, Message: "subscriptions require a WebSocket connection"}
+		}
+		return n.subscribe(params, conn)
+	case "eth_unsubscribe":
+		return n.unsubscribe(params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist", req.Method)}
+	}
+}
+
+// callArgs are the eth_call arguments the node uses.
+type callArgs struct {
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+	Data  hexutil.Bytes   `json:"data"`
+}
+
+func (n *Node) ethCall(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing call arguments")
+	}
+	var args callArgs
+	if err := json.Unmarshal(params[0], &args); err != nil {
+		return nil, fmt.Errorf("invalid call arguments: %w", err)
+	}
+	if args.To == nil {
+		return nil, fmt.Errorf("missing call target")
+	}
+
+	input := args.Input
+	if len(input) == 0 {
+		input = args.Data
+	}
+
+	probes, err := probeOverrides(params)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	block, pinned, err := n.callBlockLocked(params)
+	if err != nil {
+		return nil, err
+	}
+	if pinned {
+		defer n.rewindLocked(block)()
+	}
+
+	var out []byte
+	if _, ok := probes[*args.To]; ok {
+		out = n.runProbeLocked(*args.To, input, probes, make(map[common.Address]map[common.Address]*big.Int))
+	} else if *args.To == base.Multicall3Address {
+		out, err = n.aggregate3Locked(input)
+	} else {
+		out, err = n.callLocked(*args.To, input)
+	}
+	if err != nil {
+		return nil, &rpcError{Code: codeReverted, Message: "execution reverted: " + err.Error()}
+	}
+	return hexutil.Bytes(out), nil
+}
+
+// callBlockLocked returns the block an eth_call reads, or false to read the
+// current state, including changes queued for the next block.
+func (n *Node) callBlockLocked(params []json.RawMessage) (uint64, bool, error) {
+	if len(params) < 2 || string(params[1]) == "null" {
+		return 0, false, nil
+	}
+	var block rpc.BlockNumberOrHash
+	if err := json.Unmarshal(params[1], &block); err != nil {
+		return 0, false, fmt.Errorf("invalid block: %w", err)
+	}
+
+	if hash, ok := block.Hash(); ok {
+		for number, header := range n.headers {
+			if header.Hash() == hash {
+				return number, true, n.stateAvailableLocked(number)
+			}
+		}
+		return 0, false, &rpcError{Code: codeNotFound, Message: "header not found"}
+	}
+
+	number, _ := block.Number()
+	switch {
+	case number == rpc.EarliestBlockNumber:
+		return GenesisBlock, true, n.stateAvailableLocked(GenesisBlock)
+	case number < 0:
+		return 0, false, nil // latest, pending, safe and finalized
+	}
+	if _, ok := n.headers[uint64(number)]; !ok {
+		return 0, false, &rpcError{Code: codeNotFound, Message: "header not found"}
+	}
+	return uint64(number), true, n.stateAvailableLocked(uint64(number))
+}
+
+// stateAvailableLocked fails if the state at block is older than the node keeps.
+func (n *Node) stateAvailableLocked(block uint64) error {
+	if n.stateRetention == 0 || block+n.stateRetention > n.head {
+		return nil
+	}
+	return &rpcError{Code: codeNotFound, Message: fmt.Sprintf("missing trie node %x (path )", n.headers[block].Root)}
+}
+
+// rewindLocked undoes the pool changes made after block, so calls see the
+// state at block. The returned function restores the current state.
+func (n *Node) rewindLocked(block uint64) func() {
+	pools, order := n.pools, n.poolOrder
+	n.pools = make(map[common.Address]*Pool, len(pools))
+	for addr, p := range pools {
+		n.pools[addr] = p
+	}
+	n.poolOrder = append([]common.Address(nil), order...)
+
+	undo := func(changes []stateChange) {
+		for i := len(changes) - 1; i >= 0; i-- {
+			change := changes[i]
+			if change.prev != nil {
+				n.pools[change.pool] = change.prev
+				continue
+			}
+			delete(n.pools, change.pool)
+			for j := len(n.poolOrder) - 1; j >= 0; j-- {
+				if n.poolOrder[j] == change.pool {
+					n.poolOrder = append(n.poolOrder[:j], n.poolOrder[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+	undo(n.pendingChanges)
+	for number := n.head; number > block; number-- {
+		undo(n.changes[number])
+	}
+
+	return func() {
+		n.pools, n.poolOrder = pools, order
+	}
+}
+
+// accountOverride is the part of an eth_call state override the node uses.
+type accountOverride struct {
+	Code *hexutil.Bytes `json:"code"`
+}
+
+// probeOverrides returns the addresses a call's state override installs the probe at.
+// Other overrides are ignored.
+func probeOverrides(params []json.RawMessage) (map[common.Address]struct{}, error) {
+	probes := make(map[common.Address]struct{})
+	if len(params) < 3 || string(params[2]) == "null" {
+		return probes, nil
+	}
+
+	var overrides map[common.Address]accountOverride
+	if err := json.Unmarshal(params[2], &overrides); err != nil {
+		return nil, fmt.Errorf("invalid state override: %w", err)
+	}
+	for addr, o := range overrides {
+		if o.Code != nil && string(*o.Code) == string(base.ProbeCode) {
+			probes[addr] = struct{}{}
+		}
+	}
+	return probes, nil
+}
+
+// runProbeLocked runs a probe program as self. Calls to other probes run their
+// nested programs. Token balances changed by the program live in balances.
+func (n *Node) runProbeLocked(self common.Address, input []byte, probes map[common.Address]struct{}, balances map[common.Address]map[common.Address]*big.Int) []byte {
+	calls, ok := base.DecodeProbeProgram(input)
+	if !ok {
+		return nil
+	}
+
+	results := make([]base.ProbeResult, len(calls))
+	for i, call := range calls {
+		var (
+			out []byte
+			err error
+		)
+		if _, isProbe := probes[call.Target]; isProbe {
+			out = n.runProbeLocked(call.Target, call.CallData, probes, balances)
+		} else if token, isToken := n.tokens[call.Target]; isToken {
+			out, err = n.tokenCallLocked(self, token, call.CallData, balances)
+		} else {
+			out, err = n.callLocked(call.Target, call.CallData)
+		}
+		if err != nil {
+			results[i] = base.ProbeResult{Word: new(big.Int)}
+			continue
+		}
+
+		word := make([]byte, 32)
+		copy(word, out)
+		results[i] = base.ProbeResult{Success: true, ReturnSize: uint64(len(out)), Word: new(big.Int).SetBytes(word)}
+	}
+	return base.EncodeProbeResults(results)
+}
+
+// tokenCallLocked executes an ERC20 call from sender, including balanceOf and transfer.
+func (n *Node) tokenCallLocked(sender common.Address, token *Token, input []byte, balances map[common.Address]map[common.Address]*big.Int) ([]byte, error) {
+	method, err := aerodrome.ERC20ABI.MethodById(input)
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	switch method.Name {
+	case "balanceOf":
+		return method.Outputs.Pack(n.balanceLocked(token, args[0].(common.Address), balances))
+	case "transfer":
+		to, amount := args[0].(common.Address), args[1].(*big.Int)
+		if _, isPool := n.pools[to]; isPool && token.Honeypot {
+			return nil, fmt.Errorf("trading disabled")
+		}
+
+		from := n.balanceLocked(token, sender, balances)
+		if from.Cmp(amount) < 0 {
+			return nil, fmt.Errorf("transfer amount exceeds balance")
+		}
+		fee, _ := new(big.Float).Mul(new(big.Float).SetInt(amount), big.NewFloat(token.TransferFee)).Int(nil)
+		received := new(big.Int).Sub(amount, fee)
+
+		balances[token.Address][sender] = new(big.Int).Sub(from, amount)
+		balances[token.Address][to] = new(big.Int).Add(n.balanceLocked(token, to, balances), received)
+		return method.Outputs.Pack(true)
+	}
+	return n.callLocked(token.Address, input)
+}
+
+// balanceLocked returns holder's balance of token. Pools start with their reserves.
+func (n *Node) balanceLocked(token *Token, holder common.Address, balances map[common.Address]map[common.Address]*big.Int) *big.Int {
+	if balances[token.Address] == nil {
+		balances[token.Address] = make(map[common.Address]*big.Int)
+	}
+	if balance, ok := balances[token.Address][holder]; ok {
+		return balance
+	}
+
+	balance := new(big.Int)
+	if pool, ok := n.pools[holder]; ok {
+		if pool.Token0 == token.Address {
+			balance.Add(balance, pool.Reserve0)
+		}
+		if pool.Token1 == token.Address {
+			balance.Add(balance, pool.Reserve1)
+		}
+	}
+	return balance
+}
+
+// getCode serves eth_getCode for scripted contracts.
+func (n *Node) getCode(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "missing address"}
+	}
+	var addr common.Address
+	if err := json.Unmarshal(params[0], &addr); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid address: %v", err)}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if token, ok := n.tokens[addr]; ok {
+		if len(token.Code) > 0 {
+			return hexutil.Bytes(token.Code), nil
+		}
+		return hexutil.Bytes(placeholderCode), nil
+	}
+	if _, ok := n.pools[addr]; ok || addr == n.factory || addr == base.Multicall3Address {
+		return hexutil.Bytes(placeholderCode), nil
+	}
+	return hexutil.Bytes{}, nil
+}
+
+// call3 is a Multicall3 aggregate3 call.
+type call3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// aggregate3Locked executes a Multicall3 aggregate3 batch. Failed calls are
+// reported per call when they allow failure and revert the batch otherwise.
+func (n *Node) aggregate3Locked(input []byte) ([]byte, error) {
+	method, err := base.Multicall3ABI.MethodById(input)
+	if err != nil || method.Name != "aggregate3" {
+		return nil, fmt.Errorf("unsupported Multicall3 method")
+	}
+
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, fmt.Errorf("unpacking aggregate3: %w", err)
+	}
+	calls := *abi.ConvertType(args[0], new([]call3)).(*[]call3)
+	if n.maxMulticall > 0 && len(calls) > n.maxMulticall {
+		return nil, fmt.Errorf("out of gas: %d calls exceed the limit of %d", len(calls), n.maxMulticall)
+	}
+
+	type result struct {
+		Success    bool
+		ReturnData []byte
+	}
+	results := make([]result, len(calls))
+	for i, call := range calls {
+		out, err := n.callLocked(call.Target, call.CallData)
+		if err != nil {
+			if !call.AllowFailure {
+				return nil, fmt.Errorf("call %d: %w", i, err)
+			}
+			continue
+		}
+		results[i] = result{Success: true, ReturnData: out}
+	}
+
+	return method.Outputs.Pack(results)
+}
+
+// callLocked executes a call against the scripted factory, pool and token state.
+func (n *Node) callLocked(to common.Address, input []byte) ([]byte, error) {
+	if len(input) < 4 {
+		return nil, fmt.Errorf("missing selector")
+	}
+	if _, ok := n.reverting[to]; ok {
+		return nil, fmt.Errorf("call to %s reverted", to.Hex())
+	}
+
+	if to == n.factory {
+		method, err := aerodrome.V2FactoryABI.MethodById(input)
+		if err != nil {
+			return nil, err
+		}
+		switch method.Name {
+		case "allPoolsLength":
+			return method.Outputs.Pack(big.NewInt(int64(len(n.poolOrder))))
+		case "allPools":
+			args, err := method.Inputs.Unpack(input[4:])
+			if err != nil {
+				return nil, err
+			}
+			index := args[0].(*big.Int)
+			if !index.IsInt64() || index.Int64() >= int64(len(n.poolOrder)) {
+				return nil, fmt.Errorf("pool index %s out of range", index)
+			}
+			return method.Outputs.Pack(n.poolOrder[index.Int64()])
+		}
+	}
+
+	if pool, ok := n.pools[to]; ok {
+		method, err := aerodrome.V2PoolABI.MethodById(input)
+		if err != nil {
+			return nil, err
+		}
+		switch method.Name {
+		case "getReserves":
+			return method.Outputs.Pack(pool.Reserve0, pool.Reserve1, new(big.Int).SetUint64(n.headers[n.head].Time))
+		case "token0":
+			return method.Outputs.Pack(pool.Token0)
+		case "token1":
+			return method.Outputs.Pack(pool.Token1)
+		case "stable":
+			return method.Outputs.Pack(pool.Stable)
+		}
+	}
+
+	if token, ok := n.tokens[to]; ok {
+		method, err := aerodrome.ERC20ABI.MethodById(input)
+		if err != nil {
+			return nil, err
+		}
+		switch method.Name {
+		case "decimals":
+			return method.Outputs.Pack(token.Decimals)
+		case "symbol", "name":
+			return method.Outputs.Pack(token.Symbol)
+		}
+	}
+
+	return nil, fmt.Errorf("no contract at %s", to.Hex())
+}
+
+// filterArgs are the eth_getLogs and eth_subscribe logs filter fields.
+type filterArgs struct {
+	FromBlock string            `json:"fromBlock"`
+	ToBlock   string            `json:"toBlock"`
+	BlockHash *common.Hash      `json:"blockHash"`
+	Address   json.RawMessage   `json:"address"`
+	Topics    []json.RawMessage `json:"topics"`
+}
+
+// addresses parses the address filter, a single address or a list.
+func (f *filterArgs) addresses() (map[common.Address]struct{}, error) {
+	if len(f.Address) == 0 || string(f.Address) == "null" {
+		return nil, nil
+	}
+
+	var list []common.Address
+	if err := json.Unmarshal(f.Address, &list); err != nil {
+		var single common.Address
+		if err := json.Unmarshal(f.Address, &single); err != nil {
+			return nil, fmt.Errorf("invalid address filter: %w", err)
+		}
+		list = []common.Address{single}
+	}
+	// Like a real node, an empty list matches every address
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	set := make(map[common.Address]struct{}, len(list))
+	for _, addr := range list {
+		set[addr] = struct{}{}
+	}
+	return set, nil
+}
+
+// eventTopics parses the first topic position, a single topic or a list.
+// Later positions are not used by the watcher and are ignored.
+func (f *filterArgs) eventTopics() (map[common.Hash]struct{}, error) {
+	if len(f.Topics) == 0 || string(f.Topics[0]) == "null" {
+		return nil, nil
+	}
+
+	var list []common.Hash
+	if err := json.Unmarshal(f.Topics[0], &list); err != nil {
+		var single common.Hash
+		if err := json.Unmarshal(f.Topics[0], &single); err != nil {
+			return nil, fmt.Errorf("invalid topic filter: %w", err)
+		}
+		list = []common.Hash{single}
+	}
+
+	set := make(map[common.Hash]struct{}, len(list))
+	for _, topic := range list {
+		set[topic] = struct{}{}
+	}
+	return set, nil
+}
+
+// matchesFilter reports whether a log matches address and topic sets. Nil sets match everything.
+func matchesFilter(l *types.Log, addresses map[common.Address]struct{}, topics map[common.Hash]struct{}) bool {
+	if addresses != nil {
+		if _, ok := addresses[l.Address]; !ok {
+			return false
+		}
+	}
+	if topics != nil {
+		if len(l.Topics) == 0 {
+			return false
+		}
+		if _, ok := topics[l.Topics[0]]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) getLogs(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing filter")
+	}
+	var filter filterArgs
+	if err := json.Unmarshal(params[0], &filter); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	addresses, err := filter.addresses()
+	if err != nil {
+		return nil, err
+	}
+	topics, err := filter.eventTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	from, err := n.blockNumberLocked(filter.FromBlock, n.head)
+	if err != nil {
+		return nil, err
+	}
+	to, err := n.blockNumberLocked(filter.ToBlock, n.head)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []types.Log{}
+	for i := range n.logs {
+		l := &n.logs[i]
+		if filter.BlockHash != nil {
+			if l.BlockHash != *filter.BlockHash {
+				continue
+			}
+		} else if l.BlockNumber < from || l.BlockNumber > to {
+			continue
+		}
+		if matchesFilter(l, addresses, topics) {
+			logs = append(logs, *l)
+		}
+	}
+	if n.maxLogResults > 0 && len(logs) > n.maxLogResults {
+		return nil, &rpcError{Code: codeLimitExceeded, Message: fmt.Sprintf("query returned more than %d results", n.maxLogResults)}
+	}
+	return logs, nil
+}
+
+func (n *Node) getBlockByNumber(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing block number")
+	}
+	var tag string
+	if err := json.Unmarshal(params[0], &tag); err != nil {
+		return nil, fmt.Errorf("invalid block number: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	number, err := n.blockNumberLocked(tag, n.head)
+	if err != nil {
+		return nil, err
+	}
+	header, ok := n.headers[number]
+	if !ok {
+		return nil, nil
+	}
+	return header, nil
+}
+
+// blockNumberLocked parses a block number or tag. Empty means def.
+func (n *Node) blockNumberLocked(tag string, def uint64) (uint64, error) {
+	switch tag {
+	case "":
+		return def, nil
+	case "latest", "pending", "safe", "finalized":
+		return n.head, nil
+	case "earliest":
+		return 0, nil
+	}
+
+	number, err := hexutil.DecodeUint64(tag)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number %q: %w", tag, err)
+	}
+	return number, nil
+}
+
+func (n *Node) subscribe(params []json.RawMessage, conn *wsConn) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing subscription kind")
+	}
+	var kind string
+	if err := json.Unmarshal(params[0], &kind); err != nil {
+		return nil, fmt.Errorf("invalid subscription kind: %w", err)
+	}
+
+	sub := &subscription{kind: kind, conn: conn}
+	switch kind {
+	case SubscriptionNewHeads:
+	case SubscriptionLogs:
+		if len(params) > 1 {
+			var filter filterArgs
+			if err := json.Unmarshal(params[1], &filter); err != nil {
+				return nil, fmt.Errorf("invalid filter: %w", err)
+			}
+			var err error
+			if sub.addresses, err = filter.addresses(); err != nil {
+				return nil, err
+			}
+			if sub.topics, err = filter.eventTopics(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported subscription %q", kind)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nextSub++
+	sub.id = hexutil.EncodeUint64(uint64(n.nextSub))
+	n.subs[sub.id] = sub
+	return sub.id, nil
+}
+
+func (n *Node) unsubscribe(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing subscription ID")
+	}
+	var id string
+	if err := json.Unmarshal(params[0], &id); err != nil {
+		return nil, fmt.Errorf("invalid subscription ID: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, ok := n.subs[id]
+	delete(n.subs, id)
+	return ok, nil
+}
+
+// notify sends an eth_subscription notification. Write errors end with the connection.
+func (s *subscription) notify(result interface{}) {
+	s.conn.writeJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "eth_subscription",
+		"params": map[string]interface{}{
+			"subscription": s.id,
+			"result":       result,
+		},
+	})
+}
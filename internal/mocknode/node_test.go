@@ -0,0 +1,259 @@
+package mocknode
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"watcher/pkg/chain/base"
+	"watcher/pkg/dex/aerodrome"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	testFactory = common.HexToAddress("0x00000000000000000000000000000000000000f0")
+	testPool    = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	testToken0  = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	testToken1  = common.HexToAddress("0x00000000000000000000000000000000000000b2")
+)
+
+func newTestNode(t *testing.T) (*Node, *base.Client) {
+	node := New(testFactory)
+	t.Cleanup(node.Close)
+
+	node.AddToken(Token{Address: testToken0, Symbol: "TKN0", Decimals: 18})
+	node.AddPool(Pool{
+		Address:  testPool,
+		Token0:   testToken0,
+		Token1:   testToken1,
+		Reserve0: big.NewInt(1000),
+		Reserve1: big.NewInt(2000),
+	})
+
+	client, err := base.NewClient(node.URL())
+	if err != nil {
+		t.Fatalf("Connecting to mock node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	return node, client
+}
+
+func TestMulticallAgainstScriptedState(t *testing.T) {
+	_, client := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lengthData, _ := aerodrome.V2FactoryABI.Pack("allPoolsLength")
+	reservesData, _ := aerodrome.V2PoolABI.Pack("getReserves")
+	symbolData, _ := aerodrome.ERC20ABI.Pack("symbol")
+
+	results, err := client.BatchCallContract(ctx, []base.ContractCall{
+		{Target: testFactory, CallData: lengthData},
+		{Target: testPool, CallData: reservesData},
+		{Target: testToken0, CallData: symbolData},
+		{Target: testToken1, CallData: symbolData}, // no token scripted
+	})
+	if err != nil {
+		t.Fatalf("BatchCallContract: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	var length *big.Int
+	if err := aerodrome.V2FactoryABI.UnpackIntoInterface(&length, "allPoolsLength", results[0].Data); err != nil || length.Int64() != 1 {
+		t.Errorf("Expected 1 pool, got %v (%v)", length, err)
+	}
+
+	reserves := struct {
+		Reserve0           *big.Int
+		Reserve1           *big.Int
+		BlockTimestampLast *big.Int
+	}{}
+	if err := aerodrome.V2PoolABI.UnpackIntoInterface(&reserves, "getReserves", results[1].Data); err != nil {
+		t.Fatalf("Unpacking reserves: %v", err)
+	}
+	if reserves.Reserve0.Int64() != 1000 || reserves.Reserve1.Int64() != 2000 {
+		t.Errorf("Expected reserves 1000/2000, got %v/%v", reserves.Reserve0, reserves.Reserve1)
+	}
+
+	var symbol string
+	if err := aerodrome.ERC20ABI.UnpackIntoInterface(&symbol, "symbol", results[2].Data); err != nil || symbol != "TKN0" {
+		t.Errorf("Expected symbol TKN0, got %q (%v)", symbol, err)
+	}
+
+	if results[3].Success {
+		t.Error("Expected the call to an unscripted contract to fail")
+	}
+}
+
+func TestMinedLogsAreFilteredByRangeAndTopic(t *testing.T) {
+	node, client := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := node.SetReserves(testPool, big.NewInt(1100), big.NewInt(1900)); err != nil {
+		t.Fatal(err)
+	}
+	first := node.MineBlock()
+	node.MineBlock() // empty block
+	if err := node.SetReserves(testPool, big.NewInt(1200), big.NewInt(1800)); err != nil {
+		t.Fatal(err)
+	}
+	node.MineBlock()
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil || head != GenesisBlock+3 {
+		t.Fatalf("Expected head %d, got %d (%v)", GenesisBlock+3, head, err)
+	}
+
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: big.NewInt(GenesisBlock + 1),
+		ToBlock:   big.NewInt(GenesisBlock + 2),
+		Addresses: []common.Address{testPool},
+		Topics:    [][]common.Hash{{syncTopic}},
+	})
+	if err != nil {
+		t.Fatalf("FilterLogs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].BlockHash != first.Hash() {
+		t.Fatalf("Expected the first block's Sync log, got %+v", logs)
+	}
+
+	header, err := client.HeaderByNumber(ctx, big.NewInt(GenesisBlock+1))
+	if err != nil {
+		t.Fatalf("HeaderByNumber: %v", err)
+	}
+	if header.Hash() != first.Hash() {
+		t.Errorf("Expected header hash %s, got %s", first.Hash().Hex(), header.Hash().Hex())
+	}
+
+	logs, err = client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: big.NewInt(GenesisBlock),
+		Topics:    [][]common.Hash{{poolCreatedTopic}},
+	})
+	if err != nil || len(logs) != 0 {
+		t.Errorf("Expected no PoolCreated logs, got %d (%v)", len(logs), err)
+	}
+}
+
+func TestCallsAtEarlierBlocks(t *testing.T) {
+	node, client := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	genesis, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatalf("HeaderByNumber: %v", err)
+	}
+	if err := node.SetReserves(testPool, big.NewInt(1100), big.NewInt(1900)); err != nil {
+		t.Fatal(err)
+	}
+	first := node.MineBlock()
+	node.CreatePool(Pool{
+		Address:  common.HexToAddress("0x00000000000000000000000000000000000000a2"),
+		Token0:   testToken0,
+		Token1:   testToken1,
+		Reserve0: big.NewInt(1),
+		Reserve1: big.NewInt(1),
+	})
+	if err := node.SetReserves(testPool, big.NewInt(1200), big.NewInt(1800)); err != nil {
+		t.Fatal(err)
+	}
+	node.MineBlock()
+	// Queued for the next block, so only visible at latest
+	if err := node.SetReserves(testPool, big.NewInt(1300), big.NewInt(1700)); err != nil {
+		t.Fatal(err)
+	}
+
+	lengthData, _ := aerodrome.V2FactoryABI.Pack("allPoolsLength")
+	reservesData, _ := aerodrome.V2PoolABI.Pack("getReserves")
+	calls := []base.ContractCall{
+		{Target: testFactory, CallData: lengthData},
+		{Target: testPool, CallData: reservesData},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		block    rpc.BlockNumberOrHash
+		pools    int64
+		reserve0 int64
+	}{
+		{"genesis by hash", rpc.BlockNumberOrHashWithHash(genesis.Hash(), false), 1, 1000},
+		{"first by number", rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(first.Number.Int64())), 1, 1100},
+		{"head by number", rpc.BlockNumberOrHashWithNumber(GenesisBlock + 2), 2, 1200},
+		{"latest", base.Latest, 2, 1300},
+	} {
+		results, err := client.BatchCallContractAt(ctx, calls, tc.block)
+		if err != nil {
+			t.Fatalf("%s: BatchCallContractAt: %v", tc.name, err)
+		}
+
+		var length *big.Int
+		if err := aerodrome.V2FactoryABI.UnpackIntoInterface(&length, "allPoolsLength", results[0].Data); err != nil || length.Int64() != tc.pools {
+			t.Errorf("%s: expected %d pools, got %v (%v)", tc.name, tc.pools, length, err)
+		}
+		reserves, err := aerodrome.V2PoolABI.Unpack("getReserves", results[1].Data)
+		if err != nil || reserves[0].(*big.Int).Int64() != tc.reserve0 {
+			t.Errorf("%s: expected reserve0 %d, got %v (%v)", tc.name, tc.reserve0, reserves, err)
+		}
+	}
+
+	if _, err := client.BatchCallContractAt(ctx, calls, rpc.BlockNumberOrHashWithNumber(GenesisBlock+10)); err == nil {
+		t.Error("Expected a call at an unmined block to fail")
+	}
+
+	// A pruning node only serves the state of recent blocks
+	node.SetStateRetention(2)
+	if _, err := client.BatchCallContractAt(ctx, calls, rpc.BlockNumberOrHashWithHash(first.Hash(), false)); err != nil {
+		t.Errorf("Expected the state of the last 2 blocks to be kept, got %v", err)
+	}
+	_, err = client.BatchCallContractAt(ctx, calls, rpc.BlockNumberOrHashWithHash(genesis.Hash(), false))
+	if err == nil || !strings.Contains(err.Error(), "missing trie node") {
+		t.Errorf("Expected a call at a pruned block to fail with a missing trie node, got %v", err)
+	}
+}
+
+func TestMulticallSplitsOversizedBatches(t *testing.T) {
+	node, client := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	node.SetMaxMulticallSize(3)
+
+	reservesData, _ := aerodrome.V2PoolABI.Pack("getReserves")
+	calls := make([]base.ContractCall, 8)
+	for i := range calls {
+		calls[i] = base.ContractCall{Target: testPool, CallData: reservesData}
+	}
+
+	var limits []int
+	client.SetMulticallHook(func(limit int) { limits = append(limits, limit) })
+
+	results, err := client.BatchCallContract(ctx, calls)
+	if err != nil {
+		t.Fatalf("BatchCallContract: %v", err)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("Expected %d results, got %d", len(calls), len(results))
+	}
+	for i, r := range results {
+		if !r.Success {
+			t.Errorf("Expected call %d to succeed", i)
+		}
+	}
+
+	// The limit stays below the smallest rejected size
+	if limit := client.MulticallLimit(); limit < 1 || limit > 3 {
+		t.Errorf("Expected a multicall limit of at most 3, got %d (changes %v)", limit, limits)
+	}
+	if _, err := client.BatchCallContract(ctx, calls); err != nil {
+		t.Fatalf("BatchCallContract at the limit: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GraphState is the arbitrage graph as of a block, saved for warm restarts.
+type GraphState struct {
+	Block   uint64 // Every event up to and including this block is applied
+	SavedAt time.Time
+	Pools   []GraphPoolRecord
+	Tokens  []TokenRecord
+}
+
+// GraphPoolRecord is a pool in the graph, with the chain position of the
+// last update applied to it (zero if none was applied since bootstrap).
+type GraphPoolRecord struct {
+	Address  string
+	Token0   string
+	Token1   string
+	Reserve0 string
+	Reserve1 string
+	Fee      float64
+	IsStable bool
+	Block    uint64
+	LogIndex uint
+}
+
+// SaveGraphState replaces the saved graph state in one transaction.
+func (s *Store) SaveGraphState(ctx context.Context, state GraphState) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"graph_pools", "graph_tokens"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("clearing %s: %w", table, err)
+		}
+	}
+
+	savedAt := state.SavedAt
+	if savedAt.IsZero() {
+		savedAt = time.Now()
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO graph_state (id, block, saved_at) VALUES (1, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET block = excluded.block, saved_at = excluded.saved_at`,
+		state.Block, savedAt.UTC()); err != nil {
+		return fmt.Errorf("saving graph block: %w", err)
+	}
+
+	poolStmt, err := tx.PrepareContext(ctx, `INSERT INTO graph_pools (address, token0, token1, reserve0, reserve1, fee, is_stable, block, log_index)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer poolStmt.Close()
+
+	for _, p := range state.Pools {
+		if _, err := poolStmt.ExecContext(ctx, p.Address, p.Token0, p.Token1,
+			p.Reserve0, p.Reserve1, p.Fee, p.IsStable, p.Block, p.LogIndex); err != nil {
+			return fmt.Errorf("inserting graph pool %s: %w", p.Address, err)
+		}
+	}
+
+	tokenStmt, err := tx.PrepareContext(ctx, `INSERT INTO graph_tokens (address, symbol, decimals) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer tokenStmt.Close()
+
+	for _, t := range state.Tokens {
+		if _, err := tokenStmt.ExecContext(ctx, t.Address, t.Symbol, t.Decimals); err != nil {
+			return fmt.Errorf("inserting graph token %s: %w", t.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadGraphState retrieves the saved graph state, or nil if none was saved.
+func (s *Store) LoadGraphState(ctx context.Context) (*GraphState, error) {
+	var state GraphState
+	err := s.db.QueryRowContext(ctx, `SELECT block, saved_at FROM graph_state WHERE id = 1`).Scan(&state.Block, &state.SavedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying graph state: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT address, token0, token1, reserve0, reserve1, fee, is_stable, block, log_index FROM graph_pools`)
+	if err != nil {
+		return nil, fmt.Errorf("querying graph pools: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p GraphPoolRecord
+		if err := rows.Scan(&p.Address, &p.Token0, &p.Token1, &p.Reserve0, &p.Reserve1,
+			&p.Fee, &p.IsStable, &p.Block, &p.LogIndex); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		state.Pools = append(state.Pools, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tokenRows, err := s.db.QueryContext(ctx, `SELECT address, symbol, decimals FROM graph_tokens`)
+	if err != nil {
+		return nil, fmt.Errorf("querying graph tokens: %w", err)
+	}
+	defer tokenRows.Close()
+
+	for tokenRows.Next() {
+		var t TokenRecord
+		if err := tokenRows.Scan(&t.Address, &t.Symbol, &t.Decimals); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		state.Tokens = append(state.Tokens, t)
+	}
+
+	return &state, tokenRows.Err()
+}
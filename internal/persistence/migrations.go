@@ -0,0 +1,280 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migration is one step of the schema. Applied migrations are recorded in
+// schema_migrations and never change; schema changes are made by appending a
+// migration with the next version.
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// migrations is the schema history, in version order. Databases created
+// before versioning have no schema_migrations table and replay every
+// migration, so the statements up to version 6 must stay idempotent. Later
+// migrations only run once.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Tokens, pools, system state and tracked pools",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS tokens (
+				address TEXT PRIMARY KEY,
+				symbol TEXT NOT NULL,
+				decimals INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS pools (
+				address TEXT PRIMARY KEY,
+				token0 TEXT NOT NULL,
+				token1 TEXT NOT NULL,
+				reserve0 TEXT NOT NULL DEFAULT '0',
+				reserve1 TEXT NOT NULL DEFAULT '0',
+				fee REAL NOT NULL DEFAULT 0.003,
+				is_stable INTEGER NOT NULL DEFAULT 0,
+				tvl REAL NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (token0) REFERENCES tokens(address),
+				FOREIGN KEY (token1) REFERENCES tokens(address)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_pools_tvl ON pools(tvl DESC)`,
+			`CREATE INDEX IF NOT EXISTS idx_pools_tokens ON pools(token0, token1)`,
+			`CREATE TABLE IF NOT EXISTS system_state (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS tracked_pools (
+				pool_address TEXT PRIMARY KEY,
+				added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version:     2,
+		description: "Token screening results",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS token_safety (
+				address TEXT PRIMARY KEY,
+				class TEXT NOT NULL,
+				fee REAL NOT NULL DEFAULT 0,
+				reason TEXT NOT NULL DEFAULT '',
+				flags TEXT NOT NULL DEFAULT '',
+				checked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version:     3,
+		description: "Pool activity counters",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS pool_activity (
+				address TEXT PRIMARY KEY,
+				syncs INTEGER NOT NULL DEFAULT 0,
+				swaps INTEGER NOT NULL DEFAULT 0,
+				cycles INTEGER NOT NULL DEFAULT 0,
+				volatility REAL NOT NULL DEFAULT 0,
+				last_swap DATETIME,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version:     4,
+		description: "Curation allow and deny lists",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS curation_lists (
+				list TEXT NOT NULL,
+				address TEXT NOT NULL,
+				source TEXT NOT NULL DEFAULT 'manual',
+				added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (list, address)
+			)`,
+		},
+	},
+	{
+		version:     5,
+		description: "Opportunity history",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS opportunities (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				block INTEGER NOT NULL,
+				detected_at DATETIME NOT NULL,
+				start_token TEXT NOT NULL,
+				input_wei TEXT NOT NULL,
+				profit_wei TEXT NOT NULL,
+				profit REAL NOT NULL,
+				profit_factor REAL NOT NULL,
+				latency_us INTEGER NOT NULL,
+				capital_mode TEXT NOT NULL DEFAULT 'inventory'
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_opportunities_detected_at ON opportunities(detected_at)`,
+			`CREATE TABLE IF NOT EXISTS opportunity_hops (
+				opportunity_id INTEGER NOT NULL,
+				hop INTEGER NOT NULL,
+				pool TEXT NOT NULL,
+				token_in TEXT NOT NULL,
+				token_out TEXT NOT NULL,
+				amount_in TEXT NOT NULL,
+				amount_out TEXT NOT NULL,
+				PRIMARY KEY (opportunity_id, hop),
+				FOREIGN KEY (opportunity_id) REFERENCES opportunities(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_opportunity_hops_pool ON opportunity_hops(pool)`,
+			`CREATE INDEX IF NOT EXISTS idx_opportunity_hops_token_in ON opportunity_hops(token_in)`,
+		},
+	},
+	{
+		version:     6,
+		description: "Reserve history",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS reserve_history (
+				pool TEXT NOT NULL,
+				block INTEGER NOT NULL,
+				log_index INTEGER NOT NULL,
+				reserve0 TEXT NOT NULL,
+				reserve1 TEXT NOT NULL,
+				block_time DATETIME,
+				recorded_at DATETIME NOT NULL,
+				PRIMARY KEY (pool, block, log_index)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_reserve_history_recorded_at ON reserve_history(recorded_at)`,
+		},
+	},
+	{
+		version:     7,
+		description: "Graph state for warm restarts",
+		statements: []string{
+			`CREATE TABLE graph_state (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				block INTEGER NOT NULL,
+				saved_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE graph_pools (
+				address TEXT PRIMARY KEY,
+				token0 TEXT NOT NULL,
+				token1 TEXT NOT NULL,
+				reserve0 TEXT NOT NULL,
+				reserve1 TEXT NOT NULL,
+				fee REAL NOT NULL,
+				block INTEGER NOT NULL DEFAULT 0,
+				log_index INTEGER NOT NULL DEFAULT 0
+			)`,
+			`CREATE TABLE graph_tokens (
+				address TEXT PRIMARY KEY,
+				symbol TEXT NOT NULL,
+				decimals INTEGER NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     8,
+		description: "Pool activity by period",
+		statements: []string{
+			`CREATE TABLE pool_activity_periods (
+				address TEXT NOT NULL,
+				period_start DATETIME NOT NULL,
+				syncs INTEGER NOT NULL DEFAULT 0,
+				swaps INTEGER NOT NULL DEFAULT 0,
+				cycles INTEGER NOT NULL DEFAULT 0,
+				squared_returns REAL NOT NULL DEFAULT 0,
+				last_swap DATETIME,
+				PRIMARY KEY (address, period_start)
+			)`,
+			// Window totals saved before are kept as one period at their save time
+			`INSERT INTO pool_activity_periods (address, period_start, syncs, swaps, cycles, squared_returns, last_swap)
+				SELECT address, COALESCE(updated_at, CURRENT_TIMESTAMP), syncs, swaps, cycles, volatility * volatility, last_swap
+				FROM pool_activity`,
+			`DROP TABLE pool_activity`,
+		},
+	},
+	{
+		version:     9,
+		description: "Stable flag of saved graph pools",
+		statements: []string{
+			`ALTER TABLE graph_pools ADD COLUMN is_stable INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// SchemaVersion returns the schema version this build migrates databases to.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies the migrations the database has not applied yet, each in
+// its own transaction. It refuses a database whose schema is newer than this
+// build's, since this build may not read or write it correctly.
+func (s *Store) migrate() error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	current, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	latest := SchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("applying migration %d (%s): %w", m.version, m.description, err)
+		}
+		log.Info().Int("version", m.version).Str("description", m.description).Msg("Applied database migration")
+	}
+
+	log.Info().Int("from_version", current).Int("version", latest).Msg("Database migrations completed")
+	return nil
+}
+
+// schemaVersion returns the highest applied migration, or 0 if none is.
+func (s *Store) schemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration runs a migration's statements and records it, atomically.
+func (s *Store) applyMigration(ctx context.Context, m migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("executing statement: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.description, time.Now().UTC()); err != nil {
+		return fmt.Errorf("recording migration: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,261 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lastUnversioned is the last schema version written without
+// schema_migrations, before migrations were versioned.
+const lastUnversioned = 6
+
+// fixtureData inserts rows into the tables added at each schema version, so
+// migrations can be checked to keep them.
+var fixtureData = map[int][]string{
+	1: {
+		`INSERT INTO tokens (address, symbol, decimals) VALUES ('0xt0', 'WETH', 18), ('0xt1', 'USDC', 6)`,
+		`INSERT INTO pools (address, token0, token1, reserve0, reserve1, tvl) VALUES ('0xp1', '0xt0', '0xt1', '10', '20', 5000)`,
+		`INSERT INTO system_state (key, value) VALUES ('factory_scan_index', '42')`,
+		`INSERT INTO tracked_pools (pool_address) VALUES ('0xp1')`,
+	},
+	2: {`INSERT INTO token_safety (address, class) VALUES ('0xt1', 'safe')`},
+	3: {`INSERT INTO pool_activity (address, syncs) VALUES ('0xp1', 7)`},
+	4: {`INSERT INTO curation_lists (list, address, source) VALUES ('deny_pools', '0xp9', 'manual')`},
+	5: {
+		`INSERT INTO opportunities (id, block, detected_at, start_token, input_wei, profit_wei, profit, profit_factor, latency_us)
+			VALUES (1, 100, '2026-01-01 00:00:00+00:00', '0xt0', '1000', '5', 5, 1.005, 300)`,
+		`INSERT INTO opportunity_hops (opportunity_id, hop, pool, token_in, token_out, amount_in, amount_out)
+			VALUES (1, 0, '0xp1', '0xt0', '0xt1', '1000', '2000')`,
+	},
+	6: {`INSERT INTO reserve_history (pool, block, log_index, reserve0, reserve1, recorded_at) VALUES ('0xp1', 100, 0, '10', '20', '2026-01-01 00:00:00+00:00')`},
+	7: {
+		`INSERT INTO graph_state (id, block, saved_at) VALUES (1, 100, '2026-01-01 00:00:00+00:00')`,
+		`INSERT INTO graph_pools (address, token0, token1, reserve0, reserve1, fee) VALUES ('0xp1', '0xt0', '0xt1', '10', '20', 0.003)`,
+		`INSERT INTO graph_tokens (address, symbol, decimals) VALUES ('0xt0', 'WETH', 18), ('0xt1', 'USDC', 6)`,
+	},
+	8: {`INSERT INTO pool_activity_periods (address, period_start, swaps) VALUES ('0xp2', '2026-01-01 00:00:00+00:00', 3)`},
+	9: {`UPDATE graph_pools SET is_stable = 1 WHERE address = '0xp1'`},
+}
+
+// newFixtureDB creates a database at schema version. A versioned fixture is
+// what this package wrote at that version; an unversioned one is what the
+// store wrote before migrations were versioned, with no schema_migrations.
+func newFixtureDB(t *testing.T, version int, versioned bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if versioned {
+		if _, err := db.Exec(`CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := &Store{db: db}
+	for _, m := range migrations[:version] {
+		if versioned {
+			if err := store.applyMigration(context.Background(), m); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			for _, statement := range m.statements {
+				if _, err := db.Exec(statement); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		for _, statement := range fixtureData[m.version] {
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatalf("inserting version %d fixture data: %v", m.version, err)
+			}
+		}
+	}
+	return path
+}
+
+// schemaObjects lists the tables and indexes of a database.
+func schemaObjects(t *testing.T, store *Store) []string {
+	t.Helper()
+	rows, err := store.db.Query(`SELECT type || ' ' || name FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var objects []string
+	for rows.Next() {
+		var object string
+		if err := rows.Scan(&object); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+func TestMigrationsAreConsecutive(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, m.version)
+		}
+		if m.description == "" || len(m.statements) == 0 {
+			t.Errorf("Migration %d has no description or statements", m.version)
+		}
+	}
+}
+
+func TestMigrateFromEveryVersion(t *testing.T) {
+	ctx := context.Background()
+	fresh := newTestStore(t)
+	want := schemaObjects(t, fresh)
+
+	for version := 0; version <= SchemaVersion(); version++ {
+		for _, versioned := range []bool{true, false} {
+			if !versioned && version > lastUnversioned {
+				continue
+			}
+			name := fmt.Sprintf("v%d", version)
+			if !versioned {
+				name += " unversioned"
+			}
+			t.Run(name, func(t *testing.T) {
+				store, err := NewStore(newFixtureDB(t, version, versioned))
+				if err != nil {
+					t.Fatalf("NewStore: %v", err)
+				}
+				defer store.Close()
+
+				if got, _ := store.schemaVersion(ctx); got != SchemaVersion() {
+					t.Errorf("Expected schema version %d, got %d", SchemaVersion(), got)
+				}
+				if got := schemaObjects(t, store); !reflect.DeepEqual(got, want) {
+					t.Errorf("Expected the schema of a new database\n%v\ngot\n%v", want, got)
+				}
+
+				// Rows written at the fixture's version are still readable
+				if version >= 1 {
+					pool, err := store.GetPoolByAddress(ctx, "0xp1")
+					if err != nil || pool == nil || pool.TVL != 5000 {
+						t.Errorf("Expected the fixture pool, got %+v (%v)", pool, err)
+					}
+					if checkpoint, _ := store.GetSystemState(ctx, "factory_scan_index"); checkpoint != "42" {
+						t.Errorf("Expected the fixture checkpoint, got %q", checkpoint)
+					}
+				}
+				if version >= 3 {
+					activity, err := store.GetAllPoolActivity(ctx)
+					counts := make(map[string]int)
+					for _, a := range activity {
+						counts[a.Address] += a.Syncs + a.Swaps
+					}
+					if err != nil || counts["0xp1"] != 7 || (version >= 8 && counts["0xp2"] != 3) {
+						t.Errorf("Expected the fixture pool activity, got %+v (%v)", activity, err)
+					}
+				}
+				if version >= 4 {
+					entries, err := store.GetCurationEntries(ctx)
+					if err != nil || len(entries) != 1 {
+						t.Errorf("Expected the fixture curation entry, got %+v (%v)", entries, err)
+					}
+				}
+				if version >= 5 {
+					opps, err := store.QueryOpportunities(ctx, OpportunityFilter{Pool: "0xp1"})
+					if err != nil || len(opps) != 1 || len(opps[0].Hops) != 1 {
+						t.Errorf("Expected the fixture opportunity, got %+v (%v)", opps, err)
+					}
+				}
+				if version >= 6 {
+					reserves, err := store.GetReservesAtBlock(ctx, []string{"0xp1"}, 100)
+					if err != nil || reserves["0xp1"].Reserve1 != "20" {
+						t.Errorf("Expected the fixture reserves, got %+v (%v)", reserves, err)
+					}
+				}
+				if version >= 7 {
+					state, err := store.LoadGraphState(ctx)
+					if err != nil || state == nil || state.Block != 100 || len(state.Pools) != 1 || len(state.Tokens) != 2 {
+						t.Errorf("Expected the fixture graph state, got %+v (%v)", state, err)
+					}
+					if err == nil && state != nil && len(state.Pools) == 1 && state.Pools[0].IsStable != (version >= 9) {
+						t.Errorf("Expected the fixture pool's stable flag kept, got %+v", state.Pools[0])
+					}
+				}
+
+				// The migrated database accepts writes to every table
+				if err := store.InsertReserveHistory(ctx, []ReserveRecord{{Pool: "0xp2", Block: 1, Reserve0: "1", Reserve1: "1"}}); err != nil {
+					t.Errorf("InsertReserveHistory: %v", err)
+				}
+				if err := store.InsertOpportunities(ctx, []OpportunityRecord{{DetectedAt: time.Now(), InputWei: "1", ProfitWei: "1"}}); err != nil {
+					t.Errorf("InsertOpportunities: %v", err)
+				}
+				if err := store.SetPoolActivity(ctx, []PoolActivityRecord{{Address: "0xp2", PeriodStart: time.Now(), Syncs: 1}}); err != nil {
+					t.Errorf("SetPoolActivity: %v", err)
+				}
+				if err := store.SaveGraphState(ctx, GraphState{Block: 1, Pools: []GraphPoolRecord{{Address: "0xp2", Reserve0: "1", Reserve1: "1"}}}); err != nil {
+					t.Errorf("SaveGraphState: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := newFixtureDB(t, SchemaVersion(), true)
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, 'From a newer build', ?)`,
+		SchemaVersion()+1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	_, err = NewStore(path)
+	if err == nil || !strings.Contains(err.Error(), "newer than the supported version") {
+		t.Errorf("Expected NewStore to refuse a newer schema, got %v", err)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	path := newFixtureDB(t, SchemaVersion(), true)
+
+	saved := migrations
+	migrations = append(migrations[:len(migrations):len(migrations)], migration{
+		version:     SchemaVersion() + 1,
+		description: "Broken",
+		statements:  []string{`CREATE TABLE half_done (id INTEGER)`, `NOT SQL`},
+	})
+	_, err := NewStore(path)
+	migrations = saved
+	if err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if got, _ := store.schemaVersion(context.Background()); got != SchemaVersion() {
+		t.Errorf("Expected schema version %d after the failed migration, got %d", SchemaVersion(), got)
+	}
+	for _, object := range schemaObjects(t, store) {
+		if object == "table half_done" {
+			t.Error("Expected the failed migration's statements to be rolled back")
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// OpportunityRecord is a detected arbitrage opportunity.
+type OpportunityRecord struct {
+	ID           int64
+	Block        uint64
+	DetectedAt   time.Time
+	InputWei     string
+	ProfitWei    string
+	ProfitFactor float64
+	Latency      time.Duration
+	CapitalMode  string
+	Hops         []OpportunityHop
+}
+
+// OpportunityHop is one swap of an opportunity, with its simulated amounts.
+type OpportunityHop struct {
+	Pool      string
+	TokenIn   string
+	TokenOut  string
+	AmountIn  string
+	AmountOut string
+}
+
+// StartToken returns the token the opportunity starts and ends in.
+func (r OpportunityRecord) StartToken() string {
+	if len(r.Hops) == 0 {
+		return ""
+	}
+	return r.Hops[0].TokenIn
+}
+
+// OpportunityFilter selects opportunities. Zero fields match everything.
+type OpportunityFilter struct {
+	From       time.Time // Detected at or after
+	To         time.Time // Detected before
+	Token      string    // Any token on the path
+	StartToken string    // Token the opportunity starts and ends in
+	Pool       string    // Any pool on the path
+	MinProfit  *big.Int  // Minimum profit in wei of StartToken, which it requires
+	Limit      int       // Most recent first; 0 means no limit
+}
+
+// InsertOpportunities stores opportunities and their hops in one transaction.
+func (s *Store) InsertOpportunities(ctx context.Context, records []OpportunityRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	oppStmt, err := tx.PrepareContext(ctx, `INSERT INTO opportunities
+		(block, detected_at, start_token, input_wei, profit_wei, profit, profit_factor, latency_us, capital_mode)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer oppStmt.Close()
+
+	hopStmt, err := tx.PrepareContext(ctx, `INSERT INTO opportunity_hops
+		(opportunity_id, hop, pool, token_in, token_out, amount_in, amount_out)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer hopStmt.Close()
+
+	for _, r := range records {
+		res, err := oppStmt.ExecContext(ctx, r.Block, r.DetectedAt.UTC(), strings.ToLower(r.StartToken()),
+			r.InputWei, r.ProfitWei, weiToFloat(r.ProfitWei), r.ProfitFactor,
+			r.Latency.Microseconds(), r.CapitalMode)
+		if err != nil {
+			return fmt.Errorf("inserting opportunity at block %d: %w", r.Block, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("reading opportunity id: %w", err)
+		}
+
+		for i, h := range r.Hops {
+			if _, err := hopStmt.ExecContext(ctx, id, i, strings.ToLower(h.Pool),
+				strings.ToLower(h.TokenIn), strings.ToLower(h.TokenOut), h.AmountIn, h.AmountOut); err != nil {
+				return fmt.Errorf("inserting opportunity hop %d: %w", i, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// QueryOpportunities retrieves the opportunities matching filter, most recent
+// first, with their hops.
+func (s *Store) QueryOpportunities(ctx context.Context, filter OpportunityFilter) ([]OpportunityRecord, error) {
+	// Profits are in wei of each opportunity's start token, so they only
+	// compare within one token
+	if filter.MinProfit != nil && filter.StartToken == "" {
+		return nil, fmt.Errorf("minimum profit requires a start token")
+	}
+
+	query := `SELECT id, block, detected_at, input_wei, profit_wei, profit_factor, latency_us, capital_mode
+		FROM opportunities WHERE 1 = 1`
+	var args []any
+
+	if !filter.From.IsZero() {
+		query += ` AND detected_at >= ?`
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		query += ` AND detected_at < ?`
+		args = append(args, filter.To.UTC())
+	}
+	if filter.Token != "" {
+		query += ` AND id IN (SELECT opportunity_id FROM opportunity_hops WHERE token_in = ?)`
+		args = append(args, strings.ToLower(filter.Token))
+	}
+	if filter.StartToken != "" {
+		query += ` AND start_token = ?`
+		args = append(args, strings.ToLower(filter.StartToken))
+	}
+	if filter.Pool != "" {
+		query += ` AND id IN (SELECT opportunity_id FROM opportunity_hops WHERE pool = ?)`
+		args = append(args, strings.ToLower(filter.Pool))
+	}
+	if filter.MinProfit != nil {
+		// Narrowed on the approximate profit column, then checked exactly below
+		query += ` AND profit >= ?`
+		args = append(args, weiToFloat(filter.MinProfit.String())*(1-1e-9))
+	}
+	query += ` ORDER BY detected_at DESC, id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying opportunities: %w", err)
+	}
+	defer rows.Close()
+
+	var records []OpportunityRecord
+	index := make(map[int64]int)
+	for rows.Next() {
+		var r OpportunityRecord
+		var latencyUs int64
+		if err := rows.Scan(&r.ID, &r.Block, &r.DetectedAt, &r.InputWei, &r.ProfitWei,
+			&r.ProfitFactor, &latencyUs, &r.CapitalMode); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		if filter.MinProfit != nil {
+			profit, ok := new(big.Int).SetString(r.ProfitWei, 10)
+			if !ok || profit.Cmp(filter.MinProfit) < 0 {
+				continue
+			}
+		}
+
+		r.Latency = time.Duration(latencyUs) * time.Microsecond
+		index[r.ID] = len(records)
+		records = append(records, r)
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := s.loadOpportunityHops(ctx, records, index); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// loadOpportunityHops fills in the hops of records, whose positions are
+// indexed by opportunity ID.
+func (s *Store) loadOpportunityHops(ctx context.Context, records []OpportunityRecord, index map[int64]int) error {
+	for start := 0; start < len(records); start += sqliteMaxParams {
+		batch := records[start:min(start+sqliteMaxParams, len(records))]
+		ids := make([]any, len(batch))
+		for i, r := range batch {
+			ids[i] = r.ID
+		}
+
+		rows, err := s.db.QueryContext(ctx, `SELECT opportunity_id, pool, token_in, token_out, amount_in, amount_out
+			FROM opportunity_hops
+			WHERE opportunity_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+			ORDER BY opportunity_id, hop`, ids...)
+		if err != nil {
+			return fmt.Errorf("querying opportunity hops: %w", err)
+		}
+
+		for rows.Next() {
+			var id int64
+			var h OpportunityHop
+			if err := rows.Scan(&id, &h.Pool, &h.TokenIn, &h.TokenOut, &h.AmountIn, &h.AmountOut); err != nil {
+				rows.Close()
+				return fmt.Errorf("scanning row: %w", err)
+			}
+			r := &records[index[id]]
+			r.Hops = append(r.Hops, h)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteMaxParams bounds the number of bound parameters in one statement.
+const sqliteMaxParams = 500
+
+// weiToFloat converts a decimal wei amount to a float for indexing and
+// approximate comparisons.
+func weiToFloat(wei string) float64 {
+	f, ok := new(big.Float).SetString(wei)
+	if !ok {
+		return 0
+	}
+	v, _ := f.Float64()
+	return v
+}
+
+// OpportunityWriter stores opportunities in the background, in batches.
+type OpportunityWriter = BatchWriter[OpportunityRecord]
+
+// NewOpportunityWriter creates a writer that buffers up to bufferSize
+// opportunities and stores them every flushInterval or every batchSize
+// opportunities, whichever comes first.
+func NewOpportunityWriter(store *Store, bufferSize, batchSize int, flushInterval time.Duration) *OpportunityWriter {
+	return NewBatchWriter("opportunities", store.InsertOpportunities, bufferSize, batchSize, flushInterval)
+}
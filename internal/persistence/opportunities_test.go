@@ -0,0 +1,147 @@
+package persistence
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "watcher.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// testOpportunity returns a cycle from tokens[0] through pools, profiting profit wei.
+func testOpportunity(block uint64, at time.Time, profit string, pools []string, tokens []string) OpportunityRecord {
+	r := OpportunityRecord{
+		Block:        block,
+		DetectedAt:   at,
+		InputWei:     "1000000000000000000",
+		ProfitWei:    profit,
+		ProfitFactor: 1.01,
+		Latency:      250 * time.Microsecond,
+		CapitalMode:  "inventory",
+	}
+	for i, pool := range pools {
+		r.Hops = append(r.Hops, OpportunityHop{
+			Pool:      pool,
+			TokenIn:   tokens[i],
+			TokenOut:  tokens[(i+1)%len(tokens)],
+			AmountIn:  "1000",
+			AmountOut: "1001",
+		})
+	}
+	return r
+}
+
+func TestQueryOpportunitiesFilters(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	err := store.InsertOpportunities(ctx, []OpportunityRecord{
+		testOpportunity(100, base, "5000", []string{"0xP1", "0xP2"}, []string{"0xWETH", "0xUSDC"}),
+		testOpportunity(101, base.Add(time.Minute), "20000000000000000001", []string{"0xP2", "0xP3", "0xP4"}, []string{"0xUSDC", "0xWETH", "0xDAI"}),
+		testOpportunity(102, base.Add(2*time.Minute), "20000000000000000000", []string{"0xP3", "0xP4"}, []string{"0xDAI", "0xWETH"}),
+	})
+	if err != nil {
+		t.Fatalf("InsertOpportunities: %v", err)
+	}
+
+	minProfit, _ := new(big.Int).SetString("20000000000000000001", 10)
+	tests := []struct {
+		name   string
+		filter OpportunityFilter
+		blocks []uint64
+	}{
+		{"all, most recent first", OpportunityFilter{}, []uint64{102, 101, 100}},
+		{"time range", OpportunityFilter{From: base.Add(time.Minute), To: base.Add(2 * time.Minute)}, []uint64{101}},
+		{"token anywhere on the path", OpportunityFilter{Token: "0xdai"}, []uint64{102, 101}},
+		{"pool", OpportunityFilter{Pool: "0xp1"}, []uint64{100}},
+		{"start token", OpportunityFilter{StartToken: "0xdai"}, []uint64{102}},
+		{"exact minimum profit", OpportunityFilter{StartToken: "0xUSDC", MinProfit: minProfit}, []uint64{101}},
+		{"minimum profit in the start token only", OpportunityFilter{StartToken: "0xdai", MinProfit: minProfit}, nil},
+		{"limit", OpportunityFilter{Token: "0xweth", Limit: 2}, []uint64{102, 101}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.QueryOpportunities(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("QueryOpportunities: %v", err)
+			}
+			var blocks []uint64
+			for _, r := range records {
+				blocks = append(blocks, r.Block)
+			}
+			if len(blocks) != len(tt.blocks) {
+				t.Fatalf("Expected blocks %v, got %v", tt.blocks, blocks)
+			}
+			for i := range blocks {
+				if blocks[i] != tt.blocks[i] {
+					t.Fatalf("Expected blocks %v, got %v", tt.blocks, blocks)
+				}
+			}
+		})
+	}
+
+	// Profits in different tokens can't be compared
+	if _, err := store.QueryOpportunities(ctx, OpportunityFilter{MinProfit: minProfit}); err == nil {
+		t.Error("Expected an error for a minimum profit without a start token")
+	}
+
+	records, err := store.QueryOpportunities(ctx, OpportunityFilter{Pool: "0xP2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opp := records[0]
+	if len(opp.Hops) != 3 || opp.Hops[2].Pool != "0xp4" || opp.Hops[2].TokenOut != "0xusdc" || opp.Hops[0].AmountOut != "1001" {
+		t.Errorf("Unexpected hops %+v", opp.Hops)
+	}
+	if opp.StartToken() != "0xusdc" || opp.Latency != 250*time.Microsecond || !opp.DetectedAt.Equal(base.Add(time.Minute)) {
+		t.Errorf("Unexpected opportunity %+v", opp)
+	}
+}
+
+func TestOpportunityWriterFlushesOnShutdown(t *testing.T) {
+	store := newTestStore(t)
+	writer := NewOpportunityWriter(store, 10, 100, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- writer.Run(ctx) }()
+
+	for i := 0; i < 3; i++ {
+		if !writer.Write(testOpportunity(uint64(i), time.Now(), "1", []string{"0xP1"}, []string{"0xWETH"})) {
+			t.Fatalf("Write %d dropped", i)
+		}
+	}
+	cancel()
+	<-done
+
+	records, err := store.QueryOpportunities(context.Background(), OpportunityFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Errorf("Expected the 3 buffered opportunities stored at shutdown, got %d", len(records))
+	}
+}
+
+func TestOpportunityWriterDropsWhenFull(t *testing.T) {
+	writer := NewOpportunityWriter(newTestStore(t), 1, 100, time.Hour)
+
+	// Nothing drains the buffer, so Write must not block
+	if !writer.Write(OpportunityRecord{}) {
+		t.Fatal("Expected the first opportunity to be queued")
+	}
+	if writer.Write(OpportunityRecord{}) {
+		t.Error("Expected the second opportunity to be dropped")
+	}
+}
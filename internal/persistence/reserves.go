@@ -0,0 +1,208 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ReserveRecord is a pool's reserves after an applied Sync event.
+type ReserveRecord struct {
+	Pool       string
+	Block      uint64
+	LogIndex   uint
+	Reserve0   string
+	Reserve1   string
+	BlockTime  time.Time // Zero if the block header was not known
+	RecordedAt time.Time
+
+	// Removed marks an update a reorg removed: the row stored at its position
+	// is deleted instead. Only Pool, Block and LogIndex are used.
+	Removed bool
+}
+
+// BlockEndLogIndex is the log index of reserves read at the end of a block
+// rather than from a Sync event, so they sort after every event of the block.
+const BlockEndLogIndex uint = math.MaxUint32
+
+// ReserveHistoryWriter stores reserve updates in the background, in batches.
+type ReserveHistoryWriter = BatchWriter[ReserveRecord]
+
+// NewReserveHistoryWriter creates a writer that buffers up to bufferSize
+// reserve updates and stores them every flushInterval or every batchSize
+// updates, whichever comes first.
+func NewReserveHistoryWriter(store *Store, bufferSize, batchSize int, flushInterval time.Duration) *ReserveHistoryWriter {
+	return NewBatchWriter("reserve updates", store.InsertReserveHistory, bufferSize, batchSize, flushInterval)
+}
+
+// InsertReserveHistory stores reserve updates in order. An update at a pool
+// and log position already stored replaces it, and a removed update deletes
+// it, so no reserves of a block a reorg removed are left behind.
+func (s *Store) InsertReserveHistory(ctx context.Context, records []ReserveRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO reserve_history (pool, block, log_index, reserve0, reserve1, block_time, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(pool, block, log_index) DO UPDATE SET
+			reserve0 = excluded.reserve0,
+			reserve1 = excluded.reserve1,
+			block_time = excluded.block_time,
+			recorded_at = excluded.recorded_at`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	deleteStmt, err := tx.PrepareContext(ctx, `DELETE FROM reserve_history WHERE pool = ? AND block = ? AND log_index = ?`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer deleteStmt.Close()
+
+	now := time.Now().UTC()
+	for _, r := range records {
+		if r.Removed {
+			if _, err := deleteStmt.ExecContext(ctx, strings.ToLower(r.Pool), r.Block, r.LogIndex); err != nil {
+				return fmt.Errorf("deleting removed reserves of %s at block %d: %w", r.Pool, r.Block, err)
+			}
+			continue
+		}
+
+		var blockTime any
+		if !r.BlockTime.IsZero() {
+			blockTime = r.BlockTime.UTC()
+		}
+		recordedAt := now
+		if !r.RecordedAt.IsZero() {
+			recordedAt = r.RecordedAt.UTC()
+		}
+		if _, err := stmt.ExecContext(ctx, strings.ToLower(r.Pool), r.Block, r.LogIndex,
+			r.Reserve0, r.Reserve1, blockTime, recordedAt); err != nil {
+			return fmt.Errorf("inserting reserves of %s at block %d: %w", r.Pool, r.Block, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetReserveHistory retrieves a pool's stored reserve updates between two
+// blocks, inclusive, oldest first.
+func (s *Store) GetReserveHistory(ctx context.Context, pool string, fromBlock, toBlock uint64) ([]ReserveRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT pool, block, log_index, reserve0, reserve1, block_time, recorded_at
+		FROM reserve_history
+		WHERE pool = ? AND block BETWEEN ? AND ?
+		ORDER BY block, log_index`, strings.ToLower(pool), fromBlock, toBlock)
+	if err != nil {
+		return nil, fmt.Errorf("querying reserve history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ReserveRecord
+	for rows.Next() {
+		r, err := scanReserveRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// GetReservesAtBlock reconstructs the reserves of pools as of the end of
+// block: each pool's last stored update at or before it. Pools without an
+// update by then are left out.
+func (s *Store) GetReservesAtBlock(ctx context.Context, pools []string, block uint64) (map[string]ReserveRecord, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT pool, block, log_index, reserve0, reserve1, block_time, recorded_at
+		FROM reserve_history
+		WHERE pool = ? AND block <= ?
+		ORDER BY block DESC, log_index DESC
+		LIMIT 1`)
+	if err != nil {
+		return nil, fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	reserves := make(map[string]ReserveRecord, len(pools))
+	for _, pool := range pools {
+		pool = strings.ToLower(pool)
+		rows, err := stmt.QueryContext(ctx, pool, block)
+		if err != nil {
+			return nil, fmt.Errorf("querying reserves of %s: %w", pool, err)
+		}
+		if rows.Next() {
+			r, err := scanReserveRecord(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			reserves[pool] = r
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return reserves, nil
+}
+
+func scanReserveRecord(rows *sql.Rows) (ReserveRecord, error) {
+	var r ReserveRecord
+	var blockTime sql.NullTime
+	if err := rows.Scan(&r.Pool, &r.Block, &r.LogIndex, &r.Reserve0, &r.Reserve1, &blockTime, &r.RecordedAt); err != nil {
+		return r, fmt.Errorf("scanning row: %w", err)
+	}
+	r.BlockTime = blockTime.Time
+	return r, nil
+}
+
+// PruneReserveHistory deletes reserve updates recorded before retainAfter,
+// except each pool's latest update, so the reserves of a dormant pool can
+// still be reconstructed. Those recorded before downsampleAfter are
+// downsampled to each pool's last update per bucketBlocks blocks.
+// Reconstructing reserves at the end of a bucket is unaffected by
+// downsampling. Returns the number of rows deleted.
+func (s *Store) PruneReserveHistory(ctx context.Context, retainAfter, downsampleAfter time.Time, bucketBlocks uint64) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM reserve_history WHERE recorded_at < ? AND rowid NOT IN (
+			SELECT id FROM (
+				SELECT rowid AS id, ROW_NUMBER() OVER (
+					PARTITION BY pool
+					ORDER BY block DESC, log_index DESC
+				) AS position
+				FROM reserve_history
+			) WHERE position = 1
+		)`, retainAfter.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired reserve history: %w", err)
+	}
+	expired, _ := res.RowsAffected()
+
+	if bucketBlocks <= 1 {
+		return expired, nil
+	}
+	res, err = s.db.ExecContext(ctx, `DELETE FROM reserve_history WHERE rowid IN (
+			SELECT id FROM (
+				SELECT rowid AS id, ROW_NUMBER() OVER (
+					PARTITION BY pool, block / ?
+					ORDER BY block DESC, log_index DESC
+				) AS position
+				FROM reserve_history
+				WHERE recorded_at < ?
+			) WHERE position > 1
+		)`, bucketBlocks, downsampleAfter.UTC())
+	if err != nil {
+		return expired, fmt.Errorf("downsampling reserve history: %w", err)
+	}
+	downsampled, _ := res.RowsAffected()
+
+	return expired + downsampled, nil
+}
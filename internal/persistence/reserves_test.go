@@ -0,0 +1,141 @@
+package persistence
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetReservesAtBlock(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	err := store.InsertReserveHistory(ctx, []ReserveRecord{
+		{Pool: "0xP1", Block: 100, LogIndex: 0, Reserve0: "10", Reserve1: "20"},
+		{Pool: "0xP1", Block: 105, LogIndex: 3, Reserve0: "11", Reserve1: "19"},
+		{Pool: "0xP1", Block: 105, LogIndex: 7, Reserve0: "12", Reserve1: "18"},
+		{Pool: "0xP2", Block: 103, LogIndex: 1, Reserve0: "30", Reserve1: "40"},
+		{Pool: "0xP1", Block: 100, LogIndex: 0, Reserve0: "13", Reserve1: "17"}, // Canonical after a reorg, replaces
+	})
+	if err != nil {
+		t.Fatalf("InsertReserveHistory: %v", err)
+	}
+
+	tests := []struct {
+		block uint64
+		want  map[string]string // pool -> reserve0
+	}{
+		{99, map[string]string{}},
+		{100, map[string]string{"0xp1": "13"}},
+		{104, map[string]string{"0xp1": "13", "0xp2": "30"}},
+		{105, map[string]string{"0xp1": "12", "0xp2": "30"}},
+	}
+	for _, tt := range tests {
+		reserves, err := store.GetReservesAtBlock(ctx, []string{"0xP1", "0xp2", "0xp3"}, tt.block)
+		if err != nil {
+			t.Fatalf("GetReservesAtBlock(%d): %v", tt.block, err)
+		}
+		if len(reserves) != len(tt.want) {
+			t.Errorf("Block %d: expected %d pools, got %+v", tt.block, len(tt.want), reserves)
+		}
+		for pool, reserve0 := range tt.want {
+			if got := reserves[pool].Reserve0; got != reserve0 {
+				t.Errorf("Block %d: expected %s reserve0 %s, got %q", tt.block, pool, reserve0, got)
+			}
+		}
+	}
+
+	history, err := store.GetReserveHistory(ctx, "0xP1", 101, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].LogIndex != 3 || history[1].Reserve1 != "18" {
+		t.Errorf("Expected the two updates in block 105, oldest first, got %+v", history)
+	}
+}
+
+func TestInsertReserveHistoryDeletesRemoved(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// A fork's Sync with no canonical counterpart at its position
+	err := store.InsertReserveHistory(ctx, []ReserveRecord{
+		{Pool: "0xP1", Block: 100, LogIndex: 0, Reserve0: "10", Reserve1: "20"},
+		{Pool: "0xP1", Block: 101, LogIndex: 4, Reserve0: "11", Reserve1: "19"},
+		{Pool: "0xP1", Block: 101, LogIndex: 4, Removed: true},
+	})
+	if err != nil {
+		t.Fatalf("InsertReserveHistory: %v", err)
+	}
+
+	reserves, err := store.GetReservesAtBlock(ctx, []string{"0xp1"}, 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reserves["0xp1"]; got.Block != 100 || got.Reserve0 != "10" {
+		t.Errorf("Expected reserves from block 100 after the removal, got %+v", got)
+	}
+}
+
+func TestPruneReserveHistory(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	// One update per block for blocks 0-29: the first ten expired, the next
+	// ten old enough to downsample, the last ten recent
+	var records []ReserveRecord
+	for block := uint64(0); block < 30; block++ {
+		age := 3 * time.Hour
+		if block >= 10 {
+			age = 2 * time.Hour
+		}
+		if block >= 20 {
+			age = time.Minute
+		}
+		records = append(records, ReserveRecord{
+			Pool: "0xp1", Block: block, Reserve0: strconv.FormatUint(block, 10), Reserve1: "1",
+			RecordedAt: now.Add(-age),
+		})
+	}
+	// A dormant pool whose only update has expired
+	records = append(records, ReserveRecord{Pool: "0xp2", Block: 3, Reserve0: "7", Reserve1: "1", RecordedAt: now.Add(-3 * time.Hour)})
+	if err := store.InsertReserveHistory(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := store.PruneReserveHistory(ctx, now.Add(-150*time.Minute), now.Add(-time.Hour), 5)
+	if err != nil {
+		t.Fatalf("PruneReserveHistory: %v", err)
+	}
+	// 10 expired, and blocks 10-19 keep only 14 and 19
+	if deleted != 18 {
+		t.Errorf("Expected 18 rows deleted, got %d", deleted)
+	}
+
+	history, err := store.GetReserveHistory(ctx, "0xp1", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks []uint64
+	for _, r := range history {
+		blocks = append(blocks, r.Block)
+	}
+	if len(blocks) != 12 || blocks[0] != 14 || blocks[1] != 19 || blocks[2] != 20 {
+		t.Errorf("Expected blocks 14, 19 and 20-29 kept, got %v", blocks)
+	}
+
+	// Reserves at the end of a downsampled bucket are unchanged, and the
+	// dormant pool keeps its latest update
+	reserves, err := store.GetReservesAtBlock(ctx, []string{"0xp1", "0xp2"}, 19)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reserves["0xp1"].Reserve0; got != "19" {
+		t.Errorf("Expected reserve0 19 at block 19, got %q", got)
+	}
+	if got := reserves["0xp2"].Reserve0; got != "7" {
+		t.Errorf("Expected the dormant pool's latest reserves kept, got %q", got)
+	}
+}
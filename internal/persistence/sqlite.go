@@ -10,7 +10,6 @@ import (
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/rs/zerolog/log"
 )
 
 // Store provides SQLite-based persistence for operational state.
@@ -32,6 +31,36 @@ type PoolRecord struct {
 	UpdatedAt  time.Time
 }
 
+// TokenSafetyRecord is a token's screening classification.
+type TokenSafetyRecord struct {
+	Address   string
+	Class     string
+	Fee       float64 // Transfer fee as a fraction, for fee-on-transfer tokens
+	Reason    string
+	Flags     string // Comma-separated bytecode heuristic flags
+	CheckedAt time.Time
+}
+
+// PoolActivityRecord is a pool's activity counters over one period of the
+// activity window.
+type PoolActivityRecord struct {
+	Address        string
+	PeriodStart    time.Time
+	Syncs          int
+	Swaps          int
+	Cycles         int
+	SquaredReturns float64 // Sum of squared log price returns
+	LastSwap       time.Time
+}
+
+// CurationEntry is a pool or token on a curation allow or deny list.
+type CurationEntry struct {
+	List    string // allow_pools, deny_pools, allow_tokens or deny_tokens
+	Address string
+	Source  string // "config" for entries from the config file, otherwise "manual"
+	AddedAt time.Time
+}
+
 // TokenRecord represents a token stored in the database.
 type TokenRecord struct {
 	Address   string
@@ -68,52 +97,6 @@ func NewStore(dbPath string) (*Store, error) {
 	return store, nil
 }
 
-// migrate runs database schema migrations.
-func (s *Store) migrate() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS tokens (
-			address TEXT PRIMARY KEY,
-			symbol TEXT NOT NULL,
-			decimals INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS pools (
-			address TEXT PRIMARY KEY,
-			token0 TEXT NOT NULL,
-			token1 TEXT NOT NULL,
-			reserve0 TEXT NOT NULL DEFAULT '0',
-			reserve1 TEXT NOT NULL DEFAULT '0',
-			fee REAL NOT NULL DEFAULT 0.003,
-			is_stable INTEGER NOT NULL DEFAULT 0,
-			tvl REAL NOT NULL DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (token0) REFERENCES tokens(address),
-			FOREIGN KEY (token1) REFERENCES tokens(address)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_pools_tvl ON pools(tvl DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_pools_tokens ON pools(token0, token1)`,
-		`CREATE TABLE IF NOT EXISTS system_state (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS tracked_pools (
-			pool_address TEXT PRIMARY KEY,
-			added_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := s.db.Exec(migration); err != nil {
-			return fmt.Errorf("executing migration: %w", err)
-		}
-	}
-
-	log.Info().Msg("Database migrations completed")
-	return nil
-}
-
 // Close closes the database connection.
 func (s *Store) Close() error {
 	return s.db.Close()
@@ -209,6 +192,34 @@ func (s *Store) BulkUpsertPools(ctx context.Context, pools []PoolRecord) error {
 	return tx.Commit()
 }
 
+// InsertPoolMetadata inserts pools discovered by a factory scan. Pools already
+// stored keep their reserves and TVL.
+func (s *Store) InsertPoolMetadata(ctx context.Context, pools []PoolRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO pools (address, token0, token1, fee, is_stable, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(address) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, pool := range pools {
+		if _, err := stmt.ExecContext(ctx, pool.Address, pool.Token0, pool.Token1,
+			pool.Fee, pool.IsStable, now, now); err != nil {
+			return fmt.Errorf("inserting pool %s: %w", pool.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // GetTopPoolsByTVL retrieves the top N pools ordered by TVL.
 func (s *Store) GetTopPoolsByTVL(ctx context.Context, limit int) ([]PoolRecord, error) {
 	query := `SELECT address, token0, token1, reserve0, reserve1, fee, is_stable, tvl, created_at, updated_at
@@ -374,6 +385,150 @@ func (s *Store) GetSystemState(ctx context.Context, key string) (string, error)
 	return value, err
 }
 
+// UpsertTokenSafety stores a token's screening classification.
+func (s *Store) UpsertTokenSafety(ctx context.Context, record TokenSafetyRecord) error {
+	query := `INSERT INTO token_safety (address, class, fee, reason, flags, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(address) DO UPDATE SET
+			class = excluded.class,
+			fee = excluded.fee,
+			reason = excluded.reason,
+			flags = excluded.flags,
+			checked_at = excluded.checked_at`
+
+	_, err := s.db.ExecContext(ctx, query, record.Address, record.Class, record.Fee,
+		record.Reason, record.Flags, record.CheckedAt)
+	return err
+}
+
+// GetAllTokenSafety retrieves every token's screening classification.
+func (s *Store) GetAllTokenSafety(ctx context.Context) ([]TokenSafetyRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT address, class, fee, reason, flags, checked_at FROM token_safety`)
+	if err != nil {
+		return nil, fmt.Errorf("querying token safety: %w", err)
+	}
+	defer rows.Close()
+
+	var records []TokenSafetyRecord
+	for rows.Next() {
+		var r TokenSafetyRecord
+		if err := rows.Scan(&r.Address, &r.Class, &r.Fee, &r.Reason, &r.Flags, &r.CheckedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// SetPoolActivity replaces the stored pool activity counters.
+func (s *Store) SetPoolActivity(ctx context.Context, records []PoolActivityRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM pool_activity_periods"); err != nil {
+		return fmt.Errorf("clearing pool activity: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO pool_activity_periods
+		(address, period_start, syncs, swaps, cycles, squared_returns, last_swap)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		var lastSwap any
+		if !r.LastSwap.IsZero() {
+			lastSwap = r.LastSwap.UTC()
+		}
+		if _, err := stmt.ExecContext(ctx, r.Address, r.PeriodStart.UTC(), r.Syncs, r.Swaps, r.Cycles,
+			r.SquaredReturns, lastSwap); err != nil {
+			return fmt.Errorf("inserting pool activity %s: %w", r.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAllPoolActivity retrieves every pool's stored activity counters.
+func (s *Store) GetAllPoolActivity(ctx context.Context) ([]PoolActivityRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT address, period_start, syncs, swaps, cycles, squared_returns, last_swap
+		FROM pool_activity_periods`)
+	if err != nil {
+		return nil, fmt.Errorf("querying pool activity: %w", err)
+	}
+	defer rows.Close()
+
+	var records []PoolActivityRecord
+	for rows.Next() {
+		var r PoolActivityRecord
+		var lastSwap sql.NullTime
+		if err := rows.Scan(&r.Address, &r.PeriodStart, &r.Syncs, &r.Swaps, &r.Cycles, &r.SquaredReturns, &lastSwap); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		r.LastSwap = lastSwap.Time
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// ReplaceCurationEntries replaces the curation list entries from source.
+// Entries already stored from another source are kept as they are.
+func (s *Store) ReplaceCurationEntries(ctx context.Context, source string, entries []CurationEntry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM curation_lists WHERE source = ?", source); err != nil {
+		return fmt.Errorf("clearing curation entries: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO curation_lists (list, address, source, added_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(list, address) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, e := range entries {
+		if _, err := stmt.ExecContext(ctx, e.List, e.Address, source, now); err != nil {
+			return fmt.Errorf("inserting curation entry %s %s: %w", e.List, e.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetCurationEntries retrieves every curation list entry.
+func (s *Store) GetCurationEntries(ctx context.Context) ([]CurationEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT list, address, source, added_at FROM curation_lists`)
+	if err != nil {
+		return nil, fmt.Errorf("querying curation entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []CurationEntry
+	for rows.Next() {
+		var e CurationEntry
+		if err := rows.Scan(&e.List, &e.Address, &e.Source, &e.AddedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
 // UpdatePoolReserves updates the reserves for a pool.
 func (s *Store) UpdatePoolReserves(ctx context.Context, address string, reserve0, reserve1 *big.Int) error {
 	query := `UPDATE pools SET reserve0 = ?, reserve1 = ?, updated_at = ? WHERE address = ?`
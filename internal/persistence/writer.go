@@ -0,0 +1,105 @@
+package persistence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BatchWriter stores records in the background, in batches, so the caller
+// never waits on the database. Records arriving while the buffer is full are
+// dropped.
+type BatchWriter[T any] struct {
+	name          string
+	insert        func(ctx context.Context, records []T) error
+	batchSize     int
+	flushInterval time.Duration
+
+	ch chan T
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// NewBatchWriter creates a writer that buffers up to bufferSize records and
+// passes them to insert every flushInterval or every batchSize records,
+// whichever comes first. name identifies the records in logs.
+func NewBatchWriter[T any](name string, insert func(ctx context.Context, records []T) error, bufferSize, batchSize int, flushInterval time.Duration) *BatchWriter[T] {
+	return &BatchWriter[T]{
+		name:          name,
+		insert:        insert,
+		batchSize:     max(batchSize, 1),
+		flushInterval: flushInterval,
+		ch:            make(chan T, bufferSize),
+	}
+}
+
+// Write queues a record without blocking. Returns false if the buffer is full
+// and the record was dropped.
+func (w *BatchWriter[T]) Write(record T) bool {
+	select {
+	case w.ch <- record:
+		return true
+	default:
+		w.mu.Lock()
+		w.dropped++
+		w.mu.Unlock()
+		return false
+	}
+}
+
+// Run stores queued records until ctx is done, then stores what is still
+// buffered. Failed batches are logged and dropped.
+func (w *BatchWriter[T]) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, w.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			for drained := false; !drained; {
+				select {
+				case r := <-w.ch:
+					batch = append(batch, r)
+				default:
+					drained = true
+				}
+			}
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			w.flush(flushCtx, batch)
+			cancel()
+			return ctx.Err()
+		case r := <-w.ch:
+			batch = append(batch, r)
+			if len(batch) >= w.batchSize {
+				w.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *BatchWriter[T]) flush(ctx context.Context, batch []T) {
+	w.mu.Lock()
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+	if dropped > 0 {
+		log.Warn().Str("records", w.name).Int("dropped", dropped).Msg("Write buffer full, records dropped")
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := w.insert(ctx, batch); err != nil {
+		log.Error().Err(err).Str("records", w.name).Int("count", len(batch)).Msg("Failed to store records")
+		return
+	}
+	log.Debug().Str("records", w.name).Int("count", len(batch)).Msg("Stored records")
+}
@@ -0,0 +1,429 @@
+// Package supervisor restarts long-running components independently so a
+// failure in one does not take down the rest of the process.
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"watcher/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// State is a component's circuit-breaker state.
+type State int
+
+const (
+	// StateClosed means the component is running or restarting with backoff.
+	StateClosed State = iota
+	// StateHalfOpen means the component is on a trial run after the breaker opened.
+	StateHalfOpen
+	// StateOpen means the component failed repeatedly and is paused before a trial run.
+	StateOpen
+	// StateStopped means the component exited cleanly and will not be restarted.
+	StateStopped
+)
+
+// String returns the state name.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// permanentError marks an error that restarting cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the supervisor stops the process instead of restarting,
+// e.g. for configuration errors.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true if err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
+
+// Policy controls restart backoff and the circuit breaker.
+type Policy struct {
+	// Restart backoff grows exponentially from InitialBackoff up to MaxBackoff,
+	// randomised by ±Jitter (0.2 = ±20%).
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+
+	// FailureThreshold consecutive failures open the breaker for OpenDuration.
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	// A run lasting StableAfter resets the failure count.
+	StableAfter time.Duration
+}
+
+// DefaultPolicy returns the restart policy used for all components.
+func DefaultPolicy() Policy {
+	return Policy{
+		InitialBackoff:   time.Second,
+		MaxBackoff:       30 * time.Second,
+		Jitter:           0.2,
+		FailureThreshold: 5,
+		OpenDuration:     2 * time.Minute,
+		StableAfter:      time.Minute,
+	}
+}
+
+// backoff returns the jittered delay before restart number attempt (1-based).
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		factor := 1 + p.Jitter*(2*rand.Float64()-1)
+		delay = time.Duration(float64(delay) * factor)
+	}
+	return delay
+}
+
+// ComponentHealth is a point-in-time view of a component.
+type ComponentHealth struct {
+	Name                string    `json:"name"`
+	State               string    `json:"state"`
+	Running             bool      `json:"running"`
+	Restarts            int       `json:"restarts"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitzero"`
+}
+
+// component is a supervised long-running function.
+type component struct {
+	name string
+	run  func(ctx context.Context) error
+
+	mu       sync.Mutex
+	health   ComponentHealth
+	breaker  State
+	failures int
+}
+
+// Supervisor runs components and restarts them when they fail.
+type Supervisor struct {
+	policy  Policy
+	metrics *metrics.Metrics
+
+	mu         sync.Mutex
+	components []*component
+}
+
+// New creates a supervisor with the given restart policy.
+func New(policy Policy, m *metrics.Metrics) *Supervisor {
+	return &Supervisor{
+		policy:  policy,
+		metrics: m,
+	}
+}
+
+// Add registers a component. Run returning nil means the component finished and
+// is not restarted; returning a Permanent error stops the supervisor.
+func (s *Supervisor) Add(name string, run func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.components = append(s.components, &component{
+		name:   name,
+		run:    run,
+		health: ComponentHealth{Name: name, State: StateClosed.String()},
+	})
+}
+
+// Run runs all components until ctx is canceled, one fails permanently, or all
+// of them have finished.
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	components := s.components
+	s.mu.Unlock()
+
+	errCh := make(chan error, len(components))
+	var wg sync.WaitGroup
+	for _, c := range components {
+		wg.Add(1)
+		go func(c *component) {
+			defer wg.Done()
+			if err := s.supervise(ctx, c); err != nil {
+				errCh <- err
+			}
+		}(c)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-errCh:
+		log.Error().Err(err).Msg("Component failed permanently, shutting down")
+	case <-finished:
+		// Every component exited cleanly; a permanent failure may have raced the last exit
+		select {
+		case err = <-errCh:
+		default:
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// supervise runs a single component, restarting it according to the policy.
+// Returns a non-nil error only for permanent failures.
+func (s *Supervisor) supervise(ctx context.Context, c *component) error {
+	for {
+		c.setRunning(true)
+		s.recordState(c)
+
+		// A trial run that lasts StableAfter closes the breaker
+		var stable *time.Timer
+		if c.state() == StateHalfOpen {
+			stable = time.AfterFunc(s.policy.StableAfter, func() {
+				if c.closeBreaker() {
+					log.Info().Str("component", c.name).Msg("Component recovered, breaker closed")
+					s.recordState(c)
+				}
+			})
+		}
+
+		startedAt := time.Now()
+		err := s.runOnce(ctx, c)
+		ranFor := time.Since(startedAt)
+
+		if stable != nil {
+			stable.Stop()
+		}
+		c.setRunning(false)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err == nil {
+			log.Info().Str("component", c.name).Msg("Component finished")
+			c.setState(StateStopped)
+			s.recordState(c)
+			return nil
+		}
+
+		if IsPermanent(err) {
+			c.recordPermanentFailure(err)
+			s.recordState(c)
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+
+		delay := s.nextDelay(c, err, ranFor)
+		if s.metrics != nil {
+			s.metrics.RecordComponentRestart(c.name)
+		}
+
+		health := c.snapshot()
+		log.Error().
+			Err(err).
+			Str("component", c.name).
+			Str("state", health.State).
+			Int("consecutive_failures", health.ConsecutiveFailures).
+			Dur("restart_in", delay).
+			Msg("Component failed, scheduling restart")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		// A trial run after the breaker opened
+		if c.state() == StateOpen {
+			c.setState(StateHalfOpen)
+		}
+	}
+}
+
+// runOnce runs the component with its own context, converting panics to errors.
+// The context is canceled when the run ends so goroutines it started stop too.
+func (s *Supervisor) runOnce(ctx context.Context, c *component) (err error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return c.run(runCtx)
+}
+
+// nextDelay records a failure, updates the breaker and returns the restart delay.
+func (s *Supervisor) nextDelay(c *component, err error, ranFor time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A long healthy run means this is a fresh failure, not a crash loop,
+	// even if the run was a trial
+	if ranFor >= s.policy.StableAfter {
+		c.failures = 0
+		c.breaker = StateClosed
+	}
+	c.failures++
+
+	c.health.Restarts++
+	c.health.ConsecutiveFailures = c.failures
+	c.health.LastError = err.Error()
+	c.health.LastFailure = time.Now()
+
+	// A failed trial, or too many failures in a row, opens the breaker
+	if c.breaker == StateHalfOpen || c.failures >= s.policy.FailureThreshold {
+		c.breaker = StateOpen
+		c.health.State = StateOpen.String()
+		return s.policy.OpenDuration
+	}
+
+	c.breaker = StateClosed
+	c.health.State = StateClosed.String()
+	return s.policy.backoff(c.failures)
+}
+
+// Health returns the health of every component.
+func (s *Supervisor) Health() []ComponentHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	health := make([]ComponentHealth, len(s.components))
+	for i, c := range s.components {
+		health[i] = c.snapshot()
+	}
+	return health
+}
+
+// Healthy returns true if no component is failing.
+// Components in the closed state are healthy once they are running again.
+func (s *Supervisor) Healthy() bool {
+	for _, h := range s.Health() {
+		if h.State == StateOpen.String() || h.State == StateHalfOpen.String() {
+			return false
+		}
+		if h.State == StateClosed.String() && !h.Running {
+			return false
+		}
+	}
+	return true
+}
+
+// HealthReport returns overall health and a JSON body describing each component.
+func (s *Supervisor) HealthReport() (bool, []byte) {
+	healthy := s.Healthy()
+	body, err := json.Marshal(struct {
+		Healthy    bool              `json:"healthy"`
+		Components []ComponentHealth `json:"components"`
+	}{
+		Healthy:    healthy,
+		Components: s.Health(),
+	})
+	if err != nil {
+		return false, []byte(err.Error())
+	}
+	return healthy, body
+}
+
+// recordState publishes a component's state to metrics.
+func (s *Supervisor) recordState(c *component) {
+	if s.metrics == nil {
+		return
+	}
+	health := c.snapshot()
+	s.metrics.SetComponentHealth(c.name, int(c.state()), health.Running)
+}
+
+func (c *component) snapshot() ComponentHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.health
+}
+
+func (c *component) state() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.breaker
+}
+
+func (c *component) setState(state State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breaker = state
+	c.health.State = state.String()
+}
+
+// closeBreaker closes a half-open breaker and resets the failure count.
+// Returns false if the breaker was not half-open.
+func (c *component) closeBreaker() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.breaker != StateHalfOpen {
+		return false
+	}
+	c.breaker = StateClosed
+	c.failures = 0
+	c.health.State = StateClosed.String()
+	c.health.ConsecutiveFailures = 0
+	return true
+}
+
+func (c *component) setRunning(running bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.health.Running = running
+}
+
+func (c *component) recordPermanentFailure(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breaker = StateStopped
+	c.health.State = StateStopped.String()
+	c.health.LastError = err.Error()
+	c.health.LastFailure = time.Now()
+}
@@ -0,0 +1,243 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testPolicy() Policy {
+	return Policy{
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		FailureThreshold: 3,
+		OpenDuration:     20 * time.Millisecond,
+		StableAfter:      time.Hour,
+	}
+}
+
+func TestSupervisorRestartsFailedComponent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s := New(testPolicy(), nil)
+
+	var runs atomic.Int32
+	s.Add("flaky", func(ctx context.Context) error {
+		if runs.Add(1) < 3 {
+			return errors.New("connection lost")
+		}
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := s.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if runs.Load() != 3 {
+		t.Errorf("Expected 3 runs, got %d", runs.Load())
+	}
+
+	health := s.Health()[0]
+	if health.Restarts != 2 {
+		t.Errorf("Expected 2 restarts, got %d", health.Restarts)
+	}
+	if health.LastError != "connection lost" {
+		t.Errorf("Expected last error to be recorded, got %q", health.LastError)
+	}
+}
+
+func TestSupervisorStopsOnPermanentError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s := New(testPolicy(), nil)
+
+	var otherStopped atomic.Bool
+	s.Add("misconfigured", func(ctx context.Context) error {
+		return Permanent(errors.New("no websocket providers configured"))
+	})
+	s.Add("other", func(ctx context.Context) error {
+		<-ctx.Done()
+		otherStopped.Store(true)
+		return ctx.Err()
+	})
+
+	err := s.Run(ctx)
+	if !IsPermanent(err) {
+		t.Fatalf("Expected a permanent error, got %v", err)
+	}
+	if !otherStopped.Load() {
+		t.Error("Expected other components to be stopped")
+	}
+	if s.Health()[0].State != StateStopped.String() {
+		t.Errorf("Expected state stopped, got %s", s.Health()[0].State)
+	}
+}
+
+func TestSupervisorOpensBreakerAfterRepeatedFailures(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s := New(testPolicy(), nil)
+
+	var runs atomic.Int32
+	s.Add("broken", func(ctx context.Context) error {
+		runs.Add(1)
+		return errors.New("still broken")
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	// Three failures open the breaker; the component waits out OpenDuration
+	deadline := time.After(time.Second)
+	for s.Health()[0].State != StateOpen.String() {
+		select {
+		case <-deadline:
+			t.Fatal("Timed out waiting for the breaker to open")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if runs.Load() != 3 {
+		t.Errorf("Expected 3 runs before the breaker opened, got %d", runs.Load())
+	}
+	if s.Healthy() {
+		t.Error("Expected supervisor to report unhealthy with an open breaker")
+	}
+
+	// After the open period a single trial run fails and the breaker reopens
+	for runs.Load() < 4 {
+		select {
+		case <-deadline:
+			t.Fatal("Timed out waiting for the trial run")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if runs.Load() != 4 {
+		t.Errorf("Expected one trial run per open period, got %d runs", runs.Load())
+	}
+	if s.Health()[0].State != StateOpen.String() {
+		t.Errorf("Expected the failed trial to reopen the breaker, got %s", s.Health()[0].State)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSupervisorClosesBreakerAfterStableTrial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	policy := testPolicy()
+	policy.StableAfter = 30 * time.Millisecond
+	s := New(policy, nil)
+
+	var runs atomic.Int32
+	fail := make(chan struct{})
+	s.Add("recovering", func(ctx context.Context) error {
+		run := runs.Add(1)
+		if run <= 3 {
+			return errors.New("still broken")
+		}
+		if run > 4 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		select {
+		case <-fail:
+			return errors.New("connection lost")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	waitForState := func(what string, cond func(ComponentHealth) bool) {
+		t.Helper()
+		deadline := time.After(time.Second)
+		for !cond(s.Health()[0]) {
+			select {
+			case <-deadline:
+				t.Fatalf("Timed out waiting for %s, got %+v", what, s.Health()[0])
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+
+	// The trial run outlasts StableAfter and closes the breaker
+	waitForState("the trial run", func(h ComponentHealth) bool { return runs.Load() == 4 && h.Running })
+	waitForState("the breaker to close", func(h ComponentHealth) bool { return h.State == StateClosed.String() })
+	if !s.Healthy() {
+		t.Errorf("Expected supervisor to report healthy after a stable trial, got %+v", s.Health()[0])
+	}
+
+	// The next failure restarts with backoff instead of reopening the breaker
+	close(fail)
+	waitForState("the restart", func(h ComponentHealth) bool { return runs.Load() == 5 && h.Running })
+	if health := s.Health()[0]; health.State != StateClosed.String() || health.ConsecutiveFailures != 1 {
+		t.Errorf("Expected a closed breaker with one failure, got %+v", health)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSupervisorRecoversPanics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s := New(testPolicy(), nil)
+
+	var runs atomic.Int32
+	s.Add("panicky", func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			panic("nil map")
+		}
+		return nil
+	})
+
+	// The only component finishes cleanly, so Run returns
+	if err := s.Run(ctx); err != nil {
+		t.Errorf("Expected nil once every component finished, got %v", err)
+	}
+	if runs.Load() != 2 {
+		t.Errorf("Expected a restart after the panic, got %d runs", runs.Load())
+	}
+
+	// A clean exit is not restarted
+	health := s.Health()[0]
+	if health.State != StateStopped.String() {
+		t.Errorf("Expected state stopped after clean exit, got %s", health.State)
+	}
+	if health.LastError != "panic: nil map" {
+		t.Errorf("Expected panic to be recorded, got %q", health.LastError)
+	}
+}
+
+func TestPolicyBackoffIsCapped(t *testing.T) {
+	p := Policy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	if d := p.backoff(1); d != time.Second {
+		t.Errorf("Expected 1s for the first restart, got %v", d)
+	}
+	if d := p.backoff(3); d != 4*time.Second {
+		t.Errorf("Expected 4s for the third restart, got %v", d)
+	}
+	if d := p.backoff(20); d != 10*time.Second {
+		t.Errorf("Expected backoff capped at 10s, got %v", d)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("Expected jittered backoff within ±50%%, got %v", d)
+		}
+	}
+}
@@ -4,17 +4,33 @@ import (
 	"context"
 	"fmt"
 	"math/big"
-	"time"
+	"sync"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Latest reads the state at the latest block.
+var Latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+// Default request budget, shared by every caller of a client.
+const (
+	DefaultRateLimit = 10 // Requests per second
+	DefaultBurst     = 10
 )
 
 type Client struct {
-	ethClient   *ethclient.Client
-	rateLimiter *time.Ticker
+	ethClient *ethclient.Client
+	limiter   *tokenBucket
+
+	// Largest multicall the provider is known to accept, 0 if unbounded
+	multicallMu      sync.Mutex
+	multicallLimit   int
+	multicallCeiling int // Smallest multicall the provider rejected
+	multicallHook    func(limit int)
 }
 
 func NewClient(rpcURL string) (*Client, error) {
@@ -24,29 +40,43 @@ func NewClient(rpcURL string) (*Client, error) {
 	}
 
 	return &Client{
-		ethClient:   client,
-		rateLimiter: time.NewTicker(100 * time.Millisecond), // 10 requests per second
+		ethClient: client,
+		limiter:   newTokenBucket(DefaultRateLimit, DefaultBurst),
 	}, nil
 }
 
+// SetRateLimit sets the request budget: rate requests per second on average,
+// with bursts of up to burst requests. Every request, including each multicall
+// and each retry, takes from the same budget.
+func (c *Client) SetRateLimit(rate float64, burst int) {
+	c.limiter = newTokenBucket(rate, burst)
+}
+
 func (c *Client) Close() {
 	c.ethClient.Close()
-	c.rateLimiter.Stop()
 }
 
-func (c *Client) rateLimit() {
-	<-c.rateLimiter.C
+// rateLimit waits for the request budget to allow another request.
+func (c *Client) rateLimit(ctx context.Context) error {
+	return c.limiter.wait(ctx)
 }
 
 func (c *Client) CallContract(ctx context.Context, to common.Address, data []byte) ([]byte, error) {
-	c.rateLimit()
+	return c.CallContractAt(ctx, to, data, Latest)
+}
+
+// CallContractAt executes a call against the state at block, given by number or hash.
+func (c *Client) CallContractAt(ctx context.Context, to common.Address, data []byte, block rpc.BlockNumberOrHash) ([]byte, error) {
+	if err := c.rateLimit(ctx); err != nil {
+		return nil, err
+	}
 
 	msg := ethereum.CallMsg{
 		To:   &to,
 		Data: data,
 	}
 
-	result, err := c.ethClient.CallContract(ctx, msg, nil)
+	result, err := c.callAt(ctx, msg, block)
 	if err != nil {
 		return nil, fmt.Errorf("contract call failed: %w", err)
 	}
@@ -54,18 +84,42 @@ func (c *Client) CallContract(ctx context.Context, to common.Address, data []byt
 	return result, nil
 }
 
+// callAt sends eth_call at block. A hash takes precedence over a number.
+func (c *Client) callAt(ctx context.Context, msg ethereum.CallMsg, block rpc.BlockNumberOrHash) ([]byte, error) {
+	if hash, ok := block.Hash(); ok {
+		return c.ethClient.CallContractAtHash(ctx, msg, hash)
+	}
+	number, ok := block.Number()
+	if !ok {
+		number = rpc.LatestBlockNumber
+	}
+	return c.ethClient.CallContract(ctx, msg, big.NewInt(number.Int64()))
+}
+
 func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
 	return c.ethClient.ChainID(ctx)
 }
 
 // BlockNumber returns the current block number.
 func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
-	c.rateLimit()
+	if err := c.rateLimit(ctx); err != nil {
+		return 0, err
+	}
 	return c.ethClient.BlockNumber(ctx)
 }
 
 // FilterLogs retrieves logs matching the given filter query.
 func (c *Client) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
-	c.rateLimit()
+	if err := c.rateLimit(ctx); err != nil {
+		return nil, err
+	}
 	return c.ethClient.FilterLogs(ctx, query)
 }
+
+// HeaderByNumber returns the block header for the given number, or the latest header if number is nil.
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := c.rateLimit(ctx); err != nil {
+		return nil, err
+	}
+	return c.ethClient.HeaderByNumber(ctx, number)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
 )
 
 // Multicall3 contract address (same on all EVM chains)
@@ -70,10 +72,122 @@ type CallResult struct {
 
 // BatchCallContract executes multiple contract calls in a single RPC request using Multicall3
 func (c *Client) BatchCallContract(ctx context.Context, calls []ContractCall) ([]CallResult, error) {
+	return c.BatchCallContractAt(ctx, calls, Latest)
+}
+
+// BatchCallContractAt executes multiple contract calls in a single RPC request
+// using Multicall3, against the state at block, given by number or hash.
+// Calls beyond the multicall limit are sent in several requests.
+func (c *Client) BatchCallContractAt(ctx context.Context, calls []ContractCall, block rpc.BlockNumberOrHash) ([]CallResult, error) {
 	if len(calls) == 0 {
 		return nil, nil
 	}
 
+	results := make([]CallResult, 0, len(calls))
+	for len(calls) > 0 {
+		n := len(calls)
+		if limit := c.MulticallLimit(); limit > 0 && n > limit {
+			n = limit
+		}
+
+		chunk, err := c.multicall(ctx, calls[:n], block)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunk...)
+		calls = calls[n:]
+	}
+
+	return results, nil
+}
+
+// MulticallLimit returns the largest number of calls sent in one multicall,
+// or 0 if the provider hasn't rejected a multicall as too large.
+func (c *Client) MulticallLimit() int {
+	c.multicallMu.Lock()
+	defer c.multicallMu.Unlock()
+	return c.multicallLimit
+}
+
+// SetMulticallHook sets a function called with the new limit whenever the
+// multicall limit changes.
+func (c *Client) SetMulticallHook(fn func(limit int)) {
+	c.multicallMu.Lock()
+	defer c.multicallMu.Unlock()
+	c.multicallHook = fn
+}
+
+// multicall sends calls in one multicall. If the provider rejects it as too
+// large, the multicall limit is lowered and the calls are sent in two halves.
+func (c *Client) multicall(ctx context.Context, calls []ContractCall, block rpc.BlockNumberOrHash) ([]CallResult, error) {
+	results, err := c.aggregate3(ctx, calls, block)
+	if err == nil {
+		c.growMulticallLimit(len(calls))
+		return results, nil
+	}
+	if len(calls) == 1 || !isBatchLimitError(err.Error()) {
+		return nil, err
+	}
+
+	c.shrinkMulticallLimit(len(calls), err)
+	half := len(calls) / 2
+	first, err := c.multicall(ctx, calls[:half], block)
+	if err != nil {
+		return nil, err
+	}
+	second, err := c.multicall(ctx, calls[half:], block)
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+// shrinkMulticallLimit halves the limit after the provider rejected a
+// multicall of size calls.
+func (c *Client) shrinkMulticallLimit(size int, cause error) {
+	c.multicallMu.Lock()
+	if c.multicallCeiling == 0 || size < c.multicallCeiling {
+		c.multicallCeiling = size
+	}
+	limit := max(size/2, 1)
+	if c.multicallLimit != 0 && c.multicallLimit <= limit {
+		c.multicallMu.Unlock()
+		return
+	}
+	c.multicallLimit = limit
+	hook := c.multicallHook
+	c.multicallMu.Unlock()
+
+	log.Warn().Err(cause).Int("rejected", size).Int("limit", limit).Msg("Provider rejected multicall size, lowering limit")
+	if hook != nil {
+		hook(limit)
+	}
+}
+
+// growMulticallLimit raises the limit by 10% after a multicall at the limit
+// succeeded, staying below the smallest size the provider rejected.
+func (c *Client) growMulticallLimit(size int) {
+	c.multicallMu.Lock()
+	if c.multicallLimit == 0 || size < c.multicallLimit {
+		c.multicallMu.Unlock()
+		return
+	}
+	limit := min(c.multicallLimit+max(c.multicallLimit/10, 1), c.multicallCeiling-1)
+	if limit <= c.multicallLimit {
+		c.multicallMu.Unlock()
+		return
+	}
+	c.multicallLimit = limit
+	hook := c.multicallHook
+	c.multicallMu.Unlock()
+
+	if hook != nil {
+		hook(limit)
+	}
+}
+
+// aggregate3 sends calls in one Multicall3 aggregate3 request, retrying transient errors.
+func (c *Client) aggregate3(ctx context.Context, calls []ContractCall, block rpc.BlockNumberOrHash) ([]CallResult, error) {
 	// Build the Call3 structs for aggregate3
 	type Call3 struct {
 		Target       common.Address
@@ -99,12 +213,15 @@ func (c *Client) BatchCallContract(ctx context.Context, calls []ContractCall) ([
 	// Execute the multicall with retry logic
 	var result []byte
 	err = c.retryCallWithContext(ctx, func() error {
+		if err := c.rateLimit(ctx); err != nil {
+			return err
+		}
 		var callErr error
 		msg := ethereum.CallMsg{
 			To:   &Multicall3Address,
 			Data: data,
 		}
-		result, callErr = c.ethClient.CallContract(ctx, msg, nil)
+		result, callErr = c.callAt(ctx, msg, block)
 		return callErr
 	}, 3)
 	if err != nil {
@@ -122,6 +239,9 @@ func (c *Client) BatchCallContract(ctx context.Context, calls []ContractCall) ([
 	if err != nil {
 		return nil, fmt.Errorf("failed to unpack aggregate3 result: %w", err)
 	}
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("multicall returned %d results for %d calls", len(results), len(calls))
+	}
 
 	// Convert to CallResult
 	callResults := make([]CallResult, len(results))
@@ -172,6 +292,30 @@ func (c *Client) retryCallWithContext(ctx context.Context, fn func() error, maxR
 	return lastErr
 }
 
+// isBatchLimitError checks if an error means the request was too large for the
+// provider, so a smaller multicall may succeed
+func isBatchLimitError(errStr string) bool {
+	if isTransientError(errStr) {
+		return false
+	}
+	limitPatterns := []string{
+		"out of gas",
+		"gas limit",
+		"too large",
+		"too big",
+		"response size",
+		"size limit",
+		"413",
+	}
+	errLower := strings.ToLower(errStr)
+	for _, pattern := range limitPatterns {
+		if strings.Contains(errLower, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
 // isTransientError checks if an error is likely transient and worth retrying
 func isTransientError(errStr string) bool {
 	transientPatterns := []string{
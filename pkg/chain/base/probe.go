@@ -0,0 +1,167 @@
+package base
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+)
+
+// ProbeCode is the runtime bytecode of a call-sequence probe. Installed at an
+// address with a state override, it executes a program of calls in order as
+// that address and returns one ProbeResult per call. Calls without the probe
+// magic prefix (for example callbacks from a token) stop without effect.
+//
+// Program: magic (4 bytes), then per call: target (20), data length (2), data.
+// Result per call: success word, return data size word, first return data word.
+//
+// Assembly:
+//
+//	if calldatasize < 4 || calldata[0:4] != magic { stop }
+//	ptr, out := 4, 0
+//	while ptr < calldatasize {
+//	    target, len := calldata[ptr:ptr+20], calldata[ptr+20:ptr+22]
+//	    mem[0x8000:] = calldata[ptr+22:ptr+22+len]
+//	    mem[out:] = call(gas, target, 0, 0x8000, len, 0, 0)
+//	    mem[out+32:] = returndatasize
+//	    mem[out+64:] = returndata[0:min(returndatasize, 32)]
+//	    ptr, out = ptr+22+len, out+96
+//	}
+//	return mem[0:out]
+var ProbeCode = common.FromHex(
+	"600436106100865760003560e01c6370726f62141561008657600460005b3682101561008257813560601c" +
+		"826014013560f01c80846016016180003760006000826180006000865af183523d83602001526000836040" +
+		"01523d8060201061006557610069565b5060205b6000846040013e9290508201601601915060600161001d" +
+		"565b6000f35b00",
+)
+
+// probeMagic prefixes every probe program.
+var probeMagic = []byte("prob")
+
+const (
+	probeHeaderSize = 22 // target + data length
+	probeResultSize = 96
+)
+
+// ProbeResult is the outcome of one call in a probe program.
+type ProbeResult struct {
+	Success    bool
+	ReturnSize uint64
+	Word       *big.Int // First 32 bytes of return data, zero padded
+}
+
+// EncodeProbeProgram encodes calls as a probe program.
+func EncodeProbeProgram(calls []ContractCall) ([]byte, error) {
+	program := append([]byte{}, probeMagic...)
+	for i, call := range calls {
+		if len(call.CallData) > 0xffff {
+			return nil, fmt.Errorf("call %d data too long: %d bytes", i, len(call.CallData))
+		}
+		program = append(program, call.Target.Bytes()...)
+		program = binary.BigEndian.AppendUint16(program, uint16(len(call.CallData)))
+		program = append(program, call.CallData...)
+	}
+	return program, nil
+}
+
+// DecodeProbeProgram decodes a probe program. Returns false if input is not one.
+func DecodeProbeProgram(input []byte) ([]ContractCall, bool) {
+	if len(input) < len(probeMagic) || string(input[:len(probeMagic)]) != string(probeMagic) {
+		return nil, false
+	}
+
+	var calls []ContractCall
+	for ptr := len(probeMagic); ptr < len(input); {
+		if ptr+probeHeaderSize > len(input) {
+			return nil, false
+		}
+		target := common.BytesToAddress(input[ptr : ptr+20])
+		size := int(binary.BigEndian.Uint16(input[ptr+20 : ptr+probeHeaderSize]))
+		ptr += probeHeaderSize
+		if ptr+size > len(input) {
+			return nil, false
+		}
+		calls = append(calls, ContractCall{Target: target, CallData: input[ptr : ptr+size]})
+		ptr += size
+	}
+	return calls, true
+}
+
+// EncodeProbeResults encodes results the way the probe returns them.
+func EncodeProbeResults(results []ProbeResult) []byte {
+	out := make([]byte, 0, len(results)*probeResultSize)
+	for _, r := range results {
+		var success, size, word [32]byte
+		if r.Success {
+			success[31] = 1
+		}
+		new(big.Int).SetUint64(r.ReturnSize).FillBytes(size[:])
+		if r.Word != nil {
+			r.Word.FillBytes(word[:])
+		}
+		out = append(out, success[:]...)
+		out = append(out, size[:]...)
+		out = append(out, word[:]...)
+	}
+	return out
+}
+
+// DecodeProbeResults decodes the output of a probe.
+func DecodeProbeResults(out []byte) ([]ProbeResult, error) {
+	if len(out)%probeResultSize != 0 {
+		return nil, fmt.Errorf("probe output length %d is not a multiple of %d", len(out), probeResultSize)
+	}
+
+	results := make([]ProbeResult, len(out)/probeResultSize)
+	for i := range results {
+		chunk := out[i*probeResultSize : (i+1)*probeResultSize]
+		results[i] = ProbeResult{
+			Success:    new(big.Int).SetBytes(chunk[:32]).Sign() != 0,
+			ReturnSize: new(big.Int).SetBytes(chunk[32:64]).Uint64(),
+			Word:       new(big.Int).SetBytes(chunk[64:96]),
+		}
+	}
+	return results, nil
+}
+
+// RunProbe installs the probe at address and executes calls as that address.
+// The probe is also installed at every helper, so calls can run nested programs
+// there. Addresses keep their balances and storage; only their code is replaced.
+func (c *Client) RunProbe(ctx context.Context, address common.Address, calls []ContractCall, helpers ...common.Address) ([]ProbeResult, error) {
+	program, err := EncodeProbeProgram(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.rateLimit(ctx); err != nil {
+		return nil, err
+	}
+
+	overrides := map[common.Address]gethclient.OverrideAccount{
+		address: {Code: ProbeCode},
+	}
+	for _, helper := range helpers {
+		overrides[helper] = gethclient.OverrideAccount{Code: ProbeCode}
+	}
+	out, err := gethclient.New(c.ethClient.Client()).CallContract(ctx, ethereum.CallMsg{
+		To:   &address,
+		Data: program,
+	}, nil, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("probe call failed: %w", err)
+	}
+
+	return DecodeProbeResults(out)
+}
+
+// CodeAt returns the contract code at an address.
+func (c *Client) CodeAt(ctx context.Context, address common.Address) ([]byte, error) {
+	if err := c.rateLimit(ctx); err != nil {
+		return nil, err
+	}
+	return c.ethClient.CodeAt(ctx, address, nil)
+}
@@ -0,0 +1,104 @@
+package base
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+)
+
+var (
+	probeAddress  = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	helperAddress = common.HexToAddress("0x00000000000000000000000000000000000000a2")
+	answerAddress = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	revertAddress = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+)
+
+func newProbeConfig(t *testing.T) *runtime.Config {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatalf("Creating state: %v", err)
+	}
+
+	statedb.SetCode(probeAddress, ProbeCode)
+	statedb.SetCode(helperAddress, ProbeCode)
+	statedb.SetCode(answerAddress, common.FromHex("602a60005260206000f3")) // return 42
+	statedb.SetCode(revertAddress, common.FromHex("60006000fd"))           // revert
+
+	return &runtime.Config{State: statedb, GasLimit: 10_000_000}
+}
+
+func TestProbeExecutesProgram(t *testing.T) {
+	cfg := newProbeConfig(t)
+
+	nested, err := EncodeProbeProgram([]ContractCall{{Target: answerAddress}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := EncodeProbeProgram([]ContractCall{
+		{Target: answerAddress, CallData: []byte{1, 2, 3}},
+		{Target: revertAddress},
+		{Target: helperAddress, CallData: nested},
+		{Target: helperAddress, CallData: []byte("not a program")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := runtime.Call(probeAddress, program, cfg)
+	if err != nil {
+		t.Fatalf("Probe call: %v", err)
+	}
+	results, err := DecodeProbeResults(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	if !results[0].Success || results[0].ReturnSize != 32 || results[0].Word.Int64() != 42 {
+		t.Errorf("Expected 42 from the first call, got %+v", results[0])
+	}
+	if results[1].Success {
+		t.Errorf("Expected the reverting call to fail, got %+v", results[1])
+	}
+	// The nested program returns one result; its first word is the inner success
+	if !results[2].Success || results[2].ReturnSize != probeResultSize || results[2].Word.Int64() != 1 {
+		t.Errorf("Expected the nested program to succeed, got %+v", results[2])
+	}
+	if !results[3].Success || results[3].ReturnSize != 0 {
+		t.Errorf("Expected a call without the magic to stop, got %+v", results[3])
+	}
+}
+
+func TestProbeProgramRoundTrip(t *testing.T) {
+	calls := []ContractCall{
+		{Target: answerAddress, CallData: []byte{0xa9, 0x05, 0x9c, 0xbb}},
+		{Target: revertAddress},
+	}
+	program, err := EncodeProbeProgram(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, ok := DecodeProbeProgram(program)
+	if !ok || len(decoded) != 2 {
+		t.Fatalf("Expected 2 decoded calls, got %d (%v)", len(decoded), ok)
+	}
+	if decoded[0].Target != answerAddress || string(decoded[0].CallData) != string(calls[0].CallData) {
+		t.Errorf("Expected first call to round trip, got %+v", decoded[0])
+	}
+	if _, ok := DecodeProbeProgram([]byte{0xa9, 0x05, 0x9c, 0xbb}); ok {
+		t.Error("Expected input without the magic to be rejected")
+	}
+
+	results := []ProbeResult{{Success: true, ReturnSize: 32, Word: big.NewInt(7)}, {Word: new(big.Int)}}
+	roundTrip, err := DecodeProbeResults(EncodeProbeResults(results))
+	if err != nil || len(roundTrip) != 2 || !roundTrip[0].Success || roundTrip[0].Word.Int64() != 7 || roundTrip[1].Success {
+		t.Errorf("Expected results to round trip, got %+v (%v)", roundTrip, err)
+	}
+}
@@ -0,0 +1,45 @@
+package base
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketSharesBudget(t *testing.T) {
+	bucket := newTokenBucket(100, 5)
+	ctx := context.Background()
+
+	// Concurrent callers draw from one budget: the burst is immediate, the
+	// other five wait for refills at 100 per second
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bucket.wait(ctx); err != nil {
+				t.Errorf("wait: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected about 50ms for 10 requests, took %v", elapsed)
+	}
+}
+
+func TestTokenBucketHonoursCancellation(t *testing.T) {
+	bucket := newTokenBucket(0.1, 1)
+	if err := bucket.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bucket.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to end the wait, got %v", err)
+	}
+}
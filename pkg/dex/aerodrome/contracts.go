@@ -88,6 +88,23 @@ const ERC20ABIJSON = `[
 		"outputs": [{"internalType": "string", "name": "", "type": "string"}],
 		"stateMutability": "view",
 		"type": "function"
+	},
+	{
+		"inputs": [{"internalType": "address", "name": "account", "type": "address"}],
+		"name": "balanceOf",
+		"outputs": [{"internalType": "uint256", "name": "", "type": "uint256"}],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [
+			{"internalType": "address", "name": "to", "type": "address"},
+			{"internalType": "uint256", "name": "amount", "type": "uint256"}
+		],
+		"name": "transfer",
+		"outputs": [{"internalType": "bool", "name": "", "type": "bool"}],
+		"stateMutability": "nonpayable",
+		"type": "function"
 	}
 ]`
 